@@ -4,11 +4,29 @@ import (
 	"flag"
 	"fmt"
 	"os"
+	"time"
 
 	"github.com/therecipe/qt/widgets"
+	"go-gnome-editor/internal/logic"
 	"go-gnome-editor/internal/ui"
 )
 
+// taskFlagValues — флаги командной строки одного вида запросов к LLM
+type taskFlagValues struct {
+	provider string
+	model    string
+	timeout  time.Duration
+}
+
+func (tf *taskFlagValues) taskModel() logic.TaskModel {
+	m := logic.TaskModel{Provider: tf.provider, Model: tf.model}
+	if tf.timeout > 0 {
+		// Таймаут меньше секунды округляем вверх, чтобы не получить "без таймаута"
+		m.TimeoutSec = int((tf.timeout + time.Second - 1) / time.Second)
+	}
+	return m
+}
+
 func main() {
 	const appVersion = "0.9.1"
 	// Объявляем переменные для флагов
@@ -21,9 +39,25 @@ func main() {
 	)
 
 	// Привязываем длинные и короткие флаги к одним и тем же переменным
-	flag.StringVar(&provider, "provider", "ollama", "LLM Provider (ollama, openrouter, pollinations, URL provider)")
-	flag.StringVar(&model, "model", "gemma:2b", "LLM Model name")
-	flag.StringVar(&apiKey, "key", "", "API Key (if required)")
+	flag.StringVar(&provider, "provider", "ollama", "LLM Provider (ollama, openrouter, pollinations, anthropic, gemini, openai, profile name, URL provider); overrides config file")
+	flag.StringVar(&model, "model", "gemma:2b", "LLM Model name; overrides config file")
+	flag.StringVar(&apiKey, "key", "", "API Key (prefer environment variables or AI > Manage API Keys)")
+
+	// Отдельные провайдер/модель/таймаут для видов запросов: --line-model, --comment-provider, --chat-timeout, ...
+	taskFlags := make(map[logic.AITask]*taskFlagValues)
+	for _, task := range logic.AITasks {
+		tf := &taskFlagValues{}
+		taskFlags[task] = tf
+		if task != logic.TaskChat {
+			flag.StringVar(&tf.provider, string(task)+"-provider", "", task.Title()+": LLM provider (default: same as chat)")
+			modelHelp := ": model name (default: same as chat)"
+			if task == logic.TaskEmbed {
+				modelHelp = ": embedding model (default: nomic-embed-text for ollama, text-embedding-3-small for openai)"
+			}
+			flag.StringVar(&tf.model, string(task)+"-model", "", task.Title()+modelHelp)
+		}
+		flag.DurationVar(&tf.timeout, string(task)+"-timeout", 0, fmt.Sprintf("%s: request timeout, e.g. 20s (default %s)", task.Title(), task.DefaultTimeout()))
+	}
 
 	// Help: поддерживаем и -h, и --help
 	flag.BoolVar(&showHelp, "help", false, "Show help")
@@ -51,6 +85,35 @@ func main() {
 		os.Exit(0)
 	}
 
+	// Настройки из ~/.config/go-lite-ide/config.json (ошибка не фатальна — работаем с настройками по умолчанию)
+	cfg, err := logic.LoadConfig()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	}
+
+	// Явно указанные флаги имеют приоритет над файлами настроек
+	var overrides logic.ConfigOverrides
+	flag.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "provider":
+			overrides.Provider = provider
+		case "model":
+			overrides.Model = model
+		case "key":
+			overrides.APIKey = apiKey
+			fmt.Fprintln(os.Stderr, "Warning: --key is visible in shell history and process lists; "+
+				"use an environment variable (e.g. OPENROUTER_API_KEY) or AI > Manage API Keys instead")
+		}
+	})
+	for task, tf := range taskFlags {
+		if m := tf.taskModel(); m != (logic.TaskModel{}) {
+			if overrides.Tasks == nil {
+				overrides.Tasks = map[logic.AITask]logic.TaskModel{}
+			}
+			overrides.Tasks[task] = m
+		}
+	}
+
 	// Оставшиеся аргументы считаем путем к файлу или проекту
 	initialPath := ""
 	if flag.NArg() > 0 {
@@ -63,7 +126,7 @@ func main() {
 	app.SetApplicationVersion(appVersion) // Используем константу
 
 	// 3. Создание главного окна
-	mainWindow := ui.NewEditorWindow(provider, model, apiKey)
+	mainWindow := ui.NewEditorWindow(cfg, overrides)
 	mainWindow.SetupUI()
 
 	// 4. Открытие начального пути (если есть)
@@ -0,0 +1,207 @@
+package logic
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	// maxAgentSteps — сколько раз агент обращается к модели за один запрос
+	maxAgentSteps = 16
+	// maxToolOutput ограничивает результат инструмента, который получает модель
+	maxToolOutput = 16 * 1024
+)
+
+// agentInstructions добавляются к системному промпту в режиме агента
+const agentInstructions = `You are working as an agent inside the editor. Use the tools to inspect the project before answering.
+Paths are relative to the project root. Change files only with propose_edit: the user reviews every edit
+and every command before it runs, and may reject it — then do not repeat the same call. After edits,
+run go_build or go_test to check them when it makes sense. Finish with a short summary of what was done.`
+
+// AgentRequest — запрос в режиме агента
+type AgentRequest struct {
+	Provider string
+	Model    string
+	APIKey   string
+	History  []Message // предыдущие реплики чата
+	Prompt   string
+	Vars     PromptVars
+
+	// StepTimeout ограничивает один запрос к модели (0 — defaultTimeout);
+	// время ожидания подтверждений пользователя не учитывается
+	StepTimeout time.Duration
+}
+
+// AgentResult — итог работы агента
+type AgentResult struct {
+	Text       string
+	Provider   string
+	Model      string
+	Steps      int // запросов к модели
+	Redactions RedactionReport
+}
+
+// AgentEvent — шаг агента для вывода в чате: вызов инструмента (Tool, Text — аргументы),
+// его результат (Result) или промежуточный текст модели (только Text)
+type AgentEvent struct {
+	Tool   string
+	Text   string
+	Result string
+	Failed bool
+}
+
+// AgentHost — то, что агенту даёт редактор. ProposeEdit и RunCommand сами спрашивают
+// подтверждение пользователя и возвращают текст для модели; ошибка означает, что
+// действие не выполнено (в том числе отклонено).
+type AgentHost struct {
+	Root        string                                                                       // корень проекта
+	ReadFile    func(path string) (string, error)                                            // текст файла (с учётом открытых вкладок)
+	ProposeEdit func(ctx context.Context, path, content, description string) (string, error) // новое содержимое файла
+	RunCommand  func(ctx context.Context, args []string) (string, error)                     // go <args> в корне проекта
+	OnEvent     func(AgentEvent)
+}
+
+// AgentSystemPrompt возвращает системный промпт режима агента: промпт провайдера
+// и инструкции по работе с инструментами
+func AgentSystemPrompt(provider string, vars PromptVars) string {
+	vars.Task = TaskAgent
+	return strings.TrimSpace(ResolveSystemPrompt(provider, vars) + "\n\n" + agentInstructions)
+}
+
+// RunAgent ведёт диалог с моделью, выполняя запрошенные ею инструменты, пока
+// модель не ответит без вызовов. Провайдер должен поддерживать function calling
+// (OpenAI-совместимый API); запасные провайдеры не используются.
+func RunAgent(ctx context.Context, req AgentRequest, host AgentHost) (*AgentResult, error) {
+	result := &AgentResult{Provider: req.Provider, Model: req.Model}
+
+	system := AgentSystemPrompt(req.Provider, req.Vars)
+	provider, err := newProvider(req.Provider, req.Model, req.APIKey, system)
+	if err != nil {
+		return result, fmt.Errorf("provider error: %w", err)
+	}
+	tp, ok := provider.(ToolProvider)
+	if !ok {
+		return result, fmt.Errorf("%s: %w", req.Provider, ErrToolsUnsupported)
+	}
+
+	redact := ShouldRedact(req.Provider)
+	history := append(append([]Message(nil), req.History...), Message{Role: "user", Content: req.Prompt})
+	if redact {
+		history, result.Redactions = redactMessages(history)
+	}
+	msgs := make([]AgentMessage, len(history))
+	for i, m := range history {
+		msgs[i] = AgentMessage{Role: m.Role, Content: m.Content}
+	}
+
+	emit := func(ev AgentEvent) {
+		if host.OnEvent != nil {
+			host.OnEvent(ev)
+		}
+	}
+
+	for step := 1; step <= maxAgentSteps; step++ {
+		result.Steps = step
+		reply, err := agentStep(ctx, tp, req, system, msgs, result.Redactions)
+		if err != nil {
+			return result, err
+		}
+		msgs = append(msgs, reply)
+		if len(reply.ToolCalls) == 0 {
+			result.Text = reply.Content
+			return result, nil
+		}
+		if text := strings.TrimSpace(reply.Content); text != "" {
+			emit(AgentEvent{Text: text})
+		}
+
+		for _, call := range reply.ToolCalls {
+			emit(AgentEvent{Tool: call.Name, Text: call.Arguments})
+			out, err := host.runTool(ctx, call)
+			if ctx.Err() != nil {
+				return result, ctx.Err()
+			}
+			if err != nil {
+				out = strings.TrimSpace(out + "\nerror: " + err.Error())
+			}
+			emit(AgentEvent{Tool: call.Name, Result: out, Failed: err != nil})
+
+			if redact {
+				var report RedactionReport
+				out, report = RedactText(out)
+				result.Redactions = result.Redactions.merge(report)
+			}
+			msgs = append(msgs, AgentMessage{Role: "tool", ToolCallID: call.ID, Name: call.Name, Content: clipToolOutput(out)})
+		}
+	}
+	return result, fmt.Errorf("agent stopped after %d steps without a final answer", maxAgentSteps)
+}
+
+// agentStep отправляет диалог модели и пишет попытку в журнал запросов
+func agentStep(ctx context.Context, tp ToolProvider, req AgentRequest, system string, msgs []AgentMessage, report RedactionReport) (AgentMessage, error) {
+	timeout := req.StepTimeout
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+	sctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	start := time.Now()
+	tctx, trace := withRequestTrace(sctx)
+	reply, err := tp.SendTools(tctx, msgs, agentTools)
+
+	entry := RequestLogEntry{
+		Time:       start,
+		Kind:       "agent",
+		Task:       TaskAgent,
+		Provider:   req.Provider,
+		Model:      req.Model,
+		LatencyMs:  time.Since(start).Milliseconds(),
+		System:     system,
+		Prompt:     agentLogPrompt(msgs),
+		Redactions: report,
+		Content:    agentLogPrompt([]AgentMessage{reply})[0].Content,
+	}
+	if err != nil {
+		entry.Error = err.Error()
+	}
+	trace.fill(&entry)
+	logRequest(entry)
+	return reply, err
+}
+
+// agentLogPrompt переводит диалог с инструментами в обычные сообщения журнала:
+// вызовы и результаты инструментов записываются текстом
+func agentLogPrompt(msgs []AgentMessage) []Message {
+	out := make([]Message, len(msgs))
+	for i, m := range msgs {
+		text := m.Content
+		for _, c := range m.ToolCalls {
+			text += fmt.Sprintf("\n→ %s %s", c.Name, c.Arguments)
+		}
+		role := m.Role
+		if role == "tool" {
+			role = "user"
+			text = fmt.Sprintf("[%s result]\n%s", m.Name, text)
+		}
+		if role == "" {
+			role = "assistant"
+		}
+		out[i] = Message{Role: role, Content: strings.TrimSpace(text)}
+	}
+	return out
+}
+
+// clipToolOutput обрезает длинный результат инструмента
+func clipToolOutput(s string) string {
+	if len(s) <= maxToolOutput {
+		return s
+	}
+	cut := maxToolOutput
+	for cut > 0 && s[cut]&0xC0 == 0x80 {
+		cut--
+	}
+	return s[:cut] + fmt.Sprintf("\n… [truncated: %d more bytes]", len(s)-cut)
+}
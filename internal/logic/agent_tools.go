@@ -0,0 +1,396 @@
+package logic
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const (
+	// maxSearchMatches — сколько совпадений возвращает search_project
+	maxSearchMatches = 100
+	// maxSearchFileSize — файлы больше этого размера поиск пропускает
+	maxSearchFileSize = 1 << 20
+)
+
+// agentSkipDirs — каталоги, которые не просматривают list_dir и search_project
+var agentSkipDirs = map[string]bool{".git": true, ".golite": true, "node_modules": true, "vendor": true}
+
+// agentTools — инструменты, доступные модели в режиме агента
+var agentTools = []ToolSpec{
+	{
+		Name:        "read_file",
+		Description: "Read a text file of the project. Lines are prefixed with their numbers and a tab; the prefixes are not part of the file. Use start_line and end_line for large files.",
+		Parameters: toolSchema(map[string]interface{}{
+			"path":       stringParam("File path relative to the project root"),
+			"start_line": intParam("First line to read, 1-based (optional)"),
+			"end_line":   intParam("Last line to read, inclusive (optional)"),
+		}, "path"),
+	},
+	{
+		Name:        "list_dir",
+		Description: "List a directory of the project. Directories end with a slash.",
+		Parameters: toolSchema(map[string]interface{}{
+			"path": stringParam("Directory relative to the project root; empty for the root"),
+		}),
+	},
+	{
+		Name:        "search_project",
+		Description: "Search the text files of the project. Returns matches as path:line: text.",
+		Parameters: toolSchema(map[string]interface{}{
+			"query": stringParam("Text to find (case-insensitive) or a regular expression"),
+			"regex": map[string]interface{}{"type": "boolean", "description": "Treat query as a Go regular expression"},
+		}, "query"),
+	},
+	{
+		Name: "propose_edit",
+		Description: "Propose a change to a file; the user reviews it as a diff and may reject it. " +
+			"Either give old_text (an exact, unique fragment of the file) and new_text, or the complete new content of the file. " +
+			"Use content to create a new file.",
+		Parameters: toolSchema(map[string]interface{}{
+			"path":        stringParam("File path relative to the project root"),
+			"old_text":    stringParam("Exact text to replace; must occur in the file exactly once"),
+			"new_text":    stringParam("Replacement for old_text"),
+			"content":     stringParam("Complete new content of the file (instead of old_text/new_text)"),
+			"description": stringParam("One sentence explaining the change to the user"),
+		}, "path", "description"),
+	},
+	{
+		Name:        "go_build",
+		Description: "Run go build for packages of the project (default ./...) after the user approves it. Unsaved edits are saved first.",
+		Parameters: toolSchema(map[string]interface{}{
+			"packages": stringParam("Space-separated package patterns, e.g. ./internal/..."),
+		}),
+	},
+	{
+		Name:        "go_test",
+		Description: "Run go test for packages of the project (default ./...) after the user approves it. Unsaved edits are saved first.",
+		Parameters: toolSchema(map[string]interface{}{
+			"packages": stringParam("Space-separated package patterns, e.g. ./internal/logic"),
+			"run":      stringParam("Regular expression for -run (optional)"),
+		}),
+	},
+}
+
+func toolSchema(props map[string]interface{}, required ...string) map[string]interface{} {
+	schema := map[string]interface{}{"type": "object", "properties": props}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+func stringParam(desc string) map[string]interface{} {
+	return map[string]interface{}{"type": "string", "description": desc}
+}
+
+func intParam(desc string) map[string]interface{} {
+	return map[string]interface{}{"type": "integer", "description": desc}
+}
+
+// agentToolArgs — аргументы всех инструментов; каждый использует свои поля
+type agentToolArgs struct {
+	Path        string `json:"path"`
+	StartLine   int    `json:"start_line"`
+	EndLine     int    `json:"end_line"`
+	Query       string `json:"query"`
+	Regex       bool   `json:"regex"`
+	OldText     string `json:"old_text"`
+	NewText     string `json:"new_text"`
+	Content     string `json:"content"`
+	Description string `json:"description"`
+	Packages    string `json:"packages"`
+	Run         string `json:"run"`
+}
+
+// runTool выполняет вызов инструмента; результат и текст ошибки уходят модели
+func (h AgentHost) runTool(ctx context.Context, call ToolCall) (string, error) {
+	var args agentToolArgs
+	if err := json.Unmarshal([]byte(call.Arguments), &args); err != nil {
+		return "", fmt.Errorf("invalid arguments: %w", err)
+	}
+	switch call.Name {
+	case "read_file":
+		return h.readFileTool(args)
+	case "list_dir":
+		return h.listDirTool(args)
+	case "search_project":
+		return h.searchTool(ctx, args)
+	case "propose_edit":
+		return h.proposeEditTool(ctx, args)
+	case "go_build":
+		return h.goTool(ctx, []string{"build"}, args.Packages)
+	case "go_test":
+		prefix := []string{"test"}
+		if args.Run != "" {
+			prefix = append(prefix, "-run", args.Run)
+		}
+		return h.goTool(ctx, prefix, args.Packages)
+	}
+	return "", fmt.Errorf("unknown tool %q", call.Name)
+}
+
+// agentPrivateDirs — каталоги проекта, недоступные агенту даже для чтения
+// (история git, настройки и чаты редактора)
+var agentPrivateDirs = map[string]bool{".git": true, ".golite": true}
+
+// resolve переводит путь модели в абсолютный, не выпуская его за пределы проекта.
+// Символические ссылки разрешаются: ссылка в репозитории (docs -> ~) не открывает
+// модели файлы вне проекта.
+func (h AgentHost) resolve(path string) (string, error) {
+	if h.Root == "" {
+		return "", errors.New("no project is open")
+	}
+	p := filepath.FromSlash(strings.TrimSpace(path))
+	if !filepath.IsAbs(p) {
+		p = filepath.Join(h.Root, p)
+	}
+	p = filepath.Clean(p)
+
+	root, err := filepath.EvalSymlinks(h.Root)
+	if err != nil {
+		return "", err
+	}
+	real, err := evalExistingSymlinks(p)
+	if err != nil {
+		return "", err
+	}
+	for _, r := range []struct{ base, target string }{{h.Root, p}, {root, real}} {
+		rel, err := filepath.Rel(r.base, r.target)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return "", fmt.Errorf("%s is outside the project", path)
+		}
+		if first := strings.Split(filepath.ToSlash(rel), "/")[0]; agentPrivateDirs[first] {
+			return "", fmt.Errorf("%s is not accessible in agent mode", first)
+		}
+	}
+	return p, nil
+}
+
+// evalExistingSymlinks разрешает символические ссылки в существующей части пути:
+// файл, который агент предлагает создать, ещё не существует
+func evalExistingSymlinks(p string) (string, error) {
+	rest := ""
+	for {
+		real, err := filepath.EvalSymlinks(p)
+		if err == nil {
+			return filepath.Join(real, rest), nil
+		}
+		parent := filepath.Dir(p)
+		if !errors.Is(err, fs.ErrNotExist) || parent == p {
+			return "", err
+		}
+		rest = filepath.Join(filepath.Base(p), rest)
+		p = parent
+	}
+}
+
+// relPath — путь относительно корня для вывода модели
+func (h AgentHost) relPath(abs string) string {
+	if rel, err := filepath.Rel(h.Root, abs); err == nil {
+		return filepath.ToSlash(rel)
+	}
+	return abs
+}
+
+func (h AgentHost) read(abs string) (string, error) {
+	if h.ReadFile != nil {
+		return h.ReadFile(abs)
+	}
+	data, err := os.ReadFile(abs)
+	return string(data), err
+}
+
+func (h AgentHost) readFileTool(args agentToolArgs) (string, error) {
+	abs, err := h.resolve(args.Path)
+	if err != nil {
+		return "", err
+	}
+	text, err := h.read(abs)
+	if err != nil {
+		return "", err
+	}
+	lines := SplitLines(text)
+	first, last := args.StartLine, args.EndLine
+	if first < 1 {
+		first = 1
+	}
+	if last < 1 || last > len(lines) {
+		last = len(lines)
+	}
+	if first > last {
+		return "", fmt.Errorf("the file has %d lines", len(lines))
+	}
+
+	var sb strings.Builder
+	for n := first; n <= last; n++ {
+		if sb.Len() > maxToolOutput {
+			fmt.Fprintf(&sb, "… [stopped at line %d of %d: use start_line to read further]\n", n-1, len(lines))
+			break
+		}
+		fmt.Fprintf(&sb, "%d\t%s\n", n, lines[n-1])
+	}
+	return sb.String(), nil
+}
+
+func (h AgentHost) listDirTool(args agentToolArgs) (string, error) {
+	abs, err := h.resolve(args.Path)
+	if err != nil {
+		return "", err
+	}
+	entries, err := os.ReadDir(abs)
+	if err != nil {
+		return "", err
+	}
+	var names []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() {
+			if agentSkipDirs[name] {
+				continue
+			}
+			name += "/"
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	if len(names) == 0 {
+		return "(empty directory)", nil
+	}
+	return strings.Join(names, "\n"), nil
+}
+
+func (h AgentHost) searchTool(ctx context.Context, args agentToolArgs) (string, error) {
+	if args.Query == "" {
+		return "", errors.New("query is empty")
+	}
+	root, err := h.resolve(".")
+	if err != nil {
+		return "", err
+	}
+	match := func(line string) bool { return strings.Contains(strings.ToLower(line), strings.ToLower(args.Query)) }
+	if args.Regex {
+		re, err := regexp.Compile(args.Query)
+		if err != nil {
+			return "", err
+		}
+		match = re.MatchString
+	}
+
+	var results []string
+	more := false
+	errStop := errors.New("stop")
+	err = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if d.Type()&fs.ModeSymlink != 0 {
+			return nil // ссылка может вести за пределы проекта
+		}
+		if d.IsDir() {
+			if path != root && (agentSkipDirs[d.Name()] || strings.HasPrefix(d.Name(), ".")) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if info, err := d.Info(); err != nil || !info.Mode().IsRegular() || info.Size() > maxSearchFileSize {
+			return nil
+		}
+		data, err := os.ReadFile(path)
+		if err != nil || bytes.IndexByte(data[:min(len(data), 8000)], 0) >= 0 {
+			return nil // бинарный файл
+		}
+		scanner := bufio.NewScanner(bytes.NewReader(data))
+		scanner.Buffer(nil, maxSearchFileSize)
+		for n := 1; scanner.Scan(); n++ {
+			line := scanner.Text()
+			if !match(line) {
+				continue
+			}
+			if len(results) == maxSearchMatches {
+				more = true
+				return errStop
+			}
+			if r := []rune(strings.TrimSpace(line)); len(r) > 200 {
+				line = string(r[:200]) + "…"
+			}
+			results = append(results, fmt.Sprintf("%s:%d: %s", h.relPath(path), n, strings.TrimSpace(line)))
+		}
+		return nil
+	})
+	if err != nil && err != errStop {
+		return "", err
+	}
+	if len(results) == 0 {
+		return "no matches", nil
+	}
+	out := strings.Join(results, "\n")
+	if more {
+		out += fmt.Sprintf("\n… more than %d matches, refine the query", maxSearchMatches)
+	}
+	return out, nil
+}
+
+func (h AgentHost) proposeEditTool(ctx context.Context, args agentToolArgs) (string, error) {
+	if h.ProposeEdit == nil {
+		return "", errors.New("editing is not available")
+	}
+	abs, err := h.resolve(args.Path)
+	if err != nil {
+		return "", err
+	}
+	current, err := h.read(abs)
+	if err != nil && (args.OldText != "" || !errors.Is(err, os.ErrNotExist)) {
+		return "", err
+	}
+	content := args.Content
+	if args.OldText != "" {
+		switch n := strings.Count(current, args.OldText); n {
+		case 0:
+			return "", errors.New("old_text not found in the file; read the file again and copy the text exactly")
+		case 1:
+			content = strings.Replace(current, args.OldText, args.NewText, 1)
+		default:
+			return "", fmt.Errorf("old_text occurs %d times; include more surrounding lines", n)
+		}
+	} else if content == "" {
+		return "", errors.New("either old_text/new_text or content is required")
+	}
+	// Секреты в контексте были замаскированы; метка вместо значения испортит файл
+	if added := NewRedactedPlaceholders(current, content); len(added) > 0 {
+		return "", fmt.Errorf("the edit contains %s: the real value was hidden from you; keep the original text instead of the placeholder", strings.Join(added, ", "))
+	}
+	return h.ProposeEdit(ctx, abs, content, args.Description)
+}
+
+// goTool запускает go с шаблонами пакетов; флаги в packages не допускаются
+func (h AgentHost) goTool(ctx context.Context, prefix []string, packages string) (string, error) {
+	if h.RunCommand == nil {
+		return "", errors.New("running commands is not available")
+	}
+	if h.Root == "" {
+		return "", errors.New("no project is open")
+	}
+	pkgs := strings.Fields(packages)
+	if len(pkgs) == 0 {
+		pkgs = []string{"./..."}
+	}
+	for _, p := range pkgs {
+		if strings.HasPrefix(p, "-") {
+			return "", fmt.Errorf("%q: only package patterns are allowed", p)
+		}
+	}
+	return h.RunCommand(ctx, append(prefix, pkgs...))
+}
@@ -0,0 +1,102 @@
+package logic
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// agentProject создаёт проект со ссылкой docs на каталог вне проекта
+func agentProject(t *testing.T) (root, outside string) {
+	t.Helper()
+	base := t.TempDir()
+	root = filepath.Join(base, "project")
+	outside = filepath.Join(base, "home")
+	for _, dir := range []string{root, outside, filepath.Join(root, ".golite"), filepath.Join(root, ".git")} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	files := map[string]string{
+		filepath.Join(outside, "id_rsa"):              "PRIVATE KEY needle",
+		filepath.Join(root, "main.go"):                "package main // needle\n",
+		filepath.Join(root, ".golite", "config.json"): "{}",
+		filepath.Join(root, ".git", "config"):         "[core]",
+	}
+	for path, text := range files {
+		if err := os.WriteFile(path, []byte(text), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink(outside, filepath.Join(root, "docs")); err != nil {
+		t.Skip("symlinks are not supported:", err)
+	}
+	return root, outside
+}
+
+func TestAgentResolveRejectsEscapes(t *testing.T) {
+	root, _ := agentProject(t)
+	h := AgentHost{Root: root}
+
+	for _, path := range []string{"../home/id_rsa", "docs/id_rsa", "docs", ".git/config", ".golite/config.json", "/etc/passwd"} {
+		if _, err := h.resolve(path); err == nil {
+			t.Errorf("resolve(%q) succeeded", path)
+		}
+	}
+	for _, path := range []string{"main.go", ".", "new/file.go"} {
+		if _, err := h.resolve(path); err != nil {
+			t.Errorf("resolve(%q): %v", path, err)
+		}
+	}
+
+	call := ToolCall{Name: "read_file", Arguments: `{"path": "docs/id_rsa"}`}
+	if out, err := h.runTool(context.Background(), call); err == nil {
+		t.Fatalf("read_file through a symlink returned %q", out)
+	}
+}
+
+func TestAgentSearchSkipsSymlinks(t *testing.T) {
+	root, _ := agentProject(t)
+	h := AgentHost{Root: root}
+
+	out, err := h.runTool(context.Background(), ToolCall{Name: "search_project", Arguments: `{"query": "needle"}`})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(out, "PRIVATE") || !strings.Contains(out, "main.go:1:") {
+		t.Fatalf("unexpected search result:\n%s", out)
+	}
+}
+
+func TestAgentProposeEditRejectsRedactedPlaceholders(t *testing.T) {
+	root := t.TempDir()
+	path := filepath.Join(root, "config.go")
+	src := "package config\n\nconst note = \"[REDACTED:api key] is shown in logs\"\n\nvar key = \"real\"\n"
+	if err := os.WriteFile(path, []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+	var proposed []string
+	h := AgentHost{Root: root, ProposeEdit: func(ctx context.Context, path, content, description string) (string, error) {
+		proposed = append(proposed, content)
+		return "applied", nil
+	}}
+	edit := func(oldText, newText string) error {
+		args, _ := json.Marshal(map[string]string{"path": "config.go", "old_text": oldText, "new_text": newText})
+		_, err := h.runTool(context.Background(), ToolCall{Name: "propose_edit", Arguments: string(args)})
+		return err
+	}
+
+	if err := edit(`"real"`, `"[REDACTED:secret value]"`); err == nil || !strings.Contains(err.Error(), "[REDACTED:secret value]") {
+		t.Fatalf("placeholder edit: err = %v", err)
+	}
+	// Метка, которая уже есть в файле, не мешает правке
+	if err := edit("shown in logs", "hidden"); err != nil {
+		t.Fatal(err)
+	}
+	if len(proposed) != 1 {
+		t.Fatalf("proposed %d edit(s)", len(proposed))
+	}
+}
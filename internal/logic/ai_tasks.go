@@ -0,0 +1,113 @@
+package logic
+
+import "time"
+
+// AITask — вид запроса к LLM; для каждого можно задать свои провайдер, модель и таймаут
+type AITask string
+
+const (
+	TaskChat          AITask = "chat"      // панель AI Assistant
+	TaskLineComplete  AITask = "line"      // дополнение строки (Tab / Ctrl+Space)
+	TaskMultiLine     AITask = "multiline" // многострочное дополнение (Ctrl+L)
+	TaskCommentToCode AITask = "comment"   // генерация кода по комментарию (Ctrl+L)
+	TaskAgent         AITask = "agent"     // режим агента с инструментами (нужна модель с function calling)
+	TaskReview        AITask = "review"    // ревью незакоммиченных изменений (AI > Review My Changes)
+	TaskEmbed         AITask = "embed"     // эмбеддинги индекса кода (нужна модель эмбеддингов)
+)
+
+// AITasks — все виды запросов в порядке отображения в настройках
+var AITasks = []AITask{TaskChat, TaskLineComplete, TaskMultiLine, TaskCommentToCode, TaskAgent, TaskReview, TaskEmbed}
+
+// Title возвращает название вида запроса для UI
+func (t AITask) Title() string {
+	switch t {
+	case TaskChat:
+		return "Chat"
+	case TaskLineComplete:
+		return "Line completion"
+	case TaskMultiLine:
+		return "Multi-line completion"
+	case TaskCommentToCode:
+		return "Comment-based generation"
+	case TaskAgent:
+		return "Agent mode"
+	case TaskReview:
+		return "Code review"
+	case TaskEmbed:
+		return "Code index embeddings"
+	}
+	return string(t)
+}
+
+// DefaultTimeout — таймаут запроса, если он не задан в настройках
+func (t AITask) DefaultTimeout() time.Duration {
+	if t == TaskLineComplete {
+		return completionTimeout
+	}
+	return defaultTimeout
+}
+
+// TaskModel — настройки одного вида запросов; пустые поля наследуются от чата
+type TaskModel struct {
+	Provider   string `json:"provider,omitempty"`
+	Model      string `json:"model,omitempty"`
+	APIKey     string `json:"api_key,omitempty"`
+	TimeoutSec int    `json:"timeout_sec,omitempty"`
+}
+
+// Merge возвращает настройки, в которых заданные поля over заменяют поля m
+func (m TaskModel) Merge(over TaskModel) TaskModel {
+	if over.Provider != "" {
+		m.Provider = over.Provider
+	}
+	if over.Model != "" {
+		m.Model = over.Model
+	}
+	if over.APIKey != "" {
+		m.APIKey = over.APIKey
+	}
+	if over.TimeoutSec > 0 {
+		m.TimeoutSec = over.TimeoutSec
+	}
+	return m
+}
+
+// LLMTarget — провайдер, модель, ключ и таймаут, с которыми выполняется запрос
+type LLMTarget struct {
+	Task     AITask
+	Provider string
+	Model    string
+	APIKey   string
+	Timeout  time.Duration
+
+	// Vars — переменные системного промпта (текущий файл и проект)
+	Vars PromptVars
+}
+
+// ResolveTask вычисляет параметры запроса вида task: chat — настройки чата,
+// settings — настройки вида запроса (пустые поля наследуются от chat).
+// Ключ чата наследуется, только если провайдер тот же.
+func ResolveTask(task AITask, chat LLMTarget, settings TaskModel) LLMTarget {
+	target := chat
+	target.Task = task
+	target.Timeout = task.DefaultTimeout()
+
+	if settings.Provider != "" && normalizeProviderName(settings.Provider) != normalizeProviderName(chat.Provider) {
+		target.Provider = settings.Provider
+		target.APIKey = ""
+	}
+	if settings.Model != "" {
+		target.Model = settings.Model
+	} else if target.Provider != chat.Provider {
+		// Модель чата у другого провайдера обычно не существует: пустая модель означает
+		// модель профиля (см. newProvider) или последнюю выбранную (Config.LastModel)
+		target.Model = ""
+	}
+	if settings.APIKey != "" {
+		target.APIKey = settings.APIKey
+	}
+	if settings.TimeoutSec > 0 {
+		target.Timeout = time.Duration(settings.TimeoutSec) * time.Second
+	}
+	return target
+}
@@ -0,0 +1,366 @@
+package logic
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	// maxBuildErrors — сколько ошибок попадает в запрос исправления
+	maxBuildErrors = 30
+	// maxBuildErrorLines — сколько строк с местом ошибки разбирается, включая
+	// строки с файлами, которых нет в проекте
+	maxBuildErrorLines = 200
+	// buildFixWindow — строк вокруг ошибки, если она не внутри объявления
+	buildFixWindow = 15
+	// maxBuildFixDecl — объявление длиннее заменяется окном вокруг ошибки
+	maxBuildFixDecl = 200
+)
+
+// buildErrorRe — место ошибки компилятора, vet или теста: "path/file.go:12:5: message"
+var buildErrorRe = regexp.MustCompile(`^\s*((?:[A-Za-z]:)?[^\s:]*\.go):(\d+)(?::(\d+))?:\s*(.+)$`)
+
+// BuildError — ошибка с местом в исходнике
+type BuildError struct {
+	File    string // абсолютный путь
+	Line    int
+	Col     int // 0 — не указан
+	Message string
+}
+
+// String — ошибка в формате компилятора с путём относительно root
+func (b BuildError) String(root string) string {
+	name := projectRelPath(root, b.File)
+	if b.Col > 0 {
+		return fmt.Sprintf("%s:%d:%d: %s", name, b.Line, b.Col, b.Message)
+	}
+	return fmt.Sprintf("%s:%d: %s", name, b.Line, b.Message)
+}
+
+// projectRelPath — путь относительно root через "/", или исходный путь вне проекта
+func projectRelPath(root, path string) string {
+	if root != "" {
+		if rel, err := filepath.Rel(root, path); err == nil && !strings.HasPrefix(rel, "..") {
+			return filepath.ToSlash(rel)
+		}
+	}
+	return path
+}
+
+// ParseBuildErrors находит в выводе go build/run/test места ошибок. Относительные
+// пути берутся от dir (каталога запуска); если такого файла нет (go test печатает
+// путь от каталога пакета), ищется единственный файл проекта root с тем же окончанием пути.
+func ParseBuildErrors(output, dir, root string) []BuildError {
+	var errs []BuildError
+	seen := map[string]bool{}
+	paths := &buildPathResolver{dir: dir, root: root, cache: map[string]string{}}
+	matched := 0
+	for _, line := range strings.Split(output, "\n") {
+		m := buildErrorRe.FindStringSubmatch(strings.TrimRight(line, "\r"))
+		if m == nil {
+			continue
+		}
+		if matched++; matched > maxBuildErrorLines {
+			break
+		}
+		path := paths.resolve(m[1])
+		if path == "" {
+			continue
+		}
+		lineNo, _ := strconv.Atoi(m[2])
+		col, _ := strconv.Atoi(m[3])
+		be := BuildError{File: path, Line: lineNo, Col: col, Message: strings.TrimSpace(m[4])}
+		key := be.String("")
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		errs = append(errs, be)
+		if len(errs) == maxBuildErrors {
+			break
+		}
+	}
+	return errs
+}
+
+// buildPathResolver сопоставляет пути из вывода файлам; проект обходится
+// не более одного раза за разбор, ответы запоминаются
+type buildPathResolver struct {
+	dir, root string
+	files     []string // .go-файлы проекта, собираются при первой необходимости
+	walked    bool
+	cache     map[string]string
+}
+
+func (r *buildPathResolver) resolve(name string) string {
+	if path, ok := r.cache[name]; ok {
+		return path
+	}
+	path := r.lookup(filepath.FromSlash(name))
+	r.cache[name] = path
+	return path
+}
+
+func (r *buildPathResolver) lookup(name string) string {
+	if filepath.IsAbs(name) {
+		if fileExists(name) {
+			return filepath.Clean(name)
+		}
+		return ""
+	}
+	if p := filepath.Join(r.dir, name); r.dir != "" && fileExists(p) {
+		return p
+	}
+	if r.root == "" {
+		return ""
+	}
+	if !r.walked {
+		r.walked = true
+		r.files = projectGoFiles(r.root)
+	}
+	found := ""
+	suffix := string(filepath.Separator) + filepath.Clean(name)
+	for _, path := range r.files {
+		if strings.HasSuffix(path, suffix) {
+			if found != "" {
+				return ""
+			}
+			found = path
+		}
+	}
+	return found
+}
+
+// projectGoFiles — .go-файлы проекта без скрытых каталогов, vendor и node_modules
+func projectGoFiles(root string) []string {
+	var files []string
+	filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil
+		}
+		if info.IsDir() {
+			if path != root && (strings.HasPrefix(info.Name(), ".") || info.Name() == "vendor" || info.Name() == "node_modules") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if strings.HasSuffix(path, ".go") {
+			files = append(files, path)
+		}
+		return nil
+	})
+	return files
+}
+
+func fileExists(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && !info.IsDir()
+}
+
+// BuildFixFiles — файлы, упомянутые в ошибках, по порядку первого упоминания
+func BuildFixFiles(errs []BuildError) []string {
+	var files []string
+	seen := map[string]bool{}
+	for _, be := range errs {
+		if !seen[be.File] {
+			seen[be.File] = true
+			files = append(files, be.File)
+		}
+	}
+	return files
+}
+
+// BuildFixPrompt составляет запрос исправления: ошибки и код вокруг них. Для Go
+// берутся импорты и объявления, в которых находятся ошибки, для остальных файлов —
+// строки вокруг ошибки. read возвращает текст файла (с учётом открытых вкладок).
+func BuildFixPrompt(errs []BuildError, root string, read func(path string) (string, error)) string {
+	var sb strings.Builder
+	sb.WriteString("Fix the following Go errors.\n\nErrors:\n")
+	for _, be := range errs {
+		sb.WriteString(be.String(root) + "\n")
+	}
+	sb.WriteString("\nCode around the errors (the numbers on the left are line numbers, not part of the code):\n")
+
+	for _, file := range BuildFixFiles(errs) {
+		text, err := read(file)
+		if err != nil {
+			continue
+		}
+		var lines []int
+		for _, be := range errs {
+			if be.File == file {
+				lines = append(lines, be.Line)
+			}
+		}
+		fmt.Fprintf(&sb, "\nFile: %s\n```%s\n%s```\n", projectRelPath(root, file), fenceLanguage(file), numberedRanges(SplitLines(text), buildFixRanges(file, text, lines)))
+	}
+
+	sb.WriteString(`
+First explain the cause in one or two sentences. Then, for every file you change, write a line
+"File: <path>" followed by one fenced code block with the complete corrected top-level declarations
+of that file that change (whole functions, methods, types, var or const declarations) — not the whole
+file and not only the changed lines. If imports must change, include the complete import block.
+`)
+	return sb.String()
+}
+
+func fenceLanguage(path string) string {
+	if strings.HasSuffix(path, ".go") {
+		return "go"
+	}
+	return ""
+}
+
+// buildFixRanges возвращает диапазоны строк (с 1, включительно), которые нужно показать модели
+func buildFixRanges(path, text string, errLines []int) [][2]int {
+	var ranges [][2]int
+	window := func(line int) [2]int { return [2]int{line - buildFixWindow, line + buildFixWindow} }
+
+	var file *ast.File
+	fset := token.NewFileSet()
+	if strings.HasSuffix(path, ".go") {
+		// Файл с синтаксической ошибкой тоже частично разбирается
+		file, _ = parser.ParseFile(fset, path, text, parser.ParseComments)
+	}
+	if file != nil {
+		for _, decl := range file.Decls {
+			if d, ok := decl.(*ast.GenDecl); ok && d.Tok == token.IMPORT {
+				ranges = append(ranges, [2]int{fset.Position(d.Pos()).Line, fset.Position(d.End()).Line})
+			}
+		}
+	}
+	for _, line := range errLines {
+		r := window(line)
+		if file != nil {
+			for _, decl := range file.Decls {
+				start, end := fset.Position(decl.Pos()).Line, fset.Position(decl.End()).Line
+				if line >= start && line <= end && end-start < maxBuildFixDecl {
+					// С doc-комментарием
+					docStart, _ := goDeclSpan(fset, decl)
+					r = [2]int{strings.Count(text[:docStart], "\n") + 1, end}
+					break
+				}
+			}
+		}
+		ranges = append(ranges, r)
+	}
+	return ranges
+}
+
+// numberedRanges выводит строки диапазонов с номерами; пропуски отмечаются "..."
+func numberedRanges(lines []string, ranges [][2]int) string {
+	sort.Slice(ranges, func(i, j int) bool { return ranges[i][0] < ranges[j][0] })
+	var sb strings.Builder
+	next := 1
+	for _, r := range ranges {
+		start, end := max(r[0], next), min(r[1], len(lines))
+		if start > end {
+			continue
+		}
+		if start > next {
+			sb.WriteString("...\n")
+		}
+		for n := start; n <= end; n++ {
+			fmt.Fprintf(&sb, "%d\t%s\n", n, lines[n-1])
+		}
+		next = end + 1
+	}
+	if next <= len(lines) {
+		sb.WriteString("...\n")
+	}
+	return sb.String()
+}
+
+// FileFix — исправленный код для одного файла из ответа модели
+type FileFix struct {
+	File string // абсолютный путь
+	Code string
+}
+
+// fixFileRe — строка с путём перед блоком кода: "File: x.go", "**x.go**", "### internal/x.go"
+var fixFileRe = regexp.MustCompile(`([\w./\\-]+\.\w+)`)
+
+// ParseBuildFix извлекает из ответа блоки кода и сопоставляет их файлам files:
+// по пути в строках перед блоком, а если файл один — всем блокам. Несколько блоков
+// одного файла объединяются.
+func ParseBuildFix(resp, root string, files []string) []FileFix {
+	var fixes []FileFix
+	index := map[string]int{}
+	lines := SplitLines(resp)
+	for i := 0; i < len(lines); i++ {
+		fence := strings.TrimSpace(lines[i])
+		if !strings.HasPrefix(fence, "```") {
+			continue
+		}
+		var code []string
+		j := i + 1
+		for ; j < len(lines) && !strings.HasPrefix(strings.TrimSpace(lines[j]), "```"); j++ {
+			code = append(code, lines[j])
+		}
+
+		file := ""
+		for k := i - 1; k >= 0 && k >= i-3 && file == ""; k-- {
+			file = matchFixFile(lines[k], root, files)
+		}
+		if file == "" {
+			file = matchFixFile(fence, root, files)
+		}
+		if file == "" && len(files) == 1 {
+			file = files[0]
+		}
+		if file != "" && len(code) > 0 {
+			text := strings.Join(stripLineNumbers(code), "\n")
+			if n, ok := index[file]; ok {
+				fixes[n].Code += "\n\n" + text
+			} else {
+				index[file] = len(fixes)
+				fixes = append(fixes, FileFix{File: file, Code: text})
+			}
+		}
+		i = j
+	}
+	return fixes
+}
+
+// matchFixFile находит в строке путь одного из файлов: сначала полный относительный, затем имя
+func matchFixFile(line, root string, files []string) string {
+	for _, name := range fixFileRe.FindAllString(line, -1) {
+		name = filepath.ToSlash(strings.TrimPrefix(name, "./"))
+		for _, f := range files {
+			if projectRelPath(root, f) == name {
+				return f
+			}
+		}
+		for _, f := range files {
+			if filepath.Base(f) == filepath.Base(name) {
+				return f
+			}
+		}
+	}
+	return ""
+}
+
+// lineNumberRe — номер строки, который модель могла скопировать из запроса
+var lineNumberRe = regexp.MustCompile(`^\d+\t`)
+
+// stripLineNumbers убирает номера строк, если ими начинаются все непустые строки
+func stripLineNumbers(code []string) []string {
+	for _, line := range code {
+		if line != "" && !lineNumberRe.MatchString(line) {
+			return code
+		}
+	}
+	out := make([]string, len(code))
+	for i, line := range code {
+		out[i] = lineNumberRe.ReplaceAllString(line, "")
+	}
+	return out
+}
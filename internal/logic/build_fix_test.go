@@ -0,0 +1,173 @@
+package logic
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// buildProject создаёт проект с файлами files (путь через "/" → текст)
+func buildProject(t *testing.T, files map[string]string) string {
+	t.Helper()
+	root := t.TempDir()
+	for name, text := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(text), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+func TestParseBuildErrors(t *testing.T) {
+	root := buildProject(t, map[string]string{
+		"main.go":            "package main\n",
+		"internal/a/a.go":    "package a\n",
+		"internal/a/b.go":    "package a\n",
+		"cmd/x/util.go":      "package main\n",
+		"pkg/y/util.go":      "package y\n",
+		".cache/skip/c.go":   "package c\n",
+		"vendor/v/vendor.go": "package v\n",
+	})
+	abs := filepath.Join(root, "internal", "a", "a.go")
+
+	tests := []struct {
+		name, output, dir string
+		want              []string
+	}{
+		{"relative to dir", "# app\n./main.go:3:2: undefined: x\n", root, []string{"main.go:3:2: undefined: x"}},
+		{"package-relative path from go test", "internal/a/b.go:7: missing return\n", filepath.Join(root, "cmd"), []string{"internal/a/b.go:7: missing return"}},
+		{"suffix lookup", "a.go:10:1: syntax error\n", filepath.Join(root, "cmd"), []string{"internal/a/a.go:10:1: syntax error"}},
+		{"absolute path", abs + ":4:5: declared and not used: v\r\n", root, []string{"internal/a/a.go:4:5: declared and not used: v"}},
+		{"ambiguous name", "util.go:1:1: error\n", filepath.Join(root, "internal"), nil},
+		{"hidden and vendor dirs", "c.go:1:1: error\nvendor.go:2:1: error\n", filepath.Join(root, "internal"), nil},
+		{"unknown file", "/usr/lib/go/src/fmt/print.go:1:1: error\nmissing.go:1:1: error\n", root, nil},
+		{"duplicates", "main.go:3:2: undefined: x\nmain.go:3:2: undefined: x\n", root, []string{"main.go:3:2: undefined: x"}},
+		{"not an error", "ok  \tapp\t0.01s\n--- FAIL: TestX (0.00s)\n", root, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, be := range ParseBuildErrors(tt.output, tt.dir, root) {
+				got = append(got, be.String(root))
+			}
+			if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Fatalf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseBuildErrorsLimits(t *testing.T) {
+	root := buildProject(t, map[string]string{"main.go": "package main\n"})
+	var out strings.Builder
+	for i := 1; i <= maxBuildErrors+10; i++ {
+		fmt.Fprintf(&out, "main.go:%d:1: error %d\n", i, i)
+	}
+	if n := len(ParseBuildErrors(out.String(), root, root)); n != maxBuildErrors {
+		t.Fatalf("%d errors, want %d", n, maxBuildErrors)
+	}
+
+	// Строки с чужими файлами тоже считаются: после них ошибки проекта не ищутся
+	out.Reset()
+	for i := 0; i < maxBuildErrorLines; i++ {
+		fmt.Fprintf(&out, "missing%d.go:1:1: error\n", i)
+	}
+	out.WriteString("main.go:1:1: late error\n")
+	if errs := ParseBuildErrors(out.String(), root, root); len(errs) != 0 {
+		t.Fatalf("errors after the line limit: %v", errs)
+	}
+}
+
+func TestParseBuildFix(t *testing.T) {
+	root := "/p"
+	main, util := filepath.FromSlash("/p/main.go"), filepath.FromSlash("/p/internal/util.go")
+
+	tests := []struct {
+		name  string
+		resp  string
+		files []string
+		want  []FileFix
+	}{
+		{
+			name:  "single file takes every block",
+			resp:  "The variable is unused.\n\n```go\nfunc main() {}\n```\n\n```go\nfunc helper() {}\n```\n",
+			files: []string{main},
+			want:  []FileFix{{main, "func main() {}\n\nfunc helper() {}"}},
+		},
+		{
+			name:  "file lines before blocks",
+			resp:  "Cause.\n\nFile: internal/util.go\n```go\nfunc A() {}\n```\n**main.go**\n\n```go\nfunc main() {}\n```\n",
+			files: []string{main, util},
+			want:  []FileFix{{util, "func A() {}"}, {main, "func main() {}"}},
+		},
+		{
+			name:  "path in the fence info",
+			resp:  "```go main.go\nfunc main() {}\n```\n",
+			files: []string{main, util},
+			want:  []FileFix{{main, "func main() {}"}},
+		},
+		{
+			name:  "unknown file is skipped",
+			resp:  "File: other.go\n```go\nfunc X() {}\n```\n",
+			files: []string{main, util},
+			want:  nil,
+		},
+		{
+			name:  "copied line numbers",
+			resp:  "```go\n12\tfunc main() {\n13\t}\n```\n",
+			files: []string{main},
+			want:  []FileFix{{main, "func main() {\n}"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ParseBuildFix(tt.resp, root, tt.files)
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Fatalf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStripLineNumbers(t *testing.T) {
+	tests := []struct {
+		in, want []string
+	}{
+		{[]string{"1\tpackage a", "", "3\tfunc f() {}"}, []string{"package a", "", "func f() {}"}},
+		{[]string{"1\tpackage a", "func f() {}"}, []string{"1\tpackage a", "func f() {}"}},
+		{[]string{"x := 1", "\t10\ty"}, []string{"x := 1", "\t10\ty"}},
+		{nil, []string{}},
+	}
+	for _, tt := range tests {
+		got := stripLineNumbers(tt.in)
+		if strings.Join(got, "|") != strings.Join(tt.want, "|") {
+			t.Errorf("stripLineNumbers(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestNumberedRanges(t *testing.T) {
+	lines := []string{"a", "b", "c", "d", "e", "f"}
+	tests := []struct {
+		name   string
+		ranges [][2]int
+		want   string
+	}{
+		{"whole file", [][2]int{{1, 6}}, "1\ta\n2\tb\n3\tc\n4\td\n5\te\n6\tf\n"},
+		{"gap and tail", [][2]int{{4, 4}, {1, 2}}, "1\ta\n2\tb\n...\n4\td\n...\n"},
+		{"overlap", [][2]int{{2, 4}, {3, 5}}, "...\n2\tb\n3\tc\n4\td\n5\te\n...\n"},
+		{"out of bounds", [][2]int{{-10, 1}, {6, 20}}, "1\ta\n...\n6\tf\n"},
+		{"nested", [][2]int{{1, 6}, {2, 3}}, "1\ta\n2\tb\n3\tc\n4\td\n5\te\n6\tf\n"},
+	}
+	for _, tt := range tests {
+		if got := numberedRanges(lines, tt.ranges); got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
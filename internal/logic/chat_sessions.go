@@ -0,0 +1,210 @@
+package logic
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// chatSessionsDirName — каталог сохранённых диалогов в каталоге настроек
+const chatSessionsDirName = "chats"
+
+// projectChatsDirName — подкаталог chats с диалогами проектов
+const projectChatsDirName = "projects"
+
+// maxChatTitle — длина названия диалога, взятого из первого запроса
+const maxChatTitle = 60
+
+// ChatTurn — запрос пользователя и ответ модели
+type ChatTurn struct {
+	Prompt   string    `json:"prompt"`            // отправленный текст (после раскрытия слэш-команды)
+	Display  string    `json:"display,omitempty"` // введённый текст, если он отличается от Prompt
+	Response string    `json:"response"`          // ответ в Markdown
+	Provider string    `json:"provider,omitempty"`
+	Model    string    `json:"model,omitempty"`
+	Time     time.Time `json:"time"`
+}
+
+// ShownPrompt возвращает текст запроса в том виде, в каком его ввёл пользователь
+func (t ChatTurn) ShownPrompt() string {
+	if t.Display != "" {
+		return t.Display
+	}
+	return t.Prompt
+}
+
+// ChatSession — сохраняемый диалог AI чата
+type ChatSession struct {
+	ID      string     `json:"id"`
+	Title   string     `json:"title"`
+	Created time.Time  `json:"created"`
+	Updated time.Time  `json:"updated"`
+	Turns   []ChatTurn `json:"turns"`
+}
+
+// ChatSessionInfo — краткие сведения о сохранённом диалоге для списка
+type ChatSessionInfo struct {
+	ID      string
+	Title   string
+	Updated time.Time
+	Turns   int
+	Path    string
+}
+
+// NewChatSession создаёт пустой диалог с уникальным ID
+func NewChatSession() *ChatSession {
+	now := time.Now()
+	buf := make([]byte, 4)
+	rand.Read(buf)
+	return &ChatSession{
+		ID:      now.Format("20060102-150405") + "-" + hex.EncodeToString(buf),
+		Created: now,
+		Updated: now,
+	}
+}
+
+// AddTurn добавляет обмен репликами; первый запрос становится названием диалога
+func (s *ChatSession) AddTurn(turn ChatTurn) {
+	if turn.Time.IsZero() {
+		turn.Time = time.Now()
+	}
+	s.Turns = append(s.Turns, turn)
+	s.Updated = turn.Time
+	if s.Title == "" {
+		s.Title = chatTitle(turn.ShownPrompt())
+	}
+}
+
+// chatTitle — первая строка запроса, обрезанная до maxChatTitle символов
+func chatTitle(prompt string) string {
+	line, _, _ := strings.Cut(strings.TrimSpace(prompt), "\n")
+	if r := []rune(line); len(r) > maxChatTitle {
+		return string(r[:maxChatTitle-1]) + "…"
+	}
+	return line
+}
+
+// ChatSessionsDir возвращает каталог диалогов проекта root или, без проекта, общий.
+// Диалоги хранятся в каталоге настроек, а не в рабочем дереве, чтобы не попасть
+// в коммит; каталог проекта определяется хешем его абсолютного пути.
+func ChatSessionsDir(root string) (string, error) {
+	dir, err := ConfigDir()
+	if err != nil {
+		return "", err
+	}
+	dir = filepath.Join(dir, chatSessionsDirName)
+	if root == "" {
+		return dir, nil
+	}
+	if abs, err := filepath.Abs(root); err == nil {
+		root = abs
+	}
+	sum := sha256.Sum256([]byte(filepath.Clean(root)))
+	name := filepath.Base(root) + "-" + hex.EncodeToString(sum[:8])
+	return filepath.Join(dir, projectChatsDirName, name), nil
+}
+
+// ChatSessionPath возвращает файл диалога id в каталоге dir.
+// ID с разделителями пути или ".." отклоняется: файл не должен выйти за пределы dir.
+func ChatSessionPath(dir, id string) (string, error) {
+	if id == "" || strings.ContainsAny(id, `/\`) || strings.Contains(id, "..") {
+		return "", fmt.Errorf("invalid chat id %q", id)
+	}
+	return filepath.Join(dir, id+".json"), nil
+}
+
+// SaveChatSession записывает диалог в dir; файл доступен только владельцу —
+// в нём код проекта
+func SaveChatSession(dir string, s *ChatSession) error {
+	path, err := ChatSessionPath(dir, s.ID)
+	if err != nil {
+		return err
+	}
+	if err := writeJSONFile(path, s, 0600); err != nil {
+		return fmt.Errorf("failed to save chat: %w", err)
+	}
+	return nil
+}
+
+// LoadChatSession читает диалог из файла. ID всегда берётся из имени файла,
+// а не из JSON, — иначе чужой файл мог бы направить запись в другое место.
+func LoadChatSession(path string) (*ChatSession, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read chat: %w", err)
+	}
+	var s ChatSession
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", filepath.Base(path), err)
+	}
+	s.ID = strings.TrimSuffix(filepath.Base(path), ".json")
+	return &s, nil
+}
+
+// ListChatSessions возвращает диалоги каталога dir, от недавних к старым.
+// Повреждённые файлы пропускаются.
+func ListChatSessions(dir string) ([]ChatSessionInfo, error) {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to list chats: %w", err)
+	}
+
+	var list []ChatSessionInfo
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".json" {
+			continue
+		}
+		path := filepath.Join(dir, entry.Name())
+		s, err := LoadChatSession(path)
+		if err != nil {
+			continue
+		}
+		list = append(list, ChatSessionInfo{ID: s.ID, Title: s.Title, Updated: s.Updated, Turns: len(s.Turns), Path: path})
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Updated.After(list[j].Updated) })
+	return list, nil
+}
+
+// DeleteChatSession удаляет файл диалога
+func DeleteChatSession(path string) error {
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to delete chat: %w", err)
+	}
+	return nil
+}
+
+// ChatMarkdown формирует Markdown диалога; ответы вставляются как есть,
+// поэтому блоки кода сохраняются
+func ChatMarkdown(s *ChatSession) string {
+	var sb strings.Builder
+	title := s.Title
+	if title == "" {
+		title = "AI chat"
+	}
+	fmt.Fprintf(&sb, "# %s\n\n", title)
+	fmt.Fprintf(&sb, "_%s_\n", s.Created.Format("2006-01-02 15:04"))
+
+	for _, t := range s.Turns {
+		fmt.Fprintf(&sb, "\n## You (%s)\n\n%s\n", t.Time.Format("15:04:05"), strings.TrimSpace(t.ShownPrompt()))
+		who := "AI"
+		if t.Provider != "" {
+			who = t.Provider
+			if t.Model != "" {
+				who += " / " + t.Model
+			}
+		}
+		fmt.Fprintf(&sb, "\n## %s\n\n%s\n", who, strings.TrimSpace(t.Response))
+	}
+	return sb.String()
+}
@@ -0,0 +1,66 @@
+package logic
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestChatSessionPathRejectsTraversal(t *testing.T) {
+	dir := t.TempDir()
+	for _, id := range []string{"", "..", "../config", "../../.config/go-lite-ide/config", "a/b", `a\b`, "x..y"} {
+		if path, err := ChatSessionPath(dir, id); err == nil {
+			t.Errorf("id %q accepted: %s", id, path)
+		}
+	}
+	path, err := ChatSessionPath(dir, "20240101-120000-abcd1234")
+	if err != nil || path != filepath.Join(dir, "20240101-120000-abcd1234.json") {
+		t.Fatalf("path %q, err %v", path, err)
+	}
+}
+
+func TestLoadChatSessionTakesIDFromFileName(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "x.json")
+	data := `{"id":"../../../.config/go-lite-ide/config","title":"t","turns":[]}`
+	if err := os.WriteFile(path, []byte(data), 0600); err != nil {
+		t.Fatal(err)
+	}
+	s, err := LoadChatSession(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.ID != "x" {
+		t.Fatalf("ID = %q", s.ID)
+	}
+	s.AddTurn(ChatTurn{Prompt: "q", Response: "a"})
+	if err := SaveChatSession(dir, s); err != nil {
+		t.Fatal(err)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil || len(entries) != 1 || entries[0].Name() != "x.json" {
+		t.Fatalf("entries %v, err %v", entries, err)
+	}
+}
+
+func TestChatSessionsDirOutsideProject(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	root := t.TempDir()
+	dir, err := ChatSessionsDir(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rel, err := filepath.Rel(root, dir); err == nil && !strings.HasPrefix(rel, "..") {
+		t.Fatalf("chats are stored inside the project: %s", dir)
+	}
+	cfg, _ := ConfigDir()
+	if !strings.HasPrefix(dir, cfg+string(filepath.Separator)) {
+		t.Fatalf("chats dir %s is outside %s", dir, cfg)
+	}
+	other, _ := ChatSessionsDir(filepath.Join(root, "other"))
+	again, _ := ChatSessionsDir(root + string(filepath.Separator))
+	if other == dir || again != dir {
+		t.Fatalf("dirs: %s, %s, %s", dir, other, again)
+	}
+}
@@ -0,0 +1,508 @@
+package logic
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const (
+	// codeIndexFileName — индекс кода в каталоге .golite проекта
+	codeIndexFileName = "index.json"
+	// maxChunkLines — объявления длиннее делятся на части
+	maxChunkLines = 80
+	// maxEmbedText — сколько байт фрагмента отправляется модели эмбеддингов
+	maxEmbedText = 4000
+	// codeIndexSaveEvery — при построении индекс сохраняется после стольких файлов
+	codeIndexSaveEvery = 20
+	// DefaultIndexTopK — сколько фрагментов индекса добавляется к вопросу
+	DefaultIndexTopK = 5
+)
+
+// Vector — эмбеддинг; в JSON хранится как base64 от float32 little-endian
+type Vector []float32
+
+func (v Vector) MarshalJSON() ([]byte, error) {
+	buf := make([]byte, 4*len(v))
+	for i, f := range v {
+		binary.LittleEndian.PutUint32(buf[4*i:], math.Float32bits(f))
+	}
+	return json.Marshal(base64.StdEncoding.EncodeToString(buf))
+}
+
+func (v *Vector) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	buf, err := base64.StdEncoding.DecodeString(s)
+	if err != nil || len(buf)%4 != 0 {
+		return errors.New("invalid vector encoding")
+	}
+	*v = make(Vector, len(buf)/4)
+	for i := range *v {
+		(*v)[i] = math.Float32frombits(binary.LittleEndian.Uint32(buf[4*i:]))
+	}
+	return nil
+}
+
+// CodeChunk — фрагмент Go-файла в индексе: объявление или часть длинного объявления.
+// Текст в индекс не сохраняется (там только строки и хэш), он читается из файла при поиске.
+type CodeChunk struct {
+	Name   string `json:"name"`
+	Start  int    `json:"start"` // первая строка (с 1)
+	End    int    `json:"end"`   // последняя строка
+	Hash   string `json:"hash"`  // sha256 текста
+	Text   string `json:"text,omitempty"`
+	Vector Vector `json:"vector,omitempty"`
+}
+
+// CodeHit — найденный фрагмент с путём относительно корня проекта
+type CodeHit struct {
+	File  string
+	Chunk CodeChunk
+	Score float64 // косинусное сходство с вопросом
+}
+
+// Location — "path:start-end"
+func (h CodeHit) Location() string {
+	return fmt.Sprintf("%s:%d-%d", h.File, h.Chunk.Start, h.Chunk.End)
+}
+
+// indexedFile — фрагменты одного файла и хэш текста, по которому они построены
+type indexedFile struct {
+	Hash   string      `json:"hash"`
+	Chunks []CodeChunk `json:"chunks"`
+}
+
+// CodeIndex — семантический индекс Go-файлов проекта (<root>/.golite/index.json).
+// Векторы вычисляются моделью эмбеддингов; индекс с другой моделью не используется.
+type CodeIndex struct {
+	Provider string                  `json:"provider"`
+	Model    string                  `json:"model"`
+	Files    map[string]*indexedFile `json:"files"` // по пути относительно корня через "/"
+
+	root     string
+	mu       sync.Mutex // данные индекса
+	updateMu sync.Mutex // одно обновление за раз
+}
+
+// CodeIndexPath — файл индекса проекта
+func CodeIndexPath(root string) string {
+	return filepath.Join(root, ProjectConfigDir, codeIndexFileName)
+}
+
+// LoadCodeIndex читает индекс проекта; если файла нет, возвращает пустой индекс.
+// Повреждённый файл тоже даёт пустой индекс вместе с ошибкой.
+func LoadCodeIndex(root string) (*CodeIndex, error) {
+	ix := &CodeIndex{root: root, Files: map[string]*indexedFile{}}
+	data, err := os.ReadFile(CodeIndexPath(root))
+	if errors.Is(err, os.ErrNotExist) {
+		return ix, nil
+	}
+	if err == nil {
+		err = json.Unmarshal(data, ix)
+	}
+	if err != nil || ix.Files == nil {
+		ix.Files = map[string]*indexedFile{}
+		if err != nil {
+			return ix, fmt.Errorf("code index ignored: %w", err)
+		}
+	}
+	// Прежний формат хранил текст фрагментов: он заменяется хэшем, файл переписывается
+	legacy := false
+	for _, f := range ix.Files {
+		for i := range f.Chunks {
+			if c := &f.Chunks[i]; c.Text != "" {
+				c.Hash, c.Text, legacy = chunkHash(c.Text), "", true
+			}
+		}
+	}
+	if legacy {
+		if err := ix.save(); err != nil {
+			return ix, err
+		}
+	}
+	return ix, nil
+}
+
+// chunkHash — хэш текста фрагмента
+func chunkHash(text string) string {
+	sum := sha256.Sum256([]byte(text))
+	return hex.EncodeToString(sum[:])
+}
+
+// Root — корень проекта индекса
+func (ix *CodeIndex) Root() string {
+	return ix.root
+}
+
+// Stats возвращает число файлов и фрагментов в индексе
+func (ix *CodeIndex) Stats() (files, chunks int) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	for _, f := range ix.Files {
+		chunks += len(f.Chunks)
+	}
+	return len(ix.Files), chunks
+}
+
+// BuiltWith сообщает, что индекс не пуст и построен моделью target
+// (только такой индекс можно обновлять по одному файлу и использовать для поиска)
+func (ix *CodeIndex) BuiltWith(target LLMTarget) bool {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	return len(ix.Files) > 0 && ix.Provider == target.Provider && ix.Model == target.Model
+}
+
+// Clear удаляет индекс с диска
+func (ix *CodeIndex) Clear() error {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	ix.Files = map[string]*indexedFile{}
+	ix.Provider, ix.Model = "", ""
+	if err := os.Remove(CodeIndexPath(ix.root)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+func (ix *CodeIndex) save() error {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	// Векторы и имена объявлений раскрывают код проекта, как и диалоги
+	return writeJSONFile(CodeIndexPath(ix.root), ix, 0600)
+}
+
+// Rebuild обновляет индекс по всем Go-файлам проекта: изменённые файлы
+// индексируются заново, удалённые убираются. progress получает число обработанных файлов.
+func (ix *CodeIndex) Rebuild(ctx context.Context, target LLMTarget, progress func(done, total int)) error {
+	paths, err := CodeIndexFiles(ctx, ix.root)
+	if err != nil {
+		return err
+	}
+	keep := map[string]bool{}
+	for _, p := range paths {
+		keep[projectRelPath(ix.root, p)] = true
+	}
+	ix.mu.Lock()
+	for rel := range ix.Files {
+		if !keep[rel] {
+			delete(ix.Files, rel)
+		}
+	}
+	ix.mu.Unlock()
+	return ix.Update(ctx, target, paths, progress)
+}
+
+// Update индексирует файлы paths (абсолютные пути), если их текст изменился.
+// Векторы неизменённых объявлений переиспользуются; отсутствующие файлы удаляются из индекса.
+// Если модель эмбеддингов сменилась, прежние векторы отбрасываются.
+func (ix *CodeIndex) Update(ctx context.Context, target LLMTarget, paths []string, progress func(done, total int)) error {
+	ix.updateMu.Lock()
+	defer ix.updateMu.Unlock()
+
+	ix.mu.Lock()
+	if ix.Provider != target.Provider || ix.Model != target.Model {
+		ix.Files = map[string]*indexedFile{}
+		ix.Provider, ix.Model = target.Provider, target.Model
+	}
+	ix.mu.Unlock()
+
+	changed := 0
+	for i, path := range paths {
+		if progress != nil {
+			progress(i, len(paths))
+		}
+		if err := ctx.Err(); err != nil {
+			ix.save()
+			return err
+		}
+		n, err := ix.updateFile(ctx, target, path)
+		if err != nil {
+			if changed > 0 {
+				ix.save()
+			}
+			return fmt.Errorf("%s: %w", projectRelPath(ix.root, path), err)
+		}
+		changed += n
+		if changed >= codeIndexSaveEvery {
+			if err := ix.save(); err != nil {
+				return err
+			}
+			changed = 0
+		}
+	}
+	if progress != nil {
+		progress(len(paths), len(paths))
+	}
+	return ix.save()
+}
+
+// updateFile переиндексирует один файл; возвращает 1, если индекс изменился
+func (ix *CodeIndex) updateFile(ctx context.Context, target LLMTarget, path string) (int, error) {
+	rel := projectRelPath(ix.root, path)
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		ix.mu.Lock()
+		defer ix.mu.Unlock()
+		if _, ok := ix.Files[rel]; ok {
+			delete(ix.Files, rel)
+			return 1, nil
+		}
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	sum := sha256.Sum256(data)
+	hash := hex.EncodeToString(sum[:])
+
+	ix.mu.Lock()
+	old := ix.Files[rel]
+	ix.mu.Unlock()
+	if old != nil && old.Hash == hash {
+		return 0, nil
+	}
+
+	// Векторы объявлений, текст которых не изменился, берутся из прежнего индекса
+	reuse := map[string]Vector{}
+	if old != nil {
+		for _, c := range old.Chunks {
+			reuse[c.Hash] = c.Vector
+		}
+	}
+	chunks := ChunkGoFile(string(data))
+	var texts []string
+	var missing []int
+	for i := range chunks {
+		if v, ok := reuse[chunks[i].Hash]; ok {
+			chunks[i].Vector = v
+		} else {
+			texts = append(texts, embedText(rel, chunks[i]))
+			missing = append(missing, i)
+		}
+		chunks[i].Text = ""
+	}
+	if len(texts) > 0 {
+		vectors, err := Embed(ctx, target.Provider, target.Model, target.APIKey, texts)
+		if err != nil {
+			return 0, err
+		}
+		for j, i := range missing {
+			chunks[i].Vector = vectors[j]
+		}
+	}
+
+	ix.mu.Lock()
+	ix.Files[rel] = &indexedFile{Hash: hash, Chunks: chunks}
+	ix.mu.Unlock()
+	return 1, nil
+}
+
+// embedText — текст фрагмента для модели эмбеддингов: путь и имя помогают поиску
+func embedText(rel string, c CodeChunk) string {
+	text := fmt.Sprintf("// %s: %s\n%s", rel, c.Name, c.Text)
+	if len(text) > maxEmbedText {
+		cut := maxEmbedText
+		for cut > 0 && text[cut]&0xC0 == 0x80 {
+			cut--
+		}
+		text = text[:cut]
+	}
+	return text
+}
+
+// Search возвращает k фрагментов, наиболее близких к вопросу query. Текст фрагментов
+// читается из файлов; фрагменты, изменившиеся после индексации, пропускаются.
+func (ix *CodeIndex) Search(ctx context.Context, target LLMTarget, query string, k int) ([]CodeHit, error) {
+	ix.mu.Lock()
+	provider, model, empty := ix.Provider, ix.Model, len(ix.Files) == 0
+	ix.mu.Unlock()
+	if empty {
+		return nil, errors.New("the code index is empty: build it with AI > Code Index > Rebuild Index")
+	}
+	if provider != target.Provider || model != target.Model {
+		return nil, fmt.Errorf("the code index was built with %s/%s: rebuild it for %s/%s", provider, model, target.Provider, target.Model)
+	}
+
+	vectors, err := Embed(ctx, target.Provider, target.Model, target.APIKey, []string{query})
+	if err != nil {
+		return nil, err
+	}
+	q := vectors[0]
+
+	ix.mu.Lock()
+	var hits []CodeHit
+	for rel, f := range ix.Files {
+		for _, c := range f.Chunks {
+			if score := cosine(q, c.Vector); score > 0 {
+				hits = append(hits, CodeHit{File: rel, Chunk: c, Score: score})
+			}
+		}
+	}
+	ix.mu.Unlock()
+
+	sort.Slice(hits, func(i, j int) bool { return hits[i].Score > hits[j].Score })
+	current := map[string]map[string]CodeChunk{} // фрагменты файлов на диске по хэшу
+	found := hits[:0]
+	for _, hit := range hits {
+		if len(found) == k {
+			break
+		}
+		chunks, ok := current[hit.File]
+		if !ok {
+			chunks = map[string]CodeChunk{}
+			if data, err := os.ReadFile(filepath.Join(ix.root, filepath.FromSlash(hit.File))); err == nil {
+				for _, c := range ChunkGoFile(string(data)) {
+					chunks[c.Hash] = c
+				}
+			}
+			current[hit.File] = chunks
+		}
+		if c, ok := chunks[hit.Chunk.Hash]; ok {
+			// Строки берутся из файла: объявление могло сдвинуться
+			hit.Chunk.Start, hit.Chunk.End, hit.Chunk.Text = c.Start, c.End, c.Text
+			found = append(found, hit)
+		}
+	}
+	return found, nil
+}
+
+// cosine — косинусное сходство векторов (0, если размерности различаются)
+func cosine(a, b Vector) float64 {
+	if len(a) != len(b) || len(a) == 0 {
+		return 0
+	}
+	var dot, na, nb float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		na += float64(a[i]) * float64(a[i])
+		nb += float64(b[i]) * float64(b[i])
+	}
+	if na == 0 || nb == 0 {
+		return 0
+	}
+	return dot / math.Sqrt(na*nb)
+}
+
+// CodeIndexFiles перечисляет Go-файлы проекта для индекса
+// (без скрытых каталогов, vendor и node_modules)
+func CodeIndexFiles(ctx context.Context, root string) ([]string, error) {
+	var paths []string
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if d.IsDir() {
+			if path != root && (agentSkipDirs[d.Name()] || strings.HasPrefix(d.Name(), ".")) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if IsCodeIndexFile(path) {
+			if info, err := d.Info(); err == nil && info.Size() <= maxSearchFileSize {
+				paths = append(paths, path)
+			}
+		}
+		return nil
+	})
+	return paths, err
+}
+
+// IsCodeIndexFile сообщает, индексируется ли файл
+func IsCodeIndexFile(path string) bool {
+	return strings.HasSuffix(path, ".go")
+}
+
+// ChunkGoFile делит Go-файл на фрагменты по объявлениям верхнего уровня (с doc-комментарием);
+// импорты пропускаются, длинные объявления делятся на части. Файл, который не удалось
+// разобрать, делится на части по строкам.
+func ChunkGoFile(src string) []CodeChunk {
+	lines := SplitLines(src)
+	fset := token.NewFileSet()
+	file, _ := parser.ParseFile(fset, "", src, parser.ParseComments)
+	if file == nil || len(file.Decls) == 0 {
+		return splitChunk(lines, "code", 1, len(lines))
+	}
+
+	var chunks []CodeChunk
+	for _, decl := range file.Decls {
+		if d, ok := decl.(*ast.GenDecl); ok && d.Tok == token.IMPORT {
+			continue
+		}
+		if _, ok := decl.(*ast.BadDecl); ok {
+			continue
+		}
+		start, _ := goDeclSpan(fset, decl)
+		first := strings.Count(src[:start], "\n") + 1
+		last := fset.Position(decl.End()).Line
+		chunks = append(chunks, splitChunk(lines, chunkName(decl), first, last)...)
+	}
+	return chunks
+}
+
+// splitChunk возвращает строки first..last одним фрагментом или частями по maxChunkLines
+func splitChunk(lines []string, name string, first, last int) []CodeChunk {
+	last = min(last, len(lines))
+	var chunks []CodeChunk
+	for start := first; start <= last; start += maxChunkLines {
+		end := min(start+maxChunkLines-1, last)
+		text := strings.Join(lines[start-1:end], "\n")
+		if strings.TrimSpace(text) == "" {
+			continue
+		}
+		part := name
+		if last-first+1 > maxChunkLines {
+			part = fmt.Sprintf("%s (part %d)", name, (start-first)/maxChunkLines+1)
+		}
+		chunks = append(chunks, CodeChunk{Name: part, Start: start, End: end, Hash: chunkHash(text), Text: text})
+	}
+	return chunks
+}
+
+// chunkName — имя фрагмента: "func Name", "func (T) Name", "type A, B", "const X, ..."
+func chunkName(decl ast.Decl) string {
+	switch d := decl.(type) {
+	case *ast.FuncDecl:
+		if d.Recv != nil && len(d.Recv.List) > 0 {
+			return "func (" + receiverTypeName(d.Recv.List[0].Type) + ") " + d.Name.Name
+		}
+		return "func " + d.Name.Name
+	case *ast.GenDecl:
+		var names []string
+		for _, spec := range d.Specs {
+			switch s := spec.(type) {
+			case *ast.TypeSpec:
+				names = append(names, s.Name.Name)
+			case *ast.ValueSpec:
+				for _, n := range s.Names {
+					names = append(names, n.Name)
+				}
+			}
+		}
+		if len(names) > 3 {
+			names = append(names[:3], "...")
+		}
+		return d.Tok.String() + " " + strings.Join(names, ", ")
+	}
+	return "code"
+}
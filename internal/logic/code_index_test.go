@@ -0,0 +1,43 @@
+package logic
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCodeIndexStoresNoCode(t *testing.T) {
+	root := t.TempDir()
+	path := CodeIndexPath(root)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	// Индекс прежнего формата: с текстом фрагментов и доступный всем
+	legacy := `{"provider": "ollama", "model": "m", "files": {"main.go": {"hash": "x",
+		"chunks": [{"name": "func secret", "start": 3, "end": 5, "text": "func secret() string { return \"s3cr3t\" }"}]}}}`
+	if err := os.WriteFile(path, []byte(legacy), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	ix, err := LoadCodeIndex(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := ix.Files["main.go"].Chunks[0]
+	if c.Text != "" || c.Hash != chunkHash(`func secret() string { return "s3cr3t" }`) {
+		t.Fatalf("chunk after load: %+v", c)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "s3cr3t") {
+		t.Fatalf("code is still stored in the index:\n%s", data)
+	}
+	fi, _ := os.Stat(path)
+	if perm := fi.Mode().Perm(); perm != 0600 {
+		t.Fatalf("index mode = %#o, want 0600", perm)
+	}
+}
@@ -0,0 +1,372 @@
+package logic
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	// maxReviewDiffBytes — больший diff обрезается
+	maxReviewDiffBytes = 60000
+	// maxReviewContextBytes — объём кода изменённых функций в запросе ревью
+	maxReviewContextBytes = 60000
+	// maxReviewFindings — сколько замечаний из ответа показывается
+	maxReviewFindings = 100
+)
+
+// GitChanges — незакоммиченные изменения проекта: staged и unstaged вместе,
+// относительно последнего коммита (в новом репозитории — относительно пустого дерева).
+// Новые файлы, не добавленные в git, входят в diff как добавленные целиком.
+type GitChanges struct {
+	Root      string
+	Diff      string
+	Truncated bool             // Diff обрезан до maxReviewDiffBytes
+	Files     []string         // изменённые файлы (абсолютные пути, без удалённых), в порядке diff
+	Lines     map[string][]int // добавленные и изменённые строки рабочего дерева по файлам
+	Untracked int              // сколько новых файлов не из git вошло в diff
+	Skipped   []string         // новые файлы, не вошедшие в diff: двоичные или слишком большие
+}
+
+// LoadGitChanges выполняет git diff в каталоге проекта root (пути в diff — относительно него)
+func LoadGitChanges(ctx context.Context, root string) (*GitChanges, error) {
+	if _, err := runGit(ctx, root, nil, "rev-parse", "--show-toplevel"); err != nil {
+		return nil, err
+	}
+	base := "HEAD"
+	if _, err := runGit(ctx, root, nil, "rev-parse", "--verify", "-q", "HEAD"); err != nil {
+		// Коммитов ещё нет: сравниваем с пустым деревом (его хеш зависит от формата репозитория)
+		out, err := runGit(ctx, root, strings.NewReader(""), "hash-object", "-t", "tree", "--stdin")
+		if err != nil {
+			return nil, err
+		}
+		base = strings.TrimSpace(out)
+	}
+	diff, err := runGit(ctx, root, nil, "-c", "core.quotepath=off", "diff", "--no-color", "--no-ext-diff", "--relative", "-U3", base)
+	if err != nil {
+		return nil, err
+	}
+
+	ch := &GitChanges{Root: root, Lines: map[string][]int{}}
+	untracked, err := runGit(ctx, root, nil, "ls-files", "-z", "--others", "--exclude-standard")
+	if err != nil {
+		return nil, err
+	}
+	var sb strings.Builder
+	sb.WriteString(diff)
+	for _, name := range strings.Split(untracked, "\x00") {
+		// .golite — служебный каталог редактора (индекс, настройки проекта)
+		if name == "" || strings.HasPrefix(name, ProjectConfigDir+"/") {
+			continue
+		}
+		if d, ok := untrackedFileDiff(root, name); ok {
+			sb.WriteString(d)
+			ch.Untracked++
+		} else {
+			ch.Skipped = append(ch.Skipped, name)
+		}
+	}
+	diff = sb.String()
+	ch.Diff = diff
+
+	for _, f := range parseDiffLines(diff) {
+		path := filepath.Join(root, filepath.FromSlash(f.name))
+		ch.Files = append(ch.Files, path)
+		ch.Lines[path] = f.lines
+	}
+	if len(diff) > maxReviewDiffBytes {
+		cut := strings.LastIndex(diff[:maxReviewDiffBytes], "\n") + 1
+		ch.Diff, ch.Truncated = diff[:cut], true
+	}
+	return ch, nil
+}
+
+// untrackedFileDiff — diff нового файла name (путь от root через "/") как добавленного целиком,
+// в том виде, в каком его показал бы git после "git add -N". Двоичные и большие файлы
+// не включаются (ok == false).
+func untrackedFileDiff(root, name string) (string, bool) {
+	path := filepath.Join(root, filepath.FromSlash(name))
+	info, err := os.Lstat(path)
+	if err != nil || !info.Mode().IsRegular() || info.Size() > maxSearchFileSize {
+		return "", false
+	}
+	data, err := os.ReadFile(path)
+	if err != nil || bytes.IndexByte(data, 0) >= 0 {
+		return "", false
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "diff --git a/%s b/%s\nnew file mode 100644\n", name, name)
+	if len(data) == 0 {
+		return sb.String(), true
+	}
+	text := string(data)
+	noEOL := !strings.HasSuffix(text, "\n")
+	lines := strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+	fmt.Fprintf(&sb, "--- /dev/null\n+++ b/%s\n@@ -0,0 +1,%d @@\n", name, len(lines))
+	for _, l := range lines {
+		sb.WriteString("+" + l + "\n")
+	}
+	if noEOL {
+		sb.WriteString("\\ No newline at end of file\n")
+	}
+	return sb.String(), true
+}
+
+// runGit запускает git в dir и возвращает stdout; при ошибке — с текстом stderr
+func runGit(ctx context.Context, dir string, stdin *strings.Reader, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = dir
+	if stdin != nil {
+		cmd.Stdin = stdin
+	}
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			msg, _, _ = strings.Cut(msg, "\n")
+			return "", fmt.Errorf("git %s: %s", args[0], msg)
+		}
+		if errors.Is(err, exec.ErrNotFound) {
+			return "", errors.New("git is not installed or not in PATH")
+		}
+		return "", fmt.Errorf("git %s: %w", args[0], err)
+	}
+	return stdout.String(), nil
+}
+
+// diffFile — изменённые строки одного файла unified diff
+type diffFile struct {
+	name  string
+	lines []int
+}
+
+// hunkHeaderRe — заголовок участка: "@@ -12,5 +12,7 @@"
+var hunkHeaderRe = regexp.MustCompile(`^@@ -\d+(?:,(\d+))? \+(\d+)(?:,(\d+))? @@`)
+
+// parseDiffLines находит в diff файлы и номера строк новой версии: добавленные строки,
+// а для участков только с удалениями — строку, на месте которой было удаление.
+// Строки участка отсчитываются по заголовку "@@", поэтому удалённая строка "-- x"
+// или добавленная "++ x" не принимаются за заголовки файла.
+func parseDiffLines(diff string) []diffFile {
+	var files []diffFile
+	var cur *diffFile
+	line, changed := 0, false
+	oldLeft, newLeft := 0, 0 // строк участка осталось прочитать
+	afterOld := false        // предыдущая строка — заголовок "--- "
+	for _, l := range SplitLines(diff) {
+		if oldLeft > 0 || newLeft > 0 {
+			switch {
+			case strings.HasPrefix(l, "+"):
+				cur.lines = append(cur.lines, line)
+				line++
+				newLeft--
+				changed = true
+			case strings.HasPrefix(l, "-"):
+				if !changed {
+					cur.lines = append(cur.lines, max(line, 1))
+					changed = true
+				}
+				oldLeft--
+			case strings.HasPrefix(l, " "), l == "":
+				line++
+				oldLeft--
+				newLeft--
+				changed = false
+			}
+			continue
+		}
+
+		prevOld := afterOld
+		afterOld = false
+		switch {
+		case strings.HasPrefix(l, "diff --git "):
+			cur = nil
+		case strings.HasPrefix(l, "--- "):
+			afterOld = true
+		case strings.HasPrefix(l, "+++ ") && prevOld:
+			name := strings.TrimPrefix(l, "+++ ")
+			if name == "/dev/null" {
+				cur = nil // файл удалён
+				continue
+			}
+			files = append(files, diffFile{name: strings.TrimPrefix(name, "b/")})
+			cur = &files[len(files)-1]
+		case cur != nil && strings.HasPrefix(l, "@@"):
+			m := hunkHeaderRe.FindStringSubmatch(l)
+			if m == nil {
+				continue
+			}
+			line, _ = strconv.Atoi(m[2])
+			oldLeft, newLeft = hunkCount(m[1]), hunkCount(m[3])
+			changed = false
+		}
+	}
+	for i := range files {
+		files[i].lines = uniqueSorted(files[i].lines)
+	}
+	return files
+}
+
+// hunkCount — число строк из заголовка участка (без числа — одна строка)
+func hunkCount(s string) int {
+	if s == "" {
+		return 1
+	}
+	n, _ := strconv.Atoi(s)
+	return n
+}
+
+func uniqueSorted(lines []int) []int {
+	sort.Ints(lines)
+	out := lines[:0]
+	for i, n := range lines {
+		if i == 0 || n != lines[i-1] {
+			out = append(out, n)
+		}
+	}
+	return out
+}
+
+// ReviewPrompt составляет запрос ревью: diff и текущий код изменённых функций
+// (для Go — объявления целиком, для остальных файлов — строки вокруг изменений)
+func ReviewPrompt(ch *GitChanges) string {
+	var sb strings.Builder
+	sb.WriteString(`Review the following uncommitted changes (staged, unstaged and new files, against the last commit).
+Look for bugs, unhandled errors, race conditions, resource leaks, security problems and unclear code
+introduced by the change. Comment on unchanged code only if the change breaks it.
+
+Diff:
+` + "```diff\n")
+	sb.WriteString(ch.Diff)
+	if ch.Truncated {
+		sb.WriteString("... (diff truncated)\n")
+	}
+	sb.WriteString("```\n\nCurrent code of the changed functions (the numbers on the left are line numbers, not part of the code):\n")
+
+	size, skipped := 0, 0
+	for _, file := range ch.Files {
+		info, err := os.Stat(file)
+		if err != nil || info.IsDir() || info.Size() > maxSearchFileSize {
+			continue
+		}
+		data, err := os.ReadFile(file)
+		if err != nil || bytes.IndexByte(data, 0) >= 0 {
+			continue
+		}
+		text := string(data)
+		block := fmt.Sprintf("\nFile: %s\n```%s\n%s```\n", projectRelPath(ch.Root, file), fenceLanguage(file),
+			numberedRanges(SplitLines(text), buildFixRanges(file, text, ch.Lines[file])))
+		if size+len(block) > maxReviewContextBytes {
+			skipped++
+			continue
+		}
+		size += len(block)
+		sb.WriteString(block)
+	}
+	if skipped > 0 {
+		fmt.Fprintf(&sb, "\n(%d more changed file(s) omitted)\n", skipped)
+	}
+
+	sb.WriteString(`
+Write each finding on its own line, without code blocks, in the form
+<path>:<line>: <severity>: <comment>
+where path is the file path as in the diff, line is a line number in the current code above and
+severity is one of error, warning, suggestion. Keep each comment to one or two sentences.
+After the findings write one line "Summary: <overall assessment>". If there is nothing to report,
+write only the summary.
+`)
+	return sb.String()
+}
+
+// ReviewFinding — замечание ревью к строке файла
+type ReviewFinding struct {
+	File     string // абсолютный путь
+	Line     int
+	Severity string // error, warning или suggestion
+	Message  string
+}
+
+// Location — "path:line" относительно root
+func (f ReviewFinding) Location(root string) string {
+	return fmt.Sprintf("%s:%d", projectRelPath(root, f.File), f.Line)
+}
+
+var (
+	// findingRe — строка замечания: "- **x.go:12**: warning: ..." (маркеры списка и выделение допускаются)
+	findingRe = regexp.MustCompile("^\\s*(?:[-*•]|\\d+[.)])?\\s*[*`]*([^\\s:*`]+\\.\\w+):(\\d+)(?:[-–]\\d+)?(?::\\d+)?[*`]*\\s*[:—–-]?\\s*(.+)$")
+	// severityRe — уровень в начале комментария: "warning:", "[error]", "**suggestion** -"
+	severityRe = regexp.MustCompile(`(?i)^[\[*(]*(error|bug|warning|suggestion|note|info|nit)[\])*]*\s*[:—–-]?\s*`)
+	// summaryRe — итоговая строка ответа
+	summaryRe = regexp.MustCompile(`(?i)^[\s#*]*summary[*]*\s*:[*]*\s*(.*)$`)
+)
+
+// ParseReviewFindings извлекает из ответа замечания к файлам проекта root и итог ревью.
+// Путь ищется от root, а если такого файла нет — по имени среди изменённых files.
+func ParseReviewFindings(resp, root string, files []string) ([]ReviewFinding, string) {
+	var findings []ReviewFinding
+	var summary []string
+	inSummary := false
+	for _, line := range SplitLines(resp) {
+		if m := summaryRe.FindStringSubmatch(line); m != nil {
+			inSummary = true
+			summary = append(summary, strings.TrimSpace(m[1]))
+			continue
+		}
+		m := findingRe.FindStringSubmatch(line)
+		if m == nil {
+			// Итог может продолжаться на следующих строках
+			if inSummary && strings.TrimSpace(line) != "" && !strings.HasPrefix(strings.TrimSpace(line), "```") {
+				summary = append(summary, strings.TrimSpace(line))
+			}
+			continue
+		}
+		inSummary = false
+		file := resolveReviewPath(m[1], root, files)
+		lineNo, _ := strconv.Atoi(m[2])
+		if file == "" || lineNo <= 0 || len(findings) == maxReviewFindings {
+			continue
+		}
+
+		f := ReviewFinding{File: file, Line: lineNo, Severity: "warning", Message: strings.TrimSpace(m[3])}
+		if s := severityRe.FindStringSubmatch(f.Message); s != nil {
+			switch strings.ToLower(s[1]) {
+			case "error", "bug":
+				f.Severity = "error"
+			case "suggestion", "note", "info", "nit":
+				f.Severity = "suggestion"
+			}
+			f.Message = strings.TrimSpace(f.Message[len(s[0]):])
+		}
+		f.Message = strings.Trim(f.Message, "* ")
+		if f.Message != "" {
+			findings = append(findings, f)
+		}
+	}
+	return findings, strings.TrimSpace(strings.Join(summary, " "))
+}
+
+func resolveReviewPath(name, root string, files []string) string {
+	name = strings.TrimPrefix(filepath.ToSlash(name), "./")
+	// Модель может скопировать путь с префиксом из заголовка diff ("b/x.go")
+	for _, n := range []string{name, strings.TrimPrefix(name, "b/")} {
+		if p := filepath.Join(root, filepath.FromSlash(n)); fileExists(p) {
+			return p
+		}
+	}
+	for _, f := range files {
+		if filepath.Base(f) == filepath.Base(name) {
+			return f
+		}
+	}
+	return ""
+}
@@ -0,0 +1,205 @@
+package logic
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// reviewDiff — вывод git diff: изменённый Go-файл, SQL-файл, в котором удаляется
+// строка "-- x" и добавляется "++ y", новый и удалённый файлы
+const reviewDiff = `diff --git a/internal/store/store.go b/internal/store/store.go
+index 3b18e51..a7c2f0d 100644
+--- a/internal/store/store.go
++++ b/internal/store/store.go
+@@ -10,7 +10,8 @@ func Open(path string) (*Store, error) {
+ 	if err != nil {
+ 		return nil, err
+ 	}
+-	defer f.Close()
++	s := &Store{f: f}
++	go s.flushLoop()
+ 	return s, nil
+ }
+
+@@ -40,4 +41,3 @@ func (s *Store) Close() error {
+ 	s.mu.Lock()
+-	s.closed = true
+ 	s.mu.Unlock()
+ 	return s.f.Close()
+diff --git a/schema.sql b/schema.sql
+index 1111111..2222222 100644
+--- a/schema.sql
++++ b/schema.sql
+@@ -1,3 +1,3 @@
+ CREATE TABLE t (id INT);
+--- old comment
++++ not a header
+ CREATE INDEX i ON t (id);
+diff --git a/cmd/tool/main.go b/cmd/tool/main.go
+new file mode 100644
+index 0000000..e69de29
+--- /dev/null
++++ b/cmd/tool/main.go
+@@ -0,0 +1,3 @@
++package main
++
++func main() {}
+diff --git a/old.go b/old.go
+deleted file mode 100644
+index e69de29..0000000
+--- a/old.go
++++ /dev/null
+@@ -1,2 +0,0 @@
+-package old
+-
+`
+
+func TestParseDiffLines(t *testing.T) {
+	var got []string
+	for _, f := range parseDiffLines(reviewDiff) {
+		got = append(got, fmt.Sprintf("%s %v", f.name, f.lines))
+	}
+	want := []string{
+		"internal/store/store.go [13 14 42]",
+		"schema.sql [2]",
+		"cmd/tool/main.go [1 2 3]",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("got:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestParseDiffLinesIgnoresPlusLinesOutsideHeaders(t *testing.T) {
+	// "+++ " без предшествующего "--- " — не заголовок файла
+	diff := "diff --git a/a.go b/a.go\nBinary files differ\n+++ b/evil.go\n@@ -1 +1 @@\n+x\n"
+	if files := parseDiffLines(diff); len(files) != 0 {
+		t.Fatalf("files: %v", files)
+	}
+}
+
+func TestLoadGitChangesIncludesUntrackedFiles(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	root := t.TempDir()
+	git := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-c", "user.name=t", "-c", "user.email=t@t", "-c", "commit.gpgsign=false"}, args...)...)
+		cmd.Dir = root
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+	write := func(name, text string) {
+		t.Helper()
+		path := filepath.Join(root, filepath.FromSlash(name))
+		os.MkdirAll(filepath.Dir(path), 0755)
+		if err := os.WriteFile(path, []byte(text), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	git("init", "-q")
+	write("main.go", "package main\n\nfunc main() {}\n")
+	write(".gitignore", "*.log\n")
+	git("add", ".")
+	git("commit", "-q", "-m", "init")
+
+	write("main.go", "package main\n\nfunc main() { run() }\n")
+	write("run.go", "package main\n\nfunc run() {}")
+	write("debug.log", "ignored\n")
+	write("data.bin", "\x00\x01")
+	write(".golite/index.json", "{}")
+
+	ch, err := LoadGitChanges(context.Background(), root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var files []string
+	for _, f := range ch.Files {
+		files = append(files, projectRelPath(root, f))
+	}
+	if strings.Join(files, ",") != "main.go,run.go" || ch.Untracked != 1 {
+		t.Fatalf("files %v, untracked %d", files, ch.Untracked)
+	}
+	if strings.Join(ch.Skipped, ",") != "data.bin" {
+		t.Fatalf("skipped %v", ch.Skipped)
+	}
+	if lines := ch.Lines[filepath.Join(root, "run.go")]; fmt.Sprint(lines) != "[1 2 3]" {
+		t.Fatalf("run.go lines %v", lines)
+	}
+	if !strings.Contains(ch.Diff, "+func run() {}\n\\ No newline at end of file\n") {
+		t.Fatalf("diff:\n%s", ch.Diff)
+	}
+}
+
+func TestParseReviewFindings(t *testing.T) {
+	root := buildProject(t, map[string]string{
+		"internal/store/store.go": "package store\n",
+		"cmd/tool/main.go":        "package main\n",
+	})
+	store := filepath.Join(root, "internal", "store", "store.go")
+	tool := filepath.Join(root, "cmd", "tool", "main.go")
+	files := []string{store, tool}
+
+	tests := []struct {
+		name, resp string
+		want       []string
+		summary    string
+	}{
+		{
+			name: "requested format",
+			resp: "internal/store/store.go:14: error: flushLoop is never stopped, Close leaks the goroutine.\n" +
+				"cmd/tool/main.go:3: suggestion: main does nothing yet.\n" +
+				"Summary: One leak, otherwise fine.\n",
+			want: []string{
+				"internal/store/store.go:14 error flushLoop is never stopped, Close leaks the goroutine.",
+				"cmd/tool/main.go:3 suggestion main does nothing yet.",
+			},
+			summary: "One leak, otherwise fine.",
+		},
+		{
+			name: "markdown list",
+			resp: "## Findings\n\n" +
+				"- **internal/store/store.go:42** — **[warning]** `closed` is no longer set.\n" +
+				"1. `b/cmd/tool/main.go:1-3`: nit: add a package comment\n\n" +
+				"**Summary:** Mostly good.\nThe leak should be fixed first.\n",
+			want: []string{
+				"internal/store/store.go:42 warning `closed` is no longer set.",
+				"cmd/tool/main.go:1 suggestion add a package comment",
+			},
+			summary: "Mostly good. The leak should be fixed first.",
+		},
+		{
+			name: "base name and unknown files",
+			resp: "store.go:13:2: bug: the file is closed twice\nother.go:5: warning: not in the change\n" +
+				"README.md:0: warning: no line\nSummary: Needs work.",
+			want:    []string{"internal/store/store.go:13 error the file is closed twice"},
+			summary: "Needs work.",
+		},
+		{
+			name:    "nothing to report",
+			resp:    "Summary: No problems found.",
+			summary: "No problems found.",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			findings, summary := ParseReviewFindings(tt.resp, root, files)
+			var got []string
+			for _, f := range findings {
+				got = append(got, f.Location(root)+" "+f.Severity+" "+f.Message)
+			}
+			if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Fatalf("findings:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+			}
+			if summary != tt.summary {
+				t.Fatalf("summary %q, want %q", summary, tt.summary)
+			}
+		})
+	}
+}
@@ -0,0 +1,464 @@
+package logic
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// configDirName — каталог приложения внутри пользовательского каталога настроек (~/.config)
+	configDirName = "go-lite-ide"
+	// configFileName — имя файла глобальных настроек
+	configFileName = "config.json"
+
+	// ProjectConfigDir — каталог настроек проекта в его корне
+	ProjectConfigDir = ".golite"
+)
+
+// Config — настройки редактора, сохраняемые между запусками.
+// Глобальный файл: ~/.config/go-lite-ide/config.json,
+// переопределения проекта: <project>/.golite/config.json (только поля projectConfig).
+type Config struct {
+	// LLM
+	Provider string `json:"provider"`
+	Model    string `json:"model"`
+	APIKey   string `json:"api_key,omitempty"`
+
+	// Именованные профили провайдеров (AI > Provider)
+	Profiles []ProviderProfile `json:"profiles,omitempty"`
+
+	// Повторы при временных ошибках и запасные провайдеры
+	LLMMaxRetries       int                `json:"llm_max_retries"`
+	LLMRetryBaseDelayMs int                `json:"llm_retry_base_delay_ms"`
+	LLMRetryMaxDelayMs  int                `json:"llm_retry_max_delay_ms"`
+	LLMFallbacks        []FallbackProvider `json:"llm_fallbacks,omitempty"`
+
+	// Провайдер/модель/таймаут для отдельных видов запросов (chat, line, multiline, comment);
+	// провайдер и модель чата — поля provider и model выше
+	Tasks map[AITask]TaskModel `json:"ai_tasks,omitempty"`
+
+	// Последняя выбранная модель для каждого провайдера (AI dock > Model)
+	LastModels map[string]string `json:"last_models,omitempty"`
+
+	// Шаблон системного промпта ("" — встроенный DefaultSystemPrompt); для проекта
+	// его заменяет <project>/.golite/prompt.md
+	SystemPrompt string `json:"system_prompt,omitempty"`
+
+	// Окно контекста моделей в токенах (дополняет встроенную таблицу, см. ContextLimit)
+	ModelContextLimits map[string]int `json:"model_context_limits,omitempty"`
+
+	// Модели, принимающие картинки (дополняет встроенный список, см. ModelSupportsVision)
+	VisionModels []string `json:"vision_models,omitempty"`
+
+	// Маскирование секретов в контексте перед отправкой провайдерам не из redact_allow_providers
+	RedactSecrets        bool            `json:"redact_secrets"`
+	RedactPatterns       []RedactPattern `json:"redact_patterns,omitempty"`
+	RedactAllowProviders []string        `json:"redact_allow_providers"`
+
+	// Кэш ответов на одинаковые запросы дополнения кода
+	ResponseCache           bool `json:"response_cache"`
+	ResponseCacheTTLSec     int  `json:"response_cache_ttl_sec"`
+	ResponseCacheMaxEntries int  `json:"response_cache_max_entries"`
+	ResponseCachePersist    bool `json:"response_cache_persist"`
+
+	// Журнал запросов к LLM (JSON Lines); пустой путь — requests.jsonl в каталоге настроек
+	RequestLog     bool   `json:"request_log"`
+	RequestLogPath string `json:"request_log_path,omitempty"`
+
+	// Editor
+	ColorScheme     string `json:"color_scheme"`
+	CursorStyle     string `json:"cursor_style"`
+	ShowLineNumbers bool   `json:"show_line_numbers"`
+
+	// AI Assistant
+	AIHistoryContextSize   int  `json:"ai_history_context_size"`
+	AIUseOpenTabsAsContext bool `json:"ai_use_open_tabs_context"`
+	AILineCompleteEnabled  bool `json:"ai_line_complete_enabled"`
+	AIAutoCompleteEnabled  bool `json:"ai_auto_complete_enabled"`
+	AIFIMEnabled           bool `json:"ai_fim_enabled"`  // fill-in-the-middle для кодовых моделей
+	AIDeclContext          bool `json:"ai_decl_context"` // объявления Go, на которые ссылается код у курсора
+	AICodeIndex            bool `json:"ai_code_index"`   // фрагменты из семантического индекса проекта в чате
+
+	// Run
+	RunArgs string `json:"run_args"`
+
+	path string // файл, из которого загружена (и куда сохраняется) конфигурация
+}
+
+// ConfigOverrides — значения, заданные флагами командной строки.
+// Пустые поля означают "не задано"; заданные имеют приоритет над файлами настроек
+// и не записываются обратно в config.json.
+type ConfigOverrides struct {
+	Provider string
+	Model    string
+	APIKey   string
+
+	// Настройки видов запросов (--line-model, --chat-timeout, ...)
+	Tasks map[AITask]TaskModel
+}
+
+// DefaultConfig возвращает настройки по умолчанию (совпадают с прежними значениями флагов)
+func DefaultConfig() *Config {
+	return &Config{
+		Provider:             "ollama",
+		Model:                "gemma:2b",
+		ColorScheme:          "Monokai",
+		CursorStyle:          "Block",
+		AIHistoryContextSize: 3,
+		AIFIMEnabled:         true,
+		AIDeclContext:        true,
+
+		ResponseCache:           DefaultResponseCacheSettings.Enabled,
+		ResponseCacheTTLSec:     int(DefaultResponseCacheSettings.TTL / time.Second),
+		ResponseCacheMaxEntries: DefaultResponseCacheSettings.MaxEntries,
+
+		RedactSecrets:        true,
+		RedactAllowProviders: append([]string(nil), DefaultRedactAllowProviders...),
+
+		LLMMaxRetries:       DefaultRetryPolicy.MaxRetries,
+		LLMRetryBaseDelayMs: int(DefaultRetryPolicy.BaseDelay / time.Millisecond),
+		LLMRetryMaxDelayMs:  int(DefaultRetryPolicy.MaxDelay / time.Millisecond),
+	}
+}
+
+// Redaction возвращает настройки маскирования секретов
+func (c *Config) Redaction() RedactionSettings {
+	return RedactionSettings{
+		Enabled:        c.RedactSecrets,
+		Patterns:       c.RedactPatterns,
+		AllowProviders: c.RedactAllowProviders,
+	}
+}
+
+// ResponseCacheSettings возвращает настройки кэша ответов
+func (c *Config) ResponseCacheSettings() ResponseCacheSettings {
+	s := DefaultResponseCacheSettings
+	s.Enabled = c.ResponseCache
+	s.TTL = time.Duration(c.ResponseCacheTTLSec) * time.Second
+	s.MaxEntries = c.ResponseCacheMaxEntries
+	s.Persist = c.ResponseCachePersist
+	return s
+}
+
+// RequestLogFile возвращает путь журнала запросов или "", если журнал выключен
+func (c *Config) RequestLogFile() string {
+	if !c.RequestLog {
+		return ""
+	}
+	if c.RequestLogPath != "" {
+		return c.RequestLogPath
+	}
+	path, _ := DefaultRequestLogPath()
+	return path
+}
+
+// RetryPolicy возвращает политику повторов из настроек
+func (c *Config) RetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxRetries: c.LLMMaxRetries,
+		BaseDelay:  time.Duration(c.LLMRetryBaseDelayMs) * time.Millisecond,
+		MaxDelay:   time.Duration(c.LLMRetryMaxDelayMs) * time.Millisecond,
+	}
+}
+
+// ConfigDir возвращает каталог глобальных настроек приложения
+func ConfigDir() (string, error) {
+	base, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to locate config directory: %w", err)
+	}
+	return filepath.Join(base, configDirName), nil
+}
+
+// LoadConfig читает глобальный файл настроек.
+// Отсутствующий файл не является ошибкой — возвращаются значения по умолчанию.
+// При ошибке чтения/разбора также возвращаются значения по умолчанию вместе с ошибкой.
+func LoadConfig() (*Config, error) {
+	cfg := DefaultConfig()
+
+	dir, err := ConfigDir()
+	if err != nil {
+		return cfg, err
+	}
+	cfg.path = filepath.Join(dir, configFileName)
+
+	data, err := os.ReadFile(cfg.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return cfg, nil
+	}
+	if err != nil {
+		return cfg, fmt.Errorf("failed to read config: %w", err)
+	}
+
+	if err := json.Unmarshal(data, cfg); err != nil {
+		return DefaultConfig().withPath(cfg.path), fmt.Errorf("failed to parse %s: %w", cfg.path, err)
+	}
+	if err := cfg.migrateAPIKeys(); err != nil {
+		return cfg, fmt.Errorf("plaintext api_key left in %s: %w", cfg.path, err)
+	}
+	return cfg, nil
+}
+
+// migrateAPIKeys переносит ключи, записанные в config.json открытым текстом (api_key
+// чата и видов запросов), в Secret Service или файл ключей и сохраняет файл без них.
+// Если перенести не удалось, ключи остаются в настройках и продолжают работать.
+func (c *Config) migrateAPIKeys() error {
+	moved := false
+	for task, m := range c.Tasks {
+		if m.APIKey == "" {
+			continue
+		}
+		provider := m.Provider
+		if provider == "" {
+			provider = c.Provider
+		}
+		if err := c.storeMigratedKey(provider, m.APIKey); err != nil {
+			return err
+		}
+		m.APIKey = ""
+		c.Tasks[task] = m
+		moved = true
+	}
+	if c.APIKey != "" {
+		if err := c.storeMigratedKey(c.Provider, c.APIKey); err != nil {
+			return err
+		}
+		c.APIKey = ""
+		moved = true
+	}
+	if !moved {
+		return nil
+	}
+	return c.Save()
+}
+
+func (c *Config) storeMigratedKey(provider, key string) error {
+	// Запись ключа профиля зависит от того, что это профиль (см. KeyAccount)
+	SetProviderProfiles(c.Profiles)
+	if SecretStoreName() != "" {
+		// Хранилище может быть заблокировано или недоступно — тогда файл ключей
+		if err := StoreAPIKey(provider, key, KeySourceSecretService); err == nil {
+			return nil
+		}
+	}
+	return StoreAPIKey(provider, key, KeySourceFile)
+}
+
+// Path возвращает путь к файлу, в который сохраняются настройки
+func (c *Config) Path() string {
+	return c.path
+}
+
+// Save записывает настройки в файл (0600: в нём могут быть заголовки профилей с токенами)
+func (c *Config) Save() error {
+	if c.path == "" {
+		dir, err := ConfigDir()
+		if err != nil {
+			return err
+		}
+		c.path = filepath.Join(dir, configFileName)
+	}
+	return writeJSONFile(c.path, c, 0600)
+}
+
+// projectConfig — поля, которые может задать <project>/.golite/config.json. Файл проекта
+// приходит вместе с клонированным репозиторием, поэтому провайдер, профили, ключи, запасные
+// провайдеры, маскирование секретов и журнал запросов в нём не читаются: иначе чужой
+// репозиторий мог бы отправлять код и ключи на свой сервер.
+type projectConfig struct {
+	Model        *string `json:"model"`
+	SystemPrompt *string `json:"system_prompt"`
+
+	ColorScheme     *string `json:"color_scheme"`
+	CursorStyle     *string `json:"cursor_style"`
+	ShowLineNumbers *bool   `json:"show_line_numbers"`
+
+	AIHistoryContextSize   *int  `json:"ai_history_context_size"`
+	AIUseOpenTabsAsContext *bool `json:"ai_use_open_tabs_context"`
+	AILineCompleteEnabled  *bool `json:"ai_line_complete_enabled"`
+	AIAutoCompleteEnabled  *bool `json:"ai_auto_complete_enabled"`
+	AIFIMEnabled           *bool `json:"ai_fim_enabled"`
+	AIDeclContext          *bool `json:"ai_decl_context"`
+	AICodeIndex            *bool `json:"ai_code_index"`
+
+	RunArgs *string `json:"run_args"`
+}
+
+// apply переносит заданные в файле проекта поля в c
+func (p *projectConfig) apply(c *Config) {
+	setIfSet(&c.Model, p.Model)
+	setIfSet(&c.SystemPrompt, p.SystemPrompt)
+	setIfSet(&c.ColorScheme, p.ColorScheme)
+	setIfSet(&c.CursorStyle, p.CursorStyle)
+	setIfSet(&c.ShowLineNumbers, p.ShowLineNumbers)
+	setIfSet(&c.AIHistoryContextSize, p.AIHistoryContextSize)
+	setIfSet(&c.AIUseOpenTabsAsContext, p.AIUseOpenTabsAsContext)
+	setIfSet(&c.AILineCompleteEnabled, p.AILineCompleteEnabled)
+	setIfSet(&c.AIAutoCompleteEnabled, p.AIAutoCompleteEnabled)
+	setIfSet(&c.AIFIMEnabled, p.AIFIMEnabled)
+	setIfSet(&c.AIDeclContext, p.AIDeclContext)
+	setIfSet(&c.AICodeIndex, p.AICodeIndex)
+	setIfSet(&c.RunArgs, p.RunArgs)
+}
+
+func setIfSet[T any](dst *T, v *T) {
+	if v != nil {
+		*dst = *v
+	}
+}
+
+// projectConfigKeys — ключи JSON, разрешённые в файле проекта
+func projectConfigKeys() map[string]bool {
+	keys := map[string]bool{}
+	t := reflect.TypeOf(projectConfig{})
+	for i := 0; i < t.NumField(); i++ {
+		keys[strings.Split(t.Field(i).Tag.Get("json"), ",")[0]] = true
+	}
+	return keys
+}
+
+// WithProjectOverrides возвращает копию настроек с применёнными переопределениями проекта
+// из <root>/.golite/config.json (без файла — копию глобальных настроек). Второе значение —
+// ключи файла проекта, которые проект задавать не может и которые поэтому пропущены.
+func (c *Config) WithProjectOverrides(root string) (*Config, []string, error) {
+	merged := c.clone()
+
+	data, err := os.ReadFile(ProjectConfigPath(root))
+	if errors.Is(err, os.ErrNotExist) {
+		return merged, nil, nil
+	}
+	if err != nil {
+		return merged, nil, fmt.Errorf("failed to read project config: %w", err)
+	}
+
+	var raw map[string]json.RawMessage
+	var pc projectConfig
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return merged, nil, fmt.Errorf("failed to parse project config: %w", err)
+	}
+	if err := json.Unmarshal(data, &pc); err != nil {
+		return merged, nil, fmt.Errorf("failed to parse project config: %w", err)
+	}
+
+	var ignored []string
+	allowed := projectConfigKeys()
+	for key := range raw {
+		if !allowed[key] {
+			ignored = append(ignored, key)
+		}
+	}
+	sort.Strings(ignored)
+
+	pc.apply(merged)
+	return merged, ignored, nil
+}
+
+// LastModel возвращает последнюю выбранную модель провайдера (или пустую строку)
+func (c *Config) LastModel(provider string) string {
+	return c.LastModels[normalizeProviderName(provider)]
+}
+
+// SetLastModel запоминает модель, выбранную для провайдера
+func (c *Config) SetLastModel(provider, model string) {
+	if c.LastModels == nil {
+		c.LastModels = map[string]string{}
+	}
+	c.LastModels[normalizeProviderName(provider)] = model
+}
+
+// ProjectConfigPath возвращает путь к файлу настроек проекта
+func ProjectConfigPath(root string) string {
+	return filepath.Join(root, ProjectConfigDir, configFileName)
+}
+
+// SaveProjectSetting записывает одно значение в файл настроек проекта,
+// сохраняя остальные переопределения без изменений.
+func SaveProjectSetting(root, key string, value interface{}) error {
+	path := ProjectConfigPath(root)
+
+	settings := map[string]json.RawMessage{}
+	if data, err := os.ReadFile(path); err == nil {
+		if err := json.Unmarshal(data, &settings); err != nil {
+			return fmt.Errorf("failed to parse project config: %w", err)
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to read project config: %w", err)
+	}
+
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	settings[key] = raw
+
+	return writeJSONFile(path, settings, 0644)
+}
+
+func (c *Config) withPath(path string) *Config {
+	c.path = path
+	return c
+}
+
+// clone копирует настройки; срезы и карты копируются, чтобы json.Unmarshal
+// в копию не затронул оригинал
+func (c *Config) clone() *Config {
+	cp := *c
+	cp.Profiles = append([]ProviderProfile(nil), c.Profiles...)
+	cp.LLMFallbacks = append([]FallbackProvider(nil), c.LLMFallbacks...)
+	cp.RedactPatterns = append([]RedactPattern(nil), c.RedactPatterns...)
+	cp.RedactAllowProviders = append([]string(nil), c.RedactAllowProviders...)
+	cp.VisionModels = append([]string(nil), c.VisionModels...)
+	if c.Tasks != nil {
+		cp.Tasks = make(map[AITask]TaskModel, len(c.Tasks))
+		for k, v := range c.Tasks {
+			cp.Tasks[k] = v
+		}
+	}
+	if c.LastModels != nil {
+		cp.LastModels = make(map[string]string, len(c.LastModels))
+		for k, v := range c.LastModels {
+			cp.LastModels[k] = v
+		}
+	}
+	if c.ModelContextLimits != nil {
+		cp.ModelContextLimits = make(map[string]int, len(c.ModelContextLimits))
+		for k, v := range c.ModelContextLimits {
+			cp.ModelContextLimits[k] = v
+		}
+	}
+	return &cp
+}
+
+// writeJSONFile атомарно (через временный файл) записывает v в path
+func writeJSONFile(path string, v interface{}, perm os.FileMode) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create config directory: %w", err)
+	}
+
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode %s: %w", filepath.Base(path), err)
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), perm); err != nil {
+		return fmt.Errorf("failed to write %s: %w", filepath.Base(path), err)
+	}
+	// WriteFile не меняет права уже существующего временного файла
+	if err := os.Chmod(tmp, perm); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write %s: %w", filepath.Base(path), err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write %s: %w", filepath.Base(path), err)
+	}
+	return nil
+}
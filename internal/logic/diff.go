@@ -0,0 +1,171 @@
+package logic
+
+import "strings"
+
+// maxDiffEdits ограничивает число правок, которые ищет DiffLines; при большем
+// расхождении оставшаяся середина текста считается одним изменённым участком
+const maxDiffEdits = 2000
+
+// DiffHunk — изменённый участок: строки old[OldStart:OldEnd] заменяются на new[NewStart:NewEnd].
+// Пустой старый диапазон — вставка, пустой новый — удаление.
+type DiffHunk struct {
+	OldStart, OldEnd int
+	NewStart, NewEnd int
+}
+
+// SplitLines делит текст на строки так, что строка i соответствует блоку i QTextDocument
+func SplitLines(text string) []string {
+	return strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+}
+
+// DiffLines сравнивает строки по алгоритму Майерса и возвращает изменённые участки по порядку
+func DiffLines(old, new []string) []DiffHunk {
+	// Общие начало и конец не участвуют в поиске
+	pre := 0
+	for pre < len(old) && pre < len(new) && old[pre] == new[pre] {
+		pre++
+	}
+	suf := 0
+	for suf < len(old)-pre && suf < len(new)-pre && old[len(old)-1-suf] == new[len(new)-1-suf] {
+		suf++
+	}
+	a, b := old[pre:len(old)-suf], new[pre:len(new)-suf]
+
+	ops, ok := myersDiff(a, b)
+	if !ok {
+		if len(a) == 0 && len(b) == 0 {
+			return nil
+		}
+		return []DiffHunk{{OldStart: pre, OldEnd: pre + len(a), NewStart: pre, NewEnd: pre + len(b)}}
+	}
+
+	var hunks []DiffHunk
+	i, j := pre, pre
+	var cur *DiffHunk
+	for _, op := range ops {
+		if op == diffEqual {
+			if cur != nil {
+				hunks = append(hunks, *cur)
+				cur = nil
+			}
+			i++
+			j++
+			continue
+		}
+		if cur == nil {
+			cur = &DiffHunk{OldStart: i, OldEnd: i, NewStart: j, NewEnd: j}
+		}
+		if op == diffDelete {
+			i++
+			cur.OldEnd = i
+		} else {
+			j++
+			cur.NewEnd = j
+		}
+	}
+	if cur != nil {
+		hunks = append(hunks, *cur)
+	}
+	return hunks
+}
+
+// ApplyHunks собирает результат: принятые участки берутся из new, остальные остаются из old
+func ApplyHunks(old, new []string, hunks []DiffHunk, accept []bool) []string {
+	var out []string
+	pos := 0
+	for n, h := range hunks {
+		out = append(out, old[pos:h.OldStart]...)
+		if n < len(accept) && accept[n] {
+			out = append(out, new[h.NewStart:h.NewEnd]...)
+		} else {
+			out = append(out, old[h.OldStart:h.OldEnd]...)
+		}
+		pos = h.OldEnd
+	}
+	return append(out, old[pos:]...)
+}
+
+type diffOp byte
+
+const (
+	diffEqual diffOp = iota
+	diffDelete
+	diffInsert
+)
+
+// myersDiff возвращает кратчайший сценарий правок a → b; ok == false,
+// если правок больше maxDiffEdits
+func myersDiff(a, b []string) (ops []diffOp, ok bool) {
+	n, m := len(a), len(b)
+	if n+m == 0 {
+		return nil, true
+	}
+	limit := n + m
+	if limit > maxDiffEdits {
+		limit = maxDiffEdits
+	}
+
+	offset := limit + 1
+	v := make([]int, 2*limit+3)
+	// trace[d] — значения v[-d..d] перед шагом d
+	var trace [][]int
+	for d := 0; d <= limit; d++ {
+		trace = append(trace, append([]int(nil), v[offset-d:offset+d+1]...))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				return myersBacktrack(trace, n, m), true
+			}
+		}
+	}
+	return nil, false
+}
+
+// myersBacktrack восстанавливает сценарий правок по сохранённым шагам поиска
+func myersBacktrack(trace [][]int, n, m int) []diffOp {
+	var ops []diffOp
+	x, y := n, m
+	for d := len(trace) - 1; d > 0; d-- {
+		prev := trace[d]
+		at := func(k int) int { return prev[k+d] }
+		k := x - y
+		prevK := k - 1
+		if k == -d || (k != d && at(k-1) < at(k+1)) {
+			prevK = k + 1
+		}
+		prevX := at(prevK)
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			ops = append(ops, diffEqual)
+			x--
+			y--
+		}
+		if x == prevX {
+			ops = append(ops, diffInsert)
+		} else {
+			ops = append(ops, diffDelete)
+		}
+		x, y = prevX, prevY
+	}
+	for x > 0 && y > 0 {
+		ops = append(ops, diffEqual)
+		x--
+		y--
+	}
+
+	for i, j := 0, len(ops)-1; i < j; i, j = i+1, j-1 {
+		ops[i], ops[j] = ops[j], ops[i]
+	}
+	return ops
+}
@@ -0,0 +1,155 @@
+package logic
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrEmbeddingsUnsupported возвращается, если у провайдера нет API эмбеддингов
+var ErrEmbeddingsUnsupported = errors.New("embeddings are not supported by this provider")
+
+// embedBatchSize — сколько текстов отправляется в одном запросе /v1/embeddings
+const embedBatchSize = 32
+
+// EmbeddingProvider — провайдер, вычисляющий векторы текстов
+type EmbeddingProvider interface {
+	Embed(ctx context.Context, texts []string) ([][]float32, error)
+}
+
+// DefaultEmbeddingModel — модель эмбеддингов провайдера, если она не задана
+// в настройках вида запроса "embed" ("" — модель нужно указать явно)
+func DefaultEmbeddingModel(providerName string) string {
+	kind := normalizeProviderName(providerName)
+	if profile, ok := LookupProviderProfile(providerName); ok {
+		kind = normalizeProviderName(profile.Provider)
+	}
+	switch kind {
+	case "ollama":
+		return "nomic-embed-text"
+	case "openai":
+		return "text-embedding-3-small"
+	}
+	return ""
+}
+
+// Embed вычисляет векторы texts (в том же порядке). Секреты маскируются так же, как в Chat.
+// Запросы не пишутся в журнал: ответы состоят из векторов и очень велики.
+// Если у ctx нет дедлайна, применяется defaultTimeout.
+func Embed(ctx context.Context, providerName, model, apiKey string, texts []string) ([][]float32, error) {
+	ctx, cancel := withDefaultTimeout(ctx)
+	defer cancel()
+
+	if model == "" {
+		return nil, errors.New("no embedding model: set the model of the \"embed\" task in AI > Models per Task")
+	}
+	provider, err := newProvider(providerName, model, apiKey, "")
+	if err != nil {
+		return nil, fmt.Errorf("provider error: %w", err)
+	}
+	ep, ok := provider.(EmbeddingProvider)
+	if !ok {
+		return nil, fmt.Errorf("%s: %w", providerName, ErrEmbeddingsUnsupported)
+	}
+	if ShouldRedact(providerName) {
+		redacted := make([]string, len(texts))
+		for i, t := range texts {
+			redacted[i], _ = RedactText(t)
+		}
+		texts = redacted
+	}
+
+	vectors, err := ep.Embed(ctx, texts)
+	if err != nil {
+		return nil, err
+	}
+	if len(vectors) != len(texts) {
+		return nil, fmt.Errorf("embeddings: got %d vectors for %d texts", len(vectors), len(texts))
+	}
+	return vectors, nil
+}
+
+// --- Ollama: POST /api/embeddings (один текст на запрос) ---
+
+func (p *OllamaProvider) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	url, err := replaceURLPath(p.endpoint("http://localhost:11434/v1/chat/completions"), "/api/embeddings")
+	if err != nil {
+		return nil, err
+	}
+	vectors := make([][]float32, len(texts))
+	for i, text := range texts {
+		body, err := postJSON(ctx, url, map[string]interface{}{"model": p.Model, "prompt": text}, p.Key, p.Headers)
+		if err != nil {
+			return nil, err
+		}
+		var r struct {
+			Embedding []float32 `json:"embedding"`
+			Error     string    `json:"error"`
+		}
+		if err := json.Unmarshal(body, &r); err != nil {
+			return nil, fmt.Errorf("failed to parse embeddings response: %w", err)
+		}
+		if r.Error != "" {
+			return nil, errors.New(r.Error)
+		}
+		if len(r.Embedding) == 0 {
+			return nil, fmt.Errorf("model %s returned an empty embedding (is it an embedding model?)", p.Model)
+		}
+		vectors[i] = r.Embedding
+	}
+	return vectors, nil
+}
+
+// --- OpenAI-совместимые API: POST /v1/embeddings ---
+
+func (p *GenericURLProvider) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	return openAIEmbed(ctx, p.endpoint("https://api.openai.com/v1/chat/completions"), p.ProviderOptions, texts)
+}
+
+func (p *OpenRouterProvider) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	return openAIEmbed(ctx, p.endpoint("https://openrouter.ai/api/v1/chat/completions"), p.ProviderOptions, texts)
+}
+
+// openAIEmbed отправляет тексты пачками на /embeddings рядом с chat/completions endpoint
+func openAIEmbed(ctx context.Context, chatURL string, opts ProviderOptions, texts []string) ([][]float32, error) {
+	url := strings.TrimSuffix(chatURL, "/chat/completions") + "/embeddings"
+	if !strings.HasSuffix(chatURL, "/chat/completions") {
+		var err error
+		if url, err = replaceURLPath(chatURL, "/v1/embeddings"); err != nil {
+			return nil, err
+		}
+	}
+
+	vectors := make([][]float32, 0, len(texts))
+	for start := 0; start < len(texts); start += embedBatchSize {
+		batch := texts[start:min(start+embedBatchSize, len(texts))]
+		body, err := postJSON(ctx, url, map[string]interface{}{"model": opts.Model, "input": batch}, opts.Key, opts.Headers)
+		if err != nil {
+			return nil, err
+		}
+		var r struct {
+			Data []struct {
+				Index     int       `json:"index"`
+				Embedding []float32 `json:"embedding"`
+			} `json:"data"`
+		}
+		if err := json.Unmarshal(body, &r); err != nil {
+			return nil, fmt.Errorf("failed to parse embeddings response: %w", err)
+		}
+		if len(r.Data) != len(batch) {
+			return nil, fmt.Errorf("embeddings: got %d vectors for %d texts", len(r.Data), len(batch))
+		}
+		out := make([][]float32, len(batch))
+		for i, d := range r.Data {
+			index := d.Index
+			if index < 0 || index >= len(batch) {
+				index = i
+			}
+			out[index] = d.Embedding
+		}
+		vectors = append(vectors, out...)
+	}
+	return vectors, nil
+}
@@ -0,0 +1,341 @@
+package logic
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ErrFIMUnsupported возвращается, если провайдер или модель не поддерживают fill-in-the-middle
+var ErrFIMUnsupported = errors.New("fill-in-the-middle is not supported by this provider/model")
+
+// FIMMode — способ передачи prefix/suffix (поле "fim" профиля провайдера)
+type FIMMode string
+
+const (
+	FIMAuto   FIMMode = ""       // по имени модели (см. fimTemplates)
+	FIMSuffix FIMMode = "suffix" // API принимает параметр "suffix" (Ollama /api/generate, /v1/completions)
+	FIMTokens FIMMode = "tokens" // prompt собирается из FIM-токенов модели
+	FIMOff    FIMMode = "off"    // только чат-дополнение
+)
+
+// FIMRequest — запрос на вставку кода между Prefix и Suffix
+type FIMRequest struct {
+	Prefix    string   `json:"prefix"`
+	Suffix    string   `json:"suffix"`
+	MaxTokens int      `json:"max_tokens,omitempty"` // 0 — значение по умолчанию
+	Stop      []string `json:"stop,omitempty"`       // дополнительные стоп-последовательности (например, "\n" для одной строки)
+	Task      AITask   `json:"-"`                    // вид запроса для журнала запросов
+	Cache     bool     `json:"-"`                    // разрешить ответ из кэша (см. ChatRequest.Cache)
+}
+
+// FIMProvider — провайдер, поддерживающий fill-in-the-middle
+type FIMProvider interface {
+	// FIMMode сообщает, каким способом провайдер выполнит запрос для своей модели
+	// (FIMOff — не поддерживает)
+	FIMMode() FIMMode
+	CompleteFIM(ctx context.Context, req FIMRequest) (string, error)
+}
+
+// fimTemplate — формат FIM-токенов семейства моделей
+type fimTemplate struct {
+	format func(prefix, suffix string) string
+	stop   []string
+}
+
+// fimTemplates — FIM-токены известных кодовых моделей; ключ сравнивается с началом
+// имени модели, как в modelContextLimits
+var fimTemplates = map[string]fimTemplate{
+	"qwen2.5-coder": qwenFIM,
+	"qwen3-coder":   qwenFIM,
+	"codegemma":     qwenFIM,
+	"starcoder":     starcoderFIM,
+	"starcoder2":    starcoderFIM,
+	"stable-code":   starcoderFIM,
+	"granite-code":  starcoderFIM,
+	"deepseek-coder": {
+		format: func(p, s string) string {
+			return "<｜fim▁begin｜>" + p + "<｜fim▁hole｜>" + s + "<｜fim▁end｜>"
+		},
+		stop: []string{"<｜fim▁begin｜>", "<｜fim▁hole｜>", "<｜fim▁end｜>", "<|EOT|>"},
+	},
+	"codellama": {
+		format: func(p, s string) string { return "<PRE> " + p + " <SUF>" + s + " <MID>" },
+		stop:   []string{"<EOT>", "<PRE>", "<SUF>", "<MID>"},
+	},
+	"codestral": {
+		format: func(p, s string) string { return "[SUFFIX]" + s + "[PREFIX]" + p },
+		stop:   []string{"[PREFIX]", "[SUFFIX]"},
+	},
+}
+
+var (
+	qwenFIM = fimTemplate{
+		format: func(p, s string) string { return "<|fim_prefix|>" + p + "<|fim_suffix|>" + s + "<|fim_middle|>" },
+		stop:   []string{"<|fim_prefix|>", "<|fim_suffix|>", "<|fim_middle|>", "<|endoftext|>", "<|file_sep|>"},
+	}
+	starcoderFIM = fimTemplate{
+		format: func(p, s string) string { return "<fim_prefix>" + p + "<fim_suffix>" + s + "<fim_middle>" },
+		stop:   []string{"<fim_prefix>", "<fim_suffix>", "<fim_middle>", "<|endoftext|>", "<file_sep>"},
+	}
+)
+
+// defaultFIMMaxTokens ограничивает длину вставки
+const defaultFIMMaxTokens = 256
+
+// lookupFIMTemplate ищет FIM-токены модели по имени (без "vendor/" и тега)
+func lookupFIMTemplate(model string) (fimTemplate, bool) {
+	name := strings.ToLower(strings.TrimSpace(model))
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		name = name[i+1:]
+	}
+	best := ""
+	for prefix := range fimTemplates {
+		if strings.HasPrefix(name, prefix) && len(prefix) > len(best) {
+			best = prefix
+		}
+	}
+	if best == "" {
+		return fimTemplate{}, false
+	}
+	return fimTemplates[best], true
+}
+
+// FIMSupported сообщает, выполнит ли провайдер запрос fill-in-the-middle для модели.
+// Ключ не ищется, поэтому функцию можно вызывать из UI-потока.
+func FIMSupported(providerName, model string) bool {
+	provider, err := makeProvider(providerName, model, "", "", false)
+	if err != nil {
+		return false
+	}
+	fp, ok := provider.(FIMProvider)
+	return ok && fp.FIMMode() != FIMOff
+}
+
+// CompleteFIM запрашивает код, который нужно вставить между req.Prefix и req.Suffix.
+// Если у ctx нет дедлайна, применяется defaultTimeout.
+// Запасные провайдеры не используются: они могут не поддерживать FIM.
+// Секреты в коде маскируются так же, как в Chat; результат сообщает, что было
+// замаскировано. Результат не nil и при ошибке.
+func CompleteFIM(ctx context.Context, providerName, model, apiKey string, req FIMRequest) (*ChatResult, error) {
+	ctx, cancel := withDefaultTimeout(ctx)
+	defer cancel()
+
+	res := &ChatResult{Provider: providerName, Model: model}
+	provider, err := newProvider(providerName, model, apiKey, "")
+	if err != nil {
+		return res, fmt.Errorf("provider error: %w", err)
+	}
+	fp, ok := provider.(FIMProvider)
+	if !ok || fp.FIMMode() == FIMOff {
+		return res, ErrFIMUnsupported
+	}
+	if req.MaxTokens <= 0 {
+		req.MaxTokens = defaultFIMMaxTokens
+	}
+	var report RedactionReport
+	if ShouldRedact(providerName) {
+		var r RedactionReport
+		req.Prefix, report = RedactText(req.Prefix)
+		req.Suffix, r = RedactText(req.Suffix)
+		report = report.merge(r)
+	}
+	res.Redactions = report
+
+	cacheKey := ""
+	if req.Cache {
+		cacheKey = responseCacheKey("fim", providerName, model, "", req)
+		if text, ok := respCache.get(cacheKey); ok {
+			logRequest(RequestLogEntry{
+				Time: time.Now(), Kind: "fim", Task: req.Task, Provider: providerName, Model: model,
+				FIM: &req, Redactions: report, Content: text, Cached: true,
+			})
+			res.Text, res.Cached = text, true
+			return res, nil
+		}
+	}
+
+	start := time.Now()
+	tctx, trace := withRequestTrace(ctx)
+	text, err := fp.CompleteFIM(tctx, req)
+	if err == nil && cacheKey != "" {
+		respCache.put(cacheKey, text)
+	}
+
+	entry := RequestLogEntry{
+		Time:       start,
+		Kind:       "fim",
+		Task:       req.Task,
+		Provider:   providerName,
+		Model:      model,
+		LatencyMs:  time.Since(start).Milliseconds(),
+		FIM:        &req,
+		Redactions: report,
+		Content:    text,
+	}
+	if err != nil {
+		entry.Error = err.Error()
+	}
+	trace.fill(&entry)
+	logRequest(entry)
+	res.Text = text
+	return res, err
+}
+
+// resolveFIMMode выбирает способ FIM для режима профиля: в автоматическом режиме
+// нужна известная модель, а API без "suffix" может работать только через токены
+func resolveFIMMode(mode FIMMode, model string, suffixAPI bool) FIMMode {
+	switch mode {
+	case FIMSuffix:
+		if suffixAPI {
+			return FIMSuffix
+		}
+		return FIMOff
+	case FIMTokens, FIMOff:
+		return mode
+	}
+	if _, ok := lookupFIMTemplate(model); !ok {
+		return FIMOff
+	}
+	if suffixAPI {
+		return FIMSuffix
+	}
+	return FIMTokens
+}
+
+// fimPrompt собирает prompt и стоп-последовательности для режима FIMTokens
+func fimPrompt(model string, req FIMRequest) (string, []string, error) {
+	tmpl, ok := lookupFIMTemplate(model)
+	if !ok {
+		return "", nil, fmt.Errorf("%w: unknown FIM tokens for model %q", ErrFIMUnsupported, model)
+	}
+	// Стоп-последовательности запроса первыми: API может ограничивать их число
+	return tmpl.format(req.Prefix, req.Suffix), append(append([]string{}, req.Stop...), tmpl.stop...), nil
+}
+
+// --- Ollama: POST /api/generate ---
+
+// FIMMode: Ollama сам подставляет FIM-токены по шаблону модели, если передан "suffix"
+func (p *OllamaProvider) FIMMode() FIMMode {
+	return resolveFIMMode(p.FIM, p.Model, true)
+}
+
+func (p *OllamaProvider) CompleteFIM(ctx context.Context, req FIMRequest) (string, error) {
+	url, err := replaceURLPath(p.endpoint("http://localhost:11434/v1/chat/completions"), "/api/generate")
+	if err != nil {
+		return "", err
+	}
+
+	options := map[string]interface{}{
+		"num_predict": req.MaxTokens,
+		"temperature": 0.2,
+	}
+	payload := map[string]interface{}{
+		"model":   p.Model,
+		"stream":  false,
+		"options": options,
+	}
+
+	switch p.FIMMode() {
+	case FIMSuffix:
+		payload["prompt"] = req.Prefix
+		payload["suffix"] = req.Suffix
+		if len(req.Stop) > 0 {
+			options["stop"] = req.Stop
+		}
+	case FIMTokens:
+		prompt, stop, err := fimPrompt(p.Model, req)
+		if err != nil {
+			return "", err
+		}
+		// raw: шаблон модели не применяется, токены уже в prompt
+		payload["prompt"] = prompt
+		payload["raw"] = true
+		options["stop"] = stop
+	default:
+		return "", ErrFIMUnsupported
+	}
+
+	body, err := postJSON(ctx, url, payload, p.Key, p.Headers)
+	if err != nil {
+		return "", err
+	}
+
+	var r struct {
+		Response string `json:"response"`
+		Error    string `json:"error"`
+	}
+	if err := json.Unmarshal(body, &r); err != nil {
+		return "", fmt.Errorf("failed to parse FIM response: %w", err)
+	}
+	if r.Error != "" {
+		return "", errors.New(r.Error)
+	}
+	return r.Response, nil
+}
+
+// --- OpenAI-совместимые API: POST /v1/completions ---
+
+func (p *GenericURLProvider) FIMMode() FIMMode {
+	return resolveFIMMode(p.FIM, p.Model, p.FIM == FIMSuffix)
+}
+
+func (p *GenericURLProvider) CompleteFIM(ctx context.Context, req FIMRequest) (string, error) {
+	return openAICompleteFIM(ctx, p.endpoint("https://api.openai.com/v1/chat/completions"), p.ProviderOptions, p.FIMMode(), req)
+}
+
+func (p *OpenRouterProvider) FIMMode() FIMMode {
+	return resolveFIMMode(p.FIM, p.Model, p.FIM == FIMSuffix)
+}
+
+func (p *OpenRouterProvider) CompleteFIM(ctx context.Context, req FIMRequest) (string, error) {
+	return openAICompleteFIM(ctx, p.endpoint("https://openrouter.ai/api/v1/chat/completions"), p.ProviderOptions, p.FIMMode(), req)
+}
+
+// openAICompleteFIM отправляет запрос legacy completions рядом с chat/completions endpoint.
+// Параметр "suffix" поддерживают не все серверы, поэтому по умолчанию используются FIM-токены.
+func openAICompleteFIM(ctx context.Context, chatURL string, opts ProviderOptions, mode FIMMode, req FIMRequest) (string, error) {
+	url := strings.TrimSuffix(chatURL, "/chat/completions") + "/completions"
+	if !strings.HasSuffix(chatURL, "/chat/completions") {
+		var err error
+		if url, err = replaceURLPath(chatURL, "/v1/completions"); err != nil {
+			return "", err
+		}
+	}
+
+	payload := map[string]interface{}{
+		"model":       opts.Model,
+		"max_tokens":  req.MaxTokens,
+		"temperature": 0.2,
+	}
+	switch mode {
+	case FIMSuffix:
+		payload["prompt"] = req.Prefix
+		payload["suffix"] = req.Suffix
+		if len(req.Stop) > 0 {
+			payload["stop"] = req.Stop
+		}
+	case FIMTokens:
+		prompt, stop, err := fimPrompt(opts.Model, req)
+		if err != nil {
+			return "", err
+		}
+		payload["prompt"] = prompt
+		// OpenAI ограничивает число стоп-последовательностей четырьмя
+		if len(stop) > 4 {
+			stop = stop[:4]
+		}
+		payload["stop"] = stop
+	default:
+		return "", ErrFIMUnsupported
+	}
+
+	body, err := postJSON(ctx, url, payload, opts.Key, opts.Headers)
+	if err != nil {
+		return "", err
+	}
+	return extractContent(body)
+}
@@ -0,0 +1,581 @@
+package logic
+
+import (
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"hash/fnv"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const (
+	// goContextLines — строк до и после курсора, из которых берутся идентификаторы
+	// (в пределах объявления под курсором), если ничего не выделено
+	goContextLines = 40
+	// maxGoDeclLines — более длинные объявления обрезаются
+	maxGoDeclLines = 40
+	// maxGoDeclMethods — сколько методов перечисляется после объявления типа
+	maxGoDeclMethods = 20
+	// defaultGoContextBytes — ограничение размера объявлений по умолчанию
+	defaultGoContextBytes = 6000
+	// maxGoFsetBytes — после разбора стольких байт исходников FileSet и кэш пакетов
+	// создаются заново: FileSet только растёт, а буфер у курсора разбирается после каждой правки
+	maxGoFsetBytes = 64 << 20
+)
+
+// GoContextRequest — запрос объявлений, на которые ссылается код под курсором
+type GoContextRequest struct {
+	File       string                            // абсолютный путь файла
+	Src        string                            // текст буфера (может быть не сохранён)
+	Start, End int                               // байтовые смещения выделения; Start == End — курсор
+	ReadFile   func(path string) (string, error) // текст других файлов (с учётом вкладок); nil — с диска
+	SkipFile   bool                              // не включать объявления самого File (он уже в запросе)
+	MaxBytes   int                               // 0 — defaultGoContextBytes
+}
+
+// GoDecl — объявление типа, функции, константы или переменной из модуля
+type GoDecl struct {
+	Name string // "pkg.Name" или "pkg.Type.Method"
+	File string
+	Line int
+	Text string // исходный текст; у функций — только сигнатура
+}
+
+// goPackage — разобранный и проверенный пакет модуля
+type goPackage struct {
+	hash  uint64
+	files []*goSource
+	pkg   *types.Package
+	info  *types.Info
+	deps  map[string]*goPackage // пакеты модуля, с которыми он проверялся (по ключу загрузки)
+}
+
+// goSource — файл пакета вместе с текстом, из которого он разобран
+type goSource struct {
+	path string
+	src  string
+	file *ast.File
+}
+
+// Проверенные пакеты переиспользуются, пока не изменились их файлы и зависимости.
+// Все пакеты кэша разобраны в общий goPkgFset (позиции объектов сравниваются между пакетами).
+var (
+	goPkgMu    sync.Mutex
+	goPkgFset  = token.NewFileSet()
+	goPkgCache = map[string]*goPackage{}
+)
+
+// GoContextDecls находит идентификаторы в выделении (или рядом с курсором в пределах
+// объявления), определяет их через go/types и возвращает объявления из пакетов модуля —
+// ближайшие к курсору первыми, пока они помещаются в MaxBytes. Стандартная библиотека
+// и внешние зависимости не проверяются: их объявления модель обычно знает.
+func GoContextDecls(req GoContextRequest) []GoDecl {
+	if req.File == "" || !strings.HasSuffix(req.File, ".go") {
+		return nil
+	}
+	file := filepath.Clean(req.File)
+	modRoot := goModuleRoot(filepath.Dir(file))
+
+	goPkgMu.Lock()
+	defer goPkgMu.Unlock()
+	if goPkgFset.Base() > maxGoFsetBytes {
+		goPkgFset = token.NewFileSet()
+		goPkgCache = map[string]*goPackage{}
+	}
+
+	l := &goLoader{
+		modRoot: modRoot,
+		modPath: ModulePath(modRoot),
+		file:    file,
+		src:     req.Src,
+		read:    req.ReadFile,
+		loaded:  map[string]*goPackage{},
+		loading: map[string]bool{},
+		stubs:   map[string]*types.Package{},
+	}
+	cur := l.load(filepath.Dir(file), strings.HasSuffix(file, "_test.go"))
+	if cur == nil {
+		return nil
+	}
+	var src *goSource
+	for _, f := range cur.files {
+		if f.path == file {
+			src = f
+		}
+	}
+	if src == nil {
+		return nil
+	}
+
+	tf := goPkgFset.File(src.file.Pos())
+	start, end := goContextRegion(src, tf, req.Start, req.End)
+	cursor := tf.Pos(min(max(req.Start, 0), tf.Size()))
+
+	// Идентификаторы региона: сначала ближайшие к курсору, код до курсора важнее
+	type ref struct {
+		obj  types.Object
+		dist int
+	}
+	var refs []ref
+	ast.Inspect(src.file, func(n ast.Node) bool {
+		id, ok := n.(*ast.Ident)
+		if !ok || id.Pos() < start || id.Pos() > end {
+			return n == nil || (n.End() >= start && n.Pos() <= end)
+		}
+		if obj := cur.info.Uses[id]; obj != nil {
+			dist := int(cursor - id.Pos())
+			if dist < 0 {
+				dist = -2 * dist
+			}
+			refs = append(refs, ref{obj, dist})
+		}
+		return true
+	})
+	sort.SliceStable(refs, func(i, j int) bool { return refs[i].dist < refs[j].dist })
+
+	limit := req.MaxBytes
+	if limit <= 0 {
+		limit = defaultGoContextBytes
+	}
+	var decls []GoDecl
+	seen := map[string]bool{}
+	size := 0
+	add := func(obj types.Object) {
+		if obj == nil || obj.Pkg() == nil || (obj.Pos() >= start && obj.Pos() <= end && tf == goPkgFset.File(obj.Pos())) {
+			return
+		}
+		d, key, ok := l.declOf(obj)
+		if !ok || seen[key] || (req.SkipFile && d.File == file) {
+			return
+		}
+		seen[key] = true
+		if size+len(d.Text) > limit {
+			return
+		}
+		size += len(d.Text)
+		decls = append(decls, d)
+	}
+	for _, r := range refs {
+		switch obj := r.obj.(type) {
+		case *types.PkgName, *types.Builtin, *types.Label, *types.Nil:
+			continue
+		case *types.Var:
+			// У локальных переменных интересен только их тип
+			if obj.IsField() || obj.Parent() == obj.Pkg().Scope() {
+				add(obj)
+			}
+			add(goNamedType(obj.Type()))
+		case *types.Const, *types.TypeName:
+			if obj.Parent() == obj.Pkg().Scope() {
+				add(obj)
+			}
+		default:
+			add(obj)
+		}
+	}
+	return decls
+}
+
+// FormatGoDecls выводит объявления как Go-код; перед каждым — комментарий с местом в проекте
+func FormatGoDecls(decls []GoDecl, root string) string {
+	var sb strings.Builder
+	for _, d := range decls {
+		fmt.Fprintf(&sb, "// %s:%d\n%s\n\n", projectRelPath(root, d.File), d.Line, d.Text)
+	}
+	return sb.String()
+}
+
+// goModuleRoot — ближайший каталог с go.mod вверх от dir ("" — не найден)
+func goModuleRoot(dir string) string {
+	for {
+		if fileExists(filepath.Join(dir, "go.mod")) {
+			return dir
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// goContextRegion — диапазон, из которого берутся идентификаторы: выделение или строки
+// вокруг курсора, ограниченные объявлением под ним
+func goContextRegion(src *goSource, tf *token.File, startOff, endOff int) (token.Pos, token.Pos) {
+	startOff = min(max(startOff, 0), tf.Size())
+	endOff = min(max(endOff, startOff), tf.Size())
+	if endOff > startOff {
+		return tf.Pos(startOff), tf.Pos(endOff)
+	}
+
+	line := tf.Line(tf.Pos(startOff))
+	start := tf.LineStart(max(line-goContextLines, 1))
+	end := tf.Pos(tf.Size())
+	if last := line + goContextLines + 1; last <= tf.LineCount() {
+		end = tf.LineStart(last)
+	}
+	cursor := tf.Pos(startOff)
+	for _, decl := range src.file.Decls {
+		if decl.Pos() <= cursor && cursor <= decl.End() {
+			return max(start, decl.Pos()), min(end, decl.End())
+		}
+	}
+	return start, end
+}
+
+// goNamedType — объявленный тип значения (без указателей, срезов, каналов и т. п.)
+func goNamedType(t types.Type) types.Object {
+	for {
+		switch u := t.(type) {
+		case *types.Pointer:
+			t = u.Elem()
+		case *types.Slice:
+			t = u.Elem()
+		case *types.Array:
+			t = u.Elem()
+		case *types.Map:
+			t = u.Elem()
+		case *types.Chan:
+			t = u.Elem()
+		case *types.Named:
+			return u.Obj()
+		default:
+			return nil
+		}
+	}
+}
+
+// goLoader загружает пакеты модуля для одного запроса
+type goLoader struct {
+	modRoot, modPath string
+	file, src        string // файл запроса и его текст из буфера
+	read             func(path string) (string, error)
+	loaded           map[string]*goPackage
+	loading          map[string]bool
+	stubs            map[string]*types.Package
+}
+
+// Import — types.Importer: пакеты модуля проверяются из исходников, остальные
+// заменяются пустыми (ошибки проверки игнорируются)
+func (l *goLoader) Import(path string) (*types.Package, error) {
+	if dir := l.packageDir(path); dir != "" {
+		if p := l.load(dir, false); p != nil {
+			return p.pkg, nil
+		}
+	}
+	if pkg, ok := l.stubs[path]; ok {
+		return pkg, nil
+	}
+	name := path[strings.LastIndex(path, "/")+1:]
+	if i := strings.LastIndex(path, "/"); i > 0 && len(name) > 1 && name[0] == 'v' && strings.Trim(name[1:], "0123456789") == "" {
+		// Основной пакет модуля вида example.com/lib/v2 называется lib
+		prev := path[:i]
+		name = prev[strings.LastIndex(prev, "/")+1:]
+	}
+	pkg := types.NewPackage(path, name)
+	pkg.MarkComplete()
+	l.stubs[path] = pkg
+	return pkg, nil
+}
+
+// packageDir — каталог пакета модуля по пути импорта ("" — пакет не из модуля)
+func (l *goLoader) packageDir(path string) string {
+	if l.modPath == "" {
+		return ""
+	}
+	if path == l.modPath {
+		return l.modRoot
+	}
+	if rest, ok := strings.CutPrefix(path, l.modPath+"/"); ok {
+		return filepath.Join(l.modRoot, filepath.FromSlash(rest))
+	}
+	return ""
+}
+
+// importPath — путь импорта пакета в каталоге dir
+func (l *goLoader) importPath(dir string) string {
+	if l.modPath != "" {
+		if rel, err := filepath.Rel(l.modRoot, dir); err == nil && !strings.HasPrefix(rel, "..") {
+			if rel == "." {
+				return l.modPath
+			}
+			return l.modPath + "/" + filepath.ToSlash(rel)
+		}
+	}
+	return filepath.Base(dir)
+}
+
+func (l *goLoader) readSource(path string) (string, error) {
+	if path == l.file {
+		return l.src, nil
+	}
+	if l.read != nil {
+		return l.read(path)
+	}
+	data, err := os.ReadFile(path)
+	return string(data), err
+}
+
+// load разбирает и проверяет пакет каталога dir (tests — вместе с его _test.go).
+// Результат из кэша используется, если не изменились файлы пакета и его зависимости.
+func (l *goLoader) load(dir string, tests bool) *goPackage {
+	key := dir
+	if tests {
+		key += " [test]"
+	}
+	if p, ok := l.loaded[key]; ok {
+		return p
+	}
+	if l.loading[key] {
+		return nil // цикл импортов
+	}
+	l.loading[key] = true
+	defer delete(l.loading, key)
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+	var paths []string
+	hasFile := false
+	for _, entry := range entries {
+		name := entry.Name()
+		path := filepath.Join(dir, name)
+		if entry.IsDir() || !strings.HasSuffix(name, ".go") || (!tests && strings.HasSuffix(name, "_test.go")) {
+			continue
+		}
+		if ok, err := build.Default.MatchFile(dir, name); path != l.file && (err != nil || !ok) {
+			continue
+		}
+		hasFile = hasFile || path == l.file
+		paths = append(paths, path)
+	}
+	if !hasFile && filepath.Dir(l.file) == dir {
+		paths = append(paths, l.file) // новый, ещё не сохранённый файл
+	}
+
+	texts := make([]string, 0, len(paths))
+	h := fnv.New64a()
+	for i := 0; i < len(paths); i++ {
+		text, err := l.readSource(paths[i])
+		if err != nil {
+			paths = append(paths[:i], paths[i+1:]...)
+			i--
+			continue
+		}
+		texts = append(texts, text)
+		fmt.Fprintf(h, "%s\x00%s\x00", paths[i], text)
+	}
+	if len(paths) == 0 {
+		return nil
+	}
+
+	if p, ok := goPkgCache[key]; ok && p.hash == h.Sum64() && l.depsUnchanged(p) {
+		l.loaded[key] = p
+		return p
+	}
+
+	p := &goPackage{hash: h.Sum64(), deps: map[string]*goPackage{}}
+	var files []*ast.File
+	pkgName := ""
+	for i, path := range paths {
+		// Файл с синтаксической ошибкой тоже частично разбирается
+		f, _ := parser.ParseFile(goPkgFset, path, texts[i], parser.ParseComments)
+		if f == nil || f.Name == nil {
+			continue
+		}
+		if path == l.file || pkgName == "" {
+			pkgName = f.Name.Name
+		}
+		p.files = append(p.files, &goSource{path: path, src: texts[i], file: f})
+	}
+	kept := p.files[:0]
+	for _, s := range p.files {
+		if s.file.Name.Name == pkgName {
+			kept = append(kept, s)
+			files = append(files, s.file)
+		}
+	}
+	p.files = kept
+
+	p.info = &types.Info{Uses: map[*ast.Ident]types.Object{}}
+	conf := types.Config{Importer: l, Error: func(error) {}, FakeImportC: true}
+	p.pkg, _ = conf.Check(l.importPath(dir), goPkgFset, files, p.info)
+
+	for _, f := range files {
+		for _, imp := range f.Imports {
+			path := strings.Trim(imp.Path.Value, "\"`")
+			if depDir := l.packageDir(path); depDir != "" {
+				if dep := l.loaded[depDir]; dep != nil {
+					p.deps[depDir] = dep
+				}
+			}
+		}
+	}
+	goPkgCache[key] = p
+	l.loaded[key] = p
+	return p
+}
+
+// depsUnchanged сообщает, что зависимости пакета из кэша не перепроверялись с тех пор
+func (l *goLoader) depsUnchanged(p *goPackage) bool {
+	for dir, dep := range p.deps {
+		if l.load(dir, false) != dep {
+			return false
+		}
+	}
+	return true
+}
+
+// source — файл загруженного пакета, в котором находится pos
+func (l *goLoader) source(pos token.Pos) *goSource {
+	for _, p := range l.loaded {
+		for _, s := range p.files {
+			if s.file.FileStart <= pos && pos <= s.file.FileEnd {
+				return s
+			}
+		}
+	}
+	return nil
+}
+
+// declOf возвращает объявление объекта и ключ для исключения повторов
+func (l *goLoader) declOf(obj types.Object) (GoDecl, string, bool) {
+	s := l.source(obj.Pos())
+	if s == nil {
+		return GoDecl{}, "", false
+	}
+	var decl ast.Decl
+	for _, d := range s.file.Decls {
+		if d.Pos() <= obj.Pos() && obj.Pos() < d.End() {
+			decl = d
+			break
+		}
+	}
+	if decl == nil {
+		return GoDecl{}, "", false
+	}
+
+	off := func(pos token.Pos) int { return goPkgFset.Position(pos).Offset }
+	name := obj.Pkg().Name() + "."
+	var start, end token.Pos
+	text := ""
+	switch d := decl.(type) {
+	case *ast.FuncDecl:
+		start, end = d.Pos(), d.End()
+		if d.Doc != nil {
+			start = d.Doc.Pos()
+		}
+		if d.Body != nil {
+			end = d.Body.Lbrace
+		}
+		if d.Recv != nil && len(d.Recv.List) > 0 {
+			name += receiverTypeName(d.Recv.List[0].Type) + "."
+		}
+		name += d.Name.Name
+		text = clipGoDecl(strings.TrimSpace(s.src[off(start):off(end)]))
+	case *ast.GenDecl:
+		start, end = d.Pos(), d.End()
+		if d.Doc != nil {
+			start = d.Doc.Pos()
+		}
+		spec := goSpecAt(d, obj.Pos())
+		if ts, ok := spec.(*ast.TypeSpec); ok {
+			name += ts.Name.Name // для поля — имя структуры
+		} else {
+			name += obj.Name()
+		}
+		if d.Lparen.IsValid() && spec != nil && (d.Tok != token.CONST || goPkgFset.Position(d.End()).Line-goPkgFset.Position(d.Pos()).Line > maxGoDeclLines) {
+			// Из группы берётся только нужная спецификация; группа констант — целиком (iota)
+			start, end = spec.Pos(), spec.End()
+			if doc := goSpecDoc(spec); doc != nil {
+				start = doc.Pos()
+			}
+			text = d.Tok.String() + " " + strings.TrimSpace(s.src[off(spec.Pos()):off(end)])
+			if start != spec.Pos() {
+				text = strings.TrimSpace(s.src[off(start):off(spec.Pos())]) + "\n" + text
+			}
+		} else {
+			text = strings.TrimSpace(s.src[off(start):off(end)])
+		}
+		text = clipGoDecl(text)
+		if spec, ok := spec.(*ast.TypeSpec); ok && obj.Pos() == spec.Name.Pos() {
+			text += l.methodList(obj)
+		}
+	default:
+		return GoDecl{}, "", false
+	}
+
+	key := fmt.Sprintf("%s:%d", s.path, off(start))
+	return GoDecl{Name: name, File: s.path, Line: goPkgFset.Position(start).Line, Text: text}, key, true
+}
+
+// goSpecAt — спецификация группы, в которой объявлен объект
+func goSpecAt(d *ast.GenDecl, pos token.Pos) ast.Spec {
+	for _, spec := range d.Specs {
+		if spec.Pos() <= pos && pos < spec.End() {
+			return spec
+		}
+	}
+	return nil
+}
+
+func goSpecDoc(spec ast.Spec) *ast.CommentGroup {
+	switch s := spec.(type) {
+	case *ast.TypeSpec:
+		return s.Doc
+	case *ast.ValueSpec:
+		return s.Doc
+	}
+	return nil
+}
+
+// methodList перечисляет сигнатуры методов типа комментарием после его объявления
+// (у интерфейсов методы уже есть в объявлении)
+func (l *goLoader) methodList(obj types.Object) string {
+	named, ok := obj.Type().(*types.Named)
+	if !ok || named.NumMethods() == 0 || types.IsInterface(named) {
+		return ""
+	}
+	var sb strings.Builder
+	sb.WriteString("\n// Methods:")
+	for i := 0; i < named.NumMethods(); i++ {
+		if i == maxGoDeclMethods {
+			fmt.Fprintf(&sb, "\n// … and %d more", named.NumMethods()-i)
+			break
+		}
+		m := named.Method(i)
+		s := l.source(m.Pos())
+		if s == nil {
+			continue
+		}
+		for _, decl := range s.file.Decls {
+			if d, ok := decl.(*ast.FuncDecl); ok && d.Name.Pos() == m.Pos() {
+				end := d.End()
+				if d.Body != nil {
+					end = d.Body.Lbrace
+				}
+				sig := s.src[goPkgFset.Position(d.Pos()).Offset:goPkgFset.Position(end).Offset]
+				fmt.Fprintf(&sb, "\n//   %s", strings.Join(strings.Fields(sig), " "))
+				break
+			}
+		}
+	}
+	return sb.String()
+}
+
+// clipGoDecl обрезает слишком длинное объявление
+func clipGoDecl(text string) string {
+	lines := strings.Split(text, "\n")
+	if len(lines) <= maxGoDeclLines {
+		return text
+	}
+	return strings.Join(lines[:maxGoDeclLines], "\n") + fmt.Sprintf("\n\t// … %d more lines", len(lines)-maxGoDeclLines)
+}
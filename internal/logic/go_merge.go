@@ -0,0 +1,419 @@
+package logic
+
+import (
+	"errors"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// ErrNoMatchingDecl — во фрагменте нет объявлений, которые есть в файле
+var ErrNoMatchingDecl = errors.New("no matching declaration found")
+
+// GoMergeResult — файл с объявлениями из фрагмента кода
+type GoMergeResult struct {
+	Text      string
+	WholeFile bool     // фрагмент — целый файл (есть package), он заменяет буфер
+	Replaced  []string // объявления файла, заменённые версиями из фрагмента
+	Added     []string // новые объявления, добавленные после заменённых
+}
+
+// MergeGoSnippet подставляет в исходник src объявления из фрагмента snippet
+// (например, исправленную функцию из ответа AI). Функции и методы сопоставляются
+// по имени и типу получателя, типы, переменные и константы — по имени;
+// объявления без пары добавляются после последнего заменённого. Недостающие
+// импорты фрагмента добавляются к импортам файла, имеющиеся не удаляются.
+// Фрагмент с package считается целым файлом.
+// Если src не разбирается (синтаксическая ошибка), объявления ищутся по строкам заголовков.
+func MergeGoSnippet(src, snippet string) (GoMergeResult, error) {
+	return mergeGoSnippet(src, snippet, false)
+}
+
+// MergeGoFix — MergeGoSnippet для исправления ошибок сборки: импорты фрагмента
+// (BuildFixPrompt просит полный блок) заменяют все импорты файла, чтобы
+// неиспользуемые импорты тоже удалялись
+func MergeGoFix(src, snippet string) (GoMergeResult, error) {
+	return mergeGoSnippet(src, snippet, true)
+}
+
+func mergeGoSnippet(src, snippet string, replaceImports bool) (GoMergeResult, error) {
+	fset := token.NewFileSet()
+	snipFile, shift, err := parseGoFragment(fset, snippet)
+	if err != nil {
+		return GoMergeResult{}, err
+	}
+	if shift == 0 {
+		return GoMergeResult{Text: snippet, WholeFile: true}, nil
+	}
+
+	file, err := parser.ParseFile(fset, "", src, parser.ParseComments)
+	if err != nil {
+		return mergeGoLines(src, snippet, fset, snipFile, shift, replaceImports)
+	}
+
+	existing := map[string]ast.Decl{}
+	for _, decl := range file.Decls {
+		if key := goDeclKey(decl); key != "" {
+			existing[key] = decl
+		}
+	}
+
+	var edits []goEdit
+	var res GoMergeResult
+	var added, imports []string
+	insertAt := -1
+	for _, decl := range snipFile.Decls {
+		if d, ok := decl.(*ast.GenDecl); ok && d.Tok == token.IMPORT {
+			start, end := goDeclSpan(fset, decl)
+			imports = append(imports, snippet[start-shift:end-shift])
+			continue
+		}
+		key := goDeclKey(decl)
+		if key == "" {
+			continue
+		}
+		start, end := goDeclSpan(fset, decl)
+		text := snippet[start-shift : end-shift]
+		if old, ok := existing[key]; ok {
+			oldStart, oldEnd := goDeclSpan(fset, old)
+			edits = append(edits, goEdit{oldStart, oldEnd, text})
+			res.Replaced = append(res.Replaced, key)
+			if oldEnd > insertAt {
+				insertAt = oldEnd
+			}
+			continue
+		}
+		added = append(added, text)
+		res.Added = append(res.Added, key)
+	}
+	switch {
+	case len(imports) > 0 && replaceImports:
+		edits = append(edits, replaceGoImports(fset, file, strings.Join(imports, "\n"), &res)...)
+	case len(imports) > 0:
+		edits = append(edits, addGoImports(fset, file, src, snippetImportSpecs(fset, snipFile, snippet, shift), &res)...)
+	}
+	if len(res.Replaced) == 0 {
+		return GoMergeResult{}, ErrNoMatchingDecl
+	}
+	if len(added) > 0 {
+		if insertAt < 0 {
+			insertAt = len(strings.TrimRight(src, "\n"))
+		}
+		edits = append(edits, goEdit{insertAt, insertAt, "\n\n" + strings.Join(added, "\n\n")})
+	}
+
+	res.Text = applyGoEdits(src, edits)
+	return res, nil
+}
+
+// goEdit — замена src[start:end] на text
+type goEdit struct {
+	start, end int
+	text       string
+}
+
+// applyGoEdits применяет правки с конца, чтобы смещения оставались верными
+func applyGoEdits(src string, edits []goEdit) string {
+	sort.SliceStable(edits, func(i, j int) bool { return edits[i].start > edits[j].start })
+	for _, e := range edits {
+		src = src[:e.start] + e.text + src[e.end:]
+	}
+	return src
+}
+
+// goHeaderRe — строка, с которой начинается объявление верхнего уровня
+var goHeaderRe = regexp.MustCompile(`^(?:func\s*(?:\(\s*(?:\w+\s+)?\*?\s*(\w+)[^)]*\)\s*)?(\w+)|(type|var|const)\s+(\w+)|import\b)`)
+
+// goHeaderKey — ключ объявления (как goDeclKey) по строке заголовка; "" — не объявление
+func goHeaderKey(line string) (key string, ok bool) {
+	m := goHeaderRe.FindStringSubmatch(line)
+	switch {
+	case m == nil:
+		return "", false
+	case m[2] != "" && m[1] != "":
+		return "func (" + m[1] + ") " + m[2], true
+	case m[2] != "":
+		return "func " + m[2], true
+	case m[3] != "":
+		return m[3] + " " + m[4], true
+	}
+	return "", true
+}
+
+// mergeGoLines — вариант MergeGoSnippet для src, который не разбирается: объявление
+// файла начинается со строки заголовка (вместе с комментариями над ней) и продолжается
+// до следующего объявления верхнего уровня
+func mergeGoLines(src, snippet string, fset *token.FileSet, snipFile *ast.File, shift int, replaceImports bool) (GoMergeResult, error) {
+	lines := SplitLines(src)
+	offsets := make([]int, len(lines)+1)
+	for i, line := range lines {
+		offsets[i+1] = offsets[i] + len(line) + 1
+	}
+	offsets[len(lines)] = len(src)
+
+	type declLines struct{ start, end int }
+	existing := map[string]declLines{}
+	var importLines []declLines
+	var headers []int
+	for i, line := range lines {
+		if _, ok := goHeaderKey(line); ok {
+			headers = append(headers, i)
+		}
+	}
+	isComment := func(i int) bool {
+		t := strings.TrimSpace(lines[i])
+		return strings.HasPrefix(t, "//") || t == ""
+	}
+	for n, h := range headers {
+		key, _ := goHeaderKey(lines[h])
+		end := len(lines)
+		if n+1 < len(headers) {
+			end = headers[n+1]
+			for end > h+1 && isComment(end-1) {
+				end--
+			}
+		}
+		start := h
+		for start > 0 && strings.HasPrefix(strings.TrimSpace(lines[start-1]), "//") {
+			start--
+		}
+		if key != "" {
+			existing[key] = declLines{start, end}
+		} else {
+			importLines = append(importLines, declLines{start, end})
+		}
+	}
+	// lineSpan — смещения строк [start, end) без перевода строки после последней
+	lineSpan := func(d declLines) (int, int) {
+		end := offsets[d.end]
+		if d.end < len(lines) || strings.HasSuffix(src, "\n") {
+			end--
+		}
+		return offsets[d.start], end
+	}
+
+	var edits []goEdit
+	var res GoMergeResult
+	var added, imports []string
+	for _, decl := range snipFile.Decls {
+		start, end := goDeclSpan(fset, decl)
+		text := snippet[start-shift : end-shift]
+		if d, ok := decl.(*ast.GenDecl); ok && d.Tok == token.IMPORT {
+			imports = append(imports, text)
+			continue
+		}
+		key := goDeclKey(decl)
+		if key == "" {
+			continue
+		}
+		if old, ok := existing[key]; ok {
+			oldStart, oldEnd := lineSpan(old)
+			edits = append(edits, goEdit{oldStart, oldEnd, text})
+			res.Replaced = append(res.Replaced, key)
+			continue
+		}
+		added = append(added, text)
+		res.Added = append(res.Added, key)
+	}
+	if len(imports) > 0 && len(importLines) > 0 && !replaceImports {
+		// Спецификации файла — строки объявлений import без "import", скобок и комментариев
+		have := map[string]bool{}
+		for _, d := range importLines {
+			for _, line := range lines[d.start:d.end] {
+				line, _, _ = strings.Cut(line, "//")
+				line = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(line), "import"))
+				have[goImportKey(strings.Trim(line, "() \t"))] = true
+			}
+		}
+		missing := missingGoImports(have, snippetImportSpecs(fset, snipFile, snippet, shift), &res)
+		if len(missing) > 0 {
+			first := importLines[0]
+			if last := first.end - 1; strings.TrimSpace(lines[last]) == ")" {
+				edits = append(edits, goEdit{offsets[last], offsets[last], "\t" + strings.Join(missing, "\n\t") + "\n"})
+			} else {
+				_, end := lineSpan(first)
+				edits = append(edits, goEdit{end, end, "\nimport " + strings.Join(missing, "\nimport ")})
+			}
+		}
+	} else if len(imports) > 0 && len(importLines) > 0 {
+		for i, d := range importLines {
+			start, end := lineSpan(d)
+			text := ""
+			if i == 0 {
+				text = strings.Join(imports, "\n")
+			}
+			edits = append(edits, goEdit{start, end, text})
+		}
+		res.Replaced = append(res.Replaced, "imports")
+	}
+	if len(res.Replaced) == 0 {
+		return GoMergeResult{}, ErrNoMatchingDecl
+	}
+	if len(added) > 0 {
+		at := len(strings.TrimRight(src, "\n"))
+		edits = append(edits, goEdit{at, at, "\n\n" + strings.Join(added, "\n\n")})
+	}
+	res.Text = applyGoEdits(src, edits)
+	return res, nil
+}
+
+// replaceGoImports заменяет импорты файла блоком text: первое объявление import
+// заменяется, остальные удаляются; без импортов блок вставляется после package
+func replaceGoImports(fset *token.FileSet, file *ast.File, text string, res *GoMergeResult) []goEdit {
+	var edits []goEdit
+	for _, decl := range file.Decls {
+		if d, ok := decl.(*ast.GenDecl); ok && d.Tok == token.IMPORT {
+			start, end := goDeclSpan(fset, decl)
+			edits = append(edits, goEdit{start, end, ""})
+		}
+	}
+	if len(edits) == 0 {
+		pos := fset.Position(file.Name.End()).Offset
+		res.Added = append(res.Added, "imports")
+		return []goEdit{{pos, pos, "\n\n" + text}}
+	}
+	edits[0].text = text
+	res.Replaced = append(res.Replaced, "imports")
+	return edits
+}
+
+// snippetImportSpecs возвращает спецификации импортов фрагмента (`name "path"`)
+// без комментариев
+func snippetImportSpecs(fset *token.FileSet, snipFile *ast.File, snippet string, shift int) []string {
+	specs := make([]string, len(snipFile.Imports))
+	for i, imp := range snipFile.Imports {
+		specs[i] = snippet[fset.Position(imp.Pos()).Offset-shift : fset.Position(imp.End()).Offset-shift]
+	}
+	return specs
+}
+
+// goImportKey — спецификация импорта без различий в пробелах
+func goImportKey(spec string) string {
+	return strings.Join(strings.Fields(spec), " ")
+}
+
+// missingGoImports отбирает спецификации, которых нет в have, и отмечает их в res.Added
+func missingGoImports(have map[string]bool, specs []string, res *GoMergeResult) []string {
+	var missing []string
+	for _, spec := range specs {
+		if key := goImportKey(spec); !have[key] {
+			have[key] = true
+			missing = append(missing, spec)
+			res.Added = append(res.Added, "import "+spec)
+		}
+	}
+	return missing
+}
+
+// addGoImports добавляет недостающие импорты: в первое объявление import со скобками,
+// после объявления без скобок или, если импортов нет, после package
+func addGoImports(fset *token.FileSet, file *ast.File, src string, specs []string, res *GoMergeResult) []goEdit {
+	have := map[string]bool{}
+	for _, imp := range file.Imports {
+		have[goImportKey(src[fset.Position(imp.Pos()).Offset:fset.Position(imp.End()).Offset])] = true
+	}
+	missing := missingGoImports(have, specs, res)
+	if len(missing) == 0 {
+		return nil
+	}
+	for _, decl := range file.Decls {
+		d, ok := decl.(*ast.GenDecl)
+		if !ok || d.Tok != token.IMPORT {
+			continue
+		}
+		if d.Rparen.IsValid() {
+			pos := fset.Position(d.Rparen).Offset
+			text := "\t" + strings.Join(missing, "\n\t") + "\n"
+			if !strings.HasSuffix(src[:pos], "\n") {
+				text = "\n" + text
+			}
+			return []goEdit{{pos, pos, text}}
+		}
+		_, end := goDeclSpan(fset, decl)
+		return []goEdit{{end, end, "\nimport " + strings.Join(missing, "\nimport ")}}
+	}
+	pos := fset.Position(file.Name.End()).Offset
+	if len(missing) == 1 {
+		return []goEdit{{pos, pos, "\n\nimport " + missing[0]}}
+	}
+	return []goEdit{{pos, pos, "\n\nimport (\n\t" + strings.Join(missing, "\n\t") + "\n)"}}
+}
+
+// parseGoFragment разбирает фрагмент; без package к нему добавляется заголовок,
+// shift — его длина (0 — фрагмент является файлом)
+func parseGoFragment(fset *token.FileSet, snippet string) (*ast.File, int, error) {
+	if f, err := parser.ParseFile(fset, "", snippet, parser.ParseComments); err == nil {
+		return f, 0, nil
+	}
+	const header = "package fragment\n\n"
+	f, err := parser.ParseFile(fset, "", header+snippet, parser.ParseComments)
+	if err != nil {
+		return nil, 0, err
+	}
+	return f, len(header), nil
+}
+
+// goDeclSpan возвращает смещения объявления вместе с doc-комментарием
+func goDeclSpan(fset *token.FileSet, decl ast.Decl) (start, end int) {
+	pos := decl.Pos()
+	switch d := decl.(type) {
+	case *ast.FuncDecl:
+		if d.Doc != nil {
+			pos = d.Doc.Pos()
+		}
+	case *ast.GenDecl:
+		if d.Doc != nil {
+			pos = d.Doc.Pos()
+		}
+	}
+	return fset.Position(pos).Offset, fset.Position(decl.End()).Offset
+}
+
+// goDeclKey — ключ сопоставления объявления: "func Name", "func (T) Name", "type T", "var x"
+func goDeclKey(decl ast.Decl) string {
+	switch d := decl.(type) {
+	case *ast.FuncDecl:
+		if d.Recv != nil && len(d.Recv.List) > 0 {
+			return "func (" + receiverTypeName(d.Recv.List[0].Type) + ") " + d.Name.Name
+		}
+		return "func " + d.Name.Name
+	case *ast.GenDecl:
+		if d.Tok == token.IMPORT || len(d.Specs) != 1 {
+			return ""
+		}
+		switch s := d.Specs[0].(type) {
+		case *ast.TypeSpec:
+			return "type " + s.Name.Name
+		case *ast.ValueSpec:
+			names := make([]string, len(s.Names))
+			for i, n := range s.Names {
+				names[i] = n.Name
+			}
+			return d.Tok.String() + " " + strings.Join(names, ", ")
+		}
+	}
+	return ""
+}
+
+// receiverTypeName возвращает имя типа получателя без "*" и параметров типа
+func receiverTypeName(expr ast.Expr) string {
+	for {
+		switch t := expr.(type) {
+		case *ast.StarExpr:
+			expr = t.X
+		case *ast.IndexExpr:
+			expr = t.X
+		case *ast.IndexListExpr:
+			expr = t.X
+		case *ast.ParenExpr:
+			expr = t.X
+		case *ast.Ident:
+			return t.Name
+		default:
+			return ""
+		}
+	}
+}
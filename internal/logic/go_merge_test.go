@@ -0,0 +1,80 @@
+package logic
+
+import (
+	"strings"
+	"testing"
+)
+
+const mergeSrc = `package main
+
+import (
+	"os"
+	"strings"
+)
+
+func run() error {
+	return nil
+}
+
+func main() {
+	_ = strings.TrimSpace(os.Args[0])
+}
+`
+
+const mergeSnippet = `import (
+	"fmt"
+	"os"
+)
+
+func run() error {
+	return fmt.Errorf("failed")
+}
+`
+
+func TestMergeGoSnippetAddsImports(t *testing.T) {
+	res, err := MergeGoSnippet(mergeSrc, mergeSnippet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := strings.Replace(mergeSrc, "\t\"strings\"\n)", "\t\"strings\"\n\t\"fmt\"\n)", 1)
+	want = strings.Replace(want, "return nil", `return fmt.Errorf("failed")`, 1)
+	if res.Text != want {
+		t.Fatalf("merged:\n%s", res.Text)
+	}
+	if strings.Join(res.Added, ",") != `import "fmt"` {
+		t.Fatalf("added: %q", res.Added)
+	}
+}
+
+func TestMergeGoSnippetAddsImportsToBrokenFile(t *testing.T) {
+	src := strings.Replace(mergeSrc, "func main() {", "func main() {{", 1)
+	res, err := MergeGoSnippet(src, mergeSnippet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(res.Text, "\t\"strings\"\n\t\"fmt\"\n)") || !strings.Contains(res.Text, `return fmt.Errorf("failed")`) {
+		t.Fatalf("merged:\n%s", res.Text)
+	}
+}
+
+func TestMergeGoFixReplacesImports(t *testing.T) {
+	res, err := MergeGoFix(mergeSrc, mergeSnippet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(res.Text, `"strings"`) || !strings.Contains(res.Text, "import (\n\t\"fmt\"\n\t\"os\"\n)") {
+		t.Fatalf("merged:\n%s", res.Text)
+	}
+}
+
+func TestMergeGoSnippetSingleImport(t *testing.T) {
+	src := "package main\n\nfunc run() {}\n"
+	res, err := MergeGoSnippet(src, "import \"fmt\"\n\nfunc run() { fmt.Println() }")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "package main\n\nimport \"fmt\"\n\nfunc run() { fmt.Println() }\n"
+	if res.Text != want {
+		t.Fatalf("merged:\n%q", res.Text)
+	}
+}
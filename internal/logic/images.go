@@ -0,0 +1,98 @@
+package logic
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+)
+
+// MaxImageBytes ограничивает размер картинки, прикладываемой к запросу
+// (у Anthropic предел — 5 МБ на картинку)
+const MaxImageBytes = 5 * 1024 * 1024
+
+// ErrUnsupportedImage возвращается для файлов, которые не являются PNG или JPEG
+var ErrUnsupportedImage = errors.New("only PNG and JPEG images are supported")
+
+// visionModelPrefixes — начала имён моделей, принимающих картинки
+// (сравниваются без "vendor/", как в lookupFIMTemplate)
+var visionModelPrefixes = []string{
+	"gpt-4o", "gpt-4.1", "gpt-4-turbo", "gpt-4-vision", "gpt-5", "o1", "o3", "o4",
+	"claude-3", "claude-sonnet-4", "claude-opus-4", "claude-haiku-4",
+	"gemini", "gemma3", "gemma-3",
+	"llava", "bakllava", "llama3.2-vision", "llama-3.2-11b-vision", "llama-3.2-90b-vision", "llama4",
+	"qwen2.5vl", "qwen2.5-vl", "qwen-vl", "qwen2-vl", "qwen3-vl",
+	"minicpm-v", "moondream", "pixtral", "mistral-small3.1", "granite3.2-vision",
+	"openai", // Pollinations
+}
+
+var (
+	visionMu     sync.RWMutex
+	visionExtras []string
+)
+
+// SetVisionModels добавляет начала имён моделей, которые принимают картинки
+// (поле "vision_models" настроек)
+func SetVisionModels(prefixes []string) {
+	visionMu.Lock()
+	defer visionMu.Unlock()
+	visionExtras = append([]string(nil), prefixes...)
+}
+
+// ModelSupportsVision сообщает, известно ли, что модель принимает картинки
+func ModelSupportsVision(model string) bool {
+	name := strings.ToLower(strings.TrimSpace(model))
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		name = name[i+1:]
+	}
+	if name == "" {
+		return false
+	}
+
+	visionMu.RLock()
+	prefixes := append(append([]string(nil), visionModelPrefixes...), visionExtras...)
+	visionMu.RUnlock()
+	for _, p := range prefixes {
+		if strings.HasPrefix(name, strings.ToLower(p)) {
+			return true
+		}
+	}
+	return false
+}
+
+// ImageDataURL кодирует PNG или JPEG в data URL для поля images запроса
+func ImageDataURL(data []byte) (string, error) {
+	mime := http.DetectContentType(data)
+	if mime != "image/png" && mime != "image/jpeg" {
+		return "", fmt.Errorf("%w (got %s)", ErrUnsupportedImage, mime)
+	}
+	if len(data) > MaxImageBytes {
+		return "", fmt.Errorf("image is too large: %d KB (max %d KB)", len(data)/1024, MaxImageBytes/1024)
+	}
+	return "data:" + mime + ";base64," + base64.StdEncoding.EncodeToString(data), nil
+}
+
+// LoadImageDataURL читает файл картинки и кодирует его в data URL
+func LoadImageDataURL(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read image: %w", err)
+	}
+	return ImageDataURL(data)
+}
+
+// DecodeImageDataURL возвращает содержимое картинки из data URL (например, для миниатюры)
+func DecodeImageDataURL(url string) ([]byte, string, error) {
+	mime, data, ok := parseDataURL(url)
+	if !ok {
+		return nil, "", errors.New("not a base64 data URL")
+	}
+	raw, err := base64.StdEncoding.DecodeString(data)
+	if err != nil {
+		return nil, "", fmt.Errorf("invalid image data: %w", err)
+	}
+	return raw, mime, nil
+}
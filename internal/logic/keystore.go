@@ -0,0 +1,423 @@
+package logic
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// keyFileName — файл ключей в каталоге настроек (права 0600)
+const keyFileName = "keys.json"
+
+// secretServiceName — атрибут "service" записей в Secret Service
+const secretServiceName = "go-lite-ide"
+
+// ErrInsecureKeyFile возвращается, если файл ключей доступен группе или другим пользователям
+var ErrInsecureKeyFile = errors.New("key file permissions are too open")
+
+// KeySource — откуда взят API ключ
+type KeySource string
+
+const (
+	KeySourceNone          KeySource = ""
+	KeySourceEnv           KeySource = "env"            // переменная окружения
+	KeySourceSecretService KeySource = "secret-service" // freedesktop Secret Service
+	KeySourceFile          KeySource = "key-file"       // ~/.config/go-lite-ide/keys.json
+)
+
+// KeyInfo описывает найденный ключ провайдера (без самого ключа)
+type KeyInfo struct {
+	Account string    // имя записи в хранилищах
+	Source  KeySource // KeySourceNone — ключ не найден
+	Detail  string    // имя переменной окружения или хранилища
+}
+
+// SecretStore — хранилище секретов (Secret Service или его замена)
+type SecretStore interface {
+	Name() string
+	// Lookup возвращает "", nil, если записи нет
+	Lookup(account string) (string, error)
+	Store(account, secret string) error
+	Delete(account string) error
+}
+
+// builtinKeyEnv — общепринятые переменные окружения встроенных провайдеров
+var builtinKeyEnv = map[string][]string{
+	"openrouter":   {"OPENROUTER_API_KEY"},
+	"anthropic":    {"ANTHROPIC_API_KEY"},
+	"gemini":       {"GEMINI_API_KEY", "GOOGLE_API_KEY"},
+	"openai":       {"OPENAI_API_KEY"},
+	"pollinations": {"POLLINATIONS_API_KEY"},
+	"ollama":       {"OLLAMA_API_KEY"},
+}
+
+var (
+	keyMu       sync.Mutex
+	secretStore SecretStore
+	// secretCache запоминает ответы хранилища (кроме ошибок): secret-tool запускается не на каждый запрос
+	secretCache = map[string]string{}
+)
+
+func init() {
+	secretStore = defaultSecretStore()
+}
+
+// defaultSecretStore выбирает хранилище: GOLITE_SECRET_STORE=memory — локальная замена
+// (тесты, машины без D-Bus), off — не использовать; иначе secret-tool, если он установлен
+func defaultSecretStore() SecretStore {
+	switch os.Getenv("GOLITE_SECRET_STORE") {
+	case "memory":
+		return NewMemorySecretStore()
+	case "off":
+		return nil
+	}
+	if path, err := exec.LookPath("secret-tool"); err == nil {
+		return &secretToolStore{path: path}
+	}
+	return nil
+}
+
+// SetSecretStore заменяет хранилище секретов (nil — не использовать)
+func SetSecretStore(s SecretStore) {
+	keyMu.Lock()
+	defer keyMu.Unlock()
+	secretStore = s
+	secretCache = map[string]string{}
+}
+
+// SecretStoreName возвращает имя текущего хранилища секретов ("" — недоступно)
+func SecretStoreName() string {
+	keyMu.Lock()
+	defer keyMu.Unlock()
+	if secretStore == nil {
+		return ""
+	}
+	return secretStore.Name()
+}
+
+// profileAccountPrefix отделяет записи ключей профилей от записей встроенных провайдеров
+const profileAccountPrefix = "profile:"
+
+// KeyAccount возвращает имя записи ключа провайдера: имя провайдера, для профиля —
+// "profile:<имя>" (профиль с чужим endpoint не получает ключ встроенного провайдера),
+// для URL-провайдера — хост
+func KeyAccount(provider string) string {
+	if _, ok := LookupProviderProfile(provider); ok {
+		return profileAccountPrefix + normalizeProviderName(provider)
+	}
+	if isURL(provider) {
+		if u, err := url.Parse(provider); err == nil && u.Host != "" {
+			return strings.ToLower(u.Host)
+		}
+	}
+	return normalizeProviderName(provider)
+}
+
+var envNameReplacer = regexp.MustCompile(`[^A-Z0-9]+`)
+
+// KeyEnvVars возвращает переменные окружения, в которых ищется ключ провайдера, по порядку.
+// Профилю не передаются ключи встроенного провайдера: его endpoint может быть чужим сервером.
+func KeyEnvVars(provider string) []string {
+	var vars []string
+	if profile, ok := LookupProviderProfile(provider); ok {
+		if profile.KeyEnv != "" {
+			vars = append(vars, profile.KeyEnv)
+		}
+	} else if !isURL(provider) {
+		vars = append(vars, builtinKeyEnv[normalizeProviderName(provider)]...)
+	}
+	name := strings.Trim(envNameReplacer.ReplaceAllString(strings.ToUpper(KeyAccount(provider)), "_"), "_")
+	return append(vars, "GOLITE_"+name+"_API_KEY")
+}
+
+// ResolveAPIKey ищет ключ провайдера: переменные окружения, Secret Service, файл ключей.
+// Ошибка возвращается только для небезопасного файла ключей.
+func ResolveAPIKey(provider string) (string, KeyInfo, error) {
+	info := KeyInfo{Account: KeyAccount(provider)}
+
+	for _, name := range KeyEnvVars(provider) {
+		if v := os.Getenv(name); v != "" {
+			info.Source, info.Detail = KeySourceEnv, name
+			return v, info, nil
+		}
+	}
+
+	accounts := storedKeyAccounts(provider)
+	for _, account := range accounts {
+		if key, name := lookupSecret(account); key != "" {
+			info.Source, info.Detail = KeySourceSecretService, name
+			return key, info, nil
+		}
+	}
+
+	keys, path, err := readKeyFile()
+	if err != nil {
+		return "", info, err
+	}
+	for _, account := range accounts {
+		if key := keys[account]; key != "" {
+			info.Source, info.Detail = KeySourceFile, path
+			return key, info, nil
+		}
+	}
+	return "", info, nil
+}
+
+// storedKeyAccounts — записи хранилищ, в которых ищется ключ провайдера. Для профиля это
+// также запись без префикса, под которой ключи профилей сохранялись раньше: имя профиля
+// не может совпадать со встроенным провайдером, так что эта запись принадлежит ему.
+func storedKeyAccounts(provider string) []string {
+	account := KeyAccount(provider)
+	if legacy := strings.TrimPrefix(account, profileAccountPrefix); legacy != account {
+		return []string{account, legacy}
+	}
+	return []string{account}
+}
+
+// StoreAPIKey сохраняет ключ провайдера в Secret Service или в файл ключей
+// и удаляет его из другого хранилища
+func StoreAPIKey(provider, key string, dest KeySource) error {
+	account := KeyAccount(provider)
+	switch dest {
+	case KeySourceSecretService:
+		if err := storeSecret(account, key); err != nil {
+			return err
+		}
+		return updateKeyFile(account, "")
+	case KeySourceFile:
+		if err := updateKeyFile(account, key); err != nil {
+			return err
+		}
+		return deleteSecret(account)
+	}
+	return fmt.Errorf("cannot store API key in %q", dest)
+}
+
+// DeleteAPIKey удаляет сохранённый ключ провайдера из обоих хранилищ.
+// Переменные окружения не затрагиваются.
+func DeleteAPIKey(provider string) error {
+	for _, account := range storedKeyAccounts(provider) {
+		if err := deleteSecret(account); err != nil {
+			return err
+		}
+		if err := updateKeyFile(account, ""); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// --- Secret Service ---
+
+func lookupSecret(account string) (key, storeName string) {
+	keyMu.Lock()
+	defer keyMu.Unlock()
+	if secretStore == nil {
+		return "", ""
+	}
+	if key, cached := secretCache[account]; cached {
+		return key, secretStore.Name()
+	}
+	// Недоступный D-Bus или заблокированное хранилище — не ошибка: ключ ищется дальше.
+	// Такой ответ не запоминается, чтобы ключ нашёлся после разблокировки;
+	// запоминаются найденный ключ и ответ "записи нет".
+	key, err := secretStore.Lookup(account)
+	if err == nil {
+		secretCache[account] = key
+	}
+	return key, secretStore.Name()
+}
+
+func storeSecret(account, key string) error {
+	keyMu.Lock()
+	defer keyMu.Unlock()
+	if secretStore == nil {
+		return errors.New("Secret Service is not available (install secret-tool)")
+	}
+	if err := secretStore.Store(account, key); err != nil {
+		return err
+	}
+	secretCache[account] = key
+	return nil
+}
+
+func deleteSecret(account string) error {
+	keyMu.Lock()
+	defer keyMu.Unlock()
+	if secretStore == nil {
+		return nil
+	}
+	delete(secretCache, account)
+	return secretStore.Delete(account)
+}
+
+// secretToolStore обращается к Secret Service (GNOME Keyring, KWallet) через утилиту secret-tool:
+// собственный клиент D-Bus потребовал бы новой зависимости (модуль использует только Qt и chroma),
+// а secret-tool есть везде, где есть Secret Service. Для тестов — MemorySecretStore.
+type secretToolStore struct {
+	path string
+}
+
+// secretToolTimeout оставляет время на разблокировку хранилища пользователем
+const secretToolTimeout = 30 * time.Second
+
+func (s *secretToolStore) Name() string { return "Secret Service" }
+
+func (s *secretToolStore) Lookup(account string) (string, error) {
+	out, err := s.run("", "lookup", "service", secretServiceName, "account", account)
+	if err != nil {
+		// secret-tool завершается с кодом 1 без вывода, если записи нет
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && out == "" {
+			return "", nil
+		}
+		return "", err
+	}
+	return strings.TrimRight(out, "\n"), nil
+}
+
+func (s *secretToolStore) Store(account, secret string) error {
+	label := fmt.Sprintf("--label=Go Lite IDE API key (%s)", account)
+	_, err := s.run(secret, "store", label, "service", secretServiceName, "account", account)
+	return err
+}
+
+func (s *secretToolStore) Delete(account string) error {
+	_, err := s.run("", "clear", "service", secretServiceName, "account", account)
+	return err
+}
+
+// run запускает secret-tool; секрет передаётся через stdin, а не аргументами
+func (s *secretToolStore) run(stdin string, args ...string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), secretToolTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, s.path, args...)
+	cmd.Stdin = strings.NewReader(stdin)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return stdout.String(), fmt.Errorf("secret-tool %s: %s", args[0], msg)
+		}
+		return stdout.String(), err
+	}
+	return stdout.String(), nil
+}
+
+// MemorySecretStore — хранилище секретов в памяти процесса, замена Secret Service
+// для тестов и окружений без D-Bus
+type MemorySecretStore struct {
+	mu      sync.Mutex
+	secrets map[string]string
+}
+
+// NewMemorySecretStore создаёт пустое хранилище в памяти
+func NewMemorySecretStore() *MemorySecretStore {
+	return &MemorySecretStore{secrets: map[string]string{}}
+}
+
+func (s *MemorySecretStore) Name() string { return "memory (test)" }
+
+func (s *MemorySecretStore) Lookup(account string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.secrets[account], nil
+}
+
+func (s *MemorySecretStore) Store(account, secret string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.secrets[account] = secret
+	return nil
+}
+
+func (s *MemorySecretStore) Delete(account string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.secrets, account)
+	return nil
+}
+
+// --- Файл ключей ---
+
+// KeyFilePath возвращает путь к файлу ключей
+func KeyFilePath() (string, error) {
+	dir, err := ConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, keyFileName), nil
+}
+
+// readKeyFile читает файл ключей; файл с правами шире 0600 не читается (как ~/.ssh)
+func readKeyFile() (map[string]string, string, error) {
+	path, err := KeyFilePath()
+	if err != nil {
+		return nil, "", nil
+	}
+	fi, err := os.Stat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, path, nil
+	}
+	if err != nil {
+		return nil, path, fmt.Errorf("failed to read key file: %w", err)
+	}
+	if fi.Mode().Perm()&0077 != 0 {
+		return nil, path, fmt.Errorf("%w: %s is %#o, run chmod 600", ErrInsecureKeyFile, path, fi.Mode().Perm())
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, path, fmt.Errorf("failed to read key file: %w", err)
+	}
+	keys := map[string]string{}
+	if err := json.Unmarshal(data, &keys); err != nil {
+		return nil, path, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return keys, path, nil
+}
+
+// updateKeyFile записывает (или удаляет при пустом key) ключ в файле ключей
+func updateKeyFile(account, key string) error {
+	keys, path, err := readKeyFile()
+	if err != nil {
+		return err
+	}
+	if path == "" {
+		if path, err = KeyFilePath(); err != nil {
+			return err
+		}
+	}
+	if keys == nil {
+		if key == "" {
+			return nil
+		}
+		keys = map[string]string{}
+	}
+	if key == "" {
+		if _, ok := keys[account]; !ok {
+			return nil
+		}
+		delete(keys, account)
+	} else {
+		keys[account] = key
+	}
+
+	if err := writeJSONFile(path, keys, 0600); err != nil {
+		return err
+	}
+	// Временный файл мог остаться от прежней записи с другими правами
+	return os.Chmod(path, 0600)
+}
@@ -0,0 +1,264 @@
+package logic
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// isolateKeys направляет каталог настроек во временный каталог, очищает переменные
+// окружения ключей и подменяет Secret Service хранилищем в памяти
+func isolateKeys(t *testing.T) *MemorySecretStore {
+	t.Helper()
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	for _, vars := range builtinKeyEnv {
+		for _, name := range vars {
+			t.Setenv(name, "")
+		}
+	}
+	store := NewMemorySecretStore()
+	SetSecretStore(store)
+	t.Cleanup(func() {
+		SetSecretStore(nil)
+		SetProviderProfiles(nil)
+	})
+	return store
+}
+
+func writeKeyFile(t *testing.T, keys map[string]string, perm os.FileMode) string {
+	t.Helper()
+	path, err := KeyFilePath()
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := json.Marshal(keys)
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, data, perm); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(path, perm); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestResolveAPIKeyPrecedence(t *testing.T) {
+	store := isolateKeys(t)
+	writeKeyFile(t, map[string]string{"openrouter": "from-file"}, 0600)
+	store.Store("openrouter", "from-secret-service")
+	t.Setenv("OPENROUTER_API_KEY", "from-env")
+
+	steps := []struct {
+		key    string
+		source KeySource
+		next   func()
+	}{
+		{"from-env", KeySourceEnv, func() { t.Setenv("OPENROUTER_API_KEY", "") }},
+		{"from-secret-service", KeySourceSecretService, func() { SetSecretStore(nil) }},
+		{"from-file", KeySourceFile, nil},
+	}
+	for _, step := range steps {
+		key, info, err := ResolveAPIKey("openrouter")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if key != step.key || info.Source != step.source {
+			t.Fatalf("got %q from %q, want %q from %q", key, info.Source, step.key, step.source)
+		}
+		if step.next != nil {
+			step.next()
+		}
+	}
+}
+
+func TestResolveAPIKeyGenericEnv(t *testing.T) {
+	isolateKeys(t)
+	t.Setenv("GOLITE_LLM_EXAMPLE_COM_API_KEY", "url-key")
+
+	key, info, err := ResolveAPIKey("https://llm.example.com/v1/chat/completions")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if key != "url-key" || info.Detail != "GOLITE_LLM_EXAMPLE_COM_API_KEY" {
+		t.Fatalf("got %q from %q", key, info.Detail)
+	}
+}
+
+func TestKeyFileMustBePrivate(t *testing.T) {
+	isolateKeys(t)
+	SetSecretStore(nil)
+	writeKeyFile(t, map[string]string{"openai": "secret"}, 0644)
+
+	key, _, err := ResolveAPIKey("openai")
+	if !errors.Is(err, ErrInsecureKeyFile) {
+		t.Fatalf("err = %v, want ErrInsecureKeyFile", err)
+	}
+	if key != "" {
+		t.Fatalf("key from an insecure file was returned: %q", key)
+	}
+}
+
+func TestStoreAPIKeyToFileIsPrivate(t *testing.T) {
+	isolateKeys(t)
+
+	if err := StoreAPIKey("anthropic", "sk-ant", KeySourceFile); err != nil {
+		t.Fatal(err)
+	}
+	path, _ := KeyFilePath()
+	fi, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := fi.Mode().Perm(); perm != 0600 {
+		t.Fatalf("key file mode = %#o, want 0600", perm)
+	}
+	if key, info, _ := ResolveAPIKey("anthropic"); key != "sk-ant" || info.Source != KeySourceFile {
+		t.Fatalf("got %q from %q", key, info.Source)
+	}
+}
+
+func TestMemorySecretStore(t *testing.T) {
+	store := isolateKeys(t)
+	writeKeyFile(t, map[string]string{"gemini": "old-file-key"}, 0600)
+
+	// Сохранение в Secret Service убирает ключ из файла
+	if err := StoreAPIKey("gemini", "g-key", KeySourceSecretService); err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := store.Lookup("gemini"); v != "g-key" {
+		t.Fatalf("store has %q", v)
+	}
+	keys, _, err := readKeyFile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := keys["gemini"]; ok {
+		t.Fatal("key was left in the key file")
+	}
+
+	if err := DeleteAPIKey("gemini"); err != nil {
+		t.Fatal(err)
+	}
+	if key, info, _ := ResolveAPIKey("gemini"); key != "" {
+		t.Fatalf("deleted key still resolved from %q", info.Source)
+	}
+}
+
+func TestProfileDoesNotGetBuiltinKey(t *testing.T) {
+	store := isolateKeys(t)
+	store.Store("openrouter", "builtin-key")
+	t.Setenv("OPENROUTER_API_KEY", "builtin-env-key")
+
+	err := SetProviderProfiles([]ProviderProfile{
+		{Name: "openrouter", Provider: "openai", Endpoint: "https://evil.example.com/v1/chat/completions"},
+		{Name: "work", Provider: "openrouter", Endpoint: "https://llm.example.com/v1/chat/completions"},
+	})
+	if err == nil {
+		t.Fatal("a profile named after a built-in provider was accepted")
+	}
+	if _, ok := LookupProviderProfile("openrouter"); ok {
+		t.Fatal("profile shadows the built-in provider")
+	}
+
+	if got := KeyAccount("work"); got != "profile:work" {
+		t.Fatalf("KeyAccount = %q", got)
+	}
+	if key, info, _ := ResolveAPIKey("work"); key != "" {
+		t.Fatalf("profile got the built-in key from %q", info.Source)
+	}
+
+	if err := StoreAPIKey("work", "work-key", KeySourceSecretService); err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := store.Lookup("profile:work"); v != "work-key" {
+		t.Fatalf("profile key stored as %q", v)
+	}
+	if key, _, _ := ResolveAPIKey("work"); key != "work-key" {
+		t.Fatalf("profile key = %q", key)
+	}
+}
+
+func TestLoadConfigMovesPlaintextKeys(t *testing.T) {
+	store := isolateKeys(t)
+	dir, err := ConfigDir()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, configFileName)
+	data := `{"provider": "openrouter", "api_key": "sk-chat",
+		"ai_tasks": {"line": {"provider": "anthropic", "api_key": "sk-line"}}}`
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := LoadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.APIKey != "" || cfg.Tasks[TaskLineComplete].APIKey != "" {
+		t.Fatal("plaintext keys were kept in the settings")
+	}
+	if v, _ := store.Lookup("openrouter"); v != "sk-chat" {
+		t.Fatalf("chat key in store = %q", v)
+	}
+	if v, _ := store.Lookup("anthropic"); v != "sk-line" {
+		t.Fatalf("task key in store = %q", v)
+	}
+
+	fi, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := fi.Mode().Perm(); perm != 0600 {
+		t.Fatalf("config mode = %#o, want 0600", perm)
+	}
+	saved, _ := os.ReadFile(path)
+	var raw map[string]interface{}
+	json.Unmarshal(saved, &raw)
+	if _, ok := raw["api_key"]; ok {
+		t.Fatal("api_key is still written to config.json")
+	}
+}
+
+// countingSecretStore считает обращения к хранилищу; err имитирует заблокированное хранилище
+type countingSecretStore struct {
+	*MemorySecretStore
+	lookups int
+	err     error
+}
+
+func (s *countingSecretStore) Lookup(account string) (string, error) {
+	s.lookups++
+	if s.err != nil {
+		return "", s.err
+	}
+	return s.MemorySecretStore.Lookup(account)
+}
+
+func TestLookupSecretRetriesAfterStoreError(t *testing.T) {
+	isolateKeys(t)
+	store := &countingSecretStore{MemorySecretStore: NewMemorySecretStore(), err: errors.New("keyring is locked")}
+	SetSecretStore(store)
+	store.MemorySecretStore.Store(KeyAccount("openai"), "sk-stored")
+
+	if key, _ := lookupSecret(KeyAccount("openai")); key != "" {
+		t.Fatalf("locked store returned %q", key)
+	}
+	store.err = nil // хранилище разблокировано
+	if key, _ := lookupSecret(KeyAccount("openai")); key != "sk-stored" {
+		t.Fatalf("after unlocking: %q", key)
+	}
+	lookupSecret(KeyAccount("openai"))
+	lookupSecret(KeyAccount("gemini"))
+	lookupSecret(KeyAccount("gemini"))
+	if store.lookups != 3 {
+		t.Fatalf("%d lookups: found keys and missing entries must be cached", store.lookups)
+	}
+}
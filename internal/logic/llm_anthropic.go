@@ -0,0 +1,204 @@
+package logic
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const (
+	anthropicDefaultURL = "https://api.anthropic.com/v1/messages"
+	anthropicVersion    = "2023-06-01"
+	// max_tokens обязателен в Messages API
+	anthropicMaxTokens = 4096
+)
+
+// AnthropicProvider — нативный Anthropic Messages API (POST /v1/messages).
+// Авторизация через x-api-key, системный промпт — отдельное поле "system",
+// содержимое сообщений — массив блоков (text, image).
+type AnthropicProvider struct{ ProviderOptions }
+
+func (p *AnthropicProvider) request(history []Message, images []string) (string, map[string]interface{}, map[string]string) {
+	system, msgs := anthropicMessages(history, images, p.SystemPrompt)
+
+	payload := map[string]interface{}{
+		"model":      p.Model,
+		"max_tokens": anthropicMaxTokens,
+		"messages":   msgs,
+	}
+	if system != "" {
+		payload["system"] = system
+	}
+
+	headers := withHeaders(p.Headers, map[string]string{
+		"x-api-key":         p.Key,
+		"anthropic-version": anthropicVersion,
+	})
+	return p.endpoint(anthropicDefaultURL), payload, headers
+}
+
+func (p *AnthropicProvider) Send(ctx context.Context, history []Message, images []string) (string, error) {
+	url, payload, headers := p.request(history, images)
+
+	// Ключ передаётся в x-api-key, а не в Authorization: Bearer
+	respBody, err := postJSON(ctx, url, payload, "", headers)
+	if err != nil {
+		return "", anthropicError(err)
+	}
+
+	if msg := anthropicErrorMessage(respBody); msg != "" {
+		return "", errors.New(msg)
+	}
+
+	var r struct {
+		Content []struct {
+			Type string `json:"type"`
+			Text string `json:"text"`
+		} `json:"content"`
+		StopReason string `json:"stop_reason"`
+	}
+	if err := json.Unmarshal(respBody, &r); err != nil {
+		return "", fmt.Errorf("failed to parse anthropic response: %w", err)
+	}
+
+	var sb strings.Builder
+	for _, block := range r.Content {
+		if block.Type == "text" {
+			sb.WriteString(block.Text)
+		}
+	}
+	if sb.Len() == 0 {
+		return "", fmt.Errorf("empty anthropic response (stop_reason: %s)", r.StopReason)
+	}
+	return sb.String(), nil
+}
+
+// SendStream читает события content_block_delta до message_stop
+func (p *AnthropicProvider) SendStream(ctx context.Context, history []Message, images []string, onChunk func(string)) (string, error) {
+	url, payload, headers := p.request(history, images)
+	payload["stream"] = true
+
+	resp, err := openStream(ctx, url, payload, "", headers)
+	if err != nil {
+		return "", anthropicError(err)
+	}
+	defer resp.Body.Close()
+
+	var full strings.Builder
+	err = readSSE(resp.Body, func(data string) (bool, error) {
+		var ev struct {
+			Type  string `json:"type"`
+			Delta struct {
+				Type string `json:"type"`
+				Text string `json:"text"`
+			} `json:"delta"`
+		}
+		if err := json.Unmarshal([]byte(data), &ev); err != nil {
+			return false, fmt.Errorf("failed to parse stream chunk: %w", err)
+		}
+
+		switch ev.Type {
+		case "content_block_delta":
+			if ev.Delta.Type == "text_delta" && ev.Delta.Text != "" {
+				full.WriteString(ev.Delta.Text)
+				if onChunk != nil {
+					onChunk(ev.Delta.Text)
+				}
+			}
+		case "message_stop":
+			return true, nil
+		case "error":
+			if msg := anthropicErrorMessage([]byte(data)); msg != "" {
+				return false, errors.New(msg)
+			}
+			return false, fmt.Errorf("api error: %s", data)
+		}
+		return false, nil
+	})
+	return full.String(), err
+}
+
+// anthropicMessages переводит историю в формат Messages API.
+// Системный промпт и системные сообщения из истории возвращаются отдельно
+// (API принимает их только в "system").
+func anthropicMessages(history []Message, images []string, systemPrompt string) (string, []map[string]interface{}) {
+	var system []string
+	if systemPrompt != "" {
+		system = append(system, systemPrompt)
+	}
+	msgs := make([]map[string]interface{}, 0, len(history))
+
+	for i, m := range history {
+		if m.Role == "system" {
+			system = append(system, m.Content)
+			continue
+		}
+
+		blocks := []map[string]interface{}{
+			{"type": "text", "text": m.Content},
+		}
+		// Картинки прикрепляем к последнему сообщению пользователя, как и для OpenAI
+		if i == len(history)-1 && m.Role == "user" {
+			for _, img := range images {
+				if block := anthropicImageBlock(img); block != nil {
+					blocks = append(blocks, block)
+				}
+			}
+		}
+
+		msgs = append(msgs, map[string]interface{}{
+			"role":    m.Role,
+			"content": blocks,
+		})
+	}
+	return strings.Join(system, "\n\n"), msgs
+}
+
+func anthropicImageBlock(img string) map[string]interface{} {
+	if mimeType, data, ok := parseDataURL(img); ok {
+		return map[string]interface{}{
+			"type": "image",
+			"source": map[string]string{
+				"type":       "base64",
+				"media_type": mimeType,
+				"data":       data,
+			},
+		}
+	}
+	if isURL(img) {
+		return map[string]interface{}{
+			"type":   "image",
+			"source": map[string]string{"type": "url", "url": img},
+		}
+	}
+	return nil
+}
+
+// anthropicError дополняет *APIError сообщением из тела ответа:
+// {"type":"error","error":{"type":"invalid_request_error","message":"..."}}
+func anthropicError(err error) error {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		apiErr.Message = anthropicErrorMessage([]byte(apiErr.Body))
+	}
+	return err
+}
+
+// anthropicErrorMessage возвращает "тип: сообщение" из ответа-ошибки или пустую строку
+func anthropicErrorMessage(body []byte) string {
+	var r struct {
+		Error *struct {
+			Type    string `json:"type"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if json.Unmarshal(body, &r) != nil || r.Error == nil {
+		return ""
+	}
+	if r.Error.Type == "" {
+		return r.Error.Message
+	}
+	return r.Error.Type + ": " + r.Error.Message
+}
@@ -0,0 +1,258 @@
+package logic
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FallbackProvider — запасной провайдер (или профиль) в цепочке; пустая модель —
+// модель профиля либо модель основного запроса
+type FallbackProvider struct {
+	Provider string `json:"provider"`
+	Model    string `json:"model,omitempty"`
+}
+
+var (
+	fallbackMu        sync.RWMutex
+	fallbackProviders []FallbackProvider
+)
+
+// SetFallbackProviders задаёт упорядоченный список провайдеров, к которым Chat
+// обращается, если основной провайдер недоступен
+func SetFallbackProviders(list []FallbackProvider) {
+	fallbackMu.Lock()
+	defer fallbackMu.Unlock()
+	fallbackProviders = append([]FallbackProvider(nil), list...)
+}
+
+// FallbackProviders возвращает текущую цепочку запасных провайдеров
+func FallbackProviders() []FallbackProvider {
+	fallbackMu.RLock()
+	defer fallbackMu.RUnlock()
+	return append([]FallbackProvider(nil), fallbackProviders...)
+}
+
+// ChatRequest — запрос к LLM
+type ChatRequest struct {
+	Provider string // имя провайдера, профиля или URL
+	Model    string
+	APIKey   string
+
+	History []Message
+	Images  []string
+
+	// OnChunk, если задан, включает потоковый режим: вызывается из фоновой горутины
+	// для каждого фрагмента ответа
+	OnChunk func(string)
+
+	// NoFallback отключает цепочку запасных провайдеров
+	NoFallback bool
+
+	// Task — вид запроса для журнала запросов
+	Task AITask
+
+	// Cache разрешает ответ из кэша на такой же запрос (дополнения кода)
+	Cache bool
+
+	// Vars — значения переменных шаблона системного промпта (язык, файл, модуль)
+	Vars PromptVars
+	// System, если задан, отправляется как системный промпт вместо настроенного
+	// (повтор запроса из журнала)
+	System string
+}
+
+// ChatResult — ответ и сведения о том, кто его дал
+type ChatResult struct {
+	Text     string
+	Provider string  // провайдер, который ответил
+	Model    string  // его модель
+	Failures []error // ошибки провайдеров, опрошенных до него
+
+	// Redactions — что было замаскировано в запросе к ответившему провайдеру
+	Redactions RedactionReport
+
+	// Cached — ответ взят из кэша, запрос не отправлялся
+	Cached bool
+}
+
+// UsedFallback сообщает, что ответил не основной провайдер
+func (r *ChatResult) UsedFallback() bool {
+	return len(r.Failures) > 0
+}
+
+// chatCandidate — один провайдер цепочки
+type chatCandidate struct {
+	provider, model, key string
+	system               string // системный промпт для этого провайдера
+}
+
+// Chat отправляет запрос основному провайдеру, а если он так и не ответил
+// (после повторов, см. RetryPolicy) — по очереди запасным провайдерам.
+// Цепочка продолжается только после временных ошибок (см. IsTemporary) и если
+// провайдера не удалось создать: неверный запрос или ключ запасной не исправит.
+// Если ответ уже начал выводиться (потоковый режим) или запрос отменён,
+// цепочка не продолжается: возвращается полученная часть вместе с ошибкой.
+// Если у ctx нет дедлайна, применяется defaultTimeout.
+func Chat(ctx context.Context, req ChatRequest) (*ChatResult, error) {
+	ctx, cancel := withDefaultTimeout(ctx)
+	defer cancel()
+
+	result := &ChatResult{}
+	var lastErr error
+
+	for _, c := range chatCandidates(req) {
+		result.Provider, result.Model = c.provider, c.model
+		// Профиль запасного провайдера может задавать свой системный промпт
+		c.system = req.System
+		if c.system == "" {
+			vars := req.Vars
+			if vars.Task == "" {
+				vars.Task = req.Task
+			}
+			c.system = ResolveSystemPrompt(c.provider, vars)
+		}
+
+		// Секреты маскируются для каждого провайдера отдельно: запасной может быть локальным
+		creq := req
+		result.Redactions = nil
+		if ShouldRedact(c.provider) {
+			creq.History, result.Redactions = redactMessages(req.History)
+		}
+
+		// Картинки в ключ не входят — такие запросы не кэшируются
+		cacheKey := ""
+		if req.Cache && len(req.Images) == 0 {
+			cacheKey = responseCacheKey("chat", c.provider, c.model, c.system, creq.History)
+			if text, ok := respCache.get(cacheKey); ok {
+				entry := chatLogEntry(c, creq, result.Redactions, time.Now(), text, nil)
+				entry.Cached = true
+				logRequest(entry)
+				if req.OnChunk != nil {
+					req.OnChunk(text)
+				}
+				result.Text, result.Cached = text, true
+				return result, nil
+			}
+		}
+
+		start := time.Now()
+		tctx, trace := withRequestTrace(ctx)
+		text, streamed, err := sendOnce(tctx, c, creq)
+		entry := chatLogEntry(c, creq, result.Redactions, start, text, err)
+		trace.fill(&entry)
+		logRequest(entry)
+
+		result.Text = text
+		if err == nil {
+			if cacheKey != "" {
+				respCache.put(cacheKey, text)
+			}
+			return result, nil
+		}
+		if ctx.Err() != nil || streamed {
+			return result, err
+		}
+
+		lastErr = err
+		result.Failures = append(result.Failures, fmt.Errorf("%s: %w", c.provider, err))
+		if !IsTemporary(err) && !errors.Is(err, errProviderSetup) {
+			break
+		}
+	}
+
+	if len(result.Failures) <= 1 {
+		return result, lastErr
+	}
+	msgs := make([]string, len(result.Failures))
+	for i, f := range result.Failures {
+		msgs[i] = f.Error()
+	}
+	return result, fmt.Errorf("all providers failed: %s: %w", strings.Join(msgs[:len(msgs)-1], "; "), result.Failures[len(msgs)-1])
+}
+
+// chatCandidates строит цепочку: основной провайдер и запасные без повторов
+func chatCandidates(req ChatRequest) []chatCandidate {
+	list := []chatCandidate{{provider: req.Provider, model: req.Model, key: req.APIKey}}
+	if req.NoFallback {
+		return list
+	}
+
+	seen := map[string]bool{normalizeProviderName(req.Provider) + "\x00" + req.Model: true}
+	for _, fb := range FallbackProviders() {
+		c := chatCandidate{provider: fb.Provider, model: fb.Model}
+		if c.model == "" {
+			if profile, ok := LookupProviderProfile(fb.Provider); !ok || profile.Model == "" {
+				c.model = req.Model
+			}
+		}
+		// Ключ основного провайдера подходит только ему же
+		if normalizeProviderName(fb.Provider) == normalizeProviderName(req.Provider) {
+			c.key = req.APIKey
+		}
+
+		id := normalizeProviderName(c.provider) + "\x00" + c.model
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		list = append(list, c)
+	}
+	return list
+}
+
+// errProviderSetup — провайдер не создан (неизвестное имя, нет endpoint), запрос не отправлялся
+var errProviderSetup = errors.New("provider error")
+
+// sendOnce опрашивает одного провайдера; streamed сообщает, что часть ответа уже отдана в OnChunk
+func sendOnce(ctx context.Context, c chatCandidate, req ChatRequest) (text string, streamed bool, err error) {
+	provider, err := newProvider(c.provider, c.model, c.key, c.system)
+	if err != nil {
+		return "", false, fmt.Errorf("%w: %w", errProviderSetup, err)
+	}
+
+	if req.OnChunk == nil {
+		text, err = provider.Send(ctx, req.History, req.Images)
+		return text, false, err
+	}
+
+	onChunk := func(chunk string) {
+		streamed = true
+		req.OnChunk(chunk)
+	}
+
+	sp, ok := provider.(StreamingProvider)
+	if !ok {
+		text, err = provider.Send(ctx, req.History, req.Images)
+		if err == nil {
+			onChunk(text)
+		}
+		return text, streamed, err
+	}
+	text, err = sp.SendStream(ctx, req.History, req.Images, onChunk)
+	return text, streamed, err
+}
+
+// chatLogEntry формирует запись журнала запросов для попытки цепочки Chat
+func chatLogEntry(c chatCandidate, req ChatRequest, report RedactionReport, start time.Time, text string, err error) RequestLogEntry {
+	entry := RequestLogEntry{
+		Time:       start,
+		Kind:       "chat",
+		Task:       req.Task,
+		Provider:   c.provider,
+		Model:      c.model,
+		LatencyMs:  time.Since(start).Milliseconds(),
+		System:     c.system,
+		Prompt:     req.History,
+		Images:     len(req.Images),
+		Redactions: report,
+		Content:    text,
+	}
+	if err != nil {
+		entry.Error = err.Error()
+	}
+	return entry
+}
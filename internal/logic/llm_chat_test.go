@@ -0,0 +1,77 @@
+package logic
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+// chatChain настраивает профили primary и backup (OpenAI-совместимые серверы):
+// primary отвечает статусом status, backup — успешно. Возвращает счётчик запросов к backup.
+func chatChain(t *testing.T, status int) *atomic.Int32 {
+	t.Helper()
+	isolateKeys(t)
+	SetRetryPolicy(RetryPolicy{MaxRetries: 0})
+	t.Cleanup(func() {
+		SetRetryPolicy(DefaultRetryPolicy)
+		SetFallbackProviders(nil)
+	})
+
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+		fmt.Fprint(w, `{"error":{"message":"primary failed"}}`)
+	}))
+	t.Cleanup(primary.Close)
+	var backupCalls atomic.Int32
+	backup := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		backupCalls.Add(1)
+		fmt.Fprint(w, `{"choices":[{"message":{"role":"assistant","content":"from backup"}}]}`)
+	}))
+	t.Cleanup(backup.Close)
+
+	if err := SetProviderProfiles([]ProviderProfile{
+		{Name: "primary", Provider: "openai", Endpoint: primary.URL, Model: "m1"},
+		{Name: "backup", Provider: "openai", Endpoint: backup.URL, Model: "m2"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	SetFallbackProviders([]FallbackProvider{{Provider: "backup"}})
+	return &backupCalls
+}
+
+func chatOnce(t *testing.T, status int) (*ChatResult, int32, error) {
+	calls := chatChain(t, status)
+	res, err := Chat(context.Background(), ChatRequest{
+		Provider: "primary",
+		APIKey:   "key",
+		History:  []Message{{Role: "user", Content: "Hi"}},
+	})
+	return res, calls.Load(), err
+}
+
+func TestChatFallsBackOnTemporaryError(t *testing.T) {
+	res, calls, err := chatOnce(t, http.StatusServiceUnavailable)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if calls != 1 || res.Text != "from backup" || res.Provider != "backup" || !res.UsedFallback() {
+		t.Fatalf("result %+v, backup calls %d", res, calls)
+	}
+}
+
+func TestChatStopsOnRequestError(t *testing.T) {
+	for _, status := range []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden} {
+		res, calls, err := chatOnce(t, status)
+		var apiErr *APIError
+		if !errors.As(err, &apiErr) || apiErr.StatusCode != status {
+			t.Fatalf("status %d: err = %v", status, err)
+		}
+		if calls != 0 || res.Provider != "primary" {
+			t.Fatalf("status %d: request was passed to the fallback (%d calls, provider %q)", status, calls, res.Provider)
+		}
+	}
+}
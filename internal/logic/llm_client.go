@@ -17,14 +17,15 @@ import (
 
 const (
 
+// DefaultSystemPrompt — шаблон системного промпта по умолчанию (переменные — см. PromptVars)
 DefaultSystemPrompt = `You are a powerful AI assistant for a code editor with LLM support.
 **Core Principles:**
-1. **Coding**: Create accurate, efficient, and well-structured code in Go language.
+1. **Coding**: Create accurate, efficient, and well-structured code in {{language}}.
 2. **Context Understanding**: Analyze the current file, code context, and user tasks.
 3. **Editor Integration**: Consider the current line, selected fragment, and project files when generating code.
 4. **Safety**: Refuse requests that may be malicious or disrupt editor functionality.
 5. **Structure**: Use Markdown for explanations and comments. Always provide explanations for code blocks.
-6. **Compatibility**: Ensure your code follows Go standards and can be successfully compiled.
+6. **Compatibility**: Ensure your code follows {{language}} standards and can be successfully compiled or run.
 7. **Project Work**: When necessary, refer to other project files to ensure full functionality.
 
 **Additional Instructions:**
@@ -44,17 +45,17 @@ STRICT RULES:
 6. Keep completions concise and relevant
 7. If unsure, provide the most likely completion based on context`
 
-	// Таймаут для HTTP клиента
+	// Таймаут запроса, если у контекста нет дедлайна (см. withDefaultTimeout)
 	defaultTimeout = 120 * time.Second
 
     // Короткий таймаут для inline completion
     completionTimeout = 30 * time.Second
 )
 
-// Shared HTTP client to avoid socket exhaustion
-var httpClient = &http.Client{
-	Timeout: defaultTimeout,
-}
+// Shared HTTP client to avoid socket exhaustion.
+// Общего таймаута нет: время запроса ограничивает контекст (таймаут задачи
+// из ResolveTask или defaultTimeout), иначе длинные ответы обрывались бы на 120 с.
+var httpClient = &http.Client{}
 
 // --- Public API ---
 
@@ -73,24 +74,43 @@ func SendMessageToLLMWithTimeout(prompt, providerName, model, apiKey string, tim
     ctx, cancel := context.WithTimeout(context.Background(), timeout)
     defer cancel()
 
-    history := []Message{
-        {Role: "user", Content: prompt},
-    }
+    return SendMessageToLLMContext(ctx, prompt, providerName, model, apiKey)
+}
 
-    provider, err := newProvider(providerName, model, apiKey)
-    if err != nil {
-        return "", fmt.Errorf("provider error: %w", err)
-    }
+// SendMessageToLLMContext — версия, которой управляет вызывающая сторона:
+// отмена ctx (например, кнопкой Stop в UI) сразу прерывает HTTP-запрос.
+// Если у ctx нет дедлайна, применяется defaultTimeout.
+// Запасные провайдеры (SetFallbackProviders) опрашиваются, если основной не ответил;
+// чтобы узнать, кто ответил, используйте Chat.
+func SendMessageToLLMContext(ctx context.Context, prompt, providerName, model, apiKey string) (string, error) {
+    res, err := Chat(ctx, ChatRequest{
+        Provider: providerName,
+        Model:    model,
+        APIKey:   apiKey,
+        History:  []Message{{Role: "user", Content: prompt}},
+    })
+    return res.Text, err
+}
+
+// IsCanceled сообщает, что запрос был прерван отменой контекста (а не ошибкой API)
+func IsCanceled(err error) bool {
+    return errors.Is(err, context.Canceled)
+}
 
-    return provider.Send(ctx, history, nil)
+// withDefaultTimeout ограничивает контекст без дедлайна значением defaultTimeout
+func withDefaultTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+    if _, ok := ctx.Deadline(); ok {
+        return context.WithCancel(ctx)
+    }
+    return context.WithTimeout(ctx, defaultTimeout)
 }
 
 // --- Internal Types ---
 
 // Message — внутренняя структура для представления сообщений (аналог domain.Message)
 type Message struct {
-	Role    string
-	Content string
+	Role    string `json:"role"`
+	Content string `json:"content"`
 }
 
 // Provider — интерфейс для абстракции различных LLM API
@@ -98,116 +118,139 @@ type Provider interface {
 	Send(ctx context.Context, history []Message, images []string) (string, error)
 }
 
-// --- Provider Factory ---
-
-func newProvider(name, model, key string) (Provider, error) {
-	normalizedName := strings.ToLower(strings.TrimSpace(name))
-
-	switch normalizedName {
-	case "ollama":
-		return &OllamaProvider{Model: model}, nil
-	case "pollinations":
-		return &PollinationsProvider{Model: model, Key: key}, nil
-	case "openrouter":
-		return &OpenRouterProvider{Model: model, Key: key}, nil
-	default:
-		// Если имя похоже на URL, используем Generic провайдер
-		if isURL(name) {
-			return &GenericURLProvider{Endpoint: name, Model: model, Key: key}, nil
-		}
-		// Fallback to Ollama if unknown (или можно возвращать ошибку)
-		return &OllamaProvider{Model: model}, nil
-	}
+// StreamingProvider — провайдер, умеющий отдавать ответ по частям (SSE, "stream": true).
+// onChunk вызывается для каждого фрагмента текста; возвращается полный ответ.
+type StreamingProvider interface {
+	Provider
+	SendStream(ctx context.Context, history []Message, images []string, onChunk func(string)) (string, error)
 }
 
 // --- Provider Implementations ---
+// Провайдеры регистрируются по имени в provider_registry.go.
 
 // 1. Ollama Provider
-type OllamaProvider struct{ Model string }
+type OllamaProvider struct{ ProviderOptions }
+
+// chatRequest собирает URL и тело запроса; используется и Send, и SendStream
+func (p *OllamaProvider) chatRequest(history []Message, images []string) (string, map[string]interface{}) {
+	url := p.endpoint("http://localhost:11434/v1/chat/completions")
+
+	msgs := messagesToMaps(history, images, p.SystemPrompt)
 
-func (p *OllamaProvider) Send(ctx context.Context, history []Message, images []string) (string, error) {
-	url := "http://localhost:11434/v1/chat/completions"
-	
-	// Ollama обычно не требует системного промпта в body, если он задан в Modelfile,
-	// но мы передаем пустой или дефолтный, если нужно переопределить.
-	msgs := messagesToMaps(history, images, "") 
-	
 	payload := map[string]interface{}{
 		"model":    p.Model,
 		"messages": msgs,
 		"stream":   false,
 	}
-	
-	respBody, err := postJSON(ctx, url, payload, "")
+	return url, payload
+}
+
+func (p *OllamaProvider) Send(ctx context.Context, history []Message, images []string) (string, error) {
+	url, payload := p.chatRequest(history, images)
+
+	respBody, err := postJSON(ctx, url, payload, p.Key, p.Headers)
 	if err != nil {
 		return "", err
 	}
 	return extractContent(respBody)
 }
 
+func (p *OllamaProvider) SendStream(ctx context.Context, history []Message, images []string, onChunk func(string)) (string, error) {
+	url, payload := p.chatRequest(history, images)
+	return postJSONStream(ctx, url, payload, p.Key, p.Headers, onChunk)
+}
+
 // 2. Pollinations Provider
-type PollinationsProvider struct{ Model, Key string }
+type PollinationsProvider struct{ ProviderOptions }
 
-func (p *PollinationsProvider) Send(ctx context.Context, history []Message, images []string) (string, error) {
+func (p *PollinationsProvider) chatRequest(history []Message, images []string) (string, map[string]interface{}) {
 	// Используем HTTPS endpoint, как в рабочем примере
-	url := "https://gen.pollinations.ai/v1/chat/completions"
+	url := p.endpoint("https://gen.pollinations.ai/v1/chat/completions")
 	// Альтернативный URL из примера для справки: "https://text.pollinations.ai/openai"
-	sysPrompt := "You are a helpful assistant."
-
-	msgs := messagesToMaps(history, images, sysPrompt)
+	msgs := messagesToMaps(history, images, p.SystemPrompt)
 
 	payload := map[string]interface{}{
 		"model":    p.Model,
 		"messages": msgs,
 		"seed":     42, // Добавлен seed для детерминированности (из примера)
 	}
+	return url, payload
+}
 
-	// Pollinations часто работает бесплатно без ключа, но если ключ передан, отправляем его.
-	respBody, err := postJSON(ctx, url, payload, p.Key)
+// Pollinations часто работает бесплатно без ключа, но если ключ передан, отправляем его.
+func (p *PollinationsProvider) Send(ctx context.Context, history []Message, images []string) (string, error) {
+	url, payload := p.chatRequest(history, images)
+
+	respBody, err := postJSON(ctx, url, payload, p.Key, p.Headers)
 	if err != nil {
 		return "", err
 	}
 	return extractContent(respBody)
 }
 
+func (p *PollinationsProvider) SendStream(ctx context.Context, history []Message, images []string, onChunk func(string)) (string, error) {
+	url, payload := p.chatRequest(history, images)
+	return postJSONStream(ctx, url, payload, p.Key, p.Headers, onChunk)
+}
+
 // 3. OpenRouter Provider
-type OpenRouterProvider struct{ Model, Key string }
+type OpenRouterProvider struct{ ProviderOptions }
+
+func (p *OpenRouterProvider) chatRequest(history []Message, images []string) (string, map[string]interface{}) {
+	url := p.endpoint("https://openrouter.ai/api/v1/chat/completions")
+
+	msgs := messagesToMaps(history, images, p.SystemPrompt)
 
-func (p *OpenRouterProvider) Send(ctx context.Context, history []Message, images []string) (string, error) {
-	url := "https://openrouter.ai/api/v1/chat/completions"
-	
-	msgs := messagesToMaps(history, images, DefaultSystemPrompt)
-	
 	payload := map[string]interface{}{
 		"model":    p.Model,
 		"messages": msgs,
 	}
+	return url, payload
+}
+
+func (p *OpenRouterProvider) Send(ctx context.Context, history []Message, images []string) (string, error) {
+	url, payload := p.chatRequest(history, images)
 
-	respBody, err := postJSON(ctx, url, payload, p.Key)
+	respBody, err := postJSON(ctx, url, payload, p.Key, p.Headers)
 	if err != nil {
 		return "", err
 	}
 	return extractContent(respBody)
 }
 
-// 4. Generic URL Provider (Custom Endpoint)
-type GenericURLProvider struct{ Endpoint, Model, Key string }
+func (p *OpenRouterProvider) SendStream(ctx context.Context, history []Message, images []string, onChunk func(string)) (string, error) {
+	url, payload := p.chatRequest(history, images)
+	return postJSONStream(ctx, url, payload, p.Key, p.Headers, onChunk)
+}
+
+// 4. Generic URL Provider (Custom Endpoint, любой OpenAI-совместимый API)
+type GenericURLProvider struct{ ProviderOptions }
+
+func (p *GenericURLProvider) chatRequest(history []Message, images []string) (string, map[string]interface{}) {
+	msgs := messagesToMaps(history, images, p.SystemPrompt)
 
-func (p *GenericURLProvider) Send(ctx context.Context, history []Message, images []string) (string, error) {
-	msgs := messagesToMaps(history, images, DefaultSystemPrompt)
-	
 	payload := map[string]interface{}{
 		"model":    p.Model,
 		"messages": msgs,
 	}
-	
-	respBody, err := postJSON(ctx, p.Endpoint, payload, p.Key)
+	return p.endpoint("https://api.openai.com/v1/chat/completions"), payload
+}
+
+func (p *GenericURLProvider) Send(ctx context.Context, history []Message, images []string) (string, error) {
+	url, payload := p.chatRequest(history, images)
+
+	respBody, err := postJSON(ctx, url, payload, p.Key, p.Headers)
 	if err != nil {
 		return "", err
 	}
 	return extractContent(respBody)
 }
 
+func (p *GenericURLProvider) SendStream(ctx context.Context, history []Message, images []string, onChunk func(string)) (string, error) {
+	url, payload := p.chatRequest(history, images)
+	return postJSONStream(ctx, url, payload, p.Key, p.Headers, onChunk)
+}
+
 // --- Helper Functions (Logic) ---
 
 // messagesToMaps конвертирует []Message в формат JSON OpenAI API.
@@ -263,6 +306,34 @@ func buildMessageContent(content string, images []string) interface{} {
 	return contentParts
 }
 
+// parseDataURL разбирает картинку вида "data:image/png;base64,...."
+// на MIME-тип и base64-данные (нужно API, не принимающим data URL целиком)
+func parseDataURL(s string) (mimeType, data string, ok bool) {
+	if !strings.HasPrefix(s, "data:") {
+		return "", "", false
+	}
+	meta, data, found := strings.Cut(strings.TrimPrefix(s, "data:"), ",")
+	if !found || !strings.HasSuffix(meta, ";base64") {
+		return "", "", false
+	}
+	return strings.TrimSuffix(meta, ";base64"), data, true
+}
+
+// withHeaders возвращает копию заголовков профиля с добавленными служебными заголовками
+func withHeaders(profile map[string]string, extra map[string]string) map[string]string {
+	res := make(map[string]string, len(profile)+len(extra))
+	for k, v := range extra {
+		if v != "" {
+			res[k] = v
+		}
+	}
+	// Заголовки профиля имеют приоритет (например, другая версия API)
+	for k, v := range profile {
+		res[k] = v
+	}
+	return res
+}
+
 func isURL(s string) bool {
 	// Простая проверка, начинается ли строка с http/https
 	return strings.HasPrefix(s, "http://") || strings.HasPrefix(s, "https://")
@@ -270,30 +341,26 @@ func isURL(s string) bool {
 
 // --- Helper Functions (Network & Parsing) ---
 
-func postJSON(ctx context.Context, url string, payload interface{}, key string) ([]byte, error) {
+func postJSON(ctx context.Context, url string, payload interface{}, key string, headers map[string]string) ([]byte, error) {
 	body, err := json.Marshal(payload)
 	if err != nil {
 		return nil, fmt.Errorf("failed to marshal payload: %w", err)
 	}
 
-	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(body))
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
-	}
-	
-	req.Header.Set("Content-Type", "application/json")
-	if key != "" {
-		req.Header.Set("Authorization", "Bearer "+key)
-	}
-	// Доп. заголовки для OpenRouter, чтобы они знали источник (опционально)
-	if strings.Contains(url, "openrouter") {
-		req.Header.Set("HTTP-Referer", "https://github.com/go-gnome-editor")
-		req.Header.Set("X-Title", "Go Gnome Editor")
-	}
-
-	resp, err := httpClient.Do(req)
+	resp, err := doWithRetry(ctx, httpClient, func() (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(body))
+		if err != nil {
+			return nil, fmt.Errorf("failed to create request: %w", err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+		if key != "" {
+			req.Header.Set("Authorization", "Bearer "+key)
+		}
+		setExtraHeaders(req, url, headers)
+		return req, nil
+	})
 	if err != nil {
-		return nil, fmt.Errorf("network request failed: %w", err)
+		return nil, err
 	}
 	defer resp.Body.Close()
 
@@ -302,13 +369,55 @@ func postJSON(ctx context.Context, url string, payload interface{}, key string)
 		return nil, fmt.Errorf("failed to read response body: %w", err)
 	}
 
-	if resp.StatusCode >= 300 {
-		return nil, fmt.Errorf("api error (status %d): %s", resp.StatusCode, string(respBytes))
-	}
-	
 	return respBytes, nil
 }
 
+// APIError — ответ API с кодом статуса >= 300
+type APIError struct {
+	StatusCode int
+	Body       string // тело ответа как есть
+	Message    string // разобранное сообщение об ошибке (если формат провайдера известен)
+	RetryAfter time.Duration
+}
+
+func newAPIError(resp *http.Response, body []byte) *APIError {
+	return &APIError{
+		StatusCode: resp.StatusCode,
+		Body:       string(body),
+		RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After")),
+	}
+}
+
+// Temporary сообщает, что запрос может пройти при повторе (перегрузка, лимиты, сбой шлюза)
+func (e *APIError) Temporary() bool {
+	switch e.StatusCode {
+	case http.StatusRequestTimeout, http.StatusTooManyRequests,
+		http.StatusInternalServerError, http.StatusBadGateway,
+		http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+func (e *APIError) Error() string {
+	if e.Message != "" {
+		return fmt.Sprintf("api error (status %d): %s", e.StatusCode, e.Message)
+	}
+	return fmt.Sprintf("api error (status %d): %s", e.StatusCode, e.Body)
+}
+
+// setExtraHeaders добавляет служебные заголовки и заголовки из профиля провайдера
+func setExtraHeaders(req *http.Request, url string, headers map[string]string) {
+	// Доп. заголовки для OpenRouter, чтобы они знали источник (опционально)
+	if strings.Contains(url, "openrouter") {
+		req.Header.Set("HTTP-Referer", "https://github.com/go-gnome-editor")
+		req.Header.Set("X-Title", "Go Gnome Editor")
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+}
+
 // extractContent парсит ответ. Сначала пробует стандартный JSON OpenAI формата,
 // затем ищет JSON внутри Markdown блоков (для "грязных" ответов).
 func extractContent(body []byte) (string, error) {
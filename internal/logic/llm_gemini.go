@@ -0,0 +1,195 @@
+package logic
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// geminiDefaultURL — базовый адрес API; endpoint профиля заменяет его целиком
+const geminiDefaultURL = "https://generativelanguage.googleapis.com/v1beta"
+
+// GeminiProvider — нативный Google Gemini API (models/{model}:generateContent).
+// Ключ передаётся в заголовке x-goog-api-key, системный промпт — в systemInstruction,
+// роль ассистента называется "model".
+type GeminiProvider struct{ ProviderOptions }
+
+func (p *GeminiProvider) request(method string, history []Message, images []string) (string, map[string]interface{}, map[string]string) {
+	system, contents := geminiContents(history, images, p.SystemPrompt)
+
+	payload := map[string]interface{}{
+		"contents": contents,
+	}
+	if system != "" {
+		payload["systemInstruction"] = map[string]interface{}{
+			"parts": []map[string]string{{"text": system}},
+		}
+	}
+
+	endpoint := strings.TrimRight(p.endpoint(geminiDefaultURL), "/") +
+		"/models/" + url.PathEscape(p.Model) + ":" + method
+
+	headers := withHeaders(p.Headers, map[string]string{"x-goog-api-key": p.Key})
+	return endpoint, payload, headers
+}
+
+func (p *GeminiProvider) Send(ctx context.Context, history []Message, images []string) (string, error) {
+	endpoint, payload, headers := p.request("generateContent", history, images)
+
+	respBody, err := postJSON(ctx, endpoint, payload, "", headers)
+	if err != nil {
+		return "", geminiError(err)
+	}
+
+	text, done, err := parseGeminiResponse(respBody)
+	if err != nil {
+		return "", err
+	}
+	if text == "" {
+		return "", fmt.Errorf("empty gemini response (finish reason: %s)", done)
+	}
+	return text, nil
+}
+
+// SendStream использует streamGenerateContent?alt=sse: каждое событие — частичный GenerateContentResponse
+func (p *GeminiProvider) SendStream(ctx context.Context, history []Message, images []string, onChunk func(string)) (string, error) {
+	endpoint, payload, headers := p.request("streamGenerateContent", history, images)
+
+	resp, err := openStream(ctx, endpoint+"?alt=sse", payload, "", headers)
+	if err != nil {
+		return "", geminiError(err)
+	}
+	defer resp.Body.Close()
+
+	var full strings.Builder
+	err = readSSE(resp.Body, func(data string) (bool, error) {
+		text, _, err := parseGeminiResponse([]byte(data))
+		if err != nil {
+			return false, err
+		}
+		if text != "" {
+			full.WriteString(text)
+			if onChunk != nil {
+				onChunk(text)
+			}
+		}
+		return false, nil
+	})
+	return full.String(), err
+}
+
+// geminiContents переводит историю в contents/parts.
+// Системный промпт и системные сообщения истории возвращаются отдельно для systemInstruction.
+func geminiContents(history []Message, images []string, systemPrompt string) (string, []map[string]interface{}) {
+	var system []string
+	if systemPrompt != "" {
+		system = append(system, systemPrompt)
+	}
+	contents := make([]map[string]interface{}, 0, len(history))
+
+	for i, m := range history {
+		if m.Role == "system" {
+			system = append(system, m.Content)
+			continue
+		}
+
+		role := "user"
+		if m.Role == "assistant" {
+			role = "model"
+		}
+
+		parts := []map[string]interface{}{
+			{"text": m.Content},
+		}
+		// Gemini принимает только встроенные данные; ссылки на картинки пропускаются
+		if i == len(history)-1 && m.Role == "user" {
+			for _, img := range images {
+				if mimeType, data, ok := parseDataURL(img); ok {
+					parts = append(parts, map[string]interface{}{
+						"inlineData": map[string]string{"mimeType": mimeType, "data": data},
+					})
+				}
+			}
+		}
+
+		contents = append(contents, map[string]interface{}{
+			"role":  role,
+			"parts": parts,
+		})
+	}
+	return strings.Join(system, "\n\n"), contents
+}
+
+// parseGeminiResponse извлекает текст первого кандидата и причину завершения.
+// Заблокированный запрос (promptFeedback.blockReason) и ошибка в теле возвращаются как error.
+func parseGeminiResponse(body []byte) (string, string, error) {
+	var r struct {
+		Candidates []struct {
+			Content struct {
+				Parts []struct {
+					Text string `json:"text"`
+				} `json:"parts"`
+			} `json:"content"`
+			FinishReason string `json:"finishReason"`
+		} `json:"candidates"`
+		PromptFeedback struct {
+			BlockReason string `json:"blockReason"`
+		} `json:"promptFeedback"`
+	}
+	if msg := geminiErrorMessage(body); msg != "" {
+		return "", "", errors.New(msg)
+	}
+	if err := json.Unmarshal(body, &r); err != nil {
+		return "", "", fmt.Errorf("failed to parse gemini response: %w", err)
+	}
+	if r.PromptFeedback.BlockReason != "" {
+		return "", "", fmt.Errorf("gemini blocked the prompt: %s", r.PromptFeedback.BlockReason)
+	}
+	if len(r.Candidates) == 0 {
+		return "", "", nil
+	}
+
+	var sb strings.Builder
+	for _, part := range r.Candidates[0].Content.Parts {
+		sb.WriteString(part.Text)
+	}
+	return sb.String(), r.Candidates[0].FinishReason, nil
+}
+
+// geminiError дополняет *APIError сообщением из тела ответа:
+// {"error":{"code":400,"message":"...","status":"INVALID_ARGUMENT"}}
+func geminiError(err error) error {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		apiErr.Message = geminiErrorMessage([]byte(apiErr.Body))
+	}
+	return err
+}
+
+// geminiErrorMessage возвращает "STATUS: сообщение" из ответа-ошибки или пустую строку
+func geminiErrorMessage(body []byte) string {
+	var r struct {
+		Error *struct {
+			Message string `json:"message"`
+			Status  string `json:"status"`
+		} `json:"error"`
+	}
+	// Иногда ошибка приходит массивом из одного объекта
+	trimmed := strings.TrimSpace(string(body))
+	if strings.HasPrefix(trimmed, "[") {
+		var arr []json.RawMessage
+		if json.Unmarshal(body, &arr) == nil && len(arr) > 0 {
+			body = arr[0]
+		}
+	}
+	if json.Unmarshal(body, &r) != nil || r.Error == nil {
+		return ""
+	}
+	if r.Error.Status == "" {
+		return r.Error.Message
+	}
+	return r.Error.Status + ": " + r.Error.Message
+}
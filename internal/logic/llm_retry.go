@@ -0,0 +1,166 @@
+package logic
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// RetryPolicy — повтор запросов при временных ошибках (429, 5xx, сбои сети)
+type RetryPolicy struct {
+	MaxRetries int           // число повторов после первой попытки; 0 — без повторов
+	BaseDelay  time.Duration // задержка перед первым повтором, далее удваивается
+	MaxDelay   time.Duration // предел задержки; Retry-After больше предела не ждём
+}
+
+// DefaultRetryPolicy — политика по умолчанию
+var DefaultRetryPolicy = RetryPolicy{
+	MaxRetries: 2,
+	BaseDelay:  time.Second,
+	MaxDelay:   30 * time.Second,
+}
+
+var (
+	retryMu     sync.RWMutex
+	retryPolicy = DefaultRetryPolicy
+)
+
+// SetRetryPolicy задаёт политику повторов для всех последующих запросов
+func SetRetryPolicy(p RetryPolicy) {
+	if p.MaxRetries < 0 {
+		p.MaxRetries = 0
+	}
+	if p.BaseDelay <= 0 {
+		p.BaseDelay = DefaultRetryPolicy.BaseDelay
+	}
+	if p.MaxDelay < p.BaseDelay {
+		p.MaxDelay = p.BaseDelay
+	}
+
+	retryMu.Lock()
+	retryPolicy = p
+	retryMu.Unlock()
+}
+
+func currentRetryPolicy() RetryPolicy {
+	retryMu.RLock()
+	defer retryMu.RUnlock()
+	return retryPolicy
+}
+
+// doWithRetry выполняет запрос, повторяя его по политике повторов.
+// newReq вызывается на каждую попытку (тело запроса читается заново).
+// Успешный ответ (статус < 300) возвращается открытым; статус >= 300 — как *APIError.
+func doWithRetry(ctx context.Context, client *http.Client, newReq func() (*http.Request, error)) (*http.Response, error) {
+	policy := currentRetryPolicy()
+	trace := traceFromContext(ctx)
+
+	for attempt := 0; ; attempt++ {
+		req, err := newReq()
+		if err != nil {
+			return nil, err
+		}
+
+		var lastErr error
+		var retryAfter time.Duration
+
+		resp, err := client.Do(req)
+		switch {
+		case err != nil:
+			trace.attempt(req.URL.Redacted(), 0, nil)
+			// Отмена пользователем или истёкший дедлайн — не сетевой сбой
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			lastErr = &NetworkError{Err: err}
+		case resp.StatusCode >= 300:
+			body, _ := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			trace.attempt(req.URL.Redacted(), resp.StatusCode, body)
+			apiErr := newAPIError(resp, body)
+			if !apiErr.Temporary() {
+				return nil, apiErr
+			}
+			lastErr = apiErr
+			retryAfter = apiErr.RetryAfter
+		default:
+			trace.attempt(req.URL.Redacted(), resp.StatusCode, nil)
+			resp.Body = trace.wrapBody(resp.Body)
+			return resp, nil
+		}
+
+		if attempt >= policy.MaxRetries {
+			return nil, lastErr
+		}
+
+		delay := backoffDelay(policy, attempt)
+		if retryAfter > 0 {
+			// Сервер просит ждать дольше, чем мы готовы — пусть решает цепочка провайдеров
+			if retryAfter > policy.MaxDelay {
+				return nil, lastErr
+			}
+			delay = retryAfter
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// backoffDelay — экспоненциальная задержка с небольшим случайным разбросом
+func backoffDelay(p RetryPolicy, attempt int) time.Duration {
+	d := p.BaseDelay << uint(attempt)
+	if d <= 0 || d > p.MaxDelay {
+		d = p.MaxDelay
+	}
+	// ±20%, чтобы одновременные клиенты не повторяли запросы синхронно
+	jitter := time.Duration(rand.Int63n(2*int64(d)/5+1)) - d/5
+	return d + jitter
+}
+
+// parseRetryAfter разбирает заголовок Retry-After (секунды или HTTP-дата)
+func parseRetryAfter(v string) time.Duration {
+	if v == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(v); err == nil {
+		if secs < 0 {
+			return 0
+		}
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
+
+// NetworkError — запрос не дошёл до сервера или соединение оборвалось
+type NetworkError struct {
+	Err error
+}
+
+func (e *NetworkError) Error() string { return "network request failed: " + e.Err.Error() }
+func (e *NetworkError) Unwrap() error { return e.Err }
+
+// IsTemporary сообщает, что ошибку имеет смысл повторить или передать запасному провайдеру
+func IsTemporary(err error) bool {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.Temporary()
+	}
+	var netErr *NetworkError
+	return errors.As(err, &netErr)
+}
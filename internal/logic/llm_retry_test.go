@@ -0,0 +1,124 @@
+package logic
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestParseRetryAfter(t *testing.T) {
+	tests := []struct {
+		header   string
+		min, max time.Duration
+	}{
+		{"", 0, 0},
+		{"0", 0, 0},
+		{"7", 7 * time.Second, 7 * time.Second},
+		{"-3", 0, 0},
+		{"soon", 0, 0},
+		{time.Now().Add(90 * time.Second).UTC().Format(http.TimeFormat), 80 * time.Second, 90 * time.Second},
+		{time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat), 0, 0},
+	}
+	for _, tt := range tests {
+		if d := parseRetryAfter(tt.header); d < tt.min || d > tt.max {
+			t.Errorf("parseRetryAfter(%q) = %v, want %v..%v", tt.header, d, tt.min, tt.max)
+		}
+	}
+}
+
+func TestAPIErrorTemporary(t *testing.T) {
+	for status := 300; status < 600; status++ {
+		want := status == 408 || status == 429 || status == 500 || status == 502 || status == 503 || status == 504
+		err := &APIError{StatusCode: status}
+		if err.Temporary() != want || IsTemporary(fmt.Errorf("wrapped: %w", err)) != want {
+			t.Errorf("status %d: temporary = %v, want %v", status, err.Temporary(), want)
+		}
+	}
+	if !IsTemporary(&NetworkError{Err: errors.New("connection reset")}) || IsTemporary(errors.New("other")) {
+		t.Error("wrong IsTemporary for non-API errors")
+	}
+}
+
+func TestBackoffDelayJitter(t *testing.T) {
+	p := RetryPolicy{BaseDelay: time.Second, MaxDelay: 3 * time.Second}
+	for attempt, base := range []time.Duration{time.Second, 2 * time.Second, 3 * time.Second, 3 * time.Second} {
+		for i := 0; i < 200; i++ {
+			d := backoffDelay(p, attempt)
+			if d < base*8/10 || d > base*12/10 {
+				t.Fatalf("attempt %d: delay %v outside %v ±20%%", attempt, d, base)
+			}
+		}
+	}
+}
+
+// retryStub отвечает 429 с заголовком Retry-After на первый запрос, затем успешно
+func retryStub(t *testing.T, retryAfter string) (url string, calls *atomic.Int32) {
+	calls = &atomic.Int32{}
+	srv, _ := llmStub(t, func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			w.Header().Set("Retry-After", retryAfter)
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		fmt.Fprint(w, `{"choices":[{"message":{"content":"ok"}}]}`)
+	})
+	SetRetryPolicy(RetryPolicy{MaxRetries: 2, BaseDelay: time.Millisecond, MaxDelay: time.Second})
+	t.Cleanup(func() { SetRetryPolicy(DefaultRetryPolicy) })
+	return srv.URL, calls
+}
+
+func TestDoWithRetryRetriesTemporaryError(t *testing.T) {
+	url, calls := retryStub(t, "0")
+	res, err := SendMessageToLLM("hi", url, "m", "key")
+	if err != nil || res != "ok" || calls.Load() != 2 {
+		t.Fatalf("result %q, err %v, calls %d", res, err, calls.Load())
+	}
+}
+
+func TestDoWithRetryGivesUpOnLongRetryAfter(t *testing.T) {
+	url, calls := retryStub(t, "120")
+	_, err := SendMessageToLLM("hi", url, "m", "key")
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.RetryAfter != 120*time.Second || calls.Load() != 1 {
+		t.Fatalf("err %v, calls %d", err, calls.Load())
+	}
+}
+
+func TestReadSSE(t *testing.T) {
+	tests := []struct {
+		name, stream string
+		want         []string
+	}{
+		{"events", "data: one\n\ndata: two\n\n", []string{"one", "two"}},
+		{"multi-line data", "data: a\ndata: b\n\n", []string{"a\nb"}},
+		{"comments and fields", ": keep-alive\nevent: delta\nid: 1\ndata:{\"x\":1}\n\n", []string{`{"x":1}`}},
+		{"crlf", "data: one\r\n\r\ndata: two\r\n\r\n", []string{"one", "two"}},
+		{"last event without blank line", "data: one\n\ndata: tail", []string{"one", "tail"}},
+		{"stops when done", "data: one\n\ndata: [DONE]\n\ndata: after\n\n", []string{"one", "[DONE]"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			err := readSSE(strings.NewReader(tt.stream), func(data string) (bool, error) {
+				got = append(got, data)
+				return data == "[DONE]", nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if strings.Join(got, "|") != strings.Join(tt.want, "|") {
+				t.Fatalf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	stop := errors.New("stop")
+	err := readSSE(strings.NewReader("data: one\n\ndata: two\n\n"), func(string) (bool, error) { return false, stop })
+	if !errors.Is(err, stop) {
+		t.Fatalf("handler error: %v", err)
+	}
+}
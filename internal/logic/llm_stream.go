@@ -0,0 +1,187 @@
+package logic
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// StreamMessageToLLM — потоковая версия SendMessageToLLM.
+// onChunk получает фрагменты ответа по мере их поступления (из фоновой горутины),
+// результат — полный текст ответа.
+// Если провайдер не поддерживает стриминг, ответ придёт одним фрагментом.
+func StreamMessageToLLM(prompt, providerName, model, apiKey string, onChunk func(string)) (string, error) {
+	return StreamMessageToLLMContext(context.Background(), prompt, providerName, model, apiKey, onChunk)
+}
+
+// StreamMessageToLLMContext — потоковая версия с отменой через ctx.
+// При отмене возвращается уже полученная часть ответа вместе с ошибкой (см. IsCanceled).
+func StreamMessageToLLMContext(ctx context.Context, prompt, providerName, model, apiKey string, onChunk func(string)) (string, error) {
+	if onChunk == nil {
+		onChunk = func(string) {}
+	}
+	res, err := Chat(ctx, ChatRequest{
+		Provider: providerName,
+		Model:    model,
+		APIKey:   apiKey,
+		History:  []Message{{Role: "user", Content: prompt}},
+		OnChunk:  onChunk,
+	})
+	return res.Text, err
+}
+
+// postJSONStream отправляет запрос с "stream": true и читает ответ как Server-Sent Events
+// в формате OpenAI chat-completions (data: {...choices[0].delta.content...}, data: [DONE]).
+func postJSONStream(ctx context.Context, url string, payload map[string]interface{}, key string, headers map[string]string, onChunk func(string)) (string, error) {
+	streamPayload := make(map[string]interface{}, len(payload)+1)
+	for k, v := range payload {
+		streamPayload[k] = v
+	}
+	streamPayload["stream"] = true
+
+	resp, err := openStream(ctx, url, streamPayload, key, headers)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	// Некоторые серверы игнорируют "stream" и отвечают обычным JSON
+	if !isEventStream(resp) {
+		respBytes, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return "", fmt.Errorf("failed to read response body: %w", err)
+		}
+		content, err := extractContent(respBytes)
+		if err == nil && onChunk != nil {
+			onChunk(content)
+		}
+		return content, err
+	}
+
+	var full strings.Builder
+	err = readSSE(resp.Body, func(data string) (bool, error) {
+		if data == "[DONE]" {
+			return true, nil
+		}
+		chunk, err := parseStreamChunk(data)
+		if err != nil {
+			return false, err
+		}
+		if chunk != "" {
+			full.WriteString(chunk)
+			if onChunk != nil {
+				onChunk(chunk)
+			}
+		}
+		return false, nil
+	})
+	if err != nil {
+		return full.String(), err
+	}
+
+	return full.String(), nil
+}
+
+// openStream отправляет POST-запрос с ожиданием ответа text/event-stream.
+// Временные ошибки повторяются до начала потока (см. doWithRetry);
+// статус >= 300 возвращается как *APIError, тело ответа закрывает вызывающая сторона.
+func openStream(ctx context.Context, url string, payload interface{}, key string, headers map[string]string) (*http.Response, error) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal payload: %w", err)
+	}
+
+	return doWithRetry(ctx, httpClient, func() (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(body))
+		if err != nil {
+			return nil, fmt.Errorf("failed to create request: %w", err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Accept", "text/event-stream")
+		if key != "" {
+			req.Header.Set("Authorization", "Bearer "+key)
+		}
+		setExtraHeaders(req, url, headers)
+		return req, nil
+	})
+}
+
+// isEventStream сообщает, что сервер действительно ответил потоком SSE
+func isEventStream(resp *http.Response) bool {
+	return strings.Contains(resp.Header.Get("Content-Type"), "text/event-stream")
+}
+
+// readSSE читает поток Server-Sent Events и передаёт поле data каждого события в handle.
+// handle возвращает true, если поток завершён и дальше читать не нужно.
+func readSSE(r io.Reader, handle func(data string) (bool, error)) error {
+	scanner := bufio.NewScanner(r)
+	// Фрагменты бывают длинными (например, большой блок кода одним куском)
+	scanner.Buffer(make([]byte, 0, 64*1024), 4*1024*1024)
+
+	var data []string
+	flush := func() (bool, error) {
+		if len(data) == 0 {
+			return false, nil
+		}
+		payload := strings.Join(data, "\n")
+		data = data[:0]
+		return handle(payload)
+	}
+
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		// Пустая строка — конец события
+		if line == "" {
+			done, err := flush()
+			if err != nil || done {
+				return err
+			}
+			continue
+		}
+		// Комментарии (keep-alive) начинаются с ':'
+		if strings.HasPrefix(line, ":") {
+			continue
+		}
+		if strings.HasPrefix(line, "data:") {
+			data = append(data, strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " "))
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read stream: %w", err)
+	}
+
+	_, err := flush()
+	return err
+}
+
+// parseStreamChunk извлекает текст из одного SSE-события OpenAI-совместимого API
+func parseStreamChunk(data string) (string, error) {
+	var chunk struct {
+		Choices []struct {
+			Delta struct {
+				Content string `json:"content"`
+			} `json:"delta"`
+			Text string `json:"text"`
+		} `json:"choices"`
+		Error json.RawMessage `json:"error"`
+	}
+	if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+		return "", fmt.Errorf("failed to parse stream chunk: %w", err)
+	}
+	if len(chunk.Error) > 0 && string(chunk.Error) != "null" {
+		return "", fmt.Errorf("api error: %s", string(chunk.Error))
+	}
+	if len(chunk.Choices) == 0 {
+		return "", nil
+	}
+	if chunk.Choices[0].Delta.Content != "" {
+		return chunk.Choices[0].Delta.Content, nil
+	}
+	return chunk.Choices[0].Text, nil
+}
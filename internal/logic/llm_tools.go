@@ -0,0 +1,171 @@
+package logic
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// ErrToolsUnsupported — провайдер не поддерживает вызов инструментов (function calling)
+var ErrToolsUnsupported = errors.New("provider does not support tool calling")
+
+// ToolSpec — описание инструмента для модели; Parameters — JSON Schema аргументов
+type ToolSpec struct {
+	Name        string
+	Description string
+	Parameters  map[string]interface{}
+}
+
+// ToolCall — вызов инструмента, запрошенный моделью; Arguments — JSON объект строкой
+type ToolCall struct {
+	ID        string
+	Name      string
+	Arguments string
+}
+
+// AgentMessage — сообщение диалога с инструментами в формате OpenAI:
+// ответ ассистента может содержать ToolCalls, результат инструмента
+// передаётся с ролью "tool" и ToolCallID.
+type AgentMessage struct {
+	Role       string
+	Content    string
+	ToolCalls  []ToolCall
+	ToolCallID string
+	Name       string // имя инструмента для роли "tool"
+}
+
+// ToolProvider — провайдер, умеющий вызывать инструменты (OpenAI "tools")
+type ToolProvider interface {
+	SendTools(ctx context.Context, messages []AgentMessage, tools []ToolSpec) (AgentMessage, error)
+}
+
+func (p *OllamaProvider) SendTools(ctx context.Context, messages []AgentMessage, tools []ToolSpec) (AgentMessage, error) {
+	url, payload := p.chatRequest(nil, nil)
+	return sendOpenAITools(ctx, url, payload, p.ProviderOptions, messages, tools)
+}
+
+func (p *PollinationsProvider) SendTools(ctx context.Context, messages []AgentMessage, tools []ToolSpec) (AgentMessage, error) {
+	url, payload := p.chatRequest(nil, nil)
+	return sendOpenAITools(ctx, url, payload, p.ProviderOptions, messages, tools)
+}
+
+func (p *OpenRouterProvider) SendTools(ctx context.Context, messages []AgentMessage, tools []ToolSpec) (AgentMessage, error) {
+	url, payload := p.chatRequest(nil, nil)
+	return sendOpenAITools(ctx, url, payload, p.ProviderOptions, messages, tools)
+}
+
+func (p *GenericURLProvider) SendTools(ctx context.Context, messages []AgentMessage, tools []ToolSpec) (AgentMessage, error) {
+	url, payload := p.chatRequest(nil, nil)
+	return sendOpenAITools(ctx, url, payload, p.ProviderOptions, messages, tools)
+}
+
+// sendOpenAITools дополняет тело обычного запроса chatRequest сообщениями
+// с вызовами инструментов и списком tools
+func sendOpenAITools(ctx context.Context, url string, payload map[string]interface{}, opts ProviderOptions, messages []AgentMessage, tools []ToolSpec) (AgentMessage, error) {
+	payload["messages"] = agentMessagesToMaps(messages, opts.SystemPrompt)
+	payload["stream"] = false
+	specs := make([]map[string]interface{}, len(tools))
+	for i, t := range tools {
+		specs[i] = map[string]interface{}{
+			"type": "function",
+			"function": map[string]interface{}{
+				"name":        t.Name,
+				"description": t.Description,
+				"parameters":  t.Parameters,
+			},
+		}
+	}
+	payload["tools"] = specs
+
+	body, err := postJSON(ctx, url, payload, opts.Key, opts.Headers)
+	if err != nil {
+		return AgentMessage{}, err
+	}
+	return parseToolReply(body)
+}
+
+// agentMessagesToMaps конвертирует диалог в формат messages OpenAI API
+func agentMessagesToMaps(messages []AgentMessage, systemPrompt string) []map[string]interface{} {
+	out := make([]map[string]interface{}, 0, len(messages)+1)
+	if systemPrompt != "" {
+		out = append(out, map[string]interface{}{"role": "system", "content": systemPrompt})
+	}
+	for _, m := range messages {
+		msg := map[string]interface{}{"role": m.Role, "content": m.Content}
+		if len(m.ToolCalls) > 0 {
+			calls := make([]map[string]interface{}, len(m.ToolCalls))
+			for i, c := range m.ToolCalls {
+				calls[i] = map[string]interface{}{
+					"id":   c.ID,
+					"type": "function",
+					"function": map[string]interface{}{
+						"name":      c.Name,
+						"arguments": c.Arguments,
+					},
+				}
+			}
+			msg["tool_calls"] = calls
+		}
+		if m.Role == "tool" {
+			msg["tool_call_id"] = m.ToolCallID
+			msg["name"] = m.Name
+		}
+		out = append(out, msg)
+	}
+	return out
+}
+
+// parseToolReply извлекает из ответа текст и вызовы инструментов.
+// Некоторые серверы (Ollama) отдают arguments объектом, а не строкой, и не задают id.
+func parseToolReply(body []byte) (AgentMessage, error) {
+	var resp struct {
+		Choices []struct {
+			Message struct {
+				Content   string `json:"content"`
+				ToolCalls []struct {
+					ID       string `json:"id"`
+					Function struct {
+						Name      string          `json:"name"`
+						Arguments json.RawMessage `json:"arguments"`
+					} `json:"function"`
+				} `json:"tool_calls"`
+			} `json:"message"`
+		} `json:"choices"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return AgentMessage{}, fmt.Errorf("invalid tool response: %w", err)
+	}
+	if len(resp.Choices) == 0 {
+		return AgentMessage{}, fmt.Errorf("no choices in response: %s", truncateBody(body))
+	}
+
+	msg := resp.Choices[0].Message
+	reply := AgentMessage{Role: "assistant", Content: msg.Content}
+	for i, c := range msg.ToolCalls {
+		args := bytes.TrimSpace(c.Function.Arguments)
+		var s string
+		if json.Unmarshal(args, &s) == nil {
+			args = []byte(s)
+		}
+		if len(args) == 0 || bytes.Equal(args, []byte("null")) {
+			args = []byte("{}")
+		}
+		id := c.ID
+		if id == "" {
+			id = fmt.Sprintf("call_%d", i)
+		}
+		reply.ToolCalls = append(reply.ToolCalls, ToolCall{ID: id, Name: c.Function.Name, Arguments: string(args)})
+	}
+	return reply, nil
+}
+
+// truncateBody — начало тела ответа для сообщения об ошибке
+func truncateBody(body []byte) string {
+	const max = 300
+	if len(body) > max {
+		return string(body[:max]) + "…"
+	}
+	return string(body)
+}
@@ -0,0 +1,204 @@
+package logic
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// ErrModelListUnsupported возвращается, если провайдер не умеет перечислять модели
+var ErrModelListUnsupported = errors.New("provider does not support listing models")
+
+// ModelLister — провайдер, который может вернуть список доступных моделей
+type ModelLister interface {
+	ListModels(ctx context.Context) ([]string, error)
+}
+
+// ListModels запрашивает у провайдера (профиля или URL) список моделей.
+// Если у ctx нет дедлайна, применяется defaultTimeout.
+func ListModels(ctx context.Context, providerName, apiKey string) ([]string, error) {
+	ctx, cancel := withDefaultTimeout(ctx)
+	defer cancel()
+
+	provider, err := newProvider(providerName, "", apiKey, "")
+	if err != nil {
+		return nil, fmt.Errorf("provider error: %w", err)
+	}
+	lister, ok := provider.(ModelLister)
+	if !ok {
+		return nil, ErrModelListUnsupported
+	}
+
+	models, err := lister.ListModels(ctx)
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(models)
+	return models, nil
+}
+
+// ListModels — локальные модели Ollama (GET /api/tags)
+func (p *OllamaProvider) ListModels(ctx context.Context) ([]string, error) {
+	tagsURL, err := replaceURLPath(p.endpoint("http://localhost:11434/v1/chat/completions"), "/api/tags")
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := getJSON(ctx, tagsURL, p.Key, p.Headers)
+	if err != nil {
+		return nil, err
+	}
+
+	var r struct {
+		Models []struct {
+			Name string `json:"name"`
+		} `json:"models"`
+	}
+	if err := json.Unmarshal(body, &r); err != nil {
+		return nil, fmt.Errorf("failed to parse model list: %w", err)
+	}
+	models := make([]string, 0, len(r.Models))
+	for _, m := range r.Models {
+		models = append(models, m.Name)
+	}
+	return models, nil
+}
+
+func (p *PollinationsProvider) ListModels(ctx context.Context) ([]string, error) {
+	return listOpenAIModels(ctx, p.endpoint("https://gen.pollinations.ai/v1/chat/completions"), p.Key, p.Headers)
+}
+
+func (p *OpenRouterProvider) ListModels(ctx context.Context) ([]string, error) {
+	return listOpenAIModels(ctx, p.endpoint("https://openrouter.ai/api/v1/chat/completions"), p.Key, p.Headers)
+}
+
+func (p *GenericURLProvider) ListModels(ctx context.Context) ([]string, error) {
+	return listOpenAIModels(ctx, p.endpoint("https://api.openai.com/v1/chat/completions"), p.Key, p.Headers)
+}
+
+// ListModels — GET /v1/models Anthropic API (ответ в формате {"data":[{"id":...}]})
+func (p *AnthropicProvider) ListModels(ctx context.Context) ([]string, error) {
+	endpoint := strings.TrimSuffix(p.endpoint(anthropicDefaultURL), "/messages") + "/models?limit=1000"
+	headers := withHeaders(p.Headers, map[string]string{
+		"x-api-key":         p.Key,
+		"anthropic-version": anthropicVersion,
+	})
+
+	body, err := getJSON(ctx, endpoint, "", headers)
+	if err != nil {
+		return nil, anthropicError(err)
+	}
+	return parseModelIDs(body)
+}
+
+// ListModels — модели Gemini, поддерживающие generateContent
+func (p *GeminiProvider) ListModels(ctx context.Context) ([]string, error) {
+	endpoint := strings.TrimRight(p.endpoint(geminiDefaultURL), "/") + "/models?pageSize=1000"
+	headers := withHeaders(p.Headers, map[string]string{"x-goog-api-key": p.Key})
+
+	body, err := getJSON(ctx, endpoint, "", headers)
+	if err != nil {
+		return nil, geminiError(err)
+	}
+
+	var r struct {
+		Models []struct {
+			Name    string   `json:"name"`
+			Methods []string `json:"supportedGenerationMethods"`
+		} `json:"models"`
+	}
+	if err := json.Unmarshal(body, &r); err != nil {
+		return nil, fmt.Errorf("failed to parse model list: %w", err)
+	}
+	var models []string
+	for _, m := range r.Models {
+		for _, method := range m.Methods {
+			if method == "generateContent" {
+				models = append(models, strings.TrimPrefix(m.Name, "models/"))
+				break
+			}
+		}
+	}
+	return models, nil
+}
+
+// listOpenAIModels запрашивает GET .../models рядом с chat/completions endpoint
+func listOpenAIModels(ctx context.Context, chatURL, key string, headers map[string]string) ([]string, error) {
+	modelsURL := ""
+	if strings.HasSuffix(chatURL, "/chat/completions") {
+		modelsURL = strings.TrimSuffix(chatURL, "/chat/completions") + "/models"
+	} else {
+		var err error
+		if modelsURL, err = replaceURLPath(chatURL, "/v1/models"); err != nil {
+			return nil, err
+		}
+	}
+
+	body, err := getJSON(ctx, modelsURL, key, headers)
+	if err != nil {
+		return nil, err
+	}
+	return parseModelIDs(body)
+}
+
+// parseModelIDs разбирает ответ {"data":[{"id":"..."}]}
+func parseModelIDs(body []byte) ([]string, error) {
+	var r struct {
+		Data []struct {
+			ID string `json:"id"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(body, &r); err != nil {
+		return nil, fmt.Errorf("failed to parse model list: %w", err)
+	}
+	models := make([]string, 0, len(r.Data))
+	for _, m := range r.Data {
+		if m.ID != "" {
+			models = append(models, m.ID)
+		}
+	}
+	return models, nil
+}
+
+// replaceURLPath заменяет путь в URL, сохраняя схему и хост
+func replaceURLPath(rawURL, path string) (string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", fmt.Errorf("invalid provider URL %q: %w", rawURL, err)
+	}
+	u.Path = path
+	u.RawQuery = ""
+	return u.String(), nil
+}
+
+// getJSON выполняет GET-запрос (с повторами, см. doWithRetry) и возвращает тело ответа
+func getJSON(ctx context.Context, url, key string, headers map[string]string) ([]byte, error) {
+	resp, err := doWithRetry(ctx, httpClient, func() (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create request: %w", err)
+		}
+		req.Header.Set("Accept", "application/json")
+		if key != "" {
+			req.Header.Set("Authorization", "Bearer "+key)
+		}
+		setExtraHeaders(req, url, headers)
+		return req, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+	return body, nil
+}
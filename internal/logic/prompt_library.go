@@ -0,0 +1,239 @@
+package logic
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// promptLibraryDirName — каталог библиотеки промптов (в каталоге настроек и в .golite проекта)
+const promptLibraryDirName = "prompts"
+
+// Источники шаблонов библиотеки промптов
+const (
+	PromptFromBuiltin = "built-in"
+	PromptFromUser    = "user"
+	PromptFromProject = "project"
+)
+
+// PromptTemplate — шаблон, вызываемый командой "/<Name>" в поле ввода AI чата.
+// Переменные: {{target}}, {{selection}}, {{file}}, {{language}}, {{input}} (см. SlashVars).
+type PromptTemplate struct {
+	Name        string
+	Description string
+	Template    string
+	Source      string // PromptFromBuiltin, PromptFromUser или PromptFromProject
+	Path        string // файл шаблона ("" — встроенный)
+}
+
+// SlashVars — значения переменных шаблона команды
+type SlashVars struct {
+	Selection string // выделенный текст ("" — выделения нет)
+	File      string // имя текущего файла
+	Language  string // язык текущего файла
+	Input     string // текст после команды
+}
+
+// builtinPrompts — встроенные команды; файл с тем же именем в библиотеке заменяет их
+var builtinPrompts = []PromptTemplate{
+	{Name: "explain", Description: "Explain what the code does", Template: "Explain {{target}} step by step: what it does, how the parts interact, and anything surprising or error-prone.\n{{input}}\n{{selection}}"},
+	{Name: "test", Description: "Write unit tests", Template: "Write {{language}} unit tests for {{target}}. Cover normal cases, edge cases and error paths; use table-driven tests where idiomatic. Return a complete test file.\n{{input}}\n{{selection}}"},
+	{Name: "doc", Description: "Write doc comments", Template: "Write documentation comments for the exported and non-trivial declarations in {{target}}, following {{language}} conventions. Return the code with the comments added and nothing else changed.\n{{input}}\n{{selection}}"},
+	{Name: "fix", Description: "Find and fix bugs", Template: "Find bugs in {{target}} and fix them. For each bug explain the cause in one sentence, then give the corrected code.\n{{input}}\n{{selection}}"},
+	{Name: "review", Description: "Code review", Template: "Review {{target}} as an experienced {{language}} developer: correctness, error handling, concurrency, naming and readability. List concrete findings ordered by severity, with line references and suggested changes.\n{{input}}\n{{selection}}"},
+	{Name: "refactor", Description: "Refactor without changing behavior", Template: "Refactor {{target}} to be simpler and more idiomatic {{language}} without changing its behavior. Explain the main changes briefly, then give the full refactored code.\n{{input}}\n{{selection}}"},
+}
+
+var (
+	// promptNameRe — допустимое имя команды (и файла шаблона)
+	promptNameRe = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_-]*$`)
+	// blankLinesRe — лишние пустые строки на месте пустых переменных
+	blankLinesRe = regexp.MustCompile(`\n{3,}`)
+)
+
+// ErrInvalidPromptName возвращается для имён, которые нельзя вызвать командой
+var ErrInvalidPromptName = errors.New("prompt name may contain only letters, digits, '-' and '_'")
+
+// PromptLibraryDir возвращает каталог пользовательской библиотеки промптов
+func PromptLibraryDir() (string, error) {
+	dir, err := ConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, promptLibraryDirName), nil
+}
+
+// ProjectPromptLibraryDir возвращает каталог промптов проекта
+func ProjectPromptLibraryDir(root string) string {
+	return filepath.Join(root, ProjectConfigDir, promptLibraryDirName)
+}
+
+// LoadPromptLibrary возвращает встроенные шаблоны, дополненные и переопределённые
+// файлами <name>.md пользователя и проекта (root == "" — без проекта), по имени.
+// Нечитаемые файлы пропускаются; ошибка описывает первый из них.
+func LoadPromptLibrary(root string) ([]PromptTemplate, error) {
+	byName := map[string]PromptTemplate{}
+	for _, p := range builtinPrompts {
+		p.Source = PromptFromBuiltin
+		byName[p.Name] = p
+	}
+
+	var firstErr error
+	load := func(dir, source string) {
+		list, err := readPromptDir(dir, source)
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+		for _, p := range list {
+			byName[p.Name] = p
+		}
+	}
+	if dir, err := PromptLibraryDir(); err == nil {
+		load(dir, PromptFromUser)
+	}
+	if root != "" {
+		load(ProjectPromptLibraryDir(root), PromptFromProject)
+	}
+
+	list := make([]PromptTemplate, 0, len(byName))
+	for _, p := range byName {
+		list = append(list, p)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list, firstErr
+}
+
+// readPromptDir читает шаблоны *.md каталога; первая строка вида "# ..." — описание
+func readPromptDir(dir, source string) ([]PromptTemplate, error) {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read prompt library: %w", err)
+	}
+
+	var list []PromptTemplate
+	var firstErr error
+	for _, entry := range entries {
+		name := strings.TrimSuffix(entry.Name(), ".md")
+		if entry.IsDir() || name == entry.Name() || !promptNameRe.MatchString(name) {
+			continue
+		}
+		path := filepath.Join(dir, entry.Name())
+		data, err := os.ReadFile(path)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = fmt.Errorf("failed to read prompt %s: %w", name, err)
+			}
+			continue
+		}
+		p := PromptTemplate{Name: strings.ToLower(name), Source: source, Path: path}
+		p.Description, p.Template = splitPromptHeader(string(data))
+		list = append(list, p)
+	}
+	return list, firstErr
+}
+
+// splitPromptHeader отделяет строку-заголовок "# описание" от текста шаблона
+func splitPromptHeader(text string) (description, body string) {
+	text = strings.TrimLeft(text, "\r\n")
+	first, rest, _ := strings.Cut(text, "\n")
+	if title, ok := strings.CutPrefix(strings.TrimSpace(first), "# "); ok {
+		return strings.TrimSpace(title), strings.TrimSpace(rest)
+	}
+	return "", strings.TrimSpace(text)
+}
+
+// PromptFileContent формирует содержимое файла шаблона для библиотеки
+func PromptFileContent(p PromptTemplate) string {
+	var sb strings.Builder
+	if p.Description != "" {
+		sb.WriteString("# " + p.Description + "\n\n")
+	}
+	sb.WriteString(strings.TrimSpace(p.Template))
+	sb.WriteString("\n")
+	return sb.String()
+}
+
+// WritePromptTemplate создаёт или перезаписывает <dir>/<name>.md и возвращает путь файла
+func WritePromptTemplate(dir string, p PromptTemplate) (string, error) {
+	if !promptNameRe.MatchString(p.Name) {
+		return "", ErrInvalidPromptName
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create prompt library: %w", err)
+	}
+	path := filepath.Join(dir, strings.ToLower(p.Name)+".md")
+	if err := os.WriteFile(path, []byte(PromptFileContent(p)), 0644); err != nil {
+		return "", fmt.Errorf("failed to write prompt: %w", err)
+	}
+	return path, nil
+}
+
+// ParseSlashCommand разбирает ввод вида "/name остальной текст"; ok == false — это не команда
+func ParseSlashCommand(input string) (name, rest string, ok bool) {
+	text := strings.TrimLeft(input, " \t")
+	if !strings.HasPrefix(text, "/") {
+		return "", "", false
+	}
+	name = text[1:]
+	if i := strings.IndexFunc(name, unicode.IsSpace); i >= 0 {
+		name, rest = name[:i], name[i:]
+	}
+	if !promptNameRe.MatchString(name) {
+		return "", "", false
+	}
+	return strings.ToLower(name), strings.TrimSpace(rest), true
+}
+
+// FindPrompt ищет шаблон по имени
+func FindPrompt(library []PromptTemplate, name string) (PromptTemplate, bool) {
+	for _, p := range library {
+		if p.Name == strings.ToLower(name) {
+			return p, true
+		}
+	}
+	return PromptTemplate{}, false
+}
+
+// ExpandPrompt подставляет переменные в шаблон. Выделение вставляется блоком кода;
+// без выделения {{target}} ссылается на текущий файл, который уже есть в контексте чата.
+// Если в шаблоне нет {{input}}, текст после команды добавляется в конец.
+func ExpandPrompt(p PromptTemplate, vars SlashVars) string {
+	language := vars.Language
+	if language == "" {
+		language = "Go"
+	}
+	file := vars.File
+	if file == "" {
+		file = "untitled"
+	}
+
+	target := fmt.Sprintf("the current file %s", file)
+	selection := ""
+	if strings.TrimSpace(vars.Selection) != "" {
+		target = fmt.Sprintf("the following code from %s", file)
+		fence := strings.ToLower(strings.Fields(language)[0])
+		selection = fmt.Sprintf("```%s\n%s\n```", fence, strings.TrimRight(vars.Selection, "\n"))
+	}
+
+	tmpl := p.Template
+	if vars.Input != "" && !strings.Contains(tmpl, "{{input}}") {
+		tmpl += "\n\n{{input}}"
+	}
+	text := strings.NewReplacer(
+		"{{target}}", target,
+		"{{selection}}", selection,
+		"{{file}}", file,
+		"{{language}}", language,
+		"{{input}}", vars.Input,
+	).Replace(tmpl)
+
+	return strings.TrimSpace(blankLinesRe.ReplaceAllString(text, "\n\n"))
+}
@@ -0,0 +1,232 @@
+package logic
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ErrUnknownProvider возвращается, если имя не совпадает ни с профилем,
+// ни с зарегистрированным провайдером, ни с URL.
+var ErrUnknownProvider = errors.New("unknown provider")
+
+// ProviderOptions — параметры создания провайдера
+type ProviderOptions struct {
+	Endpoint     string            // URL API; пусто — адрес провайдера по умолчанию
+	Model        string            // имя модели
+	Key          string            // API ключ
+	Headers      map[string]string // дополнительные HTTP-заголовки
+	SystemPrompt string            // системный промпт (уже с подставленными переменными)
+	FIM          FIMMode           // способ fill-in-the-middle; пусто — по имени модели
+}
+
+func (o ProviderOptions) endpoint(def string) string {
+	if o.Endpoint != "" {
+		return o.Endpoint
+	}
+	return def
+}
+
+// ProviderFactory создаёт провайдер по параметрам
+type ProviderFactory func(opts ProviderOptions) (Provider, error)
+
+// ProviderProfile — именованный набор настроек провайдера (задаётся в config.json)
+type ProviderProfile struct {
+	Name         string            `json:"name"`
+	Provider     string            `json:"provider"`                // имя зарегистрированного провайдера
+	Endpoint     string            `json:"endpoint,omitempty"`      // переопределение URL API
+	Model        string            `json:"model,omitempty"`         // модель по умолчанию для профиля
+	KeyEnv       string            `json:"key_env,omitempty"`       // переменная окружения с API ключом
+	Headers      map[string]string `json:"headers,omitempty"`       // дополнительные HTTP-заголовки
+	SystemPrompt string            `json:"system_prompt,omitempty"` // системный промпт профиля
+	FIM          FIMMode           `json:"fim,omitempty"`           // "suffix", "tokens" или "off"
+}
+
+var (
+	registryMu        sync.RWMutex
+	providerFactories = map[string]ProviderFactory{}
+	providerProfiles  = map[string]ProviderProfile{}
+	profileOrder      []string
+)
+
+func init() {
+	RegisterProvider("ollama", func(o ProviderOptions) (Provider, error) {
+		return &OllamaProvider{o}, nil
+	})
+	RegisterProvider("pollinations", func(o ProviderOptions) (Provider, error) {
+		return &PollinationsProvider{o}, nil
+	})
+	RegisterProvider("openrouter", func(o ProviderOptions) (Provider, error) {
+		return &OpenRouterProvider{o}, nil
+	})
+	RegisterProvider("anthropic", func(o ProviderOptions) (Provider, error) {
+		return &AnthropicProvider{o}, nil
+	})
+	RegisterProvider("gemini", func(o ProviderOptions) (Provider, error) {
+		return &GeminiProvider{o}, nil
+	})
+	// "openai" — любой OpenAI-совместимый API; в профиле обычно задаётся endpoint
+	RegisterProvider("openai", func(o ProviderOptions) (Provider, error) {
+		return &GenericURLProvider{o}, nil
+	})
+}
+
+// RegisterProvider регистрирует фабрику провайдера под именем name (регистр не важен).
+// Повторная регистрация заменяет прежнюю фабрику.
+func RegisterProvider(name string, factory ProviderFactory) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	providerFactories[normalizeProviderName(name)] = factory
+}
+
+// ProviderNames возвращает отсортированный список зарегистрированных провайдеров
+func ProviderNames() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	names := make([]string, 0, len(providerFactories))
+	for name := range providerFactories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// SetProviderProfiles заменяет набор именованных профилей.
+// Профили с пустым именем, именем встроенного провайдера или незарегистрированным
+// провайдером отклоняются.
+func SetProviderProfiles(profiles []ProviderProfile) error {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	providerProfiles = map[string]ProviderProfile{}
+	profileOrder = nil
+
+	var errs []string
+	for _, p := range profiles {
+		name := normalizeProviderName(p.Name)
+		if name == "" {
+			errs = append(errs, "profile without name")
+			continue
+		}
+		if _, builtin := providerFactories[name]; builtin {
+			errs = append(errs, fmt.Sprintf("profile %q: the name of a built-in provider", p.Name))
+			continue
+		}
+		if _, ok := providerFactories[normalizeProviderName(p.Provider)]; !ok {
+			errs = append(errs, fmt.Sprintf("profile %q: %v %q", p.Name, ErrUnknownProvider, p.Provider))
+			continue
+		}
+		if _, dup := providerProfiles[name]; !dup {
+			profileOrder = append(profileOrder, name)
+		}
+		providerProfiles[name] = p
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid provider profiles: %s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// ProviderProfiles возвращает профили в порядке их объявления
+func ProviderProfiles() []ProviderProfile {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	res := make([]ProviderProfile, 0, len(profileOrder))
+	for _, name := range profileOrder {
+		res = append(res, providerProfiles[name])
+	}
+	return res
+}
+
+// LookupProviderProfile ищет профиль по имени
+func LookupProviderProfile(name string) (ProviderProfile, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	p, ok := providerProfiles[normalizeProviderName(name)]
+	return p, ok
+}
+
+// ValidateProviderName проверяет, что имя можно использовать как провайдер
+func ValidateProviderName(name string) error {
+	if isURL(name) {
+		return nil
+	}
+	registryMu.RLock()
+	_, isProfile := providerProfiles[normalizeProviderName(name)]
+	_, ok := providerFactories[normalizeProviderName(name)]
+	registryMu.RUnlock()
+	if isProfile && ok {
+		// Провайдер зарегистрирован после загрузки профилей
+		return fmt.Errorf("profile %q has the name of a built-in provider", name)
+	}
+	if !isProfile && !ok {
+		return fmt.Errorf("%w %q (available: %s)", ErrUnknownProvider, name, strings.Join(ProviderNames(), ", "))
+	}
+	return nil
+}
+
+// --- Provider Factory ---
+
+// newProvider создаёт провайдер по имени профиля, имени зарегистрированного провайдера или URL.
+// model и key, если заданы, имеют приоритет над значениями профиля; system — готовый
+// системный промпт (см. ResolveSystemPrompt).
+func newProvider(name, model, key, system string) (Provider, error) {
+	return makeProvider(name, model, key, system, true)
+}
+
+// makeProvider — newProvider, который при resolveKey == false не ищет сохранённый ключ:
+// ResolveAPIKey может запускать secret-tool, а для вопросов о возможностях
+// провайдера (см. FIMSupported) ключ не нужен
+func makeProvider(name, model, key, system string, resolveKey bool) (Provider, error) {
+	opts := ProviderOptions{Model: model, Key: key, SystemPrompt: system}
+	kind := normalizeProviderName(name)
+
+	if profile, ok := LookupProviderProfile(name); ok {
+		kind = normalizeProviderName(profile.Provider)
+		opts.Endpoint = profile.Endpoint
+		opts.Headers = profile.Headers
+		opts.FIM = profile.FIM
+		if opts.Model == "" {
+			opts.Model = profile.Model
+		}
+		// Ключ профиля из окружения важнее общего ключа (--key)
+		if profile.KeyEnv != "" {
+			if envKey := os.Getenv(profile.KeyEnv); envKey != "" {
+				opts.Key = envKey
+			}
+		}
+	}
+
+	// Явный ключ не задан — ищем сохранённый ключ этого провайдера
+	if opts.Key == "" && resolveKey {
+		stored, _, err := ResolveAPIKey(name)
+		if err != nil {
+			return nil, err
+		}
+		opts.Key = stored
+	}
+
+	if isURL(name) {
+		// Если имя похоже на URL, используем Generic провайдер
+		opts.Endpoint = name
+		return &GenericURLProvider{opts}, nil
+	}
+
+	registryMu.RLock()
+	factory, ok := providerFactories[kind]
+	registryMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%w %q (available: %s)", ErrUnknownProvider, name, strings.Join(ProviderNames(), ", "))
+	}
+	return factory(opts)
+}
+
+func normalizeProviderName(name string) string {
+	return strings.ToLower(strings.TrimSpace(name))
+}
@@ -0,0 +1,248 @@
+package logic
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// redactedPrefix начинает замену найденного секрета: "[REDACTED:<правило>]"
+const redactedPrefix = "[REDACTED:"
+
+// RedactPattern — пользовательское правило маскирования (поле "redact_patterns" настроек).
+// Если в выражении есть группы, маскируется первая, иначе всё совпадение.
+type RedactPattern struct {
+	Name  string `json:"name"`
+	Regex string `json:"regex"`
+}
+
+// RedactionSettings — настройки маскирования секретов перед отправкой провайдеру
+type RedactionSettings struct {
+	Enabled        bool
+	Patterns       []RedactPattern
+	AllowProviders []string // провайдеры (профили), которым контекст отправляется как есть
+}
+
+// DefaultRedactAllowProviders — локальные провайдеры, данные не покидают машину
+var DefaultRedactAllowProviders = []string{"ollama"}
+
+// redactRule — скомпилированное правило; group — номер маскируемой группы (0 — всё совпадение)
+type redactRule struct {
+	name  string
+	re    *regexp.Regexp
+	group int
+}
+
+// builtinRedactRules — известные форматы ключей и токенов
+var builtinRedactRules = []redactRule{
+	{"private key", regexp.MustCompile(`-----BEGIN [A-Z0-9 ]*PRIVATE KEY( BLOCK)?-----[\s\S]*?-----END [A-Z0-9 ]*PRIVATE KEY( BLOCK)?-----`), 0},
+	{"aws key", regexp.MustCompile(`\b(AKIA|ASIA)[0-9A-Z]{16}\b`), 0},
+	{"github token", regexp.MustCompile(`\b(gh[pousr]_[A-Za-z0-9]{36,}|github_pat_[A-Za-z0-9_]{40,})\b`), 0},
+	{"gitlab token", regexp.MustCompile(`\bglpat-[A-Za-z0-9_-]{20,}\b`), 0},
+	{"slack token", regexp.MustCompile(`\bxox[abposr]-[A-Za-z0-9-]{10,}\b`), 0},
+	{"google api key", regexp.MustCompile(`\bAIza[0-9A-Za-z_-]{35}\b`), 0},
+	{"api key", regexp.MustCompile(`\bsk-(ant-|or-|proj-)?[A-Za-z0-9_-]{20,}\b`), 0},
+	{"jwt", regexp.MustCompile(`\beyJ[A-Za-z0-9_-]{10,}\.eyJ[A-Za-z0-9_-]{10,}\.[A-Za-z0-9_-]{10,}\b`), 0},
+	{"bearer token", regexp.MustCompile(`(?i)\bbearer\s+([A-Za-z0-9._~+/-]{20,}=*)`), 1},
+	{"url credentials", regexp.MustCompile(`\b[a-z][a-z0-9+.-]*://[^\s:/@]+:([^\s@/]+)@`), 1},
+}
+
+// genericRedactRules — общие правила "key = value"; применяются последними,
+// чтобы специфичные правила (в том числе пользовательские) назвали секрет точнее
+var genericRedactRules = []redactRule{
+	// Строки .env и export: FOO_TOKEN=..., DB_PASSWORD="...". Имя только заглавными,
+	// значение — литерал до конца строки (допускается комментарий), поэтому
+	// присваивания в коде вида cacheKey = responseCacheKey(...) не совпадают.
+	{"env value", regexp.MustCompile(`(?m)^[ \t]*(?:export[ \t]+)?[A-Z_][A-Z0-9_]*(?:KEY|SECRET|TOKEN|PASSW(?:OR)?D|PWD|CREDENTIALS?|DSN|AUTH)[A-Z0-9_]*[ \t]*=[ \t]*["']?([^\s"'#()]{4,})["']?[ \t]*(?:#.*)?\r?$`), 1},
+	// Присваивания в коде и конфигах: apiKey := "...", "password": "..."
+	{"secret value", regexp.MustCompile(`(?i)(?:api[_-]?key|secret|token|passw(?:or)?d|pwd|credentials?|auth)["']?\s*(?::=|=|:)\s*["'` + "`" + `]([^"'` + "`" + `\s]{8,})["'` + "`" + `]`), 1},
+}
+
+var (
+	redactMu       sync.RWMutex
+	redactEnabled  = true
+	redactRules    = append(append([]redactRule{}, builtinRedactRules...), genericRedactRules...)
+	redactAllowSet = allowSet(DefaultRedactAllowProviders)
+)
+
+// SetRedaction применяет настройки маскирования. Ошибочные пользовательские
+// выражения пропускаются, остальные правила всё равно применяются.
+func SetRedaction(s RedactionSettings) error {
+	rules := append([]redactRule{}, builtinRedactRules...)
+	var bad []string
+	for _, p := range s.Patterns {
+		re, err := regexp.Compile(p.Regex)
+		if err != nil || p.Regex == "" {
+			bad = append(bad, fmt.Sprintf("%q", p.Name))
+			continue
+		}
+		name := p.Name
+		if name == "" {
+			name = "custom"
+		}
+		group := 0
+		if re.NumSubexp() > 0 {
+			group = 1
+		}
+		rules = append(rules, redactRule{name, re, group})
+	}
+	rules = append(rules, genericRedactRules...)
+
+	redactMu.Lock()
+	redactEnabled = s.Enabled
+	redactRules = rules
+	redactAllowSet = allowSet(s.AllowProviders)
+	redactMu.Unlock()
+
+	if len(bad) > 0 {
+		return fmt.Errorf("invalid redact_patterns ignored: %s", strings.Join(bad, ", "))
+	}
+	return nil
+}
+
+func allowSet(names []string) map[string]bool {
+	set := make(map[string]bool, len(names))
+	for _, n := range names {
+		set[normalizeProviderName(n)] = true
+	}
+	return set
+}
+
+// ShouldRedact сообщает, нужно ли маскировать секреты для провайдера
+func ShouldRedact(provider string) bool {
+	redactMu.RLock()
+	defer redactMu.RUnlock()
+	return redactEnabled && !redactAllowSet[normalizeProviderName(provider)]
+}
+
+// Redaction — число замен одного правила
+type Redaction struct {
+	Rule  string `json:"rule"`
+	Count int    `json:"count"`
+}
+
+// RedactionReport — сводка замаскированных фрагментов одного запроса
+type RedactionReport []Redaction
+
+// Total возвращает общее число замен
+func (r RedactionReport) Total() int {
+	n := 0
+	for _, x := range r {
+		n += x.Count
+	}
+	return n
+}
+
+// String формирует строку вида "api key ×2, private key"
+func (r RedactionReport) String() string {
+	parts := make([]string, len(r))
+	for i, x := range r {
+		parts[i] = x.Rule
+		if x.Count > 1 {
+			parts[i] += fmt.Sprintf(" ×%d", x.Count)
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// merge добавляет счётчики other к отчёту
+func (r RedactionReport) merge(other RedactionReport) RedactionReport {
+	if len(other) == 0 {
+		return r
+	}
+	counts := map[string]int{}
+	for _, x := range append(append(RedactionReport{}, r...), other...) {
+		counts[x.Rule] += x.Count
+	}
+	out := make(RedactionReport, 0, len(counts))
+	for rule, n := range counts {
+		out = append(out, Redaction{rule, n})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Count != out[j].Count {
+			return out[i].Count > out[j].Count
+		}
+		return out[i].Rule < out[j].Rule
+	})
+	return out
+}
+
+// RedactText заменяет найденные секреты на "[REDACTED:<правило>]"
+func RedactText(text string) (string, RedactionReport) {
+	redactMu.RLock()
+	rules := redactRules
+	redactMu.RUnlock()
+
+	var report RedactionReport
+	for _, rule := range rules {
+		var n int
+		text, n = rule.apply(text)
+		if n > 0 {
+			report = report.merge(RedactionReport{{rule.name, n}})
+		}
+	}
+	return text, report
+}
+
+// apply маскирует совпадения правила и возвращает число замен
+func (r redactRule) apply(s string) (string, int) {
+	matches := r.re.FindAllStringSubmatchIndex(s, -1)
+	if matches == nil {
+		return s, 0
+	}
+
+	var b strings.Builder
+	last, n := 0, 0
+	for _, m := range matches {
+		start, end := m[2*r.group], m[2*r.group+1]
+		// Уже замаскировано более специфичным правилом
+		if start < 0 || strings.HasPrefix(s[start:end], redactedPrefix) {
+			continue
+		}
+		b.WriteString(s[last:start])
+		b.WriteString(redactedPrefix + r.name + "]")
+		last = end
+		n++
+	}
+	b.WriteString(s[last:])
+	return b.String(), n
+}
+
+// redactedPlaceholderRe находит метки, которые RedactText ставит вместо секретов
+var redactedPlaceholderRe = regexp.MustCompile(`\[REDACTED:[^\]\n]*\]`)
+
+// NewRedactedPlaceholders возвращает метки "[REDACTED:…]", которых в updated больше,
+// чем в original. Модель видела только метку вместо секрета и повторила её —
+// такой текст нельзя записывать в файл.
+func NewRedactedPlaceholders(original, updated string) []string {
+	if !strings.Contains(updated, redactedPrefix) {
+		return nil
+	}
+	counts := map[string]int{}
+	for _, m := range redactedPlaceholderRe.FindAllString(original, -1) {
+		counts[m]++
+	}
+	var added []string
+	for _, m := range redactedPlaceholderRe.FindAllString(updated, -1) {
+		counts[m]--
+		if counts[m] == -1 {
+			added = append(added, m)
+		}
+	}
+	return added
+}
+
+// redactMessages возвращает копию истории с замаскированными секретами
+func redactMessages(history []Message) ([]Message, RedactionReport) {
+	var report RedactionReport
+	out := make([]Message, len(history))
+	for i, m := range history {
+		var r RedactionReport
+		m.Content, r = RedactText(m.Content)
+		report = report.merge(r)
+		out[i] = m
+	}
+	return out, report
+}
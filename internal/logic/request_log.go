@@ -0,0 +1,262 @@
+package logic
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// requestLogFileName — журнал запросов в каталоге настроек (JSON Lines)
+const requestLogFileName = "requests.jsonl"
+
+const (
+	// requestLogMemory — сколько последних запросов хранится в памяти для инспектора
+	requestLogMemory = 100
+	// maxLoggedResponse ограничивает сохраняемое тело ответа
+	maxLoggedResponse = 256 * 1024
+	// maxRequestLogSize — размер журнала, после которого он переименовывается в *.1
+	maxRequestLogSize = 20 * 1024 * 1024
+)
+
+// RequestLogEntry — один запрос к провайдеру (попытка цепочки Chat или запрос FIM)
+type RequestLogEntry struct {
+	ID         int64           `json:"id"`
+	Time       time.Time       `json:"time"`
+	Kind       string          `json:"kind"` // "chat" или "fim"
+	Task       AITask          `json:"task,omitempty"`
+	Provider   string          `json:"provider"`
+	Model      string          `json:"model,omitempty"`
+	URL        string          `json:"url,omitempty"`
+	LatencyMs  int64           `json:"latency_ms"`
+	Status     int             `json:"status,omitempty"`   // HTTP статус последней попытки
+	Attempts   int             `json:"attempts,omitempty"` // HTTP запросов с учётом повторов
+	Error      string          `json:"error,omitempty"`
+	System     string          `json:"system,omitempty"` // системный промпт
+	Prompt     []Message       `json:"prompt,omitempty"` // после маскирования секретов
+	Images     int             `json:"images,omitempty"`
+	FIM        *FIMRequest     `json:"fim,omitempty"`
+	Redactions RedactionReport `json:"redactions,omitempty"`
+	Response   string          `json:"response,omitempty"` // тело ответа как есть (для потоков — события SSE)
+	Content    string          `json:"content,omitempty"`  // извлечённый текст ответа
+	Cached     bool            `json:"cached,omitempty"`   // ответ из кэша, запрос не отправлялся
+}
+
+var (
+	requestLogMu       sync.Mutex
+	requestLogPath     string // "" — журнал в файл не пишется
+	requestLogRing     []RequestLogEntry
+	requestLogListener func(RequestLogEntry)
+	requestLogLastID   int64
+)
+
+// DefaultRequestLogPath возвращает путь журнала запросов по умолчанию
+func DefaultRequestLogPath() (string, error) {
+	dir, err := ConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, requestLogFileName), nil
+}
+
+// SetRequestLog включает запись журнала в файл path ("" — выключает)
+func SetRequestLog(path string) {
+	requestLogMu.Lock()
+	defer requestLogMu.Unlock()
+	requestLogPath = path
+}
+
+// RequestLogPath возвращает путь текущего журнала ("" — журнал выключен)
+func RequestLogPath() string {
+	requestLogMu.Lock()
+	defer requestLogMu.Unlock()
+	return requestLogPath
+}
+
+// SetRequestLogListener задаёт функцию, вызываемую (из фоновой горутины) для каждого запроса
+func SetRequestLogListener(fn func(RequestLogEntry)) {
+	requestLogMu.Lock()
+	defer requestLogMu.Unlock()
+	requestLogListener = fn
+}
+
+// RecentRequests возвращает последние запросы текущего сеанса, от старых к новым
+func RecentRequests() []RequestLogEntry {
+	requestLogMu.Lock()
+	defer requestLogMu.Unlock()
+	return append([]RequestLogEntry(nil), requestLogRing...)
+}
+
+// LoadRequestLog читает не более limit последних записей журнала
+func LoadRequestLog(path string, limit int) ([]RequestLogEntry, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open request log: %w", err)
+	}
+	defer f.Close()
+
+	var entries []RequestLogEntry
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var entry RequestLogEntry
+		// Повреждённые строки (например, оборванная запись) пропускаем
+		if json.Unmarshal(scanner.Bytes(), &entry) != nil {
+			continue
+		}
+		entries = append(entries, entry)
+		if len(entries) > limit {
+			entries = entries[1:]
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return entries, fmt.Errorf("failed to read request log: %w", err)
+	}
+	return entries, nil
+}
+
+// logRequest сохраняет запись в памяти и, если журнал включён, дописывает её в файл
+func logRequest(entry RequestLogEntry) {
+	requestLogMu.Lock()
+	// ID уникален и возрастает даже для записей в одну наносекунду
+	entry.ID = entry.Time.UnixNano()
+	if entry.ID <= requestLogLastID {
+		entry.ID = requestLogLastID + 1
+	}
+	requestLogLastID = entry.ID
+
+	requestLogRing = append(requestLogRing, entry)
+	if len(requestLogRing) > requestLogMemory {
+		requestLogRing = requestLogRing[len(requestLogRing)-requestLogMemory:]
+	}
+	path, listener := requestLogPath, requestLogListener
+	var err error
+	if path != "" {
+		err = appendRequestLog(path, entry)
+	}
+	requestLogMu.Unlock()
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	}
+	if listener != nil {
+		listener(entry)
+	}
+}
+
+// appendRequestLog дописывает строку в журнал; файл доступен только владельцу —
+// в нём код и ответы моделей
+func appendRequestLog(path string, entry RequestLogEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to encode request log entry: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create request log directory: %w", err)
+	}
+	if fi, err := os.Stat(path); err == nil && fi.Size() > maxRequestLogSize {
+		os.Rename(path, path+".1")
+	}
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open request log: %w", err)
+	}
+	defer f.Close()
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write request log: %w", err)
+	}
+	return nil
+}
+
+// --- Трассировка HTTP запроса ---
+
+// requestTrace собирает URL, статус и тело ответа запроса, выполняемого с ctx
+type requestTrace struct {
+	mu       sync.Mutex
+	url      string
+	status   int
+	attempts int
+	body     []byte
+}
+
+type requestTraceKey struct{}
+
+// withRequestTrace добавляет в ctx трассировку для журнала запросов
+func withRequestTrace(ctx context.Context) (context.Context, *requestTrace) {
+	t := &requestTrace{}
+	return context.WithValue(ctx, requestTraceKey{}, t), t
+}
+
+func traceFromContext(ctx context.Context) *requestTrace {
+	t, _ := ctx.Value(requestTraceKey{}).(*requestTrace)
+	return t
+}
+
+// attempt отмечает очередную HTTP попытку (nil-трассировка игнорируется)
+func (t *requestTrace) attempt(url string, status int, errBody []byte) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.url, t.status = url, status
+	t.attempts++
+	t.body = append(t.body[:0], capBytes(errBody)...)
+}
+
+// wrapBody копирует читаемое тело ответа в трассировку
+func (t *requestTrace) wrapBody(body io.ReadCloser) io.ReadCloser {
+	if t == nil {
+		return body
+	}
+	return &tracedBody{ReadCloser: body, trace: t}
+}
+
+func (t *requestTrace) write(p []byte) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if room := maxLoggedResponse - len(t.body); room > 0 {
+		if len(p) > room {
+			p = p[:room]
+		}
+		t.body = append(t.body, p...)
+	}
+}
+
+// fill переносит собранные сведения в запись журнала
+func (t *requestTrace) fill(entry *RequestLogEntry) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	entry.URL, entry.Status, entry.Attempts = t.url, t.status, t.attempts
+	entry.Response = string(t.body)
+}
+
+type tracedBody struct {
+	io.ReadCloser
+	trace *requestTrace
+}
+
+func (b *tracedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if n > 0 {
+		b.trace.write(p[:n])
+	}
+	return n, err
+}
+
+func capBytes(b []byte) []byte {
+	if len(b) > maxLoggedResponse {
+		return b[:maxLoggedResponse]
+	}
+	return b
+}
@@ -0,0 +1,269 @@
+package logic
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// responseCacheFileName — файл кэша ответов в каталоге настроек (если включено сохранение)
+const responseCacheFileName = "response_cache.json"
+
+// responseCacheSaveDelay — запись на диск откладывается, чтобы серия дополнений давала одну запись
+const responseCacheSaveDelay = 5 * time.Second
+
+// ResponseCacheSettings — настройки кэша ответов на одинаковые запросы дополнения
+type ResponseCacheSettings struct {
+	Enabled    bool
+	TTL        time.Duration
+	MaxEntries int
+	MaxBytes   int  // суммарный размер ответов
+	Persist    bool // сохранять кэш между запусками
+}
+
+// DefaultResponseCacheSettings — значения по умолчанию
+var DefaultResponseCacheSettings = ResponseCacheSettings{
+	Enabled:    true,
+	TTL:        10 * time.Minute,
+	MaxEntries: 500,
+	MaxBytes:   4 * 1024 * 1024,
+}
+
+// cachedResponse — запись кэша
+type cachedResponse struct {
+	Key     string    `json:"key"`
+	Text    string    `json:"text"`
+	Created time.Time `json:"created"`
+}
+
+// responseCache — LRU-кэш с ограничением по времени жизни, числу записей и размеру
+type responseCache struct {
+	mu        sync.Mutex
+	settings  ResponseCacheSettings
+	path      string                   // "" — без сохранения на диск
+	order     *list.List               // от новых к старым, элементы *cachedResponse
+	items     map[string]*list.Element // ключ — адрес содержимого запроса
+	bytes     int
+	saveTimer *time.Timer
+}
+
+var respCache = &responseCache{
+	settings: DefaultResponseCacheSettings,
+	order:    list.New(),
+	items:    map[string]*list.Element{},
+}
+
+// SetResponseCache применяет настройки кэша. При включённом сохранении
+// кэш загружается из файла; ошибка чтения файла не отключает кэш.
+func SetResponseCache(s ResponseCacheSettings) error {
+	c := respCache
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.settings = s
+	if !s.Enabled {
+		c.clearLocked()
+		c.path = ""
+		return nil
+	}
+
+	path := ""
+	if s.Persist {
+		dir, err := ConfigDir()
+		if err != nil {
+			return err
+		}
+		path = filepath.Join(dir, responseCacheFileName)
+	}
+	if path != "" && path != c.path {
+		c.path = path
+		if err := c.loadLocked(); err != nil {
+			return err
+		}
+	}
+	c.path = path
+	c.trimLocked()
+	return nil
+}
+
+// ClearResponseCache удаляет все записи кэша (и файл, если кэш сохраняется)
+func ClearResponseCache() error {
+	c := respCache
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.clearLocked()
+	if c.path != "" {
+		if err := os.Remove(c.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("failed to remove response cache: %w", err)
+		}
+	}
+	return nil
+}
+
+// FlushResponseCache сразу записывает отложенные изменения кэша на диск (при выходе)
+func FlushResponseCache() error {
+	c := respCache
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.saveTimer == nil {
+		return nil
+	}
+	c.saveTimer.Stop()
+	c.saveTimer = nil
+	return c.saveLocked()
+}
+
+// ResponseCacheLen возвращает число записей кэша
+func ResponseCacheLen() int {
+	c := respCache
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.items)
+}
+
+// responseCacheKey вычисляет адрес содержимого запроса: провайдер, модель,
+// системный промпт (с подставленными переменными) и всё, что отправляется модели
+func responseCacheKey(kind, provider, model, system string, content interface{}) string {
+	identity := struct {
+		Kind         string      `json:"kind"`
+		Provider     string      `json:"provider"`
+		Endpoint     string      `json:"endpoint,omitempty"`
+		Model        string      `json:"model"`
+		SystemPrompt string      `json:"system"`
+		Content      interface{} `json:"content"`
+	}{Kind: kind, Provider: normalizeProviderName(provider), Model: model, SystemPrompt: system, Content: content}
+
+	// Профиль с тем же именем мог поменять endpoint
+	if profile, ok := LookupProviderProfile(provider); ok {
+		identity.Endpoint = profile.Endpoint
+		if identity.Model == "" {
+			identity.Model = profile.Model
+		}
+	}
+
+	data, _ := json.Marshal(identity)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// get возвращает неустаревший ответ по ключу
+func (c *responseCache) get(key string) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.settings.Enabled {
+		return "", false
+	}
+	el, ok := c.items[key]
+	if !ok {
+		return "", false
+	}
+	entry := el.Value.(*cachedResponse)
+	if c.expired(entry) {
+		c.removeLocked(el)
+		return "", false
+	}
+	c.order.MoveToFront(el)
+	return entry.Text, true
+}
+
+// put сохраняет ответ; слишком большие ответы не кэшируются
+func (c *responseCache) put(key, text string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.settings.Enabled || text == "" || len(text) > c.settings.MaxBytes/4 {
+		return
+	}
+	if el, ok := c.items[key]; ok {
+		c.removeLocked(el)
+	}
+	c.items[key] = c.order.PushFront(&cachedResponse{Key: key, Text: text, Created: time.Now()})
+	c.bytes += len(text)
+	c.trimLocked()
+	c.scheduleSaveLocked()
+}
+
+func (c *responseCache) expired(entry *cachedResponse) bool {
+	return c.settings.TTL > 0 && time.Since(entry.Created) > c.settings.TTL
+}
+
+func (c *responseCache) removeLocked(el *list.Element) {
+	entry := c.order.Remove(el).(*cachedResponse)
+	delete(c.items, entry.Key)
+	c.bytes -= len(entry.Text)
+}
+
+func (c *responseCache) clearLocked() {
+	c.order.Init()
+	c.items = map[string]*list.Element{}
+	c.bytes = 0
+}
+
+// trimLocked удаляет устаревшие записи и самые давно использованные сверх лимитов
+func (c *responseCache) trimLocked() {
+	for el := c.order.Back(); el != nil; {
+		prev := el.Prev()
+		over := (c.settings.MaxEntries > 0 && c.order.Len() > c.settings.MaxEntries) ||
+			(c.settings.MaxBytes > 0 && c.bytes > c.settings.MaxBytes)
+		if over || c.expired(el.Value.(*cachedResponse)) {
+			c.removeLocked(el)
+		}
+		el = prev
+	}
+}
+
+// scheduleSaveLocked откладывает запись кэша на диск
+func (c *responseCache) scheduleSaveLocked() {
+	if c.path == "" || c.saveTimer != nil {
+		return
+	}
+	c.saveTimer = time.AfterFunc(responseCacheSaveDelay, func() {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		c.saveTimer = nil
+		if err := c.saveLocked(); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		}
+	})
+}
+
+// saveLocked записывает кэш от старых записей к новым; файл доступен только владельцу
+func (c *responseCache) saveLocked() error {
+	if c.path == "" {
+		return nil
+	}
+	entries := make([]*cachedResponse, 0, c.order.Len())
+	for el := c.order.Back(); el != nil; el = el.Prev() {
+		entries = append(entries, el.Value.(*cachedResponse))
+	}
+	return writeJSONFile(c.path, entries, 0600)
+}
+
+// loadLocked добавляет в кэш записи из файла
+func (c *responseCache) loadLocked() error {
+	data, err := os.ReadFile(c.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read response cache: %w", err)
+	}
+	var entries []*cachedResponse
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return fmt.Errorf("failed to parse %s: %w", c.path, err)
+	}
+	for _, entry := range entries {
+		if _, ok := c.items[entry.Key]; ok || c.expired(entry) {
+			continue
+		}
+		c.items[entry.Key] = c.order.PushFront(entry)
+		c.bytes += len(entry.Text)
+	}
+	return nil
+}
@@ -0,0 +1,139 @@
+package logic
+
+import (
+	"container/list"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestCache(s ResponseCacheSettings) *responseCache {
+	return &responseCache{settings: s, order: list.New(), items: map[string]*list.Element{}}
+}
+
+func TestResponseCacheKeyIdentity(t *testing.T) {
+	isolateKeys(t)
+	msgs := []Message{{Role: "user", Content: "complete this"}}
+	base := responseCacheKey("chat", "openai", "gpt-4o", "Be brief.", msgs)
+
+	if k := responseCacheKey("chat", " OpenAI ", "gpt-4o", "Be brief.", []Message{{Role: "user", Content: "complete this"}}); k != base {
+		t.Error("the same request has a different key")
+	}
+	differs := map[string]string{
+		"kind":     responseCacheKey("fim", "openai", "gpt-4o", "Be brief.", msgs),
+		"provider": responseCacheKey("chat", "openrouter", "gpt-4o", "Be brief.", msgs),
+		"model":    responseCacheKey("chat", "openai", "gpt-4o-mini", "Be brief.", msgs),
+		"system":   responseCacheKey("chat", "openai", "gpt-4o", "Be verbose.", msgs),
+		"messages": responseCacheKey("chat", "openai", "gpt-4o", "Be brief.", []Message{{Role: "user", Content: "complete that"}}),
+		"role":     responseCacheKey("chat", "openai", "gpt-4o", "Be brief.", []Message{{Role: "system", Content: "complete this"}}),
+	}
+	for field, k := range differs {
+		if k == base {
+			t.Errorf("%s is not part of the key", field)
+		}
+	}
+
+	// Профиль с тем же именем, но другим endpoint — другой ключ
+	profileKey := func(endpoint string) string {
+		if err := SetProviderProfiles([]ProviderProfile{{Name: "local", Provider: "ollama", Endpoint: endpoint, Model: "qwen2.5-coder"}}); err != nil {
+			t.Fatal(err)
+		}
+		return responseCacheKey("chat", "local", "", "", msgs)
+	}
+	if profileKey("http://a:11434") == profileKey("http://b:11434") {
+		t.Error("the profile endpoint is not part of the key")
+	}
+}
+
+func TestResponseCacheTTL(t *testing.T) {
+	c := newTestCache(ResponseCacheSettings{Enabled: true, TTL: time.Minute, MaxEntries: 10, MaxBytes: 1000})
+	c.put("k", "answer")
+	if text, ok := c.get("k"); !ok || text != "answer" {
+		t.Fatalf("get = %q, %v", text, ok)
+	}
+	c.items["k"].Value.(*cachedResponse).Created = time.Now().Add(-2 * time.Minute)
+	if _, ok := c.get("k"); ok {
+		t.Fatal("an expired entry was returned")
+	}
+	if len(c.items) != 0 || c.bytes != 0 {
+		t.Fatalf("expired entry kept: %d items, %d bytes", len(c.items), c.bytes)
+	}
+}
+
+func TestResponseCacheEviction(t *testing.T) {
+	c := newTestCache(ResponseCacheSettings{Enabled: true, MaxEntries: 3, MaxBytes: 40})
+	c.put("a", "aaaa")
+	c.put("b", "bbbb")
+	c.put("c", "cccc")
+	c.get("a") // a становится самой недавно использованной
+	c.put("d", "dddd")
+	if _, ok := c.get("b"); ok {
+		t.Error("the least recently used entry was not evicted by the entry limit")
+	}
+	for _, k := range []string{"a", "c", "d"} {
+		if _, ok := c.get(k); !ok {
+			t.Errorf("entry %q was evicted", k)
+		}
+	}
+
+	// Лимит размера: пятая запись по 10 байт вытесняет самую старую
+	c = newTestCache(ResponseCacheSettings{Enabled: true, MaxBytes: 40})
+	for _, k := range []string{"e", "f", "g", "h", "i"} {
+		c.put(k, strings.Repeat(k, 10))
+	}
+	if _, ok := c.get("e"); ok || c.bytes != 40 || len(c.items) != 4 {
+		t.Fatalf("size limit: %d items, %d bytes", len(c.items), c.bytes)
+	}
+	// Ответ больше четверти лимита не кэшируется
+	c.put("big", strings.Repeat("x", 11))
+	if _, ok := c.get("big"); ok {
+		t.Error("an oversized response was cached")
+	}
+}
+
+func TestResponseCachePersistRoundTrip(t *testing.T) {
+	isolateKeys(t)
+	t.Cleanup(func() {
+		SetResponseCache(ResponseCacheSettings{})
+		SetResponseCache(DefaultResponseCacheSettings)
+	})
+	settings := DefaultResponseCacheSettings
+	settings.Persist = true
+	if err := SetResponseCache(settings); err != nil {
+		t.Fatal(err)
+	}
+	respCache.put("k1", "first")
+	respCache.put("k2", "second")
+	if err := FlushResponseCache(); err != nil {
+		t.Fatal(err)
+	}
+
+	dir, _ := ConfigDir()
+	info, err := os.Stat(filepath.Join(dir, responseCacheFileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); perm != 0600 {
+		t.Fatalf("cache file mode %o", perm)
+	}
+
+	// Выключение очищает кэш в памяти, повторное включение читает файл
+	SetResponseCache(ResponseCacheSettings{})
+	if ResponseCacheLen() != 0 {
+		t.Fatal("the cache was not cleared")
+	}
+	if err := SetResponseCache(settings); err != nil {
+		t.Fatal(err)
+	}
+	// Порядок LRU сохраняется: самая новая запись — первая в очереди
+	if front := respCache.order.Front().Value.(*cachedResponse); front.Key != "k2" {
+		t.Errorf("front entry %q", front.Key)
+	}
+	for key, want := range map[string]string{"k1": "first", "k2": "second"} {
+		if text, ok := respCache.get(key); !ok || text != want {
+			t.Errorf("%s = %q, %v", key, text, ok)
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package logic
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// projectPromptFileName — системный промпт проекта в каталоге .golite
+const projectPromptFileName = "prompt.md"
+
+// PromptVars — значения переменных шаблона системного промпта:
+// {{language}}, {{file}}, {{module}}, {{project}}, {{task}}
+type PromptVars struct {
+	Language string // язык текущего файла ("Go", "Python", ...)
+	File     string // путь текущего файла относительно проекта
+	Module   string // путь модуля из go.mod
+	Project  string // имя каталога проекта
+	Task     AITask // вид запроса
+}
+
+// Источники системного промпта, от более приоритетного к менее
+const (
+	PromptSourceProject  = "project"  // <project>/.golite/prompt.md
+	PromptSourceProfile  = "profile"  // system_prompt профиля провайдера
+	PromptSourceSettings = "settings" // system_prompt в config.json
+	PromptSourceDefault  = "default"  // DefaultSystemPrompt
+)
+
+var (
+	systemPromptMu sync.RWMutex
+	settingsPrompt string
+	projectPrompt  string
+)
+
+// SetSystemPrompt задаёт шаблон системного промпта из настроек ("" — встроенный)
+func SetSystemPrompt(tmpl string) {
+	systemPromptMu.Lock()
+	defer systemPromptMu.Unlock()
+	settingsPrompt = strings.TrimSpace(tmpl)
+}
+
+// ProjectPromptPath возвращает путь файла системного промпта проекта
+func ProjectPromptPath(root string) string {
+	return filepath.Join(root, ProjectConfigDir, projectPromptFileName)
+}
+
+// LoadProjectPrompt читает <root>/.golite/prompt.md; если файла нет,
+// промпт проекта сбрасывается. found сообщает, что файл есть и не пуст.
+func LoadProjectPrompt(root string) (found bool, err error) {
+	text := ""
+	if root != "" {
+		data, rerr := os.ReadFile(ProjectPromptPath(root))
+		switch {
+		case rerr == nil:
+			text = strings.TrimSpace(string(data))
+		case !errors.Is(rerr, os.ErrNotExist):
+			err = fmt.Errorf("failed to read project prompt: %w", rerr)
+		}
+	}
+
+	systemPromptMu.Lock()
+	defer systemPromptMu.Unlock()
+	projectPrompt = text
+	return text != "", err
+}
+
+// SystemPromptTemplate возвращает шаблон системного промпта для провайдера
+// (имени, профиля или URL) и его источник
+func SystemPromptTemplate(provider string) (tmpl, source string) {
+	systemPromptMu.RLock()
+	project, settings := projectPrompt, settingsPrompt
+	systemPromptMu.RUnlock()
+
+	if project != "" {
+		return project, PromptSourceProject
+	}
+	if profile, ok := LookupProviderProfile(provider); ok && profile.SystemPrompt != "" {
+		return profile.SystemPrompt, PromptSourceProfile
+	}
+	if settings != "" {
+		return settings, PromptSourceSettings
+	}
+	return DefaultSystemPrompt, PromptSourceDefault
+}
+
+// ResolveSystemPrompt возвращает системный промпт для провайдера с подставленными переменными
+func ResolveSystemPrompt(provider string, vars PromptVars) string {
+	tmpl, _ := SystemPromptTemplate(provider)
+	return RenderSystemPrompt(tmpl, vars)
+}
+
+// RenderSystemPrompt подставляет переменные в шаблон; неизвестные {{...}} остаются как есть
+func RenderSystemPrompt(tmpl string, vars PromptVars) string {
+	language := vars.Language
+	if language == "" {
+		language = "Go"
+	}
+	file := vars.File
+	if file == "" {
+		file = "untitled"
+	}
+	task := string(vars.Task)
+	if task == "" {
+		task = string(TaskChat)
+	}
+	return strings.NewReplacer(
+		"{{language}}", language,
+		"{{file}}", file,
+		"{{module}}", vars.Module,
+		"{{project}}", vars.Project,
+		"{{task}}", task,
+	).Replace(tmpl)
+}
+
+// ModulePath возвращает путь модуля из <root>/go.mod ("" — файла нет)
+func ModulePath(root string) string {
+	if root == "" {
+		return ""
+	}
+	f, err := os.Open(filepath.Join(root, "go.mod"))
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if rest, ok := strings.CutPrefix(line, "module"); ok && (rest == "" || rest[0] == ' ' || rest[0] == '\t') {
+			return strings.Trim(strings.TrimSpace(rest), `"`)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,219 @@
+package logic
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+)
+
+// defaultContextLimit — окно контекста для неизвестных моделей (в токенах)
+const defaultContextLimit = 8192
+
+// modelContextLimits — размеры окна контекста известных семейств моделей.
+// Ключ сравнивается с началом имени модели (без префикса "vendor/" и в нижнем регистре);
+// побеждает самый длинный совпавший ключ.
+var modelContextLimits = map[string]int{
+	"gpt-3.5":     16385,
+	"gpt-4":       8192,
+	"gpt-4-turbo": 128000,
+	"gpt-4o":      128000,
+	"gpt-4.1":     1047576,
+	"gpt-5":       400000,
+	"o1":          200000,
+	"o3":          200000,
+	"o4":          200000,
+
+	"claude": 200000,
+
+	"gemini":     32768,
+	"gemini-1.5": 1048576,
+	"gemini-2":   1048576,
+
+	"gemma":  8192,
+	"gemma2": 8192,
+	"gemma3": 131072,
+
+	"llama2":   4096,
+	"llama3":   8192,
+	"llama3.1": 131072,
+	"llama3.2": 131072,
+	"llama3.3": 131072,
+
+	"codellama":  16384,
+	"deepseek":   65536,
+	"mistral":    32768,
+	"mixtral":    32768,
+	"phi3":       4096,
+	"qwen2.5":    32768,
+	"qwen3":      40960,
+	"starcoder2": 16384,
+}
+
+var (
+	limitsMu          sync.RWMutex
+	userContextLimits = map[string]int{}
+)
+
+// SetModelContextLimits задаёт пользовательские размеры окна контекста
+// (ключи — имена моделей или их префиксы, как в modelContextLimits)
+func SetModelContextLimits(limits map[string]int) {
+	limitsMu.Lock()
+	defer limitsMu.Unlock()
+	userContextLimits = make(map[string]int, len(limits))
+	for k, v := range limits {
+		if v > 0 {
+			userContextLimits[strings.ToLower(k)] = v
+		}
+	}
+}
+
+// ContextLimit возвращает размер окна контекста модели в токенах
+func ContextLimit(model string) int {
+	name := strings.ToLower(strings.TrimSpace(model))
+
+	limitsMu.RLock()
+	defer limitsMu.RUnlock()
+
+	// Пользовательские значения сравниваются и с полным именем (например, "openai/gpt-4o")
+	if limit, ok := lookupLimit(userContextLimits, name); ok {
+		return limit
+	}
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		name = name[i+1:]
+	}
+	if limit, ok := lookupLimit(userContextLimits, name); ok {
+		return limit
+	}
+	if limit, ok := lookupLimit(modelContextLimits, name); ok {
+		return limit
+	}
+	return defaultContextLimit
+}
+
+func lookupLimit(table map[string]int, name string) (int, bool) {
+	best := ""
+	for prefix := range table {
+		if strings.HasPrefix(name, prefix) && len(prefix) > len(best) {
+			best = prefix
+		}
+	}
+	if best == "" {
+		return 0, false
+	}
+	return table[best], true
+}
+
+// ResponseReserve — часть окна, оставляемая под ответ модели
+func ResponseReserve(limit int) int {
+	reserve := limit / 4
+	if reserve > 4096 {
+		reserve = 4096
+	}
+	return reserve
+}
+
+// ImageTokens — оценка размера одной приложенной картинки: провайдеры считают
+// картинку по её разрешению, типичный снимок экрана занимает около тысячи токенов
+const ImageTokens = 1000
+
+// EstimateTokens приблизительно оценивает число токенов BPE-токенизатора:
+// слово латиницей/цифрами — около 4 символов на токен, прочие буквы (кириллица и т.п.) —
+// около 2, каждый знак препинания — отдельный токен, переводы строк учитываются, пробелы — нет.
+func EstimateTokens(s string) int {
+	tokens := 0
+	asciiRun, otherRun := 0, 0
+
+	flush := func() {
+		tokens += (asciiRun + 3) / 4
+		tokens += (otherRun + 1) / 2
+		asciiRun, otherRun = 0, 0
+	}
+
+	for i := 0; i < len(s); {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		i += size
+
+		switch {
+		case r < utf8.RuneSelf && (unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_'):
+			asciiRun++
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			otherRun++
+		case r == '\n':
+			flush()
+			tokens++
+		case unicode.IsSpace(r):
+			flush()
+		default:
+			flush()
+			tokens++
+		}
+	}
+	flush()
+	return tokens
+}
+
+// ContextPart — фрагмент контекста запроса
+type ContextPart struct {
+	Kind     string // вид фрагмента ("history", "tab", "file", ...), используется при сборке промпта
+	Label    string // имя для отображения пользователю
+	Text     string
+	Priority int  // чем меньше, тем раньше фрагмент отбрасывается при нехватке места
+	Required bool // обязательный фрагмент не отбрасывается
+}
+
+// ContextFit — результат подгонки контекста под окно модели
+type ContextFit struct {
+	Parts   []ContextPart // оставшиеся фрагменты в исходном порядке
+	Dropped []ContextPart // отброшенные фрагменты
+	Tokens  int           // оценка итогового размера (фрагменты + fixedTokens)
+	Budget  int           // доступно под запрос (окно минус резерв под ответ)
+}
+
+// Over сообщает, что даже обязательные фрагменты не помещаются в окно
+func (f ContextFit) Over() bool {
+	return f.Tokens > f.Budget
+}
+
+// FitContext отбрасывает необязательные фрагменты с наименьшим приоритетом
+// (при равном приоритете — более ранние), пока запрос не поместится в budget.
+// fixedTokens — размер частей запроса, которые не входят в parts (сам вопрос, обрамление).
+func FitContext(parts []ContextPart, fixedTokens, budget int) ContextFit {
+	sizes := make([]int, len(parts))
+	total := fixedTokens
+	for i, p := range parts {
+		sizes[i] = EstimateTokens(p.Text)
+		total += sizes[i]
+	}
+
+	fit := ContextFit{Tokens: total, Budget: budget}
+
+	order := make([]int, 0, len(parts))
+	for i, p := range parts {
+		if !p.Required {
+			order = append(order, i)
+		}
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return parts[order[a]].Priority < parts[order[b]].Priority
+	})
+
+	dropped := make(map[int]bool)
+	for _, i := range order {
+		if fit.Tokens <= budget {
+			break
+		}
+		dropped[i] = true
+		fit.Tokens -= sizes[i]
+	}
+
+	for i, p := range parts {
+		if dropped[i] {
+			fit.Dropped = append(fit.Dropped, p)
+		} else {
+			fit.Parts = append(fit.Parts, p)
+		}
+	}
+	return fit
+}
@@ -1,6 +1,7 @@
 package ui
 
 import (
+	"context"
 	"fmt"
 	"path/filepath"
 	"strings"
@@ -151,11 +152,14 @@ func (e *EditorWindow) createMenus() {
     eMenu.AddSeparator()
 
     actLineComplete := eMenu.AddAction("AI &Line Completion (Tab)")
+    e.settingsActions.lineComplete = actLineComplete
     actLineComplete.SetCheckable(true)
     actLineComplete.SetChecked(e.TabManager.IsLineCompleteEnabled())
     actLineComplete.SetShortcut(gui.NewQKeySequence2("Ctrl+Shift+Space", gui.QKeySequence__NativeText))
     actLineComplete.ConnectTriggered(func(checked bool) {
         e.TabManager.SetLineCompleteEnabled(checked)
+        e.Config.AILineCompleteEnabled = checked
+        e.saveConfig()
         if checked {
             e.Window.StatusBar().ShowMessage("AI Line Completion enabled (press Tab after code to trigger)", 3000)
         } else {
@@ -164,16 +168,52 @@ func (e *EditorWindow) createMenus() {
     })
     
     actAutoComplete := eMenu.AddAction("AI &Code Completion (Ctrl+L)")
+    e.settingsActions.autoComplete = actAutoComplete
     actAutoComplete.SetCheckable(true)
     actAutoComplete.SetChecked(e.TabManager.IsAutoCompleteEnabled())
     actAutoComplete.ConnectTriggered(func(checked bool) {
         e.TabManager.SetAutoCompleteEnabled(checked)
+        e.Config.AIAutoCompleteEnabled = checked
+        e.saveConfig()
         if checked {
             e.Window.StatusBar().ShowMessage("AI Code Completion enabled (press Ctrl+L for multi-line completion)", 3000)
         } else {
             e.Window.StatusBar().ShowMessage("AI Code Completion disabled", 2000)
         }
     })
+
+    actFIM := eMenu.AddAction("Use &Fill-in-the-Middle (code models)")
+    e.settingsActions.fim = actFIM
+    actFIM.SetCheckable(true)
+    actFIM.SetChecked(e.TabManager.FIMEnabled)
+    actFIM.SetToolTip("Send code before and after the cursor to models that support FIM; others use chat completion")
+    actFIM.ConnectTriggered(func(checked bool) {
+        e.TabManager.FIMEnabled = checked
+        e.Config.AIFIMEnabled = checked
+        e.saveConfig()
+        if checked {
+            e.Window.StatusBar().ShowMessage("Fill-in-the-Middle enabled for supported code models", 3000)
+        } else {
+            e.Window.StatusBar().ShowMessage("Fill-in-the-Middle disabled", 2000)
+        }
+    })
+
+    actDecls := eMenu.AddAction("Include Referenced Go &Declarations")
+    e.settingsActions.declContext = actDecls
+    actDecls.SetCheckable(true)
+    actDecls.SetChecked(e.TabManager.DeclContextEnabled)
+    actDecls.SetToolTip("Add the declarations of types, functions and constants used near the cursor (resolved across the module) to AI requests")
+    actDecls.ConnectTriggered(func(checked bool) {
+        e.TabManager.DeclContextEnabled = checked
+        e.Config.AIDeclContext = checked
+        e.saveConfig()
+        e.UpdateAIContextDisplay()
+        if checked {
+            e.Window.StatusBar().ShowMessage("Referenced Go declarations are added to AI requests", 3000)
+        } else {
+            e.Window.StatusBar().ShowMessage("Referenced Go declarations disabled", 2000)
+        }
+    })
     
 
 	// View
@@ -203,7 +243,8 @@ func (e *EditorWindow) createMenus() {
 	// Пункт очистки истории
 	actClearHistory := aiHistoryMenu.AddAction("Clear Conversation History")
 	actClearHistory.ConnectTriggered(func(bool) {
-		if len(e.AIResponseHistory) == 0 {
+		history := e.aiHistory()
+		if len(history) == 0 {
 			e.Window.StatusBar().ShowMessage("History is already empty", 2000)
 			return
 		}
@@ -211,7 +252,7 @@ func (e *EditorWindow) createMenus() {
 		btn := widgets.QMessageBox_Question(
 			e.Window,
 			"Clear History",
-			fmt.Sprintf("Clear %d conversation entries from AI history?", len(e.AIResponseHistory)),
+			fmt.Sprintf("Clear %d conversation entries from AI history?\nThe conversation stays in Chat Sessions and can be restored with Undo Clear History.", len(history)),
 			widgets.QMessageBox__Yes|widgets.QMessageBox__No,
 			widgets.QMessageBox__No,
 		)
@@ -222,6 +263,8 @@ func (e *EditorWindow) createMenus() {
 		}
 	})
 
+	e.fillAIChatMenu(aiHistoryMenu)
+
 	// Опция для включения контекста из всех открытых вкладок
 	e.actUseTabsContext = aiHistoryMenu.AddAction("Use All Open Tabs as Context")
 	e.actUseTabsContext.SetCheckable(true)
@@ -230,6 +273,8 @@ func (e *EditorWindow) createMenus() {
 	e.actUseTabsContext.ConnectTriggered(func(checked bool) {
 
 		e.AIUseOpenTabsAsContext = checked
+		e.Config.AIUseOpenTabsAsContext = checked
+		e.saveConfig()
 		e.UpdateAIContextDisplay() // Обновляем UI, чтобы показать изменение
 		statusMsg := "Context from open tabs disabled"
 		if checked {
@@ -247,9 +292,9 @@ func (e *EditorWindow) createMenus() {
 			"• Total entries: %d\n"+
 			"• Context size: %d\n"+
 			"• Entries used for context: %d",
-			len(e.AIResponseHistory),
+			len(e.aiHistory()),
 			e.AIHistoryContextSize,
-			min(e.AIHistoryContextSize, len(e.AIResponseHistory)))
+			min(e.AIHistoryContextSize, len(e.aiHistory())))
 		
 		widgets.QMessageBox_Information(
 			e.Window,
@@ -266,6 +311,7 @@ func (e *EditorWindow) createMenus() {
 	cursorMenu := vMenu.AddMenu2("&Cursor Style")
 	cursorGroup := widgets.NewQActionGroup(e.Window)
 	cursorGroup.SetExclusive(true)
+	e.settingsActions.cursors = make(map[string]*widgets.QAction)
 
 	for _, styleName := range CursorStyleOrder {
 		style := CursorStyles[styleName]
@@ -279,10 +325,13 @@ func (e *EditorWindow) createMenus() {
 		action.SetChecked(currentStyleName == e.TabManager.GetCurrentCursorStyleName())
 		action.SetToolTip(style.Description)
 		cursorGroup.AddAction(action)
+		e.settingsActions.cursors[currentStyleName] = action
 		
 		action.ConnectTriggered(func(checked bool) {
 			if checked {
 				e.TabManager.SetCursorStyle(currentStyleName)
+				e.Config.CursorStyle = currentStyleName
+				e.saveConfig()
 			}
 		})
 	}
@@ -293,6 +342,7 @@ func (e *EditorWindow) createMenus() {
 	schemeMenu := vMenu.AddMenu2("Color &Scheme")
 	schemeGroup := widgets.NewQActionGroup(e.Window)
 	schemeGroup.SetExclusive(true)
+	e.settingsActions.schemes = make(map[string]*widgets.QAction)
 
 	// Сортируем схемы для стабильного порядка
 	schemeNames := []string{"Monokai", "Dracula", "One Dark", "Solarized Dark", "GitHub Dark"}
@@ -302,9 +352,12 @@ func (e *EditorWindow) createMenus() {
 		action.SetCheckable(true)
 		action.SetChecked(schemeName == e.TabManager.GetCurrentSchemeName())
 		schemeGroup.AddAction(action)
+		e.settingsActions.schemes[schemeName] = action
 		action.ConnectTriggered(func(checked bool) {
 			if checked {
 				e.TabManager.SetColorScheme(schemeName)
+				e.Config.ColorScheme = schemeName
+				e.saveConfig()
 			}
 		})
 	}
@@ -313,12 +366,18 @@ func (e *EditorWindow) createMenus() {
 
 	actLineNumbers := vMenu.AddAction("Show Line Numbers")
 	actLineNumbers.SetCheckable(true)
+	e.settingsActions.lineNumbers = actLineNumbers
 	actLineNumbers.SetChecked(e.TabManager.IsLineNumbersVisible())
 	actLineNumbers.ConnectTriggered(func(checked bool) {
 		e.TabManager.ToggleLineNumbers()
 		actLineNumbers.SetChecked(e.TabManager.IsLineNumbersVisible())
+		e.Config.ShowLineNumbers = e.TabManager.IsLineNumbersVisible()
+		e.saveConfig()
 	})
 
+	// AI
+	e.createAIMenu(mb)
+
 	// Run
 	rMenu := mb.AddMenu2("&Run")
 
@@ -336,6 +395,7 @@ func (e *EditorWindow) createMenus() {
 		// Exec блокирует поток до закрытия окна. Возвращает 1 (Accepted), если нажали OK.
 		if dlg.Exec() == int(widgets.QDialog__Accepted) {
 			e.RunArgs = dlg.TextValue()
+			e.saveRunArgs()
 			e.Window.StatusBar().ShowMessage(fmt.Sprintf("Args set: %s", e.RunArgs), 3000)
 		}
 	})
@@ -387,6 +447,7 @@ func (e *EditorWindow) runGoCode() {
 		targetArgs = append(targetArgs, userArgs...)
 	}
 
+	e.lastRunDir = targetDir
 	e.OutputDock.Show()
 	e.OutputText.AppendPlainText(fmt.Sprintf("\n--- Starting: go %v ---\n", targetArgs[1:]))
 
@@ -432,183 +493,248 @@ func (e *EditorWindow) runGoCode() {
 }
 
 func (e *EditorWindow) HandleAskLLM(prompt string) {
+	e.askLLM(prompt, prompt)
+}
+
+// askLLM отправляет prompt в чат; display — то, что показывается в строке "You:"
+// (например, слэш-команда, раскрытая в prompt)
+func (e *EditorWindow) askLLM(display, prompt string) {
 	e.AIDock.Show()
+	// Ответ попадает во вкладку и диалог, активные в момент отправки
+	chat := e.currentAIChat()
+	if chat.busy() {
+		e.Window.StatusBar().ShowMessage("Wait for the running AI request in this chat to finish or stop it", 3000)
+		return
+	}
 	e.UpdateAIContextDisplay()
+	view := chat.view
+
+	images := e.takeAIImages()
+	if len(images) > 0 && strings.TrimSpace(prompt) == "" {
+		prompt, display = "Describe this image.", "Describe this image."
+	}
+	view.Append(fmt.Sprintf("<b>You:</b> %s%s", html.EscapeString(display), imagesNotice(len(images))))
+	if display != prompt {
+		view.Append(fmt.Sprintf("<span style='color:#888; font-size:10px;'>Expanded to: %s</span>",
+			html.EscapeString(firstLine(prompt))))
+	}
+
+	// Объявления Go снимаются с редактора сейчас, а ищутся в фоне вместе с фрагментами индекса
+	declReq := e.TabManager.goDeclRequest(e.TabManager.CurrentEditor(), true, 0)
+
+	// Фрагменты индекса кода ищутся по вопросу до сборки контекста;
+	// пока идёт поиск, второй вопрос в эту вкладку не отправляется
+	chat.pending = true
+	e.updateAIButtons()
+	e.searchCodeIndex(chat, prompt, func(hits []logic.CodeHit) {
+		e.findGoDecls(declReq, func(decls goDecls) {
+			// Контекст: история, текущий файл, объявления, индекс, вкладки, файлы проекта, буфер обмена.
+			// Не помещающиеся в окно модели фрагменты отбрасываются (см. buildAIPrompt).
+			built := e.buildAIPrompt(prompt, len(images), decls, hits)
+
+			// Показываем контекст в чате ===
+			if len(built.Info) > 0 {
+				view.Append(fmt.Sprintf("<span style='color:#888; font-size:10px;'>Context: %s · ~%d / %d tokens</span>", 
+					strings.Join(built.Info, ", "), built.Tokens, built.Limit))
+			}
 	
-	// Формируем информацию о контексте для отображения пользователю
-	var contextInfo []string
+			fullPrompt := built.Text
 
-    // Защита от случайного редактирования
-    e.AIChat.SetReadOnly(true)
+			if e.aiAgentCheckbox != nil && e.aiAgentCheckbox.IsChecked() {
+				e.runAgent(chat, display, prompt, fullPrompt, images)
+				return
+			}
 
-	e.AIChat.Append(fmt.Sprintf("<b>You:</b> %s", prompt))
+			e.sendAIRequest(chat, display, prompt, fullPrompt, images, nil)
+		})
+	})
+}
 
-	contextStr := ""
+// sendAIRequest отправляет запрос чата от имени вкладки chat и выводит ответ в неё;
+// onAnswer, если задан, вызывается в UI потоке после успешного ответа
+func (e *EditorWindow) sendAIRequest(chat *aiChatTab, display, prompt, fullPrompt string, images []string, onAnswer func(resp string)) {
+	session := chat.session
+	view := chat.view
+	live := func() bool { return !chat.closed && chat.session == session }
 
-	// 0. История предыдущих диалогов с AI
-	historyContext := e.GetAIHistoryContext()
-	if historyContext != "" {
-		contextStr += historyContext
-		usedCount := e.AIHistoryContextSize
-		if usedCount > len(e.AIResponseHistory) {
-			usedCount = len(e.AIResponseHistory)
-		}
-		contextInfo = append(contextInfo, fmt.Sprintf("[%d prev. responses]", usedCount))
+	if chat.cancel != nil {
+		view.Append("<span style='color:red'>Error: another AI request is still running in this chat</span>")
+		return
 	}
 
-	// 1. Текущий файл
-	ed := e.TabManager.CurrentEditor()
-	if ed != nil {
-		fileName := "Untitled"
-		if ed.FilePath != "" {
-			fileName = filepath.Base(ed.FilePath)
-		}
-		contextStr += fmt.Sprintf("\nUser is editing file: %s\nContent:\n%s\n", fileName, ed.TextEdit.ToPlainText())
-		contextInfo = append(contextInfo, fileName)
+	// Ответ выводится по мере генерации: сначала как простой текст,
+	// после завершения потока он заменяется отформатированным HTML.
+	stream := beginAIStream(view)
+
+	target := e.llmTarget(logic.TaskChat)
+	if len(images) > 0 && !logic.ModelSupportsVision(target.Model) {
+		// Список моделей неполный — отправляем всё равно, но предупреждаем
+		view.Append(fmt.Sprintf("<span style='color:#db4; font-size:10px;'>⚠ %s is not known to accept images; add it to vision_models if it does</span>",
+			html.EscapeString(target.Model)))
 	}
+	ctx, cancel := context.WithTimeout(context.Background(), target.Timeout)
+	e.setAIRequest(chat, cancel)
 
-	// 1.5 Контекст из других открытых вкладок (если опция включена)
-	if e.AIUseOpenTabsAsContext {
-		tabsContext, tabNames := e.TabManager.GetAllOpenTabsContext(ed)
-		if tabsContext != "" {
-			contextStr += tabsContext
-			// Добавляем информацию в UI
-			if len(tabNames) <= 3 {
-				contextInfo = append(contextInfo, fmt.Sprintf("[other tabs: %s]", strings.Join(tabNames, ", ")))
+	go func() {
+		res, err := logic.Chat(ctx, logic.ChatRequest{
+			Provider: target.Provider,
+			Model:    target.Model,
+			APIKey:   target.APIKey,
+			History:  []logic.Message{{Role: "user", Content: fullPrompt}},
+			Images:   images,
+			Task:     target.Task,
+			Vars:     target.Vars,
+			OnChunk: func(chunk string) {
+				e.RunOnUIThread(func() {
+					if live() {
+						appendAIStreamChunk(stream, chunk)
+					}
+				})
+			},
+		})
+		resp := res.Text
+		cancel()
+		e.RunOnUIThread(func() {
+			e.setAIRequest(chat, nil)
+			// Вкладка закрыта или диалог очищен — выводить некуда
+			if !live() {
+				return
+			}
+
+			endAIStream(stream)
+			if logic.IsCanceled(err) {
+				// Показываем то, что успело прийти до остановки, но не сохраняем в историю
+				if strings.TrimSpace(resp) != "" {
+					view.Append(fmt.Sprintf("<b>AI:</b><br>%s", e.renderAIResponse(chat, resp)))
+				}
+				view.Append("<span style='color:#888'><i>[Stopped by user]</i></span>")
+			} else if err != nil {
+				view.Append(fmt.Sprintf("<span style='color:red'>Error: %v</span>", err))
+				appendRedactionSummary(view, res)
 			} else {
-				contextInfo = append(contextInfo, fmt.Sprintf("[other tabs: %s, ... +%d]", strings.Join(tabNames[:3], ", "), len(tabNames)-3))
+				view.Append(fmt.Sprintf("<b>AI:</b><br>%s", e.renderAIResponse(chat, resp)))
+				appendRedactionSummary(view, res)
+				e.reportChatResult(res)
+
+				// Сохраняем в диалог успешный ответ (оригинальный, не HTML)
+				e.addAITurn(chat, session, chatTurnFromResult(display, prompt, res))
+				// Обновляем отображение контекста
+				e.UpdateAIContextDisplay()
+				if onAnswer != nil {
+					onAnswer(resp)
+				}
 			}
+			sb := view.VerticalScrollBar()
+			sb.SetValue(sb.Maximum())
+		})
+	}()
+}
 
-			// contextInfo = append(contextInfo, fmt.Sprintf("[%d other tabs]", len(tabNames)))
-		}
+// reportChatResult показывает в статусной строке, какой провайдер ответил,
+// и почему пропущены предыдущие в цепочке
+func (e *EditorWindow) reportChatResult(res *logic.ChatResult) {
+	msg := fmt.Sprintf("AI answered by %s", res.Provider)
+	if res.Model != "" {
+		msg += fmt.Sprintf(" (%s)", res.Model)
 	}
+	if n := res.Redactions.Total(); n > 0 {
+		msg += fmt.Sprintf(" · %d secret(s) redacted", n)
+	}
+	if res.UsedFallback() {
+		msg += fmt.Sprintf(" — fallback after: %v", res.Failures[len(res.Failures)-1])
+		e.Window.StatusBar().ShowMessage(msg, 8000)
+		return
+	}
+	e.Window.StatusBar().ShowMessage(msg, 3000)
+}
 
-
-	// 2. Файлы проекта
-	projectFiles := e.ProjectManager.GetContextFiles()
-	if len(projectFiles) > 0 {
-		ctx, _ := e.FileManager.CollectSpecificFilesContext(projectFiles)
-		contextStr += ctx
-		for _, f := range projectFiles {
-			contextInfo = append(contextInfo, filepath.Base(f))
-		}
+// appendRedactionSummary выводит в чат, какие секреты были замаскированы перед отправкой
+func appendRedactionSummary(view *widgets.QTextBrowser, res *logic.ChatResult) {
+	if res == nil || len(res.Redactions) == 0 {
+		return
 	}
+	view.Append(fmt.Sprintf("<span style='color:#db4; font-size:10px;'>🔒 Redacted before sending to %s: %s</span>",
+		html.EscapeString(res.Provider), html.EscapeString(res.Redactions.String())))
+}
 
-	//   3. Буфер обмена (если включён) ===
-    if e.AIClipboardCheckbox != nil && e.AIClipboardCheckbox.IsChecked() {
-		clipboard := gui.QGuiApplication_Clipboard()
-		clipText := clipboard.Text(gui.QClipboard__Clipboard)
+// aiStream описывает временную область чата, куда дописывается потоковый ответ
+type aiStream struct {
+	view     *widgets.QTextBrowser
+	startPos int  // позиция документа перед началом области
+	received bool // пришёл ли хотя бы один фрагмент
+}
 
-		if clipText != "" {
-			// Ограничиваем размер буфера обмена (например, 10000 символов)
-			if len(clipText) > 10000 {
-				clipText = clipText[:10000] + "\n... [truncated]"
-			}
-			contextStr += fmt.Sprintf("\n--- Clipboard Content ---\n%s\n--- End Clipboard ---\n", clipText)
-			contextInfo = append(contextInfo, "[clipboard]")
-		}
-	}
+// beginAIStream добавляет в чат заголовок ответа с индикатором ожидания
+func beginAIStream(view *widgets.QTextBrowser) *aiStream {
+	cursor := gui.NewQTextCursor2(view.Document())
+	cursor.MovePosition(gui.QTextCursor__End, gui.QTextCursor__MoveAnchor, 1)
+	stream := &aiStream{view: view, startPos: cursor.Position()}
 
-	// Показываем контекст в чате ===
-	if len(contextInfo) > 0 {
-		e.AIChat.Append(fmt.Sprintf("<span style='color:#888; font-size:10px;'>Context: %s</span>", 
-			strings.Join(contextInfo, ", ")))
-	}
-	
-	e.AIChat.Append("<i>Thinking...</i>")
+	view.Append("<b>AI:</b> <i>Thinking...</i>")
+	return stream
+}
 
-	fullPrompt := contextStr + "\nUser Request: " + prompt
+// appendAIStreamChunk дописывает очередной фрагмент ответа в конец чата
+func appendAIStreamChunk(stream *aiStream, chunk string) {
+	cursor := gui.NewQTextCursor2(stream.view.Document())
+	cursor.MovePosition(gui.QTextCursor__End, gui.QTextCursor__MoveAnchor, 1)
 
-	go func() {
+	if !stream.received {
+		// Первый фрагмент: убираем "Thinking..." и начинаем текст с новой строки
+		stream.received = true
+		cursor.MovePosition(gui.QTextCursor__StartOfBlock, gui.QTextCursor__KeepAnchor, 1)
+		cursor.RemoveSelectedText()
+		cursor.InsertHtml("<b>AI:</b>")
+		cursor.InsertBlock()
+		cursor.SetCharFormat(gui.NewQTextCharFormat())
+	}
+	cursor.InsertText(chunk)
 
-		resp, err := logic.SendMessageToLLM(fullPrompt, e.LLMProvider, e.LLMModel, e.LLMKey)
-		e.RunOnUIThread(func() {
-			if err != nil {
-				e.AIChat.Append(fmt.Sprintf("<span style='color:red'>Error: %v</span>", err))
-			} else {
-				// NEW: Очищаем предыдущие кодовые блоки
-				e.CurrentCodeBlocks = make([]CodeBlockData, 0)
-				
-				// Интеллектуальная обработка ответа для сохранения отступов в коде
-				var finalHtml strings.Builder
-				parts := strings.Split(resp, "```") // Разделяем ответ на текст и код
-
-				codeBlockIndex := 0 // NEW: Счётчик блоков кода
-
-				for i, part := range parts {
-					// Пропускаем пустые части
-					if strings.TrimSpace(part) == "" {
-						continue
-					}
-					
-					if i%2 == 0 {
-						// Обычный текст
-						escapedText := html.EscapeString(part)
-						finalHtml.WriteString(fmt.Sprintf(
-							`<div style="white-space: pre-wrap; word-wrap: break-word;">%s</div>`,
-							escapedText,
-						))
-					} else {
-						// Блок кода
-						codeContent := part
-						language := ""
-						
-						// Извлекаем язык программирования
-						if nlIndex := strings.Index(part, "\n"); nlIndex != -1 {
-							langHint := strings.TrimSpace(part[:nlIndex])
-							if len(langHint) < 10 && !strings.Contains(langHint, " ") {
-								language = langHint
-								codeContent = part[nlIndex+1:]
-							}
-						}
-						
-						cleanCode := strings.TrimSpace(codeContent)
-						escapedCode := html.EscapeString(cleanCode)
-
-						// NEW: Сохраняем блок кода для последующего использования
-						e.CurrentCodeBlocks = append(e.CurrentCodeBlocks, CodeBlockData{
-							Code:     cleanCode,
-							Language: language,
-							Index:    codeBlockIndex,
-						})
-
-						// NEW: Добавляем кнопку над кодом
-						langLabel := language
-						if langLabel == "" {
-							langLabel = "code"
-						}
-						
-                        finalHtml.WriteString(fmt.Sprintf(
-                            `<div style="margin: 5px 0;">
-                                <a href="copycode:%d" style="background-color: #4A90E2; color: white; padding: 5px 12px; text-decoration: none; border-radius: 4px; font-size: 11px; display: inline-block; margin-bottom: 5px;">
-                                    📋 Copy %s to Editor
-                                </a>
-                            </div>`,
-                            codeBlockIndex,
-                            langLabel,
-                        ))
-                                               
-						// Оборачиваем код в <pre><code>
-						finalHtml.WriteString(fmt.Sprintf(
-							`<pre style="background-color: #2E2E2E; color: #DCDCDC; padding: 10px; border-radius: 5px; white-space: pre-wrap; word-wrap: break-word; margin-top: 0;"><code>%s</code></pre>`,
-							escapedCode,
-						))
-						// Увеличиваем счётчик
-						codeBlockIndex++
-					}
-				}
+	sb := stream.view.VerticalScrollBar()
+	sb.SetValue(sb.Maximum())
+}
 
-				e.AIChat.Append(fmt.Sprintf("<b>AI:</b><br>%s", finalHtml.String()))
+// endAIStream удаляет временную область потокового ответа
+func endAIStream(stream *aiStream) {
+	cursor := gui.NewQTextCursor2(stream.view.Document())
+	cursor.SetPosition(stream.startPos, gui.QTextCursor__MoveAnchor)
+	cursor.MovePosition(gui.QTextCursor__End, gui.QTextCursor__KeepAnchor, 1)
+	cursor.RemoveSelectedText()
+}
 
-				// Сохраняем в историю успешный ответ (оригинальный, не HTML)
-				e.AddToAIHistory(prompt, resp)
-				// Обновляем отображение контекста
-				e.UpdateAIContextDisplay()
-			}
-			sb := e.AIChat.VerticalScrollBar()
-			sb.SetValue(sb.Maximum())
+// renderAIResponse превращает ответ LLM (Markdown) в HTML для чата и добавляет его блоки кода
+// к блокам вкладки: номера в ссылках copycode:N сквозные, старые ответы остаются рабочими.
+// Код подсвечивается chroma в цветах текущей схемы редактора.
+func (e *EditorWindow) renderAIResponse(chat *aiChatTab, resp string) string {
+	style := chromaStyle(e.TabManager.GetCurrentSchemeName())
+
+	return renderMarkdown(resp, func(language, code string) string {
+		codeBlockIndex := len(chat.codeBlocks)
+		// Сохраняем блок кода для кнопки "Copy to Editor"
+		chat.codeBlocks = append(chat.codeBlocks, CodeBlockData{
+			Code:     code,
+			Language: language,
+			Index:    codeBlockIndex,
 		})
-	}()
+
+		langLabel := language
+		if langLabel == "" {
+			langLabel = "code"
+		}
+		return fmt.Sprintf(
+			`<div style="margin: 5px 0;">
+                    <a href="copycode:%d" style="background-color: #4A90E2; color: white; padding: 5px 12px; text-decoration: none; border-radius: 4px; font-size: 11px; display: inline-block; margin-bottom: 5px;">
+                        📋 Copy %s to Editor
+                    </a>
+                    &nbsp;<a href="%s%d" style="background-color: #5C9E5C; color: white; padding: 5px 12px; text-decoration: none; border-radius: 4px; font-size: 11px; display: inline-block; margin-bottom: 5px;">
+                        ± Apply as Diff
+                    </a>
+                </div>`,
+			codeBlockIndex,
+			html.EscapeString(langLabel),
+			applyDiffLink, codeBlockIndex,
+		) + highlightCode(language, code, style)
+	})
 }
 
 func (e *EditorWindow) showGoToLineDialog() {
@@ -653,6 +779,8 @@ func (e *EditorWindow) showHistoryContextSizeDialog() {
 	if dlg.Exec() == int(widgets.QDialog__Accepted) {
 		newSize := dlg.IntValue()
 		e.AIHistoryContextSize = newSize
+		e.Config.AIHistoryContextSize = newSize
+		e.saveConfig()
 		
 		if newSize == 0 {
 			e.Window.StatusBar().ShowMessage("AI history context disabled", 2000)
@@ -675,7 +803,7 @@ func min(a, b int) int {
 }
 
 // handleCodeBlockClick обрабатывает клик по кнопке копирования кода
-func (e *EditorWindow) handleCodeBlockClick(url string) {
+func (e *EditorWindow) handleCodeBlockClick(chat *aiChatTab, url string) {
     // Парсим URL вида "copycode:0", "copycode://0", "copycode:1" и т.д.
     if !strings.HasPrefix(url, "copycode:") {
     	return
@@ -691,12 +819,12 @@ func (e *EditorWindow) handleCodeBlockClick(url string) {
 	}
 	
 	// Проверяем валидность индекса
-	if blockIndex < 0 || blockIndex >= len(e.CurrentCodeBlocks) {
+	if blockIndex < 0 || blockIndex >= len(chat.codeBlocks) {
 		e.Window.StatusBar().ShowMessage("Code block not found", 2000)
 		return
 	}
 	
-	codeBlock := e.CurrentCodeBlocks[blockIndex]
+	codeBlock := chat.codeBlocks[blockIndex]
 	
 	// Получаем текущий редактор
 	ed := e.TabManager.CurrentEditor()
@@ -711,7 +839,7 @@ func (e *EditorWindow) handleCodeBlockClick(url string) {
 	}
 	
 	// ИСПРАВЛЕНИЕ: Сохраняем текущий фокус AI чата, чтобы не потерять содержимое
-	aiChatHadFocus := e.AIChat.HasFocus()
+	aiChatHadFocus := chat.view.HasFocus()
 	
 	// Переключаем фокус на редактор ПЕРЕД манипуляциями с курсором
 	ed.TextEdit.SetFocus2()
@@ -734,7 +862,7 @@ func (e *EditorWindow) handleCodeBlockClick(url string) {
 	
 	// ИСПРАВЛЕНИЕ: Возвращаем фокус AI чату, если он был активен
 	if aiChatHadFocus {
-		e.AIChat.SetFocus2()
+		chat.view.SetFocus2()
 	}
 
 	// Показываем сообщение об успехе
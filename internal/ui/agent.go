@@ -0,0 +1,423 @@
+package ui
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/therecipe/qt/gui"
+	"github.com/therecipe/qt/widgets"
+
+	"go-gnome-editor/internal/logic"
+)
+
+// agentLink — префикс ссылок подтверждения действий агента: agent:allow:N, agent:deny:N
+const agentLink = "agent:"
+
+// agentApproval — действие агента, ждущее решения пользователя; allow и deny
+// выполняются в UI потоке
+type agentApproval struct {
+	ctx   context.Context
+	allow func()
+	deny  func()
+}
+
+// runAgent выполняет запрос в режиме агента: модель вызывает инструменты,
+// а правки файлов и команды выполняются только после подтверждения в чате
+func (e *EditorWindow) runAgent(chat *aiChatTab, display, prompt, fullPrompt string, images []string) {
+	session, view := chat.session, chat.view
+	live := func() bool { return !chat.closed && chat.session == session }
+
+	if chat.cancel != nil {
+		view.Append("<span style='color:red'>Error: another AI request is still running in this chat</span>")
+		return
+	}
+	if len(images) > 0 {
+		view.Append("<span style='color:#db4; font-size:10px;'>⚠ Images are not sent in agent mode</span>")
+	}
+	root := ""
+	if e.ProjectManager.IsActive {
+		root = e.ProjectManager.RootPath
+	} else {
+		view.Append("<span style='color:#db4; font-size:10px;'>⚠ No project is open: the agent cannot read files or run commands</span>")
+	}
+
+	target := e.llmTarget(logic.TaskAgent)
+	ctx, cancel := context.WithCancel(context.Background())
+	e.setAIRequest(chat, cancel)
+
+	name := target.Provider
+	if target.Model != "" {
+		name += " (" + target.Model + ")"
+	}
+	view.Append(fmt.Sprintf("<b>Agent:</b> <i>working with %s…</i>", html.EscapeString(name)))
+
+	host := logic.AgentHost{
+		Root: root,
+		ReadFile: func(path string) (string, error) {
+			return e.awaitUI(ctx, func(done func(string, error)) { done(e.bufferOrFileText(path)) })
+		},
+		ProposeEdit: func(ctx context.Context, path, content, description string) (string, error) {
+			return e.awaitUI(ctx, func(done func(string, error)) {
+				e.askAgentEdit(ctx, chat, path, content, description, done)
+			})
+		},
+		RunCommand: func(ctx context.Context, args []string) (string, error) {
+			return e.agentRunGo(ctx, chat, root, args)
+		},
+		OnEvent: func(ev logic.AgentEvent) {
+			e.RunOnUIThread(func() {
+				if live() {
+					appendAgentEvent(view, ev)
+				}
+			})
+		},
+	}
+
+	go func() {
+		res, err := logic.RunAgent(ctx, logic.AgentRequest{
+			Provider:    target.Provider,
+			Model:       target.Model,
+			APIKey:      target.APIKey,
+			Prompt:      fullPrompt,
+			Vars:        target.Vars,
+			StepTimeout: target.Timeout,
+		}, host)
+		cancel()
+		e.RunOnUIThread(func() {
+			e.setAIRequest(chat, nil)
+			e.dropStaleAgentApprovals()
+			if !live() {
+				return
+			}
+
+			chatRes := &logic.ChatResult{Text: res.Text, Provider: res.Provider, Model: res.Model, Redactions: res.Redactions}
+			switch {
+			case logic.IsCanceled(err):
+				view.Append("<span style='color:#888'><i>[Agent stopped by user]</i></span>")
+			case errors.Is(err, logic.ErrToolsUnsupported):
+				view.Append(fmt.Sprintf("<span style='color:red'>Error: %v</span><br><span style='color:#888'>Choose an OpenAI-compatible provider for Agent mode in AI > Models per Task...</span>",
+					html.EscapeString(err.Error())))
+			case err != nil:
+				view.Append(fmt.Sprintf("<span style='color:red'>Error: %v</span>", html.EscapeString(err.Error())))
+				appendRedactionSummary(view, chatRes)
+			default:
+				view.Append(fmt.Sprintf("<b>AI:</b><br>%s", e.renderAIResponse(chat, res.Text)))
+				appendRedactionSummary(view, chatRes)
+				e.Window.StatusBar().ShowMessage(fmt.Sprintf("Agent finished in %d step(s)", res.Steps), 3000)
+				e.addAITurn(chat, session, chatTurnFromResult(display, prompt, chatRes))
+				e.UpdateAIContextDisplay()
+			}
+			sb := view.VerticalScrollBar()
+			sb.SetValue(sb.Maximum())
+		})
+	}()
+}
+
+// awaitUI выполняет f в UI потоке и ждёт, пока она вызовет done; отмена ctx прерывает ожидание
+func (e *EditorWindow) awaitUI(ctx context.Context, f func(done func(string, error))) (string, error) {
+	type reply struct {
+		text string
+		err  error
+	}
+	ch := make(chan reply, 1)
+	e.RunOnUIThread(func() {
+		f(func(text string, err error) {
+			select {
+			case ch <- reply{text, err}:
+			default:
+			}
+		})
+	})
+	select {
+	case r := <-ch:
+		return r.text, r.err
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
+
+// bufferOrFileText возвращает текст открытой вкладки файла, а если он не открыт — файл с диска
+func (e *EditorWindow) bufferOrFileText(path string) (string, error) {
+	if ed := e.findEditor(path); ed != nil {
+		return ed.TextEdit.ToPlainText(), nil
+	}
+	data, err := os.ReadFile(path)
+	return string(data), err
+}
+
+// findEditor возвращает вкладку файла path (путь очищен filepath.Clean) или nil
+func (e *EditorWindow) findEditor(path string) *CodeEditorTab {
+	for _, ed := range e.TabManager.Editors {
+		if ed.FilePath != "" && filepath.Clean(ed.FilePath) == path {
+			return ed
+		}
+	}
+	return nil
+}
+
+// askAgent выводит в чат запрос подтверждения со ссылками allowTitle и Reject
+func (e *EditorWindow) askAgent(ctx context.Context, chat *aiChatTab, message, allowTitle string, allow, deny func()) {
+	if e.agentApprovals == nil {
+		e.agentApprovals = map[int]*agentApproval{}
+	}
+	e.agentApprovalID++
+	id := e.agentApprovalID
+	e.agentApprovals[id] = &agentApproval{ctx: ctx, allow: allow, deny: deny}
+
+	chat.view.Append(fmt.Sprintf("<span style='color:#db4;'>%s</span> — <a href='%sallow:%d'>%s</a> · <a href='%sdeny:%d'>Reject</a>",
+		message, agentLink, id, allowTitle, agentLink, id))
+	sb := chat.view.VerticalScrollBar()
+	sb.SetValue(sb.Maximum())
+	e.AIDock.Show()
+	e.Window.StatusBar().ShowMessage("The agent is waiting for your approval", 5000)
+}
+
+// answerAgent обрабатывает ссылку подтверждения agent:allow:N или agent:deny:N
+func (e *EditorWindow) answerAgent(url string) {
+	var action string
+	var id int
+	fields := strings.SplitN(strings.TrimPrefix(url, agentLink), ":", 2)
+	if len(fields) == 2 {
+		action = fields[0]
+		fmt.Sscanf(fields[1], "%d", &id)
+	}
+	approval := e.agentApprovals[id]
+	if approval == nil {
+		e.Window.StatusBar().ShowMessage("This request has already been answered", 3000)
+		return
+	}
+	delete(e.agentApprovals, id)
+	if approval.ctx.Err() != nil {
+		e.Window.StatusBar().ShowMessage("The agent is no longer running", 3000)
+		return
+	}
+	if action == "allow" {
+		approval.allow()
+	} else {
+		approval.deny()
+	}
+}
+
+// dropStaleAgentApprovals забывает подтверждения завершённых запросов
+func (e *EditorWindow) dropStaleAgentApprovals() {
+	for id, approval := range e.agentApprovals {
+		if approval.ctx.Err() != nil {
+			delete(e.agentApprovals, id)
+		}
+	}
+}
+
+// askAgentEdit предлагает правку файла; после "Review…" она показывается как diff
+// и принятые участки попадают в буфер редактора одним шагом отмены
+func (e *EditorWindow) askAgentEdit(ctx context.Context, chat *aiChatTab, path, content, description string, done func(string, error)) {
+	rel := e.relProjectPath(path)
+	message := fmt.Sprintf("✎ The agent proposes a change to <b>%s</b>: %s", html.EscapeString(rel), html.EscapeString(description))
+	e.askAgent(ctx, chat, message, "Review…",
+		func() { done(e.applyAgentEdit(path, rel, content, description)) },
+		func() { done("", errors.New("the user rejected the edit")) })
+}
+
+// applyAgentEdit открывает файл (новый файл — в новой вкладке) и показывает сравнение с content
+func (e *EditorWindow) applyAgentEdit(path, rel, content, description string) (string, error) {
+	ed := e.findEditor(path)
+	if ed == nil {
+		if _, err := os.Stat(path); err == nil {
+			e.TabManager.OpenFile(path)
+		} else {
+			e.TabManager.addTab(path, "")
+		}
+		ed = e.findEditor(path)
+		if ed == nil {
+			return "", fmt.Errorf("cannot open %s", rel)
+		}
+	} else {
+		e.TabManager.Tabs.SetCurrentIndex(e.TabManager.getTabIndex(ed))
+	}
+
+	applied, total := e.showDiffDialog(ed, "Agent: "+description, content)
+	switch {
+	case total == 0:
+		return "no changes: the file already has this content", nil
+	case applied == 0:
+		return "", errors.New("the user rejected the edit")
+	case applied < total:
+		return fmt.Sprintf("the user applied %d of %d changed hunks to %s; the rest were rejected. The buffer is not saved yet.", applied, total, rel), nil
+	}
+	return fmt.Sprintf("the edit was applied to %s. The buffer is not saved yet.", rel), nil
+}
+
+// relProjectPath — путь относительно корня проекта для сообщений
+func (e *EditorWindow) relProjectPath(path string) string {
+	if e.ProjectManager.IsActive {
+		if rel, err := filepath.Rel(e.ProjectManager.RootPath, path); err == nil {
+			return filepath.ToSlash(rel)
+		}
+	}
+	return path
+}
+
+// agentRunGo спрашивает подтверждение и запускает go в корне проекта через ProcessRunner;
+// вывод показывается в панели Output и возвращается модели
+func (e *EditorWindow) agentRunGo(ctx context.Context, chat *aiChatTab, root string, args []string) (string, error) {
+	cmdline := "go " + strings.Join(args, " ")
+
+	var mu sync.Mutex
+	var out strings.Builder
+	onOutput := func(text string) {
+		mu.Lock()
+		out.WriteString(text)
+		mu.Unlock()
+		e.RunOnUIThread(func() {
+			e.OutputText.MoveCursor(gui.QTextCursor__End, gui.QTextCursor__MoveAnchor)
+			e.OutputText.InsertPlainText(text)
+			sb := e.OutputText.VerticalScrollBar()
+			sb.SetValue(sb.Maximum())
+		})
+	}
+
+	// ProcessRunner выполняет одну программу: новый запуск остановил бы программу,
+	// запущенную пользователем (Run Go Code), поэтому агент ждёт её завершения.
+	// Команда запускается в UI-потоке сразу после подтверждения, чтобы между
+	// проверкой и запуском не успел начаться другой запуск.
+	errBusy := errors.New("a program started by the user is running in the Output panel; wait until it finishes and try again")
+	var doneChan <-chan error
+	var stop func()
+	_, err := e.awaitUI(ctx, func(done func(string, error)) {
+		if e.ProcessRunner.IsRunning() {
+			done("", errBusy)
+			return
+		}
+		message := fmt.Sprintf("▶ The agent wants to run <code>%s</code> (unsaved project files are saved first)", html.EscapeString(cmdline))
+		e.askAgent(ctx, chat, message, "Run",
+			func() {
+				if e.ProcessRunner.IsRunning() {
+					done("", errBusy)
+					return
+				}
+				if !e.saveAgentFiles(root) {
+					done("", errors.New("unsaved files could not be saved"))
+					return
+				}
+				e.lastRunDir = root
+				e.OutputDock.Show()
+				e.OutputText.AppendPlainText(fmt.Sprintf("\n--- Agent: %s ---\n", cmdline))
+				doneChan, stop = e.ProcessRunner.StartCommand(root, "go", args, onOutput)
+				e.BtnStop.SetEnabled(true)
+				e.BtnStop.DisconnectClicked()
+				e.BtnStop.ConnectClicked(func(bool) {
+					stop()
+					e.OutputText.AppendPlainText("\n[Stopped by User]\n")
+					e.BtnStop.SetEnabled(false)
+				})
+				done("", nil)
+			},
+			func() { done("", errors.New("the user declined to run the command")) })
+	})
+	if err != nil {
+		// Отмена могла совпасть с подтверждением: запущенная команда останавливается
+		e.RunOnUIThread(func() {
+			if stop != nil {
+				stop()
+				e.BtnStop.SetEnabled(false)
+				e.BtnStop.DisconnectClicked()
+			}
+		})
+		return "", err
+	}
+
+	select {
+	case err = <-doneChan:
+	case <-ctx.Done():
+		stop()
+		err = ctx.Err()
+	}
+
+	resultMsg := "Finished Successfully."
+	if err != nil {
+		resultMsg = fmt.Sprintf("Finished with Error: %v", err)
+	}
+	e.RunOnUIThread(func() {
+		e.OutputText.AppendPlainText(fmt.Sprintf("\n>>> %s\n", resultMsg))
+		e.BtnStop.SetEnabled(false)
+		e.BtnStop.DisconnectClicked()
+	})
+
+	mu.Lock()
+	text := strings.TrimSpace(out.String())
+	mu.Unlock()
+	if err != nil {
+		return text, fmt.Errorf("%s: %v", cmdline, err)
+	}
+	if text == "" {
+		text = "(no output)"
+	}
+	return text + "\n" + cmdline + ": ok", nil
+}
+
+// saveAgentFiles сохраняет изменённые вкладки файлов проекта перед запуском команды
+func (e *EditorWindow) saveAgentFiles(root string) bool {
+	for _, ed := range e.TabManager.GetUnsavedEditors() {
+		if ed.FilePath == "" {
+			continue
+		}
+		if rel, err := filepath.Rel(root, ed.FilePath); err != nil || strings.HasPrefix(rel, "..") {
+			continue
+		}
+		if !e.TabManager.SaveTab(ed) {
+			return false
+		}
+	}
+	return true
+}
+
+// appendAgentEvent выводит в чат шаг агента: вызов инструмента, результат или пояснение модели
+func appendAgentEvent(view *widgets.QTextBrowser, ev logic.AgentEvent) {
+	switch {
+	case ev.Tool == "":
+		view.Append(fmt.Sprintf("<span style='color:#888;'><i>%s</i></span>", html.EscapeString(shortText(ev.Text, 300))))
+	case ev.Result == "" && !ev.Failed:
+		view.Append(fmt.Sprintf("<span style='color:#8ab; font-size:10px;'>🔧 %s %s</span>",
+			html.EscapeString(ev.Tool), html.EscapeString(agentArgsSummary(ev.Text))))
+	default:
+		color := "#888"
+		if ev.Failed {
+			color = "#d66"
+		}
+		lines := strings.Count(ev.Result, "\n") + 1
+		summary := firstLine(ev.Result)
+		if lines > 1 {
+			summary += fmt.Sprintf(" … (%d lines)", lines)
+		}
+		view.Append(fmt.Sprintf("<span style='color:%s; font-size:10px;'>↳ %s</span>", color, html.EscapeString(shortText(summary, 200))))
+	}
+}
+
+// agentArgsSummary — краткая запись аргументов инструмента: путь, запрос или пакеты
+func agentArgsSummary(args string) string {
+	var m map[string]interface{}
+	if json.Unmarshal([]byte(args), &m) != nil {
+		return shortText(args, 120)
+	}
+	var parts []string
+	for _, key := range []string{"path", "query", "packages", "run", "start_line", "end_line"} {
+		if v, ok := m[key]; ok && fmt.Sprint(v) != "" {
+			parts = append(parts, fmt.Sprintf("%s=%v", key, v))
+		}
+	}
+	return shortText(strings.Join(parts, " "), 120)
+}
+
+// shortText обрезает строку до n символов
+func shortText(s string, n int) string {
+	if r := []rune(s); len(r) > n {
+		return string(r[:n-1]) + "…"
+	}
+	return s
+}
@@ -0,0 +1,462 @@
+package ui
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/therecipe/qt/core"
+	"github.com/therecipe/qt/gui"
+	"github.com/therecipe/qt/widgets"
+
+	"go-gnome-editor/internal/logic"
+)
+
+// Служебные ссылки чата
+const (
+	undoClearLink = "undo:clear" // возвращает очищенный диалог
+	applyDiffLink = "applydiff:" // applydiff:N — сравнить блок кода N с редактором
+)
+
+// aiChatTab — вкладка AI чата со своим диалогом и блоками кода
+type aiChatTab struct {
+	view       *widgets.QTextBrowser
+	session    *logic.ChatSession
+	dir        string            // каталог, куда сохраняется диалог ("" — выбирается при первом сохранении)
+	codeBlocks []CodeBlockData   // блоки кода всех ответов вкладки, индекс — номер в ссылке copycode:N
+	buildFixes [][]logic.FileFix // исправления ошибок сборки, индекс — номер в ссылке buildfix:N
+	closed     bool
+
+	// Запрос вкладки: вкладки отправляют запросы независимо друг от друга
+	cancel  context.CancelFunc // отмена выполняющегося запроса (nil — запроса нет)
+	pending bool               // вопрос принят, для него ещё собирается контекст
+}
+
+// busy сообщает, что новый вопрос во вкладку отправлять нельзя: временная область
+// ответа удаляется до конца чата, и два потока в одной вкладке мешали бы друг другу
+func (chat *aiChatTab) busy() bool {
+	return chat.cancel != nil || chat.pending
+}
+
+// cancelRequest прерывает запрос вкладки; вкладка остаётся занятой, пока он не завершится
+func (chat *aiChatTab) cancelRequest() {
+	if chat.cancel != nil {
+		chat.cancel()
+	}
+}
+
+// clearedAIChat — диалог, убранный ClearAIHistory, для отмены
+type clearedAIChat struct {
+	chat    *aiChatTab
+	session *logic.ChatSession
+	dir     string
+}
+
+// createAIChatTabs создаёт вкладки чата с кнопками нового диалога и списка сохранённых
+func (e *EditorWindow) createAIChatTabs() *widgets.QTabWidget {
+	e.aiChats = widgets.NewQTabWidget(nil)
+	e.aiChats.SetTabsClosable(true)
+	e.aiChats.SetMovable(true)
+	e.aiChats.SetDocumentMode(true)
+	e.aiChats.ConnectTabCloseRequested(func(index int) {
+		if chat := e.aiChatAt(index); chat != nil {
+			e.closeAIChat(chat)
+		}
+	})
+	e.aiChats.ConnectCurrentChanged(func(int) {
+		e.updateAIButtons()
+		e.UpdateAIContextDisplay()
+	})
+
+	corner := widgets.NewQWidget(nil, 0)
+	cornerLayout := widgets.NewQHBoxLayout()
+	cornerLayout.SetContentsMargins(0, 0, 0, 0)
+
+	btnNew := widgets.NewQToolButton(nil)
+	btnNew.SetText("+")
+	btnNew.SetToolTip("New chat")
+	btnNew.ConnectClicked(func(bool) { e.newAIChat(nil, "") })
+	cornerLayout.AddWidget(btnNew, 0, 0)
+
+	btnSessions := widgets.NewQToolButton(nil)
+	btnSessions.SetText("☰")
+	btnSessions.SetToolTip("Saved chats")
+	btnSessions.SetPopupMode(widgets.QToolButton__InstantPopup)
+	menu := widgets.NewQMenu(btnSessions)
+	e.fillAIChatMenu(menu)
+	btnSessions.SetMenu(menu)
+	cornerLayout.AddWidget(btnSessions, 0, 0)
+
+	corner.SetLayout(cornerLayout)
+	e.aiChats.SetCornerWidget(corner, core.Qt__TopRightCorner)
+
+	e.newAIChat(nil, "")
+	return e.aiChats
+}
+
+// fillAIChatMenu добавляет действия с диалогами (меню панели и View > AI History)
+func (e *EditorWindow) fillAIChatMenu(menu *widgets.QMenu) {
+	menu.AddAction("&New Chat").ConnectTriggered(func(bool) { e.newAIChat(nil, "") })
+	menu.AddAction("Chat &Sessions...").ConnectTriggered(func(bool) { e.showChatSessionsDialog() })
+	menu.AddAction("&Export Chat to Markdown...").ConnectTriggered(func(bool) {
+		if chat := e.currentAIChat(); chat != nil {
+			e.exportAIChat(chat.session)
+		}
+	})
+	actUndo := menu.AddAction("&Undo Clear History")
+	actUndo.ConnectTriggered(func(bool) { e.undoClearAIHistory() })
+	menu.ConnectAboutToShow(func() { actUndo.SetEnabled(e.aiCleared != nil) })
+}
+
+// newAIChat открывает вкладку с диалогом session (nil — новый пустой) из каталога dir
+func (e *EditorWindow) newAIChat(session *logic.ChatSession, dir string) *aiChatTab {
+	if session == nil {
+		session = logic.NewChatSession()
+	}
+	chat := &aiChatTab{session: session, dir: dir}
+
+	chat.view = widgets.NewQTextBrowser(nil)
+	chat.view.SetOpenExternalLinks(false)
+	chat.view.SetReadOnly(true)
+	chat.view.SetTextInteractionFlags(
+		core.Qt__TextBrowserInteraction | core.Qt__LinksAccessibleByMouse,
+	) // разрешаем только клик по ссылкам
+
+	// Клик обрабатывается в UI потоке, "навигация" QTextBrowser запрещена
+	chat.view.ConnectAnchorClicked(func(link *core.QUrl) {
+		if link == nil {
+			return
+		}
+		e.handleAIChatLink(chat, link.ToString(core.QUrl__None))
+		chat.view.SetSource(core.NewQUrl())
+	})
+
+	e.aiChatTabs = append(e.aiChatTabs, chat)
+	index := e.aiChats.AddTab(chat.view, "")
+	e.renderAIChat(chat)
+	e.aiChats.SetCurrentIndex(index)
+	return chat
+}
+
+// currentAIChat возвращает активную вкладку чата
+func (e *EditorWindow) currentAIChat() *aiChatTab {
+	if e.aiChats == nil {
+		return nil
+	}
+	return e.aiChatAt(e.aiChats.CurrentIndex())
+}
+
+// aiChatAt возвращает вкладку чата по индексу в QTabWidget
+func (e *EditorWindow) aiChatAt(index int) *aiChatTab {
+	for _, chat := range e.aiChatTabs {
+		if e.aiChats.IndexOf(chat.view) == index {
+			return chat
+		}
+	}
+	return nil
+}
+
+// closeAIChat закрывает вкладку; диалог уже сохранён и доступен в списке сессий
+func (e *EditorWindow) closeAIChat(chat *aiChatTab) {
+	chat.cancelRequest()
+	chat.closed = true
+	for i, c := range e.aiChatTabs {
+		if c == chat {
+			e.aiChatTabs = append(e.aiChatTabs[:i], e.aiChatTabs[i+1:]...)
+			break
+		}
+	}
+	e.aiChats.RemoveTab(e.aiChats.IndexOf(chat.view))
+	chat.view.DeleteLater()
+
+	// В панели всегда есть хотя бы одна вкладка
+	if len(e.aiChatTabs) == 0 {
+		e.newAIChat(nil, "")
+	}
+}
+
+// updateAIChatTitle показывает название диалога на вкладке
+func (e *EditorWindow) updateAIChatTitle(chat *aiChatTab) {
+	title := chat.session.Title
+	if title == "" {
+		title = "New chat"
+	}
+	index := e.aiChats.IndexOf(chat.view)
+	if r := []rune(title); len(r) > 20 {
+		title = string(r[:19]) + "…"
+	}
+	e.aiChats.SetTabText(index, title)
+	e.aiChats.SetTabToolTip(index, chat.session.Title)
+}
+
+// renderAIChat заново выводит сохранённые реплики диалога во вкладку
+func (e *EditorWindow) renderAIChat(chat *aiChatTab) {
+	chat.view.Clear()
+	chat.codeBlocks = nil
+	for _, turn := range chat.session.Turns {
+		// Диалоги загружаются из каталога проекта: текст реплики не должен стать разметкой или ссылкой
+		chat.view.Append("<b>You:</b> " + html.EscapeString(turn.ShownPrompt()))
+		chat.view.Append(fmt.Sprintf("<b>AI:</b><br>%s", e.renderAIResponse(chat, turn.Response)))
+	}
+	if n := len(chat.session.Turns); n > 0 {
+		chat.view.Append(fmt.Sprintf("<span style='color:#888; font-size:10px;'>Restored %d message(s) from %s</span>",
+			n, chat.session.Updated.Format("2006-01-02 15:04")))
+	}
+	e.updateAIChatTitle(chat)
+}
+
+// handleAIChatLink обрабатывает ссылки чата: копирование кода, сравнение, отмену очистки
+// и внешние ссылки из ответов (открываются в браузере)
+func (e *EditorWindow) handleAIChatLink(chat *aiChatTab, url string) {
+	switch {
+	case url == undoClearLink:
+		e.undoClearAIHistory()
+	case strings.HasPrefix(url, applyDiffLink):
+		e.applyCodeBlockAsDiff(chat, url)
+	case strings.HasPrefix(url, agentLink):
+		e.answerAgent(url)
+	case strings.HasPrefix(url, buildFixLink):
+		e.reviewBuildFixLink(chat, url)
+	case strings.HasPrefix(url, "http://"), strings.HasPrefix(url, "https://"), strings.HasPrefix(url, "mailto:"):
+		gui.QDesktopServices_OpenUrl(core.NewQUrl3(url, core.QUrl__TolerantMode))
+	default:
+		e.handleCodeBlockClick(chat, url)
+	}
+}
+
+// aiChatDir возвращает каталог диалогов текущего проекта (или общий без проекта)
+func (e *EditorWindow) aiChatDir() (string, error) {
+	root := ""
+	if e.ProjectManager.IsActive {
+		root = e.ProjectManager.RootPath
+	}
+	return logic.ChatSessionsDir(root)
+}
+
+// addAITurn добавляет реплику в диалог вкладки и сохраняет его
+func (e *EditorWindow) addAITurn(chat *aiChatTab, session *logic.ChatSession, turn logic.ChatTurn) {
+	session.AddTurn(turn)
+	if chat.dir == "" {
+		dir, err := e.aiChatDir()
+		if err != nil {
+			e.Window.StatusBar().ShowMessage(err.Error(), 5000)
+			return
+		}
+		chat.dir = dir
+	}
+	if err := logic.SaveChatSession(chat.dir, session); err != nil {
+		e.Window.StatusBar().ShowMessage(err.Error(), 5000)
+	}
+	if !chat.closed && chat.session == session {
+		e.updateAIChatTitle(chat)
+	}
+}
+
+// aiHistory возвращает реплики активного диалога в виде истории для контекста
+func (e *EditorWindow) aiHistory() []AIHistoryEntry {
+	chat := e.currentAIChat()
+	if chat == nil {
+		return nil
+	}
+	history := make([]AIHistoryEntry, len(chat.session.Turns))
+	for i, turn := range chat.session.Turns {
+		history[i] = AIHistoryEntry{
+			UserPrompt: turn.Prompt,
+			AIResponse: turn.Response,
+			Timestamp:  turn.Time.Format("15:04:05"),
+		}
+	}
+	return history
+}
+
+// ClearAIHistory начинает в активной вкладке новый диалог. Прежний остаётся
+// в списке сессий и возвращается ссылкой Undo или через undoClearAIHistory.
+func (e *EditorWindow) ClearAIHistory() {
+	chat := e.currentAIChat()
+	if chat == nil || len(chat.session.Turns) == 0 {
+		return
+	}
+	chat.cancelRequest()
+	e.aiCleared = &clearedAIChat{chat: chat, session: chat.session, dir: chat.dir}
+	chat.session = logic.NewChatSession()
+	chat.dir = ""
+	e.renderAIChat(chat)
+	chat.view.Append(fmt.Sprintf("<span style='color:#888; font-size:10px;'>Conversation cleared · <a href='%s'>Undo</a></span>", undoClearLink))
+	e.Window.StatusBar().ShowMessage("AI conversation history cleared (still available in Chat Sessions)", 3000)
+}
+
+// undoClearAIHistory возвращает диалог, убранный последним ClearAIHistory.
+// Если вкладка закрыта или в ней уже начат новый разговор, диалог открывается в новой вкладке.
+func (e *EditorWindow) undoClearAIHistory() {
+	cleared := e.aiCleared
+	if cleared == nil {
+		e.Window.StatusBar().ShowMessage("Nothing to undo", 2000)
+		return
+	}
+	e.aiCleared = nil
+
+	chat := cleared.chat
+	if chat.closed || len(chat.session.Turns) > 0 {
+		e.newAIChat(cleared.session, cleared.dir)
+	} else {
+		chat.session, chat.dir = cleared.session, cleared.dir
+		e.renderAIChat(chat)
+		e.aiChats.SetCurrentWidget(chat.view)
+	}
+	e.UpdateAIContextDisplay()
+	e.Window.StatusBar().ShowMessage("AI conversation restored", 2000)
+}
+
+// openChatSession открывает сохранённый диалог; уже открытый — переключается на его вкладку
+func (e *EditorWindow) openChatSession(info logic.ChatSessionInfo) {
+	for _, chat := range e.aiChatTabs {
+		if chat.session.ID == info.ID {
+			e.aiChats.SetCurrentWidget(chat.view)
+			return
+		}
+	}
+	session, err := logic.LoadChatSession(info.Path)
+	if err != nil {
+		e.Window.StatusBar().ShowMessage(err.Error(), 5000)
+		return
+	}
+	e.AIDock.Show()
+	e.newAIChat(session, filepath.Dir(info.Path))
+}
+
+// showChatSessionsDialog показывает сохранённые диалоги проекта: открыть, экспортировать, удалить
+func (e *EditorWindow) showChatSessionsDialog() {
+	dir, err := e.aiChatDir()
+	if err != nil {
+		e.Window.StatusBar().ShowMessage(err.Error(), 5000)
+		return
+	}
+
+	dlg := widgets.NewQDialog(e.Window, core.Qt__Dialog)
+	dlg.SetWindowTitle("Chat Sessions")
+	layout := widgets.NewQVBoxLayout()
+
+	where := "Chats saved without a project"
+	if e.ProjectManager.IsActive {
+		where = "Chats of project " + filepath.Base(e.ProjectManager.RootPath)
+	}
+	label := widgets.NewQLabel2(where, nil, 0)
+	label.SetStyleSheet("color: #888;")
+	layout.AddWidget(label, 0, 0)
+
+	list := widgets.NewQListWidget(nil)
+	layout.AddWidget(list, 1, 0)
+
+	var sessions []logic.ChatSessionInfo
+	reload := func() {
+		list.Clear()
+		sessions, err = logic.ListChatSessions(dir)
+		if err != nil {
+			e.Window.StatusBar().ShowMessage(err.Error(), 5000)
+		}
+		for _, s := range sessions {
+			title := s.Title
+			if title == "" {
+				title = s.ID
+			}
+			list.AddItem(fmt.Sprintf("%s — %s · %d message(s)", title, s.Updated.Format("2006-01-02 15:04"), s.Turns))
+		}
+		if len(sessions) > 0 {
+			list.SetCurrentRow(0)
+		}
+	}
+	selected := func() (logic.ChatSessionInfo, bool) {
+		row := list.CurrentRow()
+		if row < 0 || row >= len(sessions) {
+			return logic.ChatSessionInfo{}, false
+		}
+		return sessions[row], true
+	}
+	reload()
+
+	open := func() {
+		if s, ok := selected(); ok {
+			e.openChatSession(s)
+			dlg.Accept()
+		}
+	}
+	list.ConnectItemDoubleClicked(func(*widgets.QListWidgetItem) { open() })
+
+	buttons := widgets.NewQHBoxLayout()
+	btnOpen := widgets.NewQPushButton2("Open", nil)
+	btnOpen.ConnectClicked(func(bool) { open() })
+	btnExport := widgets.NewQPushButton2("Export...", nil)
+	btnExport.ConnectClicked(func(bool) {
+		s, ok := selected()
+		if !ok {
+			return
+		}
+		session, err := logic.LoadChatSession(s.Path)
+		if err != nil {
+			e.Window.StatusBar().ShowMessage(err.Error(), 5000)
+			return
+		}
+		e.exportAIChat(session)
+	})
+	btnDelete := widgets.NewQPushButton2("Delete", nil)
+	btnDelete.ConnectClicked(func(bool) {
+		s, ok := selected()
+		if !ok {
+			return
+		}
+		if widgets.QMessageBox_Question(dlg, "Delete Chat", fmt.Sprintf("Delete chat %q?", s.Title),
+			widgets.QMessageBox__Yes|widgets.QMessageBox__No, widgets.QMessageBox__No) != widgets.QMessageBox__Yes {
+			return
+		}
+		if err := logic.DeleteChatSession(s.Path); err != nil {
+			e.Window.StatusBar().ShowMessage(err.Error(), 5000)
+		}
+		reload()
+	})
+	btnClose := widgets.NewQPushButton2("Close", nil)
+	btnClose.ConnectClicked(func(bool) { dlg.Reject() })
+
+	buttons.AddWidget(btnOpen, 0, 0)
+	buttons.AddWidget(btnExport, 0, 0)
+	buttons.AddWidget(btnDelete, 0, 0)
+	buttons.AddStretch(1)
+	buttons.AddWidget(btnClose, 0, 0)
+	layout.AddLayout(buttons, 0)
+
+	dlg.SetLayout(layout)
+	dlg.Resize2(560, 380)
+	dlg.Exec()
+}
+
+// exportAIChat сохраняет диалог в Markdown-файл, выбранный пользователем
+func (e *EditorWindow) exportAIChat(session *logic.ChatSession) {
+	if session == nil || len(session.Turns) == 0 {
+		e.Window.StatusBar().ShowMessage("Chat is empty", 2000)
+		return
+	}
+	name := "chat-" + session.ID + ".md"
+	if e.ProjectManager.IsActive {
+		name = filepath.Join(e.ProjectManager.RootPath, name)
+	}
+	path := widgets.QFileDialog_GetSaveFileName(e.Window, "Export Chat", name, "Markdown (*.md);;All Files (*)", "", 0)
+	if path == "" {
+		return
+	}
+	if err := os.WriteFile(path, []byte(logic.ChatMarkdown(session)), 0644); err != nil {
+		e.Window.StatusBar().ShowMessage(fmt.Sprintf("Failed to export chat: %v", err), 5000)
+		return
+	}
+	e.Window.StatusBar().ShowMessage("Chat exported to "+path, 3000)
+}
+
+// chatTurnFromResult собирает реплику для сохранения из запроса и результата
+func chatTurnFromResult(display, prompt string, res *logic.ChatResult) logic.ChatTurn {
+	turn := logic.ChatTurn{Prompt: prompt, Response: res.Text, Provider: res.Provider, Model: res.Model}
+	if strings.TrimSpace(display) != strings.TrimSpace(prompt) {
+		turn.Display = display
+	}
+	return turn
+}
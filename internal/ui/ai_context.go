@@ -0,0 +1,418 @@
+package ui
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/therecipe/qt/core"
+	"github.com/therecipe/qt/gui"
+
+	"go-gnome-editor/internal/logic"
+)
+
+// Виды фрагментов контекста AI (logic.ContextPart.Kind)
+const (
+	ctxHistory   = "history"
+	ctxFile      = "file"
+	ctxTab       = "tab"
+	ctxProject   = "project"
+	ctxClipboard = "clipboard"
+	ctxDecls     = "decls"
+	ctxIndex     = "index"
+)
+
+// Приоритеты фрагментов: при нехватке окна модели первыми отбрасываются другие вкладки,
+// затем самые старые записи истории; текущий файл не отбрасывается.
+const (
+	priorityOtherTab    = 10
+	priorityHistory     = 20 // + номер записи: старые отбрасываются раньше новых
+	priorityIndex       = 70 // − место в выдаче: менее похожие фрагменты отбрасываются раньше
+	priorityClipboard   = 80
+	priorityDecls       = 85
+	priorityProjectFile = 90
+)
+
+// maxClipboardChars ограничивает размер буфера обмена в контексте
+const maxClipboardChars = 10000
+
+// aiPrompt — собранный запрос к AI чату
+type aiPrompt struct {
+	Text   string           // полный промпт
+	Info   []string         // краткое описание контекста для чата
+	Fit    logic.ContextFit // что поместилось в окно модели
+	Tokens int              // оценка размера запроса: Text, системный промпт и картинки
+	Limit  int              // окно контекста модели
+}
+
+// aiContextSources — снимок источников контекста, снятый в UI-потоке.
+// Файлы проекта читаются и промпт собирается по снимку вне UI-потока.
+type aiContextSources struct {
+	head         []logic.ContextPart // история, текущий файл, объявления, индекс, вкладки
+	projectFiles []string
+	clipboard    string
+}
+
+// collectAIContext снимает источники контекста в порядке их следования в промпте:
+// история, текущий файл, объявления, фрагменты индекса, другие вкладки, файлы проекта,
+// буфер обмена. Объявления и фрагменты индекса ищутся заранее вне UI-потока
+// (см. findGoDecls и searchCodeIndex).
+func (e *EditorWindow) collectAIContext(decls goDecls, hits []logic.CodeHit) aiContextSources {
+	var src aiContextSources
+
+	// 0. История предыдущих диалогов с AI
+	for i, entry := range e.aiHistoryForContext() {
+		src.head = append(src.head, logic.ContextPart{
+			Kind:     ctxHistory,
+			Label:    entry.Timestamp,
+			Text:     formatAIHistoryEntry(i+1, entry),
+			Priority: priorityHistory + i,
+		})
+	}
+
+	// 1. Текущий файл
+	ed := e.TabManager.CurrentEditor()
+	if ed != nil {
+		fileName := "Untitled"
+		if ed.FilePath != "" {
+			fileName = filepath.Base(ed.FilePath)
+		}
+		src.head = append(src.head, logic.ContextPart{
+			Kind:     ctxFile,
+			Label:    fileName,
+			Text:     fmt.Sprintf("\nUser is editing file: %s\nContent:\n%s\n", fileName, ed.TextEdit.ToPlainText()),
+			Required: true,
+		})
+	}
+
+	// 1.2 Объявления из модуля, на которые ссылается код у курсора (сам файл уже в запросе)
+	if decls.n > 0 {
+		src.head = append(src.head, logic.ContextPart{
+			Kind:     ctxDecls,
+			Label:    fmt.Sprintf("[%d declarations]", decls.n),
+			Text:     "\n--- Declarations referenced near the cursor ---\n" + decls.text + "--- End of declarations ---\n",
+			Priority: priorityDecls,
+		})
+	}
+
+	// 1.3 Фрагменты индекса кода, найденные по вопросу
+	for i, hit := range hits {
+		src.head = append(src.head, logic.ContextPart{
+			Kind:     ctxIndex,
+			Label:    hit.Location(),
+			Text:     formatIndexHit(hit),
+			Priority: priorityIndex - i,
+		})
+	}
+
+	// 1.5 Контекст из других открытых вкладок (если опция включена)
+	if e.AIUseOpenTabsAsContext {
+		for _, tab := range e.TabManager.OpenTabsContext(ed) {
+			src.head = append(src.head, logic.ContextPart{
+				Kind:     ctxTab,
+				Label:    tab.Name,
+				Text:     formatTabContext(tab),
+				Priority: priorityOtherTab,
+			})
+		}
+	}
+
+	// 2. Файлы проекта (читаются при сборке, см. parts)
+	src.projectFiles = e.ProjectManager.GetContextFiles()
+
+	// 3. Буфер обмена (если включён)
+	if e.AIClipboardCheckbox != nil && e.AIClipboardCheckbox.IsChecked() {
+		src.clipboard = gui.QGuiApplication_Clipboard().Text(gui.QClipboard__Clipboard)
+	}
+
+	return src
+}
+
+// labels возвращает имена снятых фрагментов вида kind
+func (src aiContextSources) labels(kind string) []string {
+	var labels []string
+	for _, p := range src.head {
+		if p.Kind == kind {
+			labels = append(labels, p.Label)
+		}
+	}
+	return labels
+}
+
+// parts возвращает фрагменты контекста; файлы проекта берутся из кэша files
+func (src aiContextSources) parts(files *contextFileCache) []logic.ContextPart {
+	parts := append([]logic.ContextPart(nil), src.head...)
+
+	for _, path := range src.projectFiles {
+		content, err := files.read(path)
+		if err != nil {
+			continue // Skip unreadable files
+		}
+		parts = append(parts, logic.ContextPart{
+			Kind:     ctxProject,
+			Label:    filepath.Base(path),
+			Text:     fmt.Sprintf("--- File: %s ---\n%s\n\n", filepath.Base(path), content),
+			Priority: priorityProjectFile,
+		})
+	}
+	files.retain(src.projectFiles)
+
+	if clipText := src.clipboard; clipText != "" {
+		if len(clipText) > maxClipboardChars {
+			clipText = clipText[:maxClipboardChars] + "\n... [truncated]"
+		}
+		parts = append(parts, logic.ContextPart{
+			Kind:     ctxClipboard,
+			Label:    "clipboard",
+			Text:     fmt.Sprintf("\n--- Clipboard Content ---\n%s\n--- End Clipboard ---\n", clipText),
+			Priority: priorityClipboard,
+		})
+	}
+	return parts
+}
+
+// contextFileCache хранит содержимое файлов контекста проекта, пока у файла
+// не изменились время изменения и размер
+type contextFileCache struct {
+	mu    sync.Mutex
+	files map[string]cachedContextFile
+}
+
+type cachedContextFile struct {
+	modTime time.Time
+	size    int64
+	text    string
+}
+
+func (c *contextFileCache) read(path string) (string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+	c.mu.Lock()
+	cached, ok := c.files[path]
+	c.mu.Unlock()
+	if ok && cached.modTime.Equal(info.ModTime()) && cached.size == info.Size() {
+		return cached.text, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	c.mu.Lock()
+	if c.files == nil {
+		c.files = make(map[string]cachedContextFile)
+	}
+	c.files[path] = cachedContextFile{modTime: info.ModTime(), size: info.Size(), text: string(data)}
+	c.mu.Unlock()
+	return string(data), nil
+}
+
+// retain забывает файлы, убранные из контекста проекта
+func (c *contextFileCache) retain(paths []string) {
+	keep := make(map[string]bool, len(paths))
+	for _, p := range paths {
+		keep[p] = true
+	}
+	c.mu.Lock()
+	for p := range c.files {
+		if !keep[p] {
+			delete(c.files, p)
+		}
+	}
+	c.mu.Unlock()
+}
+
+// aiPromptRequest — всё, что нужно для сборки промпта вне UI-потока
+type aiPromptRequest struct {
+	userPrompt string
+	sources    aiContextSources
+	target     logic.LLMTarget
+	agent      bool
+	images     int // число приложенных картинок
+}
+
+// newAIPromptRequest снимает состояние для сборки промпта чата в модель,
+// получающую запрос (вида "chat" или "agent"); вызывается в UI-потоке
+func (e *EditorWindow) newAIPromptRequest(userPrompt string, images int, decls goDecls, hits []logic.CodeHit) aiPromptRequest {
+	agent := e.aiAgentCheckbox != nil && e.aiAgentCheckbox.IsChecked()
+	task := logic.TaskChat
+	if agent {
+		task = logic.TaskAgent
+	}
+	return aiPromptRequest{
+		userPrompt: userPrompt,
+		sources:    e.collectAIContext(decls, hits),
+		target:     e.llmTarget(task),
+		agent:      agent,
+		images:     images,
+	}
+}
+
+// build собирает промпт, отбрасывая фрагменты контекста, которые не помещаются
+// в окно модели вместе с системным промптом и картинками
+func (r aiPromptRequest) build(files *contextFileCache) aiPrompt {
+	request := "\nUser Request: " + r.userPrompt
+	system := logic.ResolveSystemPrompt(r.target.Provider, r.target.Vars)
+	if r.agent {
+		system = logic.AgentSystemPrompt(r.target.Provider, r.target.Vars)
+	}
+	extra := logic.EstimateTokens(system) + r.images*logic.ImageTokens
+
+	limit := logic.ContextLimit(r.target.Model)
+	budget := limit - logic.ResponseReserve(limit)
+
+	// Обрамление групп (заголовки истории, вкладок) оцениваем с запасом
+	const framingTokens = 40
+	fit := logic.FitContext(r.sources.parts(files), logic.EstimateTokens(request)+framingTokens+extra, budget)
+
+	text := renderAIContext(fit.Parts) + request
+	return aiPrompt{
+		Text:   text,
+		Info:   describeAIContext(fit),
+		Fit:    fit,
+		Tokens: logic.EstimateTokens(text) + extra,
+		Limit:  limit,
+	}
+}
+
+// buildAIPrompt собирает промпт чата в UI-потоке (при отправке вопроса)
+func (e *EditorWindow) buildAIPrompt(userPrompt string, images int, decls goDecls, hits []logic.CodeHit) aiPrompt {
+	return e.newAIPromptRequest(userPrompt, images, decls, hits).build(&e.aiContextFiles)
+}
+
+// renderAIContext склеивает фрагменты, добавляя заголовки групп
+func renderAIContext(parts []logic.ContextPart) string {
+	var sb strings.Builder
+	for i, p := range parts {
+		first := i == 0 || parts[i-1].Kind != p.Kind
+		last := i == len(parts)-1 || parts[i+1].Kind != p.Kind
+
+		if first {
+			switch p.Kind {
+			case ctxHistory:
+				sb.WriteString(aiHistoryHeader)
+			case ctxTab:
+				sb.WriteString("\n--- Context from other open tabs ---\n")
+			case ctxIndex:
+				sb.WriteString("\n--- Relevant code from the project index ---\n")
+			case ctxProject:
+				sb.WriteString("Project Context:\n")
+			}
+		}
+		sb.WriteString(p.Text)
+		if last {
+			switch p.Kind {
+			case ctxHistory:
+				sb.WriteString(aiHistoryFooter)
+			case ctxTab:
+				sb.WriteString("\n--- End of other open tabs context ---\n")
+			case ctxIndex:
+				sb.WriteString("--- End of project index results ---\n")
+			}
+		}
+	}
+	return sb.String()
+}
+
+// describeAIContext формирует строку "Context: ..." для чата
+func describeAIContext(fit logic.ContextFit) []string {
+	var info, tabNames, indexHits []string
+	historyCount := 0
+
+	for _, p := range fit.Parts {
+		switch p.Kind {
+		case ctxHistory:
+			historyCount++
+		case ctxTab:
+			tabNames = append(tabNames, p.Label)
+		case ctxIndex:
+			indexHits = append(indexHits, p.Label)
+		case ctxClipboard:
+			info = append(info, "[clipboard]")
+		default:
+			info = append(info, p.Label)
+		}
+	}
+
+	if historyCount > 0 {
+		info = append([]string{fmt.Sprintf("[%d prev. responses]", historyCount)}, info...)
+	}
+	if len(tabNames) > 0 {
+		if len(tabNames) <= 3 {
+			info = append(info, fmt.Sprintf("[other tabs: %s]", strings.Join(tabNames, ", ")))
+		} else {
+			info = append(info, fmt.Sprintf("[other tabs: %s, ... +%d]", strings.Join(tabNames[:3], ", "), len(tabNames)-3))
+		}
+	}
+	if len(indexHits) > 0 {
+		info = append(info, fmt.Sprintf("[index: %s]", strings.Join(indexHits, ", ")))
+	}
+	if len(fit.Dropped) > 0 {
+		labels := make([]string, len(fit.Dropped))
+		for i, p := range fit.Dropped {
+			labels[i] = p.Label
+			if p.Kind == ctxHistory {
+				labels[i] = "history " + p.Label
+			}
+		}
+		info = append(info, fmt.Sprintf("[trimmed to fit: %s]", strings.Join(labels, ", ")))
+	}
+	return info
+}
+
+// tokenMeterHTML — строка "N / M tokens" для AIContextLabel
+func tokenMeterHTML(p aiPrompt) string {
+	color := "#8c8"
+	switch {
+	case p.Fit.Over():
+		color = "#e66"
+	case len(p.Fit.Dropped) > 0 || p.Tokens > p.Fit.Budget*3/4:
+		color = "#db4"
+	}
+
+	meter := fmt.Sprintf("🧮 <b>Tokens:</b> <span style='color:%s'>%d / %d</span>", color, p.Tokens, p.Limit)
+	if n := len(p.Fit.Dropped); n > 0 {
+		meter += fmt.Sprintf(" (%d part(s) trimmed)", n)
+	}
+	if p.Fit.Over() {
+		meter += " — current file alone exceeds the model context"
+	}
+	return meter
+}
+
+// scheduleAIContextUpdate обновляет панель контекста с задержкой,
+// чтобы не пересчитывать токены на каждое нажатие клавиши
+func (e *EditorWindow) scheduleAIContextUpdate() {
+	if e.AIDock == nil || !e.AIDock.IsVisible() {
+		return
+	}
+	if e.aiContextTimer == nil {
+		e.aiContextTimer = core.NewQTimer(e.Window)
+		e.aiContextTimer.SetSingleShot(true)
+		e.aiContextTimer.ConnectTimeout(e.UpdateAIContextDisplay)
+	}
+	e.aiContextTimer.Start(400)
+}
+
+// updateTokenMeter пересчитывает счётчик токенов по снимку req вне UI-потока (без объявлений Go
+// и фрагментов индекса: они ищутся только при отправке). Пока идёт подсчёт,
+// на панели остаётся прежнее значение; устаревшие результаты отбрасываются.
+func (e *EditorWindow) updateTokenMeter(req aiPromptRequest) {
+	e.aiMeterSeq++
+	seq := e.aiMeterSeq
+	go func() {
+		meter := tokenMeterHTML(req.build(&e.aiContextFiles))
+		e.RunOnUIThread(func() {
+			if seq != e.aiMeterSeq {
+				return
+			}
+			e.aiMeterHTML = meter
+			e.showAIContextLabel()
+		})
+	}()
+}
@@ -0,0 +1,78 @@
+package ui
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"go-gnome-editor/internal/logic"
+)
+
+func TestContextFileCacheRereadsChangedFiles(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "notes.md")
+	write := func(text string, mtime time.Time) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(text), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var c contextFileCache
+	start := time.Now().Add(-time.Hour)
+	write("first", start)
+	if text, err := c.read(path); err != nil || text != "first" {
+		t.Fatalf("read = %q, %v", text, err)
+	}
+
+	// Тот же размер и время изменения — берётся из кэша
+	write("xxxxx", start)
+	if text, _ := c.read(path); text != "first" {
+		t.Fatalf("unchanged file re-read: %q", text)
+	}
+	write("second", start.Add(time.Second))
+	if text, _ := c.read(path); text != "second" {
+		t.Fatalf("changed file not re-read: %q", text)
+	}
+
+	c.retain(nil)
+	if len(c.files) != 0 {
+		t.Fatalf("removed files kept: %d", len(c.files))
+	}
+	os.Remove(path)
+	if _, err := c.read(path); err == nil {
+		t.Fatal("missing file read without an error")
+	}
+}
+
+func TestAIPromptRequestCountsSystemPromptAndImages(t *testing.T) {
+	req := aiPromptRequest{
+		userPrompt: "why does it fail?",
+		sources: aiContextSources{head: []logic.ContextPart{
+			{Kind: ctxFile, Label: "main.go", Text: "\nUser is editing file: main.go\nContent:\npackage main\n", Required: true},
+		}},
+		target: logic.LLMTarget{Provider: "openai", Model: "gpt-4o"},
+	}
+	var files contextFileCache
+	chat := req.build(&files)
+	system := logic.EstimateTokens(logic.ResolveSystemPrompt("openai", logic.PromptVars{}))
+	if want := logic.EstimateTokens(chat.Text) + system; chat.Tokens != want {
+		t.Fatalf("chat tokens %d, want %d", chat.Tokens, want)
+	}
+	if !strings.HasSuffix(chat.Text, "User Request: why does it fail?") {
+		t.Fatalf("text %q", chat.Text)
+	}
+
+	req.agent, req.images = true, 2
+	agent := req.build(&files)
+	system = logic.EstimateTokens(logic.AgentSystemPrompt("openai", logic.PromptVars{}))
+	if want := logic.EstimateTokens(agent.Text) + system + 2*logic.ImageTokens; agent.Tokens != want {
+		t.Fatalf("agent tokens %d, want %d", agent.Tokens, want)
+	}
+	if agent.Fit.Tokens < agent.Tokens-50 {
+		t.Fatalf("fixed cost not counted in the fit: %d of %d", agent.Fit.Tokens, agent.Tokens)
+	}
+}
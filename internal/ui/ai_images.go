@@ -0,0 +1,221 @@
+package ui
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/therecipe/qt/core"
+	"github.com/therecipe/qt/gui"
+	"github.com/therecipe/qt/widgets"
+
+	"go-gnome-editor/internal/logic"
+)
+
+const (
+	// aiImageMaxSide — картинки крупнее уменьшаются перед отправкой
+	aiImageMaxSide = 2048
+	// aiThumbnailSize — размер миниатюры в панели контекста
+	aiThumbnailSize = 48
+	// aiImageFilter — фильтр диалога "Attach Image"
+	aiImageFilter = "Images (*.png *.jpg *.jpeg)"
+)
+
+// aiImageBar — миниатюры картинок, приложенных к следующему сообщению чата
+type aiImageBar struct {
+	widget  *widgets.QWidget
+	layout  *widgets.QHBoxLayout
+	buttons []*widgets.QToolButton
+}
+
+// createImageBar создаёт полосу миниатюр (скрыта, пока нет картинок)
+func (e *EditorWindow) createImageBar() *widgets.QWidget {
+	bar := &aiImageBar{
+		widget: widgets.NewQWidget(nil, 0),
+		layout: widgets.NewQHBoxLayout(),
+	}
+	bar.layout.SetContentsMargins(0, 0, 0, 0)
+	bar.layout.SetSpacing(4)
+	bar.layout.AddStretch(1)
+	bar.widget.SetLayout(bar.layout)
+	bar.widget.Hide()
+	e.aiImageBar = bar
+	return bar.widget
+}
+
+// setupAIInputImages принимает картинки, вставленные или перетащенные в поле ввода:
+// данные изображения из буфера обмена и локальные файлы PNG/JPEG
+func (e *EditorWindow) setupAIInputImages() {
+	e.AIInput.ConnectCanInsertFromMimeData(func(source *core.QMimeData) bool {
+		if source.HasImage() || len(imageFilesFromMime(source)) > 0 {
+			return true
+		}
+		return e.AIInput.CanInsertFromMimeDataDefault(source)
+	})
+	e.AIInput.ConnectInsertFromMimeData(func(source *core.QMimeData) {
+		if files := imageFilesFromMime(source); len(files) > 0 {
+			e.attachImageFiles(files)
+			return
+		}
+		if source.HasImage() {
+			img := gui.NewQImageFromPointer(source.ImageData().ToImage())
+			e.attachQImage(img, "pasted image")
+			return
+		}
+		e.AIInput.InsertFromMimeDataDefault(source)
+	})
+}
+
+// imageFilesFromMime возвращает локальные файлы PNG/JPEG из перетаскиваемых/вставляемых данных
+func imageFilesFromMime(source *core.QMimeData) []string {
+	if source == nil || !source.HasUrls() {
+		return nil
+	}
+	var files []string
+	for _, u := range source.Urls() {
+		if !u.IsLocalFile() {
+			continue
+		}
+		path := u.ToLocalFile()
+		switch strings.ToLower(filepath.Ext(path)) {
+		case ".png", ".jpg", ".jpeg":
+			files = append(files, path)
+		}
+	}
+	return files
+}
+
+// promptAttachImages открывает диалог выбора картинок для следующего сообщения
+func (e *EditorWindow) promptAttachImages() {
+	files := widgets.QFileDialog_GetOpenFileNames(e.Window, "Attach Image", e.ProjectManager.RootPath, aiImageFilter, "", 0)
+	if len(files) > 0 {
+		e.attachImageFiles(files)
+	}
+}
+
+// attachImageFiles прикладывает файлы картинок; слишком большие уменьшаются
+func (e *EditorWindow) attachImageFiles(files []string) {
+	for _, path := range files {
+		url, err := logic.LoadImageDataURL(path)
+		if err == nil {
+			e.addAIImage(url, filepath.Base(path))
+			continue
+		}
+		// Крупный снимок экрана и т.п. — пробуем уменьшить через QImage
+		img := gui.NewQImage9(path, "")
+		if img.IsNull() {
+			e.Window.StatusBar().ShowMessage(fmt.Sprintf("%s: %v", filepath.Base(path), err), 5000)
+			continue
+		}
+		e.attachQImage(img, filepath.Base(path))
+	}
+}
+
+// attachQImage кодирует изображение (вставленное из буфера обмена или не прошедшее
+// по размеру) в PNG, а если он слишком велик — в JPEG
+func (e *EditorWindow) attachQImage(img *gui.QImage, name string) {
+	if img == nil || img.IsNull() {
+		e.Window.StatusBar().ShowMessage("Clipboard does not contain a usable image", 3000)
+		return
+	}
+	if img.Width() > aiImageMaxSide || img.Height() > aiImageMaxSide {
+		img = img.Scaled(core.NewQSize2(aiImageMaxSide, aiImageMaxSide), core.Qt__KeepAspectRatio, core.Qt__SmoothTransformation)
+	}
+
+	data, err := encodeQImage(img, "PNG", -1)
+	if err == nil && len(data) > logic.MaxImageBytes {
+		data, err = encodeQImage(img, "JPG", 85)
+	}
+	if err != nil {
+		e.Window.StatusBar().ShowMessage(fmt.Sprintf("Failed to attach %s: %v", name, err), 5000)
+		return
+	}
+	url, err := logic.ImageDataURL(data)
+	if err != nil {
+		e.Window.StatusBar().ShowMessage(fmt.Sprintf("Failed to attach %s: %v", name, err), 5000)
+		return
+	}
+	e.addAIImage(url, name)
+}
+
+// encodeQImage сохраняет изображение во временный файл заданного формата и читает его
+func encodeQImage(img *gui.QImage, format string, quality int) ([]byte, error) {
+	f, err := os.CreateTemp("", "golite-image-*."+strings.ToLower(format))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temp file: %w", err)
+	}
+	path := f.Name()
+	f.Close()
+	defer os.Remove(path)
+
+	if !img.Save(path, format, quality) {
+		return nil, fmt.Errorf("failed to encode image as %s", format)
+	}
+	return os.ReadFile(path)
+}
+
+// addAIImage добавляет картинку к следующему сообщению и показывает миниатюру
+func (e *EditorWindow) addAIImage(dataURL, name string) {
+	e.aiImages = append(e.aiImages, dataURL)
+	e.aiImageNames = append(e.aiImageNames, name)
+	e.refreshImageBar()
+	e.Window.StatusBar().ShowMessage(fmt.Sprintf("Attached %s (%d image(s) for the next message)", name, len(e.aiImages)), 3000)
+}
+
+// removeAIImage убирает i-ю приложенную картинку
+func (e *EditorWindow) removeAIImage(i int) {
+	if i < 0 || i >= len(e.aiImages) {
+		return
+	}
+	e.aiImages = append(e.aiImages[:i], e.aiImages[i+1:]...)
+	e.aiImageNames = append(e.aiImageNames[:i], e.aiImageNames[i+1:]...)
+	e.refreshImageBar()
+}
+
+// takeAIImages возвращает приложенные картинки и очищает список (они уходят с сообщением)
+func (e *EditorWindow) takeAIImages() []string {
+	images := e.aiImages
+	e.aiImages, e.aiImageNames = nil, nil
+	e.refreshImageBar()
+	return images
+}
+
+// refreshImageBar перестраивает миниатюры; клик по миниатюре убирает картинку
+func (e *EditorWindow) refreshImageBar() {
+	bar := e.aiImageBar
+	if bar == nil {
+		return
+	}
+	for _, btn := range bar.buttons {
+		bar.layout.RemoveWidget(btn)
+		btn.DeleteLater()
+	}
+	bar.buttons = nil
+
+	for i, url := range e.aiImages {
+		idx := i
+		btn := widgets.NewQToolButton(nil)
+		btn.SetIconSize(core.NewQSize2(aiThumbnailSize, aiThumbnailSize))
+		btn.SetToolTip(fmt.Sprintf("%s\nClick to remove", e.aiImageNames[i]))
+		if raw, _, err := logic.DecodeImageDataURL(url); err == nil {
+			pm := gui.NewQPixmap()
+			if pm.LoadFromData(raw, uint(len(raw)), "", core.Qt__AutoColor) {
+				btn.SetIcon(gui.NewQIcon2(pm.Scaled(core.NewQSize2(aiThumbnailSize, aiThumbnailSize), core.Qt__KeepAspectRatio, core.Qt__SmoothTransformation)))
+			}
+		}
+		btn.ConnectClicked(func(bool) { e.removeAIImage(idx) })
+		// Перед растяжкой в конце полосы
+		bar.layout.InsertWidget(len(bar.buttons), btn, 0, 0)
+		bar.buttons = append(bar.buttons, btn)
+	}
+	bar.widget.SetVisible(len(e.aiImages) > 0)
+}
+
+// imagesNotice — пометка о приложенных картинках для строки "You:" в чате
+func imagesNotice(n int) string {
+	if n == 0 {
+		return ""
+	}
+	return fmt.Sprintf(" <span style='color:#888; font-size:10px;'>📎 %d image(s)</span>", n)
+}
@@ -0,0 +1,239 @@
+package ui
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/therecipe/qt/core"
+	"github.com/therecipe/qt/gui"
+	"github.com/therecipe/qt/widgets"
+
+	"go-gnome-editor/internal/logic"
+)
+
+// createAIMenu добавляет меню AI с выбором провайдера
+func (e *EditorWindow) createAIMenu(mb *widgets.QMenuBar) {
+	aiMenu := mb.AddMenu2("&AI")
+
+	providerMenu := aiMenu.AddMenu2("&Provider")
+	// Список строится при каждом открытии: профили могли измениться
+	providerMenu.ConnectAboutToShow(func() {
+		e.rebuildProviderMenu(providerMenu)
+	})
+	e.rebuildProviderMenu(providerMenu)
+
+	actTasks := aiMenu.AddAction("&Models per Task...")
+	actTasks.SetToolTip("Provider, model and timeout for chat, line/multi-line completion and comment generation")
+	actTasks.ConnectTriggered(func(bool) { e.showAITasksDialog() })
+
+	actPrompt := aiMenu.AddAction("&System Prompt...")
+	actPrompt.SetToolTip("Edit the system prompt template; a project can override it with .golite/prompt.md")
+	actPrompt.ConnectTriggered(func(bool) { e.showSystemPromptDialog() })
+
+	actKeys := aiMenu.AddAction("Manage API &Keys...")
+	actKeys.SetToolTip("Store provider API keys in the Secret Service or a private key file")
+	actKeys.ConnectTriggered(func(bool) { e.showAPIKeysDialog() })
+
+	aiMenu.AddSeparator()
+	actRedact := aiMenu.AddAction("&Redact Secrets Before Sending")
+	e.settingsActions.redact = actRedact
+	actRedact.SetCheckable(true)
+	actRedact.SetChecked(e.Config.RedactSecrets)
+	actRedact.SetToolTip("Mask API keys, tokens, private keys and .env values for providers not in redact_allow_providers")
+	actRedact.ConnectTriggered(func(checked bool) {
+		e.Config.RedactSecrets = checked
+		if err := logic.SetRedaction(e.Config.Redaction()); err != nil {
+			e.Window.StatusBar().ShowMessage(err.Error(), 5000)
+		}
+		e.saveConfig()
+		if checked {
+			e.Window.StatusBar().ShowMessage("Secrets are redacted before sending to remote providers", 3000)
+		} else {
+			e.Window.StatusBar().ShowMessage("Secret redaction disabled: context is sent verbatim", 3000)
+		}
+	})
+
+	aiMenu.AddSeparator()
+	actReview := aiMenu.AddAction("Review My &Changes")
+	actReview.SetShortcut(gui.NewQKeySequence2("Ctrl+Shift+R", gui.QKeySequence__NativeText))
+	actReview.SetToolTip("Send the uncommitted git changes (staged and unstaged) with the changed functions to the AI;\nfindings are listed in the Review Findings panel")
+	actReview.ConnectTriggered(func(bool) { e.reviewChanges() })
+
+	e.fillCodeIndexMenu(aiMenu.AddMenu2("Code &Index"))
+
+	aiMenu.AddSeparator()
+	actInspector := aiMenu.AddAction("LLM &Inspector")
+	actInspector.SetToolTip("Browse the prompts and raw responses of recent requests and re-send them")
+	actInspector.ConnectTriggered(func(bool) {
+		e.inspector.dock.SetVisible(!e.inspector.dock.IsVisible())
+	})
+
+	actClearCache := aiMenu.AddAction("Clear Response &Cache")
+	actClearCache.SetToolTip("Forget cached answers to identical completion requests")
+	actClearCache.ConnectTriggered(func(bool) {
+		n := logic.ResponseCacheLen()
+		if err := logic.ClearResponseCache(); err != nil {
+			e.Window.StatusBar().ShowMessage(err.Error(), 5000)
+			return
+		}
+		e.Window.StatusBar().ShowMessage(fmt.Sprintf("Response cache cleared (%d entries)", n), 3000)
+	})
+
+	actLog := aiMenu.AddAction("&Log Requests to File")
+	e.settingsActions.requestLog = actLog
+	actLog.SetCheckable(true)
+	actLog.SetChecked(e.Config.RequestLog)
+	actLog.SetToolTip("Append every request (prompt, raw response, latency, status) to a JSONL file")
+	actLog.ConnectTriggered(func(checked bool) {
+		e.Config.RequestLog = checked
+		logic.SetRequestLog(e.Config.RequestLogFile())
+		e.saveConfig()
+		if checked {
+			e.Window.StatusBar().ShowMessage("Logging LLM requests to "+e.Config.RequestLogFile(), 5000)
+		} else {
+			e.Window.StatusBar().ShowMessage("LLM request log disabled", 2000)
+		}
+	})
+}
+
+// rebuildProviderMenu заполняет меню провайдеров и профилей, отмечая текущий
+func (e *EditorWindow) rebuildProviderMenu(menu *widgets.QMenu) {
+	menu.Clear()
+	group := widgets.NewQActionGroup(menu)
+	group.SetExclusive(true)
+
+	addItem := func(title, name, model string) {
+		act := menu.AddAction(title)
+		act.SetCheckable(true)
+		act.SetChecked(name == e.LLMProvider)
+		group.AddAction(act)
+		act.ConnectTriggered(func(bool) { e.selectProvider(name, model) })
+	}
+
+	for _, name := range logic.ProviderNames() {
+		addItem(name, name, "")
+	}
+
+	if profiles := logic.ProviderProfiles(); len(profiles) > 0 {
+		menu.AddSeparator()
+		for _, p := range profiles {
+			title := p.Name
+			if p.Model != "" {
+				title = fmt.Sprintf("%s (%s)", p.Name, p.Model)
+			}
+			addItem(title, p.Name, p.Model)
+		}
+	}
+
+	menu.AddSeparator()
+
+	actURL := menu.AddAction("Custom URL...")
+	if isURLProvider(e.LLMProvider) {
+		actURL.SetCheckable(true)
+		actURL.SetChecked(true)
+		group.AddAction(actURL)
+	}
+	actURL.ConnectTriggered(func(bool) {
+		dlg := widgets.NewQInputDialog(e.Window, core.Qt__Dialog)
+		dlg.SetWindowTitle("Custom Provider URL")
+		dlg.SetLabelText("OpenAI-compatible chat completions URL:")
+		if isURLProvider(e.LLMProvider) {
+			dlg.SetTextValue(e.LLMProvider)
+		}
+		dlg.SetInputMode(widgets.QInputDialog__TextInput)
+		if dlg.Exec() == int(widgets.QDialog__Accepted) && dlg.TextValue() != "" {
+			e.selectProvider(dlg.TextValue(), "")
+		}
+	})
+
+	actEdit := menu.AddAction("Edit Profiles...")
+	actEdit.ConnectTriggered(func(bool) { e.editProviderProfiles() })
+
+	actReload := menu.AddAction("Reload Profiles")
+	actReload.ConnectTriggered(func(bool) { e.reloadProviderProfiles() })
+}
+
+// selectProvider делает провайдер (или профиль) текущим и сохраняет выбор.
+// model, если задан, заменяет текущую модель; иначе восстанавливается
+// последняя модель, выбранная для этого провайдера.
+func (e *EditorWindow) selectProvider(name, model string) {
+	if err := logic.ValidateProviderName(name); err != nil {
+		widgets.QMessageBox_Warning(e.Window, "Provider", err.Error(), widgets.QMessageBox__Ok, widgets.QMessageBox__Ok)
+		return
+	}
+
+	e.LLMProvider = name
+	e.Config.Provider = name
+	// Без модели профиля берём последнюю модель, выбранную для этого провайдера
+	if model == "" {
+		model = e.Config.LastModel(name)
+	}
+	if model != "" {
+		e.LLMModel = model
+		e.Config.Model = model
+	}
+	e.saveConfig()
+	e.UpdateAIContextDisplay()
+
+	// Список моделей относится к прежнему провайдеру; незавершённый запрос списка игнорируем
+	e.modelsLoadedFor = ""
+	e.modelListRequest++
+	if e.AIModelCombo != nil {
+		e.setModelList(nil)
+		if e.AIDock.IsVisible() {
+			e.refreshModelList()
+		}
+	}
+
+	e.Window.StatusBar().ShowMessage(fmt.Sprintf("AI provider: %s, model: %s", e.LLMProvider, e.LLMModel), 3000)
+}
+
+// editProviderProfiles открывает глобальный config.json во вкладке редактора.
+// После сохранения файла профили перечитываются автоматически.
+func (e *EditorWindow) editProviderProfiles() {
+	path := e.Config.Path()
+	if path == "" || !fileExists(path) {
+		e.saveConfig()
+		path = e.Config.Path()
+	}
+	if path == "" {
+		return
+	}
+	e.TabManager.OpenFile(path)
+}
+
+// reloadProviderProfiles перечитывает профили из глобального файла настроек
+func (e *EditorWindow) reloadProviderProfiles() {
+	cfg, err := logic.LoadConfig()
+	if err != nil {
+		e.Window.StatusBar().ShowMessage(fmt.Sprintf("Failed to load settings: %v", err), 5000)
+		return
+	}
+	e.Config.Profiles = cfg.Profiles
+	if err := logic.SetProviderProfiles(cfg.Profiles); err != nil {
+		e.Window.StatusBar().ShowMessage(err.Error(), 5000)
+		return
+	}
+	e.Window.StatusBar().ShowMessage(fmt.Sprintf("Loaded %d provider profiles", len(cfg.Profiles)), 3000)
+}
+
+// onFileSaved вызывается после сохранения файла в редакторе
+func (e *EditorWindow) onFileSaved(path string) {
+	if e.Config != nil && path == e.Config.Path() {
+		e.reloadProviderProfiles()
+	}
+	if e.ProjectManager.IsActive && path == logic.ProjectPromptPath(e.ProjectManager.RootPath) {
+		e.loadProjectPrompt(e.ProjectManager.RootPath)
+	}
+	e.updateCodeIndex(path)
+}
+
+func isURLProvider(name string) bool {
+	return strings.HasPrefix(name, "http://") || strings.HasPrefix(name, "https://")
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
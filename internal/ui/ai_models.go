@@ -0,0 +1,119 @@
+package ui
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/therecipe/qt/widgets"
+
+	"go-gnome-editor/internal/logic"
+)
+
+// createModelPicker создаёт строку выбора модели для панели AI:
+// редактируемый список моделей текущего провайдера и кнопку обновления списка
+func (e *EditorWindow) createModelPicker() *widgets.QHBoxLayout {
+	row := widgets.NewQHBoxLayout()
+	row.SetContentsMargins(0, 0, 0, 0)
+
+	row.AddWidget(widgets.NewQLabel2("Model:", nil, 0), 0, 0)
+
+	e.AIModelCombo = widgets.NewQComboBox(nil)
+	e.AIModelCombo.SetEditable(true)
+	// Введённое вручную имя не добавляем в список — он отражает ответ провайдера
+	e.AIModelCombo.SetInsertPolicy(widgets.QComboBox__NoInsert)
+	e.AIModelCombo.SetToolTip("Model used by the AI assistant and completions.\nType a name or pick one reported by the provider.")
+	e.AIModelCombo.ConnectActivated2(func(text string) {
+		e.selectModel(strings.TrimSpace(text))
+	})
+	row.AddWidget(e.AIModelCombo, 1, 0)
+
+	btnRefresh := widgets.NewQPushButton2("⟳", nil)
+	btnRefresh.SetToolTip("Fetch the model list from the provider")
+	btnRefresh.SetMaximumWidth(30)
+	btnRefresh.ConnectClicked(func(bool) { e.refreshModelList() })
+	row.AddWidget(btnRefresh, 0, 0)
+
+	e.syncModelPicker()
+	return row
+}
+
+// syncModelPicker показывает в списке текущую модель
+func (e *EditorWindow) syncModelPicker() {
+	if e.AIModelCombo == nil {
+		return
+	}
+	if e.AIModelCombo.FindText(e.LLMModel, 0) < 0 && e.LLMModel != "" {
+		e.AIModelCombo.AddItems([]string{e.LLMModel})
+	}
+	e.AIModelCombo.SetCurrentText(e.LLMModel)
+}
+
+// ensureModelList загружает список моделей, если он ещё не загружен для текущего провайдера
+func (e *EditorWindow) ensureModelList() {
+	if e.modelsLoadedFor != e.LLMProvider {
+		e.refreshModelList()
+	}
+}
+
+// refreshModelList запрашивает список моделей у текущего провайдера в фоне
+func (e *EditorWindow) refreshModelList() {
+	if e.AIModelCombo == nil {
+		return
+	}
+
+	provider, key := e.LLMProvider, e.explicitKey()
+	e.modelsLoadedFor = provider
+	e.modelListRequest++
+	requestID := e.modelListRequest
+
+	e.Window.StatusBar().ShowMessage(fmt.Sprintf("Fetching models from %s...", provider), 0)
+
+	go func() {
+		models, err := logic.ListModels(context.Background(), provider, key)
+		e.RunOnUIThread(func() {
+			// Провайдер успели сменить — ответ уже не нужен
+			if requestID != e.modelListRequest {
+				return
+			}
+			if err != nil {
+				if errors.Is(err, logic.ErrModelListUnsupported) {
+					e.Window.StatusBar().ShowMessage(fmt.Sprintf("%s: model list is not available, type the model name", provider), 4000)
+				} else {
+					e.Window.StatusBar().ShowMessage(fmt.Sprintf("Failed to fetch models: %v", err), 5000)
+				}
+				// Повторим при следующем открытии панели
+				e.modelsLoadedFor = ""
+				return
+			}
+			e.setModelList(models)
+			e.Window.StatusBar().ShowMessage(fmt.Sprintf("%d model(s) available from %s", len(models), provider), 3000)
+		})
+	}()
+}
+
+// setModelList заменяет содержимое списка, сохраняя выбранную модель
+func (e *EditorWindow) setModelList(models []string) {
+	e.AIModelCombo.BlockSignals(true)
+	e.AIModelCombo.Clear()
+	e.AIModelCombo.AddItems(models)
+	e.AIModelCombo.BlockSignals(false)
+	e.syncModelPicker()
+}
+
+// selectModel делает модель текущей и запоминает её как последнюю для провайдера
+func (e *EditorWindow) selectModel(model string) {
+	if model == "" || model == e.LLMModel {
+		return
+	}
+
+	e.LLMModel = model
+	e.Config.Model = model
+	e.Config.SetLastModel(e.LLMProvider, model)
+	e.saveConfig()
+
+	e.syncModelPicker()
+	e.UpdateAIContextDisplay()
+	e.Window.StatusBar().ShowMessage(fmt.Sprintf("AI model: %s", model), 3000)
+}
@@ -0,0 +1,198 @@
+package ui
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/therecipe/qt/core"
+	"github.com/therecipe/qt/gui"
+	"github.com/therecipe/qt/widgets"
+
+	"go-gnome-editor/internal/logic"
+)
+
+// promptLibrary загружает библиотеку промптов с учётом текущего проекта
+func (e *EditorWindow) promptLibrary() []logic.PromptTemplate {
+	root := ""
+	if e.ProjectManager.IsActive {
+		root = e.ProjectManager.RootPath
+	}
+	library, err := logic.LoadPromptLibrary(root)
+	if err != nil {
+		e.Window.StatusBar().ShowMessage(err.Error(), 5000)
+	}
+	return library
+}
+
+// slashVars собирает выделение и сведения о текущем файле для шаблона команды
+func (e *EditorWindow) slashVars(input string) logic.SlashVars {
+	vars := logic.SlashVars{Input: input}
+	ed := e.TabManager.CurrentEditor()
+	if ed == nil {
+		return vars
+	}
+	vars.File = "Untitled"
+	if ed.FilePath != "" {
+		vars.File = filepath.Base(ed.FilePath)
+	}
+	vars.Language = e.TabManager.detectLanguageFromPath(ed.FilePath)
+	// Qt разделяет строки выделения символом U+2029
+	vars.Selection = strings.ReplaceAll(ed.TextEdit.TextCursor().SelectedText(), "\u2029", "\n")
+	return vars
+}
+
+// expandSlashCommand раскрывает "/команда текст" в промпт из библиотеки.
+// Текст без команды возвращается как есть; "//..." отправляется как "/...".
+// ok == false — неизвестная команда, сообщение уже показано.
+func (e *EditorWindow) expandSlashCommand(text string) (prompt string, ok bool) {
+	if trimmed := strings.TrimLeft(text, " \t"); strings.HasPrefix(trimmed, "//") {
+		return trimmed[1:], true
+	}
+	name, rest, isCommand := logic.ParseSlashCommand(text)
+	if !isCommand {
+		return text, true
+	}
+
+	library := e.promptLibrary()
+	p, found := logic.FindPrompt(library, name)
+	if !found {
+		names := make([]string, len(library))
+		for i, p := range library {
+			names[i] = "/" + p.Name
+		}
+		e.Window.StatusBar().ShowMessage(fmt.Sprintf("Unknown command /%s (available: %s; start with // to send text beginning with /)",
+			name, strings.Join(names, " ")), 8000)
+		return "", false
+	}
+	return logic.ExpandPrompt(p, e.slashVars(rest)), true
+}
+
+// createPromptPicker создаёт кнопку с меню библиотеки промптов
+func (e *EditorWindow) createPromptPicker() *widgets.QPushButton {
+	btn := widgets.NewQPushButton2("/", nil)
+	btn.SetToolTip("Prompt library: insert a slash command (/explain, /test, /doc, /fix, /review, /refactor, ...)")
+	btn.SetMaximumWidth(40)
+
+	menu := widgets.NewQMenu(btn)
+	// Файлы библиотеки могли измениться — меню строится при каждом открытии
+	menu.ConnectAboutToShow(func() { e.rebuildPromptMenu(menu) })
+	btn.SetMenu(menu)
+	return btn
+}
+
+// rebuildPromptMenu заполняет меню командами и действиями редактирования библиотеки
+func (e *EditorWindow) rebuildPromptMenu(menu *widgets.QMenu) {
+	menu.Clear()
+	library := e.promptLibrary()
+
+	for _, p := range library {
+		name := p.Name
+		title := "/" + name
+		if p.Description != "" {
+			title += " — " + p.Description
+		}
+		act := menu.AddAction(title)
+		act.SetToolTip(p.Template)
+		act.ConnectTriggered(func(bool) { e.insertSlashCommand(name) })
+	}
+
+	menu.AddSeparator()
+	editMenu := menu.AddMenu2("&Edit Prompt")
+	for _, p := range library {
+		p := p
+		title := p.Name
+		if p.Source != logic.PromptFromUser {
+			title += fmt.Sprintf(" (%s)", p.Source)
+		}
+		editMenu.AddAction(title).ConnectTriggered(func(bool) { e.editPromptTemplate(p) })
+	}
+
+	menu.AddAction("&New Prompt...").ConnectTriggered(func(bool) { e.newPromptTemplate() })
+	menu.AddAction("Open Library &Folder").ConnectTriggered(func(bool) {
+		dir, err := logic.PromptLibraryDir()
+		if err == nil {
+			err = os.MkdirAll(dir, 0755)
+		}
+		if err != nil {
+			e.Window.StatusBar().ShowMessage(err.Error(), 5000)
+			return
+		}
+		gui.QDesktopServices_OpenUrl(core.QUrl_FromLocalFile(dir))
+	})
+}
+
+// insertSlashCommand ставит "/name " в начало поля ввода AI
+func (e *EditorWindow) insertSlashCommand(name string) {
+	text := e.AIInput.ToPlainText()
+	if _, rest, ok := logic.ParseSlashCommand(text); ok {
+		text = rest
+	}
+	e.AIInput.SetPlainText("/" + name + " " + strings.TrimLeft(text, " \t"))
+	cursor := e.AIInput.TextCursor()
+	cursor.MovePosition(gui.QTextCursor__End, gui.QTextCursor__MoveAnchor, 1)
+	e.AIInput.SetTextCursor(cursor)
+	e.AIInput.SetFocus2()
+}
+
+// editPromptTemplate открывает файл шаблона; встроенный сначала копируется
+// в пользовательскую библиотеку, где его можно изменить
+func (e *EditorWindow) editPromptTemplate(p logic.PromptTemplate) {
+	path := p.Path
+	if path == "" {
+		dir, err := logic.PromptLibraryDir()
+		if err == nil {
+			path, err = logic.WritePromptTemplate(dir, p)
+		}
+		if err != nil {
+			e.Window.StatusBar().ShowMessage(err.Error(), 5000)
+			return
+		}
+	}
+	e.TabManager.OpenFile(path)
+}
+
+// newPromptTemplate создаёт шаблон в библиотеке проекта (если проект открыт) или пользователя
+func (e *EditorWindow) newPromptTemplate() {
+	ok := false
+	name := widgets.QInputDialog_GetText(e.Window, "New Prompt", "Command name (used as /name):",
+		widgets.QLineEdit__Normal, "", &ok, core.Qt__Dialog, core.Qt__ImhNone)
+	name = strings.TrimPrefix(strings.TrimSpace(name), "/")
+	if !ok || name == "" {
+		return
+	}
+
+	dir, err := logic.PromptLibraryDir()
+	if e.ProjectManager.IsActive {
+		dir, err = logic.ProjectPromptLibraryDir(e.ProjectManager.RootPath), nil
+	}
+	if err != nil {
+		e.Window.StatusBar().ShowMessage(err.Error(), 5000)
+		return
+	}
+	if fileExists(filepath.Join(dir, strings.ToLower(name)+".md")) {
+		e.Window.StatusBar().ShowMessage(fmt.Sprintf("Prompt /%s already exists", name), 5000)
+		return
+	}
+
+	path, err := logic.WritePromptTemplate(dir, logic.PromptTemplate{
+		Name:        name,
+		Description: "Describe the command here",
+		Template:    "Describe the task for {{target}} here.\n{{input}}\n{{selection}}",
+	})
+	if err != nil {
+		e.Window.StatusBar().ShowMessage(err.Error(), 5000)
+		return
+	}
+	e.TabManager.OpenFile(path)
+	e.Window.StatusBar().ShowMessage("Variables: {{target}} {{selection}} {{file}} {{language}} {{input}}", 8000)
+}
+
+// firstLine возвращает первую строку текста (с многоточием, если строк больше)
+func firstLine(text string) string {
+	if line, _, more := strings.Cut(strings.TrimSpace(text), "\n"); more {
+		return line + " …"
+	}
+	return strings.TrimSpace(text)
+}
@@ -0,0 +1,151 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/therecipe/qt/core"
+	"github.com/therecipe/qt/widgets"
+
+	"go-gnome-editor/internal/logic"
+)
+
+// taskRow — строка диалога настроек одного вида запросов
+type taskRow struct {
+	task     logic.AITask
+	provider *widgets.QComboBox
+	model    *widgets.QLineEdit
+	timeout  *widgets.QSpinBox
+}
+
+// showAITasksDialog показывает диалог выбора провайдера, модели и таймаута
+// для чата, дополнения строки, многострочного дополнения и генерации по комментарию
+func (e *EditorWindow) showAITasksDialog() {
+	dlg := widgets.NewQDialog(e.Window, core.Qt__Dialog)
+	dlg.SetWindowTitle("AI Models per Task")
+
+	layout := widgets.NewQVBoxLayout()
+	hint := widgets.NewQLabel2("Empty fields use the chat settings. Timeout 0 = default.", nil, 0)
+	hint.SetStyleSheet("color: #888;")
+	layout.AddWidget(hint, 0, 0)
+
+	grid := widgets.NewQGridLayout2()
+	for col, title := range []string{"Task", "Provider", "Model", "Timeout"} {
+		grid.AddWidget2(widgets.NewQLabel2("<b>"+title+"</b>", nil, 0), 0, col, 0)
+	}
+
+	providers := append([]string{}, logic.ProviderNames()...)
+	for _, p := range logic.ProviderProfiles() {
+		providers = append(providers, p.Name)
+	}
+
+	var rows []taskRow
+	for i, task := range logic.AITasks {
+		settings := e.aiTasks[task]
+		row := taskRow{
+			task:     task,
+			provider: widgets.NewQComboBox(nil),
+			model:    widgets.NewQLineEdit(nil),
+			timeout:  widgets.NewQSpinBox(nil),
+		}
+
+		row.provider.SetEditable(true)
+		row.provider.SetInsertPolicy(widgets.QComboBox__NoInsert)
+		if task == logic.TaskChat {
+			row.provider.AddItems(providers)
+			row.provider.SetCurrentText(e.LLMProvider)
+			row.model.SetText(e.LLMModel)
+		} else {
+			row.provider.AddItems(append([]string{""}, providers...))
+			row.provider.SetCurrentText(settings.Provider)
+			row.provider.LineEdit().SetPlaceholderText("same as chat")
+			row.model.SetText(settings.Model)
+			row.model.SetPlaceholderText("same as chat")
+			if task == logic.TaskEmbed {
+				row.model.SetPlaceholderText("default embedding model")
+			}
+		}
+
+		row.timeout.SetRange(0, 3600)
+		row.timeout.SetSuffix(" s")
+		row.timeout.SetSpecialValueText(fmt.Sprintf("default (%s)", task.DefaultTimeout()))
+		row.timeout.SetValue(settings.TimeoutSec)
+
+		grid.AddWidget2(widgets.NewQLabel2(task.Title(), nil, 0), i+1, 0, 0)
+		grid.AddWidget2(row.provider, i+1, 1, 0)
+		grid.AddWidget2(row.model, i+1, 2, 0)
+		grid.AddWidget2(row.timeout, i+1, 3, 0)
+		rows = append(rows, row)
+	}
+	layout.AddLayout(grid, 0)
+
+	if len(e.CLIOverrides.Tasks) > 0 {
+		note := widgets.NewQLabel2("Command line flags for tasks take precedence until restart.", nil, 0)
+		note.SetStyleSheet("color: #db4;")
+		layout.AddWidget(note, 0, 0)
+	}
+
+	buttons := widgets.NewQDialogButtonBox3(widgets.QDialogButtonBox__Ok|widgets.QDialogButtonBox__Cancel, nil)
+	buttons.ConnectAccepted(dlg.Accept)
+	buttons.ConnectRejected(dlg.Reject)
+	layout.AddWidget(buttons, 0, 0)
+	dlg.SetLayout(layout)
+	dlg.Resize2(640, 220)
+
+	// Диалог остаётся открытым, пока введены неизвестные провайдеры
+	for dlg.Exec() == int(widgets.QDialog__Accepted) {
+		if err := e.applyAITaskRows(rows); err != nil {
+			widgets.QMessageBox_Warning(e.Window, "AI Models per Task", err.Error(), widgets.QMessageBox__Ok, widgets.QMessageBox__Ok)
+			continue
+		}
+		return
+	}
+}
+
+// applyAITaskRows проверяет и сохраняет значения диалога
+func (e *EditorWindow) applyAITaskRows(rows []taskRow) error {
+	for _, row := range rows {
+		if name := strings.TrimSpace(row.provider.CurrentText()); name != "" {
+			if err := logic.ValidateProviderName(name); err != nil {
+				return fmt.Errorf("%s: %w", row.task.Title(), err)
+			}
+		} else if row.task == logic.TaskChat {
+			return fmt.Errorf("%s: provider is required", row.task.Title())
+		}
+	}
+
+	if e.Config.Tasks == nil {
+		e.Config.Tasks = map[logic.AITask]logic.TaskModel{}
+	}
+
+	for _, row := range rows {
+		provider := strings.TrimSpace(row.provider.CurrentText())
+		model := strings.TrimSpace(row.model.Text())
+		settings := e.Config.Tasks[row.task]
+		settings.TimeoutSec = row.timeout.Value()
+
+		if row.task == logic.TaskChat {
+			// Провайдер и модель чата — основные настройки (AI > Provider, список моделей)
+			if provider != e.LLMProvider {
+				e.selectProvider(provider, model)
+			} else {
+				e.selectModel(model)
+			}
+		} else {
+			settings.Provider = provider
+			settings.Model = model
+		}
+
+		if settings == (logic.TaskModel{}) {
+			delete(e.Config.Tasks, row.task)
+		} else {
+			e.Config.Tasks[row.task] = settings
+		}
+	}
+	e.saveConfig()
+
+	e.setAITasks(e.Config.Tasks)
+
+	e.Window.StatusBar().ShowMessage("AI task settings saved", 3000)
+	return nil
+}
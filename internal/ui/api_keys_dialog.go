@@ -0,0 +1,183 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/therecipe/qt/core"
+	"github.com/therecipe/qt/widgets"
+
+	"go-gnome-editor/internal/logic"
+)
+
+// keyDialogProviders возвращает провайдеры для диалога ключей: встроенные, профили
+// и URL-провайдеры, используемые сейчас
+func (e *EditorWindow) keyDialogProviders() []string {
+	names := append([]string{}, logic.ProviderNames()...)
+	for _, p := range logic.ProviderProfiles() {
+		names = append(names, p.Name)
+	}
+
+	seen := map[string]bool{}
+	for _, n := range names {
+		seen[n] = true
+	}
+	for _, task := range logic.AITasks {
+		if p := e.llmTarget(task).Provider; isURLProvider(p) && !seen[p] {
+			seen[p] = true
+			names = append(names, p)
+		}
+	}
+	return names
+}
+
+// describeKey возвращает описание источника ключа провайдера для таблицы
+func (e *EditorWindow) describeKey(provider string) string {
+	if provider == e.llmKeyProvider && e.LLMKey != "" {
+		if e.CLIOverrides.APIKey != "" {
+			return "--key flag (visible in shell history)"
+		}
+		return "config.json (plaintext)"
+	}
+
+	_, info, err := logic.ResolveAPIKey(provider)
+	if err != nil {
+		return "error: " + err.Error()
+	}
+	switch info.Source {
+	case logic.KeySourceEnv:
+		return "environment: $" + info.Detail
+	case logic.KeySourceSecretService:
+		return info.Detail
+	case logic.KeySourceFile:
+		return "key file"
+	}
+	return "—"
+}
+
+// showAPIKeysDialog показывает диалог управления API ключами провайдеров
+func (e *EditorWindow) showAPIKeysDialog() {
+	dlg := widgets.NewQDialog(e.Window, core.Qt__Dialog)
+	dlg.SetWindowTitle("Manage API Keys")
+	layout := widgets.NewQVBoxLayout()
+
+	keyFile, _ := logic.KeyFilePath()
+	hint := widgets.NewQLabel2(fmt.Sprintf(
+		"Keys are looked up in environment variables, then the Secret Service, then %s (mode 0600).", keyFile), nil, 0)
+	hint.SetWordWrap(true)
+	hint.SetStyleSheet("color: #888;")
+	layout.AddWidget(hint, 0, 0)
+
+	providers := e.keyDialogProviders()
+	table := widgets.NewQTableWidget2(len(providers), 2, nil)
+	table.SetHorizontalHeaderLabels([]string{"Provider", "Key"})
+	table.SetSelectionBehavior(widgets.QAbstractItemView__SelectRows)
+	table.SetSelectionMode(widgets.QAbstractItemView__SingleSelection)
+	table.SetEditTriggers(widgets.QAbstractItemView__NoEditTriggers)
+	table.HorizontalHeader().SetStretchLastSection(true)
+	table.VerticalHeader().SetVisible(false)
+
+	refresh := func() {
+		for i, name := range providers {
+			table.SetItem(i, 0, widgets.NewQTableWidgetItem2(name, 0))
+			table.SetItem(i, 1, widgets.NewQTableWidgetItem2(e.describeKey(name), 0))
+		}
+		table.ResizeColumnToContents(0)
+	}
+	refresh()
+	layout.AddWidget(table, 1, 0)
+
+	// Куда сохранять новые ключи
+	storeRow := widgets.NewQHBoxLayout()
+	storeRow.AddWidget(widgets.NewQLabel2("Store new keys in:", nil, 0), 0, 0)
+	storeCombo := widgets.NewQComboBox(nil)
+	destinations := []logic.KeySource{}
+	if name := logic.SecretStoreName(); name != "" {
+		storeCombo.AddItem(name, core.NewQVariant())
+		destinations = append(destinations, logic.KeySourceSecretService)
+	}
+	storeCombo.AddItem("Key file (0600)", core.NewQVariant())
+	destinations = append(destinations, logic.KeySourceFile)
+	storeRow.AddWidget(storeCombo, 1, 0)
+	layout.AddLayout(storeRow, 0)
+
+	selected := func() string {
+		row := table.CurrentRow()
+		if row < 0 || row >= len(providers) {
+			return ""
+		}
+		return providers[row]
+	}
+	report := func(err error) {
+		if err != nil {
+			widgets.QMessageBox_Warning(dlg, "Manage API Keys", err.Error(), widgets.QMessageBox__Ok, widgets.QMessageBox__Ok)
+		}
+		// Ключ мог измениться — список моделей запросим заново
+		e.modelsLoadedFor = ""
+		refresh()
+	}
+
+	buttons := widgets.NewQHBoxLayout()
+	btnSet := widgets.NewQPushButton2("Set Key...", nil)
+	btnSet.ConnectClicked(func(bool) {
+		provider := selected()
+		if provider == "" {
+			return
+		}
+		ok := false
+		key := widgets.QInputDialog_GetText(dlg, "Set API Key", fmt.Sprintf("API key for %s:", provider),
+			widgets.QLineEdit__Password, "", &ok, core.Qt__Dialog, core.Qt__ImhNone)
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			return
+		}
+		report(logic.StoreAPIKey(provider, key, destinations[storeCombo.CurrentIndex()]))
+	})
+	buttons.AddWidget(btnSet, 0, 0)
+
+	btnRemove := widgets.NewQPushButton2("Remove Key", nil)
+	btnRemove.ConnectClicked(func(bool) {
+		if provider := selected(); provider != "" {
+			report(logic.DeleteAPIKey(provider))
+		}
+	})
+	buttons.AddWidget(btnRemove, 0, 0)
+
+	// Ключ из config.json хранится открытым текстом — предлагаем перенести его
+	if e.Config.APIKey != "" {
+		btnMove := widgets.NewQPushButton2("Move config.json Key", nil)
+		btnMove.SetToolTip("Store the plaintext api_key from config.json securely and remove it from the file")
+		btnMove.ConnectClicked(func(bool) {
+			err := e.moveConfigKey(destinations[storeCombo.CurrentIndex()])
+			if err == nil {
+				btnMove.SetEnabled(false)
+			}
+			report(err)
+		})
+		buttons.AddWidget(btnMove, 0, 0)
+	}
+
+	buttons.AddStretch(1)
+	btnClose := widgets.NewQPushButton2("Close", nil)
+	btnClose.ConnectClicked(func(bool) { dlg.Accept() })
+	buttons.AddWidget(btnClose, 0, 0)
+	layout.AddLayout(buttons, 0)
+
+	dlg.SetLayout(layout)
+	dlg.Resize2(620, 360)
+	dlg.Exec()
+}
+
+// moveConfigKey переносит api_key из config.json в защищённое хранилище
+func (e *EditorWindow) moveConfigKey(dest logic.KeySource) error {
+	if err := logic.StoreAPIKey(e.Config.Provider, e.Config.APIKey, dest); err != nil {
+		return err
+	}
+	e.Config.APIKey = ""
+	e.saveConfig()
+	if e.CLIOverrides.APIKey == "" {
+		e.LLMKey = ""
+	}
+	e.Window.StatusBar().ShowMessage("API key moved out of config.json", 3000)
+	return nil
+}
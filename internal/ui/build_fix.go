@@ -0,0 +1,126 @@
+package ui
+
+import (
+	"fmt"
+	"html"
+	"strings"
+
+	"go-gnome-editor/internal/logic"
+)
+
+// buildFixLink — ссылка повторного просмотра исправления: buildfix:N
+const buildFixLink = "buildfix:"
+
+// lastRunOutput возвращает вывод последнего запуска из панели Output
+func lastRunOutput(text string) string {
+	start := max(strings.LastIndex(text, "--- Starting: "), strings.LastIndex(text, "--- Agent: "))
+	if start < 0 {
+		return text
+	}
+	return text[start:]
+}
+
+// fixBuildErrors — кнопка "Fix with AI" панели вывода: ошибки последнего запуска
+// и код вокруг них отправляются в чат, а ответ показывается как diff по файлам
+func (e *EditorWindow) fixBuildErrors() {
+	root := ""
+	if e.ProjectManager.IsActive {
+		root = e.ProjectManager.RootPath
+	}
+	dir := e.lastRunDir
+	if dir == "" {
+		dir = root
+	}
+	errs := logic.ParseBuildErrors(lastRunOutput(e.OutputText.ToPlainText()), dir, root)
+	if len(errs) == 0 {
+		e.Window.StatusBar().ShowMessage("No Go error locations (file.go:line:col) found in the output", 4000)
+		return
+	}
+
+	files := logic.BuildFixFiles(errs)
+	names := make([]string, len(files))
+	for i, f := range files {
+		names[i] = e.relProjectPath(f)
+	}
+	display := fmt.Sprintf("Fix with AI: %d error(s) in %s", len(errs), strings.Join(names, ", "))
+	prompt := logic.BuildFixPrompt(errs, root, e.bufferOrFileText)
+
+	e.AIDock.Show()
+	chat := e.currentAIChat()
+	if chat.busy() {
+		e.Window.StatusBar().ShowMessage("Wait for the running AI request in this chat to finish or stop it", 3000)
+		return
+	}
+	chat.view.Append("<b>You:</b> " + html.EscapeString(display))
+	e.sendAIRequest(chat, display, prompt, prompt, nil, func(resp string) {
+		fixes := logic.ParseBuildFix(resp, root, files)
+		if len(fixes) == 0 {
+			chat.view.Append("<span style='color:#888; font-size:10px;'>The answer has no code for the files with errors</span>")
+			return
+		}
+		chat.view.Append(fmt.Sprintf("<a href='%s%d'>± Review fix (%d file(s))</a>", buildFixLink, len(chat.buildFixes), len(fixes)))
+		chat.buildFixes = append(chat.buildFixes, fixes)
+		e.reviewBuildFix(fixes)
+	})
+}
+
+// reviewBuildFixLink снова показывает исправление по ссылке buildfix:N
+func (e *EditorWindow) reviewBuildFixLink(chat *aiChatTab, url string) {
+	var index int
+	if _, err := fmt.Sscanf(strings.TrimPrefix(url, buildFixLink), "%d", &index); err != nil || index < 0 || index >= len(chat.buildFixes) {
+		e.Window.StatusBar().ShowMessage("Fix not found", 2000)
+		return
+	}
+	e.reviewBuildFix(chat.buildFixes[index])
+}
+
+// reviewBuildFix по очереди показывает изменения каждого файла в окне сравнения;
+// принятые участки попадают в буфер редактора одним шагом отмены на файл
+func (e *EditorWindow) reviewBuildFix(fixes []logic.FileFix) {
+	changed := 0
+	for i, fix := range fixes {
+		ed := e.findEditor(fix.File)
+		if ed == nil {
+			e.TabManager.OpenFile(fix.File)
+			if ed = e.findEditor(fix.File); ed == nil {
+				continue
+			}
+		} else {
+			e.TabManager.Tabs.SetCurrentIndex(e.TabManager.getTabIndex(ed))
+		}
+
+		proposed, note := e.mergeBuildFix(ed, fix.Code)
+		note = fmt.Sprintf("Fix with AI — file %d of %d, %s. %s", i+1, len(fixes), e.relProjectPath(fix.File), note)
+		if applied, _ := e.showDiffDialog(ed, note, proposed); applied > 0 {
+			changed++
+		}
+	}
+	if changed > 0 {
+		e.Window.StatusBar().ShowMessage(fmt.Sprintf("Fix applied to %d of %d file(s) — save and run again", changed, len(fixes)), 5000)
+	}
+}
+
+// mergeBuildFix подставляет исправленный код в текст редактора: для Go — вместо
+// объявлений с теми же именами, без совпадений — в конец файла
+func (e *EditorWindow) mergeBuildFix(ed *CodeEditorTab, code string) (string, string) {
+	current := ed.TextEdit.ToPlainText()
+	if e.TabManager.detectLanguageFromPath(ed.FilePath) != "Go" {
+		if strings.HasSuffix(current, "\n") && !strings.HasSuffix(code, "\n") {
+			code += "\n"
+		}
+		return code, "Comparing with the whole file"
+	}
+
+	res, err := logic.MergeGoFix(current, code)
+	switch {
+	case err != nil:
+		return strings.TrimRight(current, "\n") + "\n\n" + code + "\n",
+			fmt.Sprintf("No matching declaration (%v) — the code is added at the end of the file", err)
+	case res.WholeFile:
+		if strings.HasSuffix(current, "\n") && !strings.HasSuffix(code, "\n") {
+			code += "\n"
+		}
+		return code, "The fix is a complete file — comparing with the whole file"
+	}
+	return res.Text, goMergeNote(res)
+}
@@ -0,0 +1,240 @@
+package ui
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/therecipe/qt/widgets"
+
+	"go-gnome-editor/internal/logic"
+)
+
+// indexSearchTimeout ограничивает поиск по индексу перед отправкой вопроса
+const indexSearchTimeout = 20 * time.Second
+
+// fillCodeIndexMenu добавляет действия с индексом кода (AI > Code Index)
+func (e *EditorWindow) fillCodeIndexMenu(menu *widgets.QMenu) {
+	actUse := menu.AddAction("&Use in Chat")
+	e.settingsActions.codeIndex = actUse
+	actUse.SetCheckable(true)
+	actUse.SetChecked(e.UseCodeIndex)
+	actUse.SetToolTip("Add the project code most relevant to each question, found by embeddings")
+	actUse.ConnectTriggered(func(checked bool) {
+		e.UseCodeIndex = checked
+		e.Config.AICodeIndex = checked
+		e.saveConfig()
+		e.lastIndexHits = nil
+		e.UpdateAIContextDisplay()
+		switch ix := e.projectCodeIndex(); {
+		case !checked:
+			e.Window.StatusBar().ShowMessage("Code index disabled", 2000)
+		case ix != nil && !ix.BuiltWith(e.indexTarget()):
+			e.Window.StatusBar().ShowMessage("The code index is not built for the current embedding model — use AI > Code Index > Rebuild Index", 5000)
+		default:
+			e.Window.StatusBar().ShowMessage("Relevant code from the index is added to chat questions", 3000)
+		}
+	})
+
+	menu.AddSeparator()
+	menu.AddAction("&Rebuild Index").ConnectTriggered(func(bool) { e.rebuildCodeIndex() })
+	actStop := menu.AddAction("&Stop Indexing")
+	actStop.ConnectTriggered(func(bool) {
+		if e.indexCancel != nil {
+			e.indexCancel()
+		}
+	})
+	menu.AddAction("&Clear Index").ConnectTriggered(func(bool) { e.clearCodeIndex() })
+	menu.ConnectAboutToShow(func() { actStop.SetEnabled(e.indexCancel != nil) })
+}
+
+// indexTarget — провайдер и модель эмбеддингов (вид запроса "embed"); если модель
+// не задана, берётся модель эмбеддингов провайдера по умолчанию, а не модель чата
+func (e *EditorWindow) indexTarget() logic.LLMTarget {
+	target := e.llmTarget(logic.TaskEmbed)
+	if e.aiTasks[logic.TaskEmbed].Model == "" {
+		target.Model = logic.DefaultEmbeddingModel(target.Provider)
+	}
+	return target
+}
+
+// projectCodeIndex возвращает индекс открытого проекта, загружая его при первом
+// обращении или после смены проекта (nil — проект не открыт)
+func (e *EditorWindow) projectCodeIndex() *logic.CodeIndex {
+	if !e.ProjectManager.IsActive {
+		return nil
+	}
+	root := e.ProjectManager.RootPath
+	if e.codeIndex == nil || e.codeIndex.Root() != root {
+		if e.indexCancel != nil {
+			e.indexCancel()
+			e.indexCancel = nil
+		}
+		ix, err := logic.LoadCodeIndex(root)
+		if err != nil {
+			e.Window.StatusBar().ShowMessage(err.Error(), 5000)
+		}
+		e.codeIndex = ix
+		e.lastIndexHits = nil
+	}
+	return e.codeIndex
+}
+
+// rebuildCodeIndex индексирует изменённые Go-файлы проекта в фоне
+func (e *EditorWindow) rebuildCodeIndex() {
+	ix := e.projectCodeIndex()
+	if ix == nil {
+		e.Window.StatusBar().ShowMessage("Open a project folder to build the code index", 3000)
+		return
+	}
+	if e.indexCancel != nil {
+		e.Window.StatusBar().ShowMessage("The code index is already being built", 3000)
+		return
+	}
+
+	target := e.indexTarget()
+	ctx, cancel := context.WithCancel(context.Background())
+	e.indexCancel = cancel
+	e.Window.StatusBar().ShowMessage(fmt.Sprintf("⏳ Indexing project with %s/%s...", target.Provider, target.Model), 0)
+
+	go func() {
+		err := ix.Rebuild(ctx, target, func(done, total int) {
+			e.RunOnUIThread(func() {
+				if ctx.Err() == nil {
+					e.Window.StatusBar().ShowMessage(fmt.Sprintf("⏳ Indexing project: %d / %d files (AI > Code Index > Stop Indexing)", done, total), 0)
+				}
+			})
+		})
+		e.RunOnUIThread(func() {
+			cancel()
+			if e.codeIndex == ix {
+				e.indexCancel = nil
+			}
+			files, chunks := ix.Stats()
+			switch {
+			case errors.Is(err, context.Canceled):
+				e.Window.StatusBar().ShowMessage(fmt.Sprintf("Indexing stopped: %d declarations in %d files indexed", chunks, files), 5000)
+			case err != nil:
+				e.Window.StatusBar().ShowMessage(fmt.Sprintf("Code index: %v", err), 8000)
+			default:
+				e.Window.StatusBar().ShowMessage(fmt.Sprintf("Code index ready: %d declarations in %d files", chunks, files), 5000)
+			}
+			e.UpdateAIContextDisplay()
+		})
+	}()
+}
+
+// clearCodeIndex останавливает построение и удаляет индекс проекта
+func (e *EditorWindow) clearCodeIndex() {
+	ix := e.projectCodeIndex()
+	if ix == nil {
+		return
+	}
+	if e.indexCancel != nil {
+		e.indexCancel()
+		e.indexCancel = nil
+	}
+	if err := ix.Clear(); err != nil {
+		e.Window.StatusBar().ShowMessage(fmt.Sprintf("Failed to remove the code index: %v", err), 5000)
+		return
+	}
+	e.lastIndexHits = nil
+	e.UpdateAIContextDisplay()
+	e.Window.StatusBar().ShowMessage("Code index cleared", 3000)
+}
+
+// updateCodeIndex переиндексирует сохранённый файл, если индекс используется
+// и построен текущей моделью эмбеддингов
+func (e *EditorWindow) updateCodeIndex(path string) {
+	if !e.UseCodeIndex || !logic.IsCodeIndexFile(path) {
+		return
+	}
+	ix := e.projectCodeIndex()
+	target := e.indexTarget()
+	if ix == nil || !ix.BuiltWith(target) || !strings.HasPrefix(path, ix.Root()+string(filepath.Separator)) {
+		return
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), target.Timeout)
+		defer cancel()
+		if err := ix.Update(ctx, target, []string{path}, nil); err != nil {
+			e.RunOnUIThread(func() {
+				e.Window.StatusBar().ShowMessage(fmt.Sprintf("Code index not updated: %v", err), 5000)
+			})
+		}
+	}()
+}
+
+// searchCodeIndex ищет в индексе фрагменты для вопроса и передаёт их then в UI-потоке
+// (lastIndexHits только показывается в панели контекста). Без индекса then вызывается
+// сразу; ошибка поиска выводится в чат, и вопрос отправляется без фрагментов.
+func (e *EditorWindow) searchCodeIndex(chat *aiChatTab, question string, then func(hits []logic.CodeHit)) {
+	ix := e.projectCodeIndex()
+	if !e.UseCodeIndex || ix == nil || strings.TrimSpace(question) == "" {
+		then(nil)
+		return
+	}
+
+	// Текущий файл и так целиком в запросе
+	current := ""
+	if ed := e.TabManager.CurrentEditor(); ed != nil && ed.FilePath != "" {
+		current = e.relProjectPath(ed.FilePath)
+	}
+	target := e.indexTarget()
+	e.Window.StatusBar().ShowMessage("🔎 Searching the code index...", 0)
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), indexSearchTimeout)
+		defer cancel()
+		found, err := ix.Search(ctx, target, question, logic.DefaultIndexTopK+2)
+		var hits []logic.CodeHit
+		for _, hit := range found {
+			if hit.File != current && len(hits) < logic.DefaultIndexTopK {
+				hits = append(hits, hit)
+			}
+		}
+
+		e.RunOnUIThread(func() {
+			e.Window.StatusBar().ClearMessage()
+			if err != nil {
+				chat.view.Append(fmt.Sprintf("<span style='color:#db4; font-size:10px;'>⚠ Code index: %s</span>", html.EscapeString(err.Error())))
+			}
+			e.lastIndexHits = hits
+			e.UpdateAIContextDisplay()
+			then(hits)
+		})
+	}()
+}
+
+// formatIndexHit — фрагмент индекса для промпта
+func formatIndexHit(hit logic.CodeHit) string {
+	return fmt.Sprintf("\n// %s (%s)\n```go\n%s\n```\n", hit.Location(), hit.Chunk.Name, hit.Chunk.Text)
+}
+
+// codeIndexStatusHTML — строки AIContextLabel о состоянии индекса и найденных фрагментах
+func (e *EditorWindow) codeIndexStatusHTML() []string {
+	ix := e.projectCodeIndex()
+	switch {
+	case ix == nil:
+		return []string{"🔎 <b>Code index:</b> open a project folder to use it"}
+	case e.indexCancel != nil:
+		return []string{"🔎 <b>Code index:</b> building..."}
+	case !ix.BuiltWith(e.indexTarget()):
+		return []string{"🔎 <b>Code index:</b> not built for the current embedding model (AI > Code Index > Rebuild Index)"}
+	}
+
+	files, chunks := ix.Stats()
+	lines := []string{fmt.Sprintf("🔎 <b>Code index:</b> %d declarations in %d files", chunks, files)}
+	if len(e.lastIndexHits) > 0 {
+		lines = append(lines, "   Retrieved for the last question:")
+		for _, hit := range e.lastIndexHits {
+			lines = append(lines, fmt.Sprintf("   • %s — %s (%.2f)",
+				html.EscapeString(hit.Location()), html.EscapeString(hit.Chunk.Name), hit.Score))
+		}
+	}
+	return lines
+}
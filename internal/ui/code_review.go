@@ -0,0 +1,200 @@
+package ui
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"path/filepath"
+	"strings"
+
+	"github.com/therecipe/qt/core"
+	"github.com/therecipe/qt/widgets"
+
+	"go-gnome-editor/internal/logic"
+)
+
+// reviewPanel — панель "Review Findings": замечания AI к незакоммиченным изменениям
+type reviewPanel struct {
+	dock   *widgets.QDockWidget
+	info   *widgets.QLabel
+	list   *widgets.QListWidget
+	btnRun *widgets.QPushButton
+	stop   *widgets.QPushButton
+
+	root     string
+	findings []logic.ReviewFinding // в том же порядке, что и строки list
+	cancel   context.CancelFunc    // текущее ревью (nil — не идёт)
+}
+
+// setupReviewDock создаёт панель замечаний ревью
+func (e *EditorWindow) setupReviewDock() {
+	rp := &reviewPanel{}
+	e.review = rp
+
+	rp.dock = widgets.NewQDockWidget("Review Findings", e.Window, 0)
+	rp.dock.SetObjectName("ReviewDock")
+
+	wrapper := widgets.NewQWidget(nil, 0)
+	layout := widgets.NewQVBoxLayout()
+	layout.SetContentsMargins(0, 0, 0, 0)
+
+	toolbar := widgets.NewQHBoxLayout()
+	rp.btnRun = widgets.NewQPushButton2("Review Again", nil)
+	rp.btnRun.SetToolTip("Send the current git diff (staged and unstaged) to the AI for review")
+	rp.btnRun.ConnectClicked(func(bool) { e.reviewChanges() })
+	rp.stop = widgets.NewQPushButton2("Stop", nil)
+	rp.stop.SetEnabled(false)
+	rp.stop.ConnectClicked(func(bool) {
+		if rp.cancel != nil {
+			rp.cancel()
+		}
+	})
+	btnClear := widgets.NewQPushButton2("Clear", nil)
+	btnClear.ConnectClicked(func(bool) { e.showReviewFindings(nil, "") })
+	toolbar.AddWidget(rp.btnRun, 0, 0)
+	toolbar.AddWidget(rp.stop, 0, 0)
+	toolbar.AddWidget(btnClear, 0, 0)
+	toolbar.AddStretch(1)
+	layout.AddLayout(toolbar, 0)
+
+	rp.info = widgets.NewQLabel2("", nil, 0)
+	rp.info.SetWordWrap(true)
+	rp.info.SetTextFormat(core.Qt__RichText)
+	rp.info.SetTextInteractionFlags(core.Qt__TextSelectableByMouse)
+	layout.AddWidget(rp.info, 0, 0)
+
+	rp.list = widgets.NewQListWidget(nil)
+	rp.list.SetWordWrap(true)
+	rp.list.ConnectItemActivated(func(*widgets.QListWidgetItem) { e.openReviewFinding(rp.list.CurrentRow()) })
+	rp.list.ConnectItemClicked(func(*widgets.QListWidgetItem) { e.openReviewFinding(rp.list.CurrentRow()) })
+	layout.AddWidget(rp.list, 1, 0)
+
+	wrapper.SetLayout(layout)
+	rp.dock.SetWidget(wrapper)
+	e.Window.AddDockWidget(core.Qt__BottomDockWidgetArea, rp.dock)
+	rp.dock.Hide()
+}
+
+// reviewChanges — AI > Review My Changes: git diff проекта с кодом изменённых функций
+// отправляется модели вида запроса "review", замечания выводятся в панель
+func (e *EditorWindow) reviewChanges() {
+	rp := e.review
+	if !e.ProjectManager.IsActive {
+		e.Window.StatusBar().ShowMessage("Open a project folder (a git repository) to review its changes", 3000)
+		return
+	}
+	if rp.cancel != nil {
+		e.Window.StatusBar().ShowMessage("A review is already running", 2000)
+		return
+	}
+	root := e.ProjectManager.RootPath
+	rp.dock.Show()
+	rp.dock.Raise()
+
+	// git видит только сохранённые файлы
+	unsaved := 0
+	for _, ed := range e.TabManager.GetUnsavedEditors() {
+		if ed.FilePath != "" && strings.HasPrefix(ed.FilePath, root+string(filepath.Separator)) {
+			unsaved++
+		}
+	}
+	note := ""
+	if unsaved > 0 {
+		note = fmt.Sprintf(" <span style='color:#db4;'>⚠ %d unsaved file(s) are reviewed as saved on disk</span>", unsaved)
+	}
+
+	target := e.llmTarget(logic.TaskReview)
+	ctx, cancel := context.WithTimeout(context.Background(), target.Timeout)
+	rp.cancel = cancel
+	rp.stop.SetEnabled(true)
+	rp.btnRun.SetEnabled(false)
+	rp.info.SetText(fmt.Sprintf("⏳ Reviewing uncommitted changes with %s/%s...%s",
+		html.EscapeString(target.Provider), html.EscapeString(target.Model), note))
+
+	go func() {
+		var findings []logic.ReviewFinding
+		var summary string
+		changes, err := logic.LoadGitChanges(ctx, root)
+		if err == nil && len(changes.Files) == 0 {
+			summary = "No uncommitted changes to review"
+		} else if err == nil {
+			var res *logic.ChatResult
+			res, err = logic.Chat(ctx, logic.ChatRequest{
+				Provider: target.Provider,
+				Model:    target.Model,
+				APIKey:   target.APIKey,
+				History:  []logic.Message{{Role: "user", Content: logic.ReviewPrompt(changes)}},
+				Task:     target.Task,
+				Vars:     target.Vars,
+			})
+			if err == nil {
+				findings, summary = logic.ParseReviewFindings(res.Text, root, changes.Files)
+				if len(findings) == 0 && summary == "" {
+					summary = shortText(strings.TrimSpace(res.Text), 500)
+				}
+				summary = fmt.Sprintf("%d finding(s) in %d changed file(s). %s", len(findings), len(changes.Files), summary)
+			}
+		}
+		if err == nil && len(changes.Skipped) > 0 {
+			summary += fmt.Sprintf(" (%d new file(s) skipped as binary or too large: %s)",
+				len(changes.Skipped), strings.Join(changes.Skipped, ", "))
+		}
+		cancel()
+
+		e.RunOnUIThread(func() {
+			rp.cancel = nil
+			rp.stop.SetEnabled(false)
+			rp.btnRun.SetEnabled(true)
+			switch {
+			case logic.IsCanceled(err):
+				rp.info.SetText("Review stopped")
+			case err != nil:
+				rp.info.SetText(fmt.Sprintf("<span style='color:#d66;'>Review failed: %s</span>", html.EscapeString(err.Error())))
+			default:
+				rp.root = root
+				e.showReviewFindings(findings, html.EscapeString(summary)+note)
+			}
+		})
+	}()
+}
+
+// showReviewFindings заполняет список замечаний; info — HTML строки над списком
+func (e *EditorWindow) showReviewFindings(findings []logic.ReviewFinding, info string) {
+	rp := e.review
+	rp.findings = findings
+	rp.list.Clear()
+	rp.info.SetText(info)
+	for _, f := range findings {
+		text := fmt.Sprintf("%s %s — %s", severityIcon(f.Severity), f.Location(rp.root), f.Message)
+		item := widgets.NewQListWidgetItem2(text, rp.list, 0)
+		item.SetToolTip(fmt.Sprintf("%s: %s", f.Severity, f.Message))
+	}
+}
+
+func severityIcon(severity string) string {
+	switch severity {
+	case "error":
+		return "⛔"
+	case "suggestion":
+		return "💡"
+	}
+	return "⚠"
+}
+
+// openReviewFinding открывает файл замечания и переходит к его строке
+func (e *EditorWindow) openReviewFinding(row int) {
+	rp := e.review
+	if row < 0 || row >= len(rp.findings) {
+		return
+	}
+	f := rp.findings[row]
+	if ed := e.findEditor(f.File); ed != nil {
+		e.TabManager.Tabs.SetCurrentIndex(e.TabManager.getTabIndex(ed))
+	} else {
+		e.TabManager.OpenFile(f.File)
+		if e.findEditor(f.File) == nil {
+			return
+		}
+	}
+	e.TabManager.GoToLine(f.Line)
+}
@@ -0,0 +1,341 @@
+package ui
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/therecipe/qt/core"
+	"github.com/therecipe/qt/gui"
+	"github.com/therecipe/qt/widgets"
+
+	"go-gnome-editor/internal/logic"
+)
+
+// Цвета строк в сравнении
+const (
+	diffRemovedColor = "#5A1E1E"
+	diffAddedColor   = "#1E4620"
+	diffFillerColor  = "#2A2A2A"
+)
+
+// diffRow — строка side-by-side сравнения; -1 — пустая строка-заполнитель
+type diffRow struct {
+	old, new int
+	changed  bool
+}
+
+// diffRows выравнивает строки старого и нового текста; hunkRows — первая строка каждого участка
+func diffRows(oldLines, newLines []string, hunks []logic.DiffHunk) (rows []diffRow, hunkRows []int) {
+	i, j := 0, 0
+	for _, h := range hunks {
+		for ; i < h.OldStart; i, j = i+1, j+1 {
+			rows = append(rows, diffRow{old: i, new: j})
+		}
+		hunkRows = append(hunkRows, len(rows))
+		n, m := h.OldEnd-h.OldStart, h.NewEnd-h.NewStart
+		for k := 0; k < n || k < m; k++ {
+			row := diffRow{old: -1, new: -1, changed: true}
+			if k < n {
+				row.old = h.OldStart + k
+			}
+			if k < m {
+				row.new = h.NewStart + k
+			}
+			rows = append(rows, row)
+		}
+		i, j = h.OldEnd, h.NewEnd
+	}
+	for ; i < len(oldLines); i, j = i+1, j+1 {
+		rows = append(rows, diffRow{old: i, new: j})
+	}
+	return rows, hunkRows
+}
+
+// fillDiffSide выводит одну сторону сравнения с номерами строк и подсветкой изменений
+func fillDiffSide(text *widgets.QPlainTextEdit, lines []string, rows []diffRow, isNew bool, changedColor string) {
+	var sb strings.Builder
+	for n, row := range rows {
+		idx := row.old
+		if isNew {
+			idx = row.new
+		}
+		if n > 0 {
+			sb.WriteByte('\n')
+		}
+		if idx >= 0 {
+			fmt.Fprintf(&sb, "%5d  %s", idx+1, lines[idx])
+		}
+	}
+	text.SetPlainText(sb.String())
+
+	doc := text.Document()
+	for n, row := range rows {
+		if !row.changed {
+			continue
+		}
+		color := changedColor
+		if (isNew && row.new < 0) || (!isNew && row.old < 0) {
+			color = diffFillerColor
+		}
+		format := gui.NewQTextBlockFormat()
+		format.SetBackground(gui.NewQBrush3(hexToQColor(color), core.Qt__SolidPattern))
+		gui.NewQTextCursor4(doc.FindBlockByNumber(n)).SetBlockFormat(format)
+	}
+}
+
+// hunkTitle — подпись участка в списке
+func hunkTitle(h logic.DiffHunk, oldLines, newLines []string) string {
+	removed, added := h.OldEnd-h.OldStart, h.NewEnd-h.NewStart
+	preview := ""
+	switch {
+	case added > 0:
+		preview = strings.TrimSpace(newLines[h.NewStart])
+	case removed > 0:
+		preview = strings.TrimSpace(oldLines[h.OldStart])
+	}
+	if r := []rune(preview); len(r) > 60 {
+		preview = string(r[:59]) + "…"
+	}
+	return fmt.Sprintf("Line %d: −%d +%d   %s", h.OldStart+1, removed, added, preview)
+}
+
+// hunkHasPlaceholder сообщает, есть ли в строках участка одна из меток placeholders
+func hunkHasPlaceholder(lines, placeholders []string) bool {
+	for _, line := range lines {
+		for _, p := range placeholders {
+			if strings.Contains(line, p) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// showDiffDialog показывает side-by-side сравнение текста редактора ed с newText;
+// отмеченные участки применяются одним шагом отмены. note поясняет, с чем идёт сравнение.
+// Возвращает число применённых участков и общее число участков (0 — текст не отличается).
+func (e *EditorWindow) showDiffDialog(ed *CodeEditorTab, note, newText string) (applied, total int) {
+	oldText := ed.TextEdit.ToPlainText()
+	oldLines := logic.SplitLines(oldText)
+	newLines := logic.SplitLines(newText)
+	hunks := logic.DiffLines(oldLines, newLines)
+	if len(hunks) == 0 {
+		e.Window.StatusBar().ShowMessage("No changes: the code is already in the editor", 3000)
+		return 0, 0
+	}
+
+	name := "Untitled"
+	if ed.FilePath != "" {
+		name = filepath.Base(ed.FilePath)
+	}
+	dlg := widgets.NewQDialog(e.Window, core.Qt__Dialog)
+	dlg.SetWindowTitle("Apply as Diff — " + name)
+	layout := widgets.NewQVBoxLayout()
+
+	if note != "" {
+		label := widgets.NewQLabel2(note, nil, 0)
+		label.SetWordWrap(true)
+		label.SetStyleSheet("color: #888;")
+		layout.AddWidget(label, 0, 0)
+	}
+
+	// Модель повторила метку замаскированного секрета: такие участки не отмечаются
+	redacted := logic.NewRedactedPlaceholders(oldText, newText)
+	if len(redacted) > 0 {
+		label := widgets.NewQLabel2("⚠ The code contains "+strings.Join(redacted, ", ")+
+			" — the model saw a masked secret, not its value. Changes with these placeholders are unchecked.", nil, 0)
+		label.SetTextFormat(core.Qt__PlainText)
+		label.SetWordWrap(true)
+		label.SetStyleSheet("color: #d55;")
+		layout.AddWidget(label, 0, 0)
+	}
+
+	rows, hunkRows := diffRows(oldLines, newLines, hunks)
+	splitter := widgets.NewQSplitter2(core.Qt__Horizontal, nil)
+	oldView, newView := newInspectorText(true), newInspectorText(true)
+	for _, view := range []*widgets.QPlainTextEdit{oldView, newView} {
+		view.SetLineWrapMode(widgets.QPlainTextEdit__NoWrap)
+		splitter.AddWidget(view)
+	}
+	fillDiffSide(oldView, oldLines, rows, false, diffRemovedColor)
+	fillDiffSide(newView, newLines, rows, true, diffAddedColor)
+
+	// Строки выровнены, поэтому стороны прокручиваются вместе
+	oldView.VerticalScrollBar().ConnectValueChanged(func(v int) { newView.VerticalScrollBar().SetValue(v) })
+	newView.VerticalScrollBar().ConnectValueChanged(func(v int) { oldView.VerticalScrollBar().SetValue(v) })
+	oldView.HorizontalScrollBar().ConnectValueChanged(func(v int) { newView.HorizontalScrollBar().SetValue(v) })
+	newView.HorizontalScrollBar().ConnectValueChanged(func(v int) { oldView.HorizontalScrollBar().SetValue(v) })
+	layout.AddWidget(splitter, 1, 0)
+
+	list := widgets.NewQListWidget(nil)
+	list.SetMaximumHeight(140)
+	list.SetToolTip("Checked changes are applied; select a change to scroll to it")
+	for _, h := range hunks {
+		title, state := hunkTitle(h, oldLines, newLines), core.Qt__Checked
+		if hunkHasPlaceholder(newLines[h.NewStart:h.NewEnd], redacted) {
+			title, state = "⚠ "+title, core.Qt__Unchecked
+		}
+		item := widgets.NewQListWidgetItem2(title, list, 0)
+		item.SetFlags(core.Qt__ItemIsUserCheckable | core.Qt__ItemIsEnabled | core.Qt__ItemIsSelectable)
+		item.SetCheckState(state)
+	}
+	list.ConnectCurrentRowChanged(func(row int) {
+		if row < 0 || row >= len(hunkRows) {
+			return
+		}
+		cursor := gui.NewQTextCursor4(oldView.Document().FindBlockByNumber(hunkRows[row]))
+		oldView.SetTextCursor(cursor)
+		oldView.CenterCursor()
+	})
+	layout.AddWidget(list, 0, 0)
+
+	setAll := func(state core.Qt__CheckState) {
+		for i := 0; i < list.Count(); i++ {
+			list.Item(i).SetCheckState(state)
+		}
+	}
+	tools := widgets.NewQHBoxLayout()
+	btnAll := widgets.NewQPushButton2("Accept All", nil)
+	btnAll.ConnectClicked(func(bool) { setAll(core.Qt__Checked) })
+	btnNone := widgets.NewQPushButton2("Reject All", nil)
+	btnNone.ConnectClicked(func(bool) { setAll(core.Qt__Unchecked) })
+	tools.AddWidget(btnAll, 0, 0)
+	tools.AddWidget(btnNone, 0, 0)
+	tools.AddStretch(1)
+
+	buttons := widgets.NewQDialogButtonBox3(widgets.QDialogButtonBox__Apply|widgets.QDialogButtonBox__Cancel, nil)
+	buttons.Button(widgets.QDialogButtonBox__Apply).ConnectClicked(func(bool) { dlg.Accept() })
+	buttons.ConnectRejected(dlg.Reject)
+	tools.AddWidget(buttons, 0, 0)
+	layout.AddLayout(tools, 0)
+
+	dlg.SetLayout(layout)
+	dlg.Resize2(1100, 650)
+	if dlg.Exec() != int(widgets.QDialog__Accepted) {
+		return 0, len(hunks)
+	}
+
+	accept := make([]bool, len(hunks))
+	count := 0
+	for i := range hunks {
+		accept[i] = list.Item(i).CheckState() == core.Qt__Checked
+		if accept[i] {
+			count++
+		}
+	}
+	if count == 0 {
+		return 0, len(hunks)
+	}
+	applyDiffHunks(ed, len(oldLines), newLines, hunks, accept)
+	e.Window.StatusBar().ShowMessage(fmt.Sprintf("Applied %d of %d change(s) — Ctrl+Z undoes them", count, len(hunks)), 4000)
+	return count, len(hunks)
+}
+
+// applyDiffHunks заменяет строки принятых участков в редакторе одним шагом отмены.
+// Строка i текста соответствует блоку i документа (см. logic.SplitLines).
+func applyDiffHunks(ed *CodeEditorTab, oldCount int, newLines []string, hunks []logic.DiffHunk, accept []bool) {
+	doc := ed.TextEdit.Document()
+	docEnd := doc.CharacterCount() - 1
+	pos := func(line int) int { return doc.FindBlockByNumber(line).Position() }
+
+	cursor := ed.TextEdit.TextCursor()
+	cursor.BeginEditBlock()
+	// С конца, чтобы номера строк выше оставались верными
+	for n := len(hunks) - 1; n >= 0; n-- {
+		if !accept[n] {
+			continue
+		}
+		h := hunks[n]
+		lines := strings.Join(newLines[h.NewStart:h.NewEnd], "\n")
+		var start, end int
+		var text string
+		switch {
+		case h.OldEnd < oldCount:
+			start, end = pos(h.OldStart), pos(h.OldEnd)
+			if h.NewEnd > h.NewStart {
+				text = lines + "\n"
+			}
+		case h.OldStart < oldCount:
+			// Участок доходит до последней строки, после которой нет перевода строки
+			start, end, text = pos(h.OldStart), docEnd, lines
+			if h.NewEnd == h.NewStart && h.OldStart > 0 {
+				start--
+			}
+		default:
+			// Вставка после последней строки
+			start, end, text = docEnd, docEnd, "\n"+lines
+		}
+		cursor.SetPosition(start, gui.QTextCursor__MoveAnchor)
+		cursor.SetPosition(end, gui.QTextCursor__KeepAnchor)
+		cursor.InsertText(text)
+	}
+	cursor.EndEditBlock()
+	ed.TextEdit.SetTextCursor(cursor)
+}
+
+// applyCodeBlockAsDiff сравнивает блок кода из ответа (ссылка applydiff:N) с текущим
+// редактором. Сравнение идёт с выделенными строками, для Go — с объявлениями тех же
+// функций и типов, иначе с файлом целиком.
+func (e *EditorWindow) applyCodeBlockAsDiff(chat *aiChatTab, url string) {
+	var index int
+	if _, err := fmt.Sscanf(strings.TrimPrefix(url, applyDiffLink), "%d", &index); err != nil || index < 0 || index >= len(chat.codeBlocks) {
+		e.Window.StatusBar().ShowMessage("Code block not found", 2000)
+		return
+	}
+	block := chat.codeBlocks[index]
+
+	ed := e.TabManager.CurrentEditor()
+	if ed == nil || ed.TextEdit == nil {
+		e.Window.StatusBar().ShowMessage("Open the file to apply the code to", 3000)
+		return
+	}
+	current := ed.TextEdit.ToPlainText()
+	proposed, note := e.proposeCodeBlockEdit(ed, current, block)
+	e.showDiffDialog(ed, note, proposed)
+}
+
+// proposeCodeBlockEdit возвращает текст редактора с подставленным блоком кода и пояснение
+func (e *EditorWindow) proposeCodeBlockEdit(ed *CodeEditorTab, current string, block CodeBlockData) (string, string) {
+	cursor := ed.TextEdit.TextCursor()
+	if cursor.HasSelection() {
+		doc := ed.TextEdit.Document()
+		first := doc.FindBlock(cursor.SelectionStart()).BlockNumber()
+		endBlock := doc.FindBlock(cursor.SelectionEnd())
+		last := endBlock.BlockNumber()
+		// Выделение до начала строки её не захватывает
+		if last > first && cursor.SelectionEnd() == endBlock.Position() {
+			last--
+		}
+		lines := logic.SplitLines(current)
+		merged := append(append(append([]string{}, lines[:first]...), logic.SplitLines(block.Code)...), lines[last+1:]...)
+		return strings.Join(merged, "\n"), fmt.Sprintf("Comparing with the selected lines %d–%d", first+1, last+1)
+	}
+
+	wholeFile := block.Code
+	if strings.HasSuffix(current, "\n") && !strings.HasSuffix(wholeFile, "\n") {
+		wholeFile += "\n"
+	}
+	isGo := e.TabManager.detectLanguageFromPath(ed.FilePath) == "Go" || strings.EqualFold(block.Language, "go")
+	if !isGo {
+		return wholeFile, "Comparing with the whole file"
+	}
+
+	res, err := logic.MergeGoSnippet(current, block.Code)
+	switch {
+	case err != nil:
+		return wholeFile, fmt.Sprintf("No matching declaration (%v) — comparing with the whole file", err)
+	case res.WholeFile:
+		return wholeFile, "The code block is a complete file — comparing with the whole file"
+	}
+	return res.Text, goMergeNote(res)
+}
+
+// goMergeNote — пояснение к сравнению: какие объявления заменены и добавлены
+func goMergeNote(res logic.GoMergeResult) string {
+	note := "Replaces " + strings.Join(res.Replaced, ", ")
+	if len(res.Added) > 0 {
+		note += "; adds " + strings.Join(res.Added, ", ")
+	}
+	return note
+}
@@ -0,0 +1,126 @@
+package ui
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"go-gnome-editor/internal/logic"
+)
+
+// completionDeclBytes — объём объявлений в запросах inline-дополнения (они должны быть быстрыми)
+const completionDeclBytes = 3000
+
+// goDeclRequest — запрос объявлений, подготовленный в UI-потоке
+type goDeclRequest struct {
+	logic.GoContextRequest
+	root string // относительно него выводятся пути
+}
+
+// goDeclRequest снимает текст редактора, курсор (или выделение) и несохранённые
+// Go-вкладки, чтобы искать объявления можно было вне UI-потока.
+// nil — опция выключена или это не Go-файл.
+func (tm *TabManager) goDeclRequest(ed *CodeEditorTab, skipFile bool, maxBytes int) *goDeclRequest {
+	if !tm.DeclContextEnabled || ed == nil || ed.FilePath == "" || tm.detectLanguageFromPath(ed.FilePath) != "Go" {
+		return nil
+	}
+	text := ed.TextEdit.ToPlainText()
+	cursor := ed.TextEdit.TextCursor()
+
+	overlay := map[string]string{}
+	for _, other := range tm.GetUnsavedEditors() {
+		if other != ed && strings.HasSuffix(other.FilePath, ".go") {
+			overlay[filepath.Clean(other.FilePath)] = other.TextEdit.ToPlainText()
+		}
+	}
+
+	root := filepath.Dir(ed.FilePath)
+	if tm.Parent.ProjectManager.IsActive {
+		root = tm.Parent.ProjectManager.RootPath
+	}
+	return &goDeclRequest{
+		GoContextRequest: logic.GoContextRequest{
+			File:  ed.FilePath,
+			Src:   text,
+			Start: utf16ToByteOffset(text, cursor.SelectionStart()),
+			End:   utf16ToByteOffset(text, cursor.SelectionEnd()),
+			ReadFile: func(path string) (string, error) {
+				if t, ok := overlay[path]; ok {
+					return t, nil
+				}
+				data, err := os.ReadFile(path)
+				return string(data), err
+			},
+			SkipFile: skipFile,
+			MaxBytes: maxBytes,
+		},
+		root: root,
+	}
+}
+
+// decls находит объявления и выводит их Go-кодом ("" — ничего не найдено)
+func (r *goDeclRequest) decls() (string, int) {
+	if r == nil {
+		return "", 0
+	}
+	decls := logic.GoContextDecls(r.GoContextRequest)
+	if len(decls) == 0 {
+		return "", 0
+	}
+	return logic.FormatGoDecls(decls, r.root), len(decls)
+}
+
+// goDecls — объявления для запроса чата, найденные вне UI-потока
+type goDecls struct {
+	text string
+	n    int
+}
+
+// findGoDecls ищет объявления в горутине и передаёт их then в UI-потоке;
+// без запроса (nil) then вызывается сразу
+func (e *EditorWindow) findGoDecls(r *goDeclRequest, then func(goDecls)) {
+	if r == nil {
+		then(goDecls{})
+		return
+	}
+	go func() {
+		text, n := r.decls()
+		e.RunOnUIThread(func() { then(goDecls{text: text, n: n}) })
+	}()
+}
+
+// addGoDecls дополняет запрос inline-дополнения объявлениями: чат-промпт — блоком
+// перед инструкциями, FIM — комментарием перед кодом. Вызывается в горутине запроса.
+func addGoDecls(r *goDeclRequest, prompt string, fim *logic.FIMRequest) string {
+	decls, _ := r.decls()
+	if decls == "" {
+		return prompt
+	}
+	if fim != nil {
+		var sb strings.Builder
+		sb.WriteString("// Declarations used near the cursor:\n")
+		for _, line := range strings.Split(strings.TrimRight(decls, "\n"), "\n") {
+			if !strings.HasPrefix(line, "//") {
+				line = strings.TrimRight("// "+line, " ")
+			}
+			sb.WriteString(line + "\n")
+		}
+		fim.Prefix = sb.String() + "\n" + fim.Prefix
+	}
+	return "Declarations used near the cursor (for reference, do not repeat them):\n```go\n" + decls + "```\n\n" + prompt
+}
+
+// utf16ToByteOffset переводит позицию Qt (в UTF-16 символах) в байтовое смещение в text
+func utf16ToByteOffset(text string, pos int) int {
+	units := 0
+	for i, r := range text {
+		if units >= pos {
+			return i
+		}
+		units++
+		if r >= 0x10000 {
+			units++
+		}
+	}
+	return len(text)
+}
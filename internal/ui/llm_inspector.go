@@ -0,0 +1,329 @@
+package ui
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"html"
+	"strings"
+
+	"github.com/therecipe/qt/core"
+	"github.com/therecipe/qt/gui"
+	"github.com/therecipe/qt/widgets"
+
+	"go-gnome-editor/internal/logic"
+)
+
+// fimHoleMarker отмечает место вставки в промпте FIM-запроса в инспекторе
+const fimHoleMarker = "<<<FILL_HERE>>>"
+
+// maxInspectorEntries ограничивает список запросов в инспекторе
+const maxInspectorEntries = 500
+
+// llmInspector — панель просмотра и повторной отправки запросов к LLM
+type llmInspector struct {
+	dock    *widgets.QDockWidget
+	list    *widgets.QListWidget
+	info    *widgets.QLabel
+	prompt  *widgets.QPlainTextEdit
+	system  *widgets.QPlainTextEdit
+	content *widgets.QPlainTextEdit
+	raw     *widgets.QPlainTextEdit
+	resend  *widgets.QPushButton
+
+	entries []logic.RequestLogEntry // в том же порядке, что и строки list
+}
+
+// setupInspectorDock создаёт панель "LLM Inspector" и подписывается на журнал запросов
+func (e *EditorWindow) setupInspectorDock() {
+	ins := &llmInspector{}
+	e.inspector = ins
+
+	ins.dock = widgets.NewQDockWidget("LLM Inspector", e.Window, 0)
+	ins.dock.SetObjectName("LLMInspectorDock")
+
+	wrapper := widgets.NewQWidget(nil, 0)
+	layout := widgets.NewQVBoxLayout()
+	layout.SetContentsMargins(0, 0, 0, 0)
+
+	toolbar := widgets.NewQHBoxLayout()
+	btnLoad := widgets.NewQPushButton2("Load Log File", nil)
+	btnLoad.SetToolTip("Show the last requests from the request log (AI > Log Requests to File)")
+	btnLoad.ConnectClicked(func(bool) { e.loadInspectorLog() })
+	btnClear := widgets.NewQPushButton2("Clear", nil)
+	btnClear.ConnectClicked(func(bool) {
+		ins.entries = nil
+		ins.list.Clear()
+		e.showInspectorEntry(-1)
+	})
+	toolbar.AddWidget(btnLoad, 0, 0)
+	toolbar.AddWidget(btnClear, 0, 0)
+	toolbar.AddStretch(1)
+	layout.AddLayout(toolbar, 0)
+
+	splitter := widgets.NewQSplitter2(core.Qt__Horizontal, nil)
+
+	ins.list = widgets.NewQListWidget(nil)
+	ins.list.ConnectCurrentRowChanged(e.showInspectorEntry)
+	splitter.AddWidget(ins.list)
+
+	details := widgets.NewQWidget(nil, 0)
+	detailsLayout := widgets.NewQVBoxLayout()
+	detailsLayout.SetContentsMargins(0, 0, 0, 0)
+
+	ins.info = widgets.NewQLabel2("", nil, 0)
+	ins.info.SetWordWrap(true)
+	ins.info.SetTextInteractionFlags(core.Qt__TextSelectableByMouse)
+	detailsLayout.AddWidget(ins.info, 0, 0)
+
+	tabs := widgets.NewQTabWidget(nil)
+	ins.prompt = newInspectorText(false)
+	ins.prompt.SetToolTip("Edit the prompt and press Re-send to compare answers")
+	ins.system = newInspectorText(false)
+	ins.system.SetToolTip("System prompt sent with the request; Re-send uses the edited text")
+	ins.content = newInspectorText(true)
+	ins.raw = newInspectorText(true)
+	tabs.AddTab(ins.prompt, "Prompt")
+	tabs.AddTab(ins.system, "System")
+	tabs.AddTab(ins.content, "Response")
+	tabs.AddTab(ins.raw, "Raw Body")
+	detailsLayout.AddWidget(tabs, 1, 0)
+
+	buttons := widgets.NewQHBoxLayout()
+	ins.resend = widgets.NewQPushButton2("Re-send", nil)
+	ins.resend.SetToolTip("Send the (edited) prompt again to the same provider and model, without fallbacks")
+	ins.resend.SetEnabled(false)
+	ins.resend.ConnectClicked(func(bool) { e.resendInspectorEntry() })
+	btnCopy := widgets.NewQPushButton2("Copy as JSON", nil)
+	btnCopy.ConnectClicked(func(bool) {
+		if row := ins.list.CurrentRow(); row >= 0 && row < len(ins.entries) {
+			data, _ := json.MarshalIndent(ins.entries[row], "", "  ")
+			gui.QGuiApplication_Clipboard().SetText(string(data), gui.QClipboard__Clipboard)
+		}
+	})
+	buttons.AddWidget(ins.resend, 0, 0)
+	buttons.AddWidget(btnCopy, 0, 0)
+	buttons.AddStretch(1)
+	detailsLayout.AddLayout(buttons, 0)
+
+	details.SetLayout(detailsLayout)
+	splitter.AddWidget(details)
+	splitter.SetStretchFactor(0, 1)
+	splitter.SetStretchFactor(1, 3)
+	layout.AddWidget(splitter, 1, 0)
+
+	wrapper.SetLayout(layout)
+	ins.dock.SetWidget(wrapper)
+	e.Window.AddDockWidget(core.Qt__BottomDockWidgetArea, ins.dock)
+	ins.dock.Hide()
+
+	// Запросы этого сеанса, выполненные до создания панели
+	for _, entry := range logic.RecentRequests() {
+		e.addInspectorEntry(entry)
+	}
+	logic.SetRequestLogListener(func(entry logic.RequestLogEntry) {
+		e.RunOnUIThread(func() { e.addInspectorEntry(entry) })
+	})
+}
+
+func newInspectorText(readOnly bool) *widgets.QPlainTextEdit {
+	text := widgets.NewQPlainTextEdit(nil)
+	text.SetReadOnly(readOnly)
+	text.SetLineWrapMode(widgets.QPlainTextEdit__WidgetWidth)
+	text.SetStyleSheet("font-family: Monospace;")
+	return text
+}
+
+// inspectorTitle — строка списка: время, вид, провайдер, задержка, результат
+func inspectorTitle(entry logic.RequestLogEntry) string {
+	kind := entry.Kind
+	if entry.Task != "" {
+		kind += "/" + string(entry.Task)
+	}
+	result := "ok"
+	switch {
+	case entry.Error != "":
+		result = "error"
+	case entry.Cached:
+		result = "cached"
+	case entry.Status != 0:
+		result = fmt.Sprint(entry.Status)
+	}
+	who := entry.Provider
+	if entry.Model != "" {
+		who += " (" + entry.Model + ")"
+	}
+	return fmt.Sprintf("%s  %s  %s  %d ms  %s", entry.Time.Format("15:04:05"), kind, who, entry.LatencyMs, result)
+}
+
+// addInspectorEntry добавляет запрос в конец списка
+func (e *EditorWindow) addInspectorEntry(entry logic.RequestLogEntry) {
+	ins := e.inspector
+	ins.entries = append(ins.entries, entry)
+	ins.list.AddItem(inspectorTitle(entry))
+	item := ins.list.Item(ins.list.Count() - 1)
+	if entry.Error != "" {
+		item.SetForeground(gui.NewQBrush3(gui.NewQColor6("#e66"), core.Qt__SolidPattern))
+		item.SetToolTip(entry.Error)
+	}
+
+	if len(ins.entries) > maxInspectorEntries {
+		ins.entries = ins.entries[1:]
+		ins.list.TakeItem(0)
+	}
+}
+
+// showInspectorEntry показывает подробности запроса из строки row (-1 — ничего)
+func (e *EditorWindow) showInspectorEntry(row int) {
+	ins := e.inspector
+	if row < 0 || row >= len(ins.entries) {
+		ins.info.SetText("")
+		ins.prompt.SetPlainText("")
+		ins.system.SetPlainText("")
+		ins.content.SetPlainText("")
+		ins.raw.SetPlainText("")
+		ins.resend.SetEnabled(false)
+		return
+	}
+	entry := ins.entries[row]
+
+	var info []string
+	info = append(info, fmt.Sprintf("<b>%s</b> %s", html.EscapeString(entry.Kind), entry.Time.Format("2006-01-02 15:04:05")))
+	if entry.URL != "" {
+		info = append(info, html.EscapeString(entry.URL))
+	}
+	if entry.Cached {
+		info = append(info, "answered from the response cache")
+	} else {
+		info = append(info, fmt.Sprintf("status %d · %d attempt(s) · %d ms", entry.Status, entry.Attempts, entry.LatencyMs))
+	}
+	if entry.Images > 0 {
+		info = append(info, fmt.Sprintf("%d image(s)", entry.Images))
+	}
+	if len(entry.Redactions) > 0 {
+		info = append(info, "redacted: "+html.EscapeString(entry.Redactions.String()))
+	}
+	if entry.Error != "" {
+		info = append(info, fmt.Sprintf("<span style='color:#e66'>%s</span>", html.EscapeString(entry.Error)))
+	}
+	ins.info.SetText(strings.Join(info, "<br>"))
+
+	ins.prompt.SetPlainText(inspectorPrompt(entry))
+	ins.system.SetPlainText(entry.System)
+	ins.content.SetPlainText(entry.Content)
+	ins.raw.SetPlainText(entry.Response)
+	ins.resend.SetEnabled(true)
+}
+
+// inspectorPrompt — редактируемый текст промпта: последнее сообщение чата
+// или код FIM-запроса с отметкой места вставки
+func inspectorPrompt(entry logic.RequestLogEntry) string {
+	if entry.FIM != nil {
+		return entry.FIM.Prefix + fimHoleMarker + entry.FIM.Suffix
+	}
+	if n := len(entry.Prompt); n > 0 {
+		return entry.Prompt[n-1].Content
+	}
+	return ""
+}
+
+// resendInspectorEntry повторяет выбранный запрос с текстом из вкладки Prompt.
+// Новый запрос и ответ появляются в конце списка.
+func (e *EditorWindow) resendInspectorEntry() {
+	ins := e.inspector
+	row := ins.list.CurrentRow()
+	if row < 0 || row >= len(ins.entries) {
+		return
+	}
+	entry := ins.entries[row]
+	text := ins.prompt.ToPlainText()
+	system := ins.system.ToPlainText()
+
+	key := ""
+	if entry.Provider == e.llmKeyProvider {
+		key = e.LLMKey
+	}
+	timeout := e.llmTarget(entry.Task).Timeout
+	if entry.Task == "" {
+		timeout = logic.TaskChat.DefaultTimeout()
+	}
+
+	var fim *logic.FIMRequest
+	if entry.FIM != nil {
+		i := strings.Index(text, fimHoleMarker)
+		if i < 0 {
+			e.Window.StatusBar().ShowMessage("FIM prompt must contain "+fimHoleMarker, 5000)
+			return
+		}
+		req := *entry.FIM
+		req.Prefix, req.Suffix, req.Task = text[:i], text[i+len(fimHoleMarker):], entry.Task
+		// Повтор всегда уходит провайдеру, а не в кэш
+		req.Cache = false
+		fim = &req
+	}
+
+	history := append([]logic.Message(nil), entry.Prompt...)
+	if n := len(history); n > 0 {
+		history[n-1].Content = text
+	} else {
+		history = []logic.Message{{Role: "user", Content: text}}
+	}
+
+	ins.resend.SetEnabled(false)
+	e.Window.StatusBar().ShowMessage(fmt.Sprintf("Re-sending to %s...", entry.Provider), 0)
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+
+		var err error
+		if fim != nil {
+			_, err = logic.CompleteFIM(ctx, entry.Provider, entry.Model, key, *fim)
+		} else {
+			_, err = logic.Chat(ctx, logic.ChatRequest{
+				Provider:   entry.Provider,
+				Model:      entry.Model,
+				APIKey:     key,
+				History:    history,
+				NoFallback: true,
+				Task:       entry.Task,
+				System:     system,
+			})
+		}
+		e.RunOnUIThread(func() {
+			ins.resend.SetEnabled(ins.list.CurrentRow() >= 0)
+			if err != nil {
+				e.Window.StatusBar().ShowMessage(fmt.Sprintf("Re-send failed: %v", err), 5000)
+				return
+			}
+			// Ответ уже добавлен в список журналом запросов — выделяем его
+			ins.list.SetCurrentRow(ins.list.Count() - 1)
+			e.Window.StatusBar().ShowMessage("Re-sent; the new answer is selected", 3000)
+		})
+	}()
+}
+
+// loadInspectorLog добавляет в список последние записи файла журнала
+func (e *EditorWindow) loadInspectorLog() {
+	path := logic.RequestLogPath()
+	if path == "" {
+		path, _ = logic.DefaultRequestLogPath()
+	}
+	entries, err := logic.LoadRequestLog(path, maxInspectorEntries)
+	if err != nil {
+		e.Window.StatusBar().ShowMessage(err.Error(), 5000)
+	}
+	if len(entries) == 0 {
+		e.Window.StatusBar().ShowMessage("Request log is empty: enable AI > Log Requests to File", 5000)
+		return
+	}
+
+	ins := e.inspector
+	ins.entries = nil
+	ins.list.Clear()
+	for _, entry := range entries {
+		e.addInspectorEntry(entry)
+	}
+	ins.list.SetCurrentRow(ins.list.Count() - 1)
+	e.Window.StatusBar().ShowMessage(fmt.Sprintf("Loaded %d requests from %s", len(entries), path), 3000)
+}
@@ -0,0 +1,474 @@
+package ui
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/alecthomas/chroma/v2"
+	"github.com/alecthomas/chroma/v2/lexers"
+	"github.com/alecthomas/chroma/v2/styles"
+)
+
+// Разметка Markdown, которую понимает чат: заголовки, списки (в том числе вложенные),
+// цитаты, таблицы, горизонтальные линии, блоки кода и inline-разметка.
+// HTML ограничен тем, что умеет QTextBrowser.
+
+var (
+	mdFenceRe     = regexp.MustCompile("^(\\s*)(`{3,}|~{3,})\\s*([^`\\s]*)")
+	mdHeadingRe   = regexp.MustCompile(`^\s{0,3}(#{1,6})\s+(.*?)(\s+#+)?\s*$`)
+	mdListRe      = regexp.MustCompile(`^(\s*)([-*+]|\d{1,9}[.)])(\s+|$)(.*)$`)
+	mdTableSepRe  = regexp.MustCompile(`^\s*\|?\s*:?-+:?\s*(\|\s*:?-+:?\s*)*\|?\s*$`)
+	mdEscapeRe    = regexp.MustCompile(`\\([\\` + "`" + `*_{}\[\]()#+\-.!|~>])`)
+	mdImageRe     = regexp.MustCompile(`!\[([^\]]*)\]\(([^)\s]+)(?:\s+&#34;[^)]*&#34;)?\)`)
+	mdLinkRe      = regexp.MustCompile(`\[([^\]]+)\]\(([^)\s]+)(?:\s+&#34;[^)]*&#34;)?\)`)
+	mdBoldRe      = regexp.MustCompile(`\*\*(\S(?:.*?\S)?)\*\*`)
+	mdBoldUnderRe = regexp.MustCompile(`(^|[^\w])__(\S(?:.*?\S)?)__([^\w]|$)`)
+	mdItalicRe    = regexp.MustCompile(`(^|[^*\w])\*([^*\s](?:[^*]*[^*\s])?)\*`)
+	mdItalicUnder = regexp.MustCompile(`(^|[^\w])_([^_\s](?:[^_]*[^_\s])?)_([^\w]|$)`)
+	mdStrikeRe    = regexp.MustCompile(`~~(\S(?:.*?\S)?)~~`)
+	mdHolderRe    = regexp.MustCompile("\x00(\\d+)\x00")
+)
+
+// mdInlineCodeStyle — оформление `кода` внутри текста
+const mdInlineCodeStyle = "font-family: Monospace; background-color: #3A3A3A; color: #E6DB74;"
+
+// mdRenderer переводит Markdown в HTML; блоки кода отдаются функции code,
+// которая возвращает их HTML (подсветка, кнопка копирования)
+type mdRenderer struct {
+	sb    strings.Builder
+	code  func(lang, code string) string
+	tight bool // абзацы без <p> (содержимое пунктов списка)
+}
+
+// renderMarkdown возвращает HTML текста src для QTextBrowser
+func renderMarkdown(src string, code func(lang, code string) string) string {
+	r := &mdRenderer{code: code}
+	r.blocks(strings.Split(strings.ReplaceAll(src, "\r\n", "\n"), "\n"))
+	return r.sb.String()
+}
+
+// blocks разбирает строки на блоки
+func (r *mdRenderer) blocks(lines []string) {
+	for i := 0; i < len(lines); {
+		line := lines[i]
+		switch {
+		case strings.TrimSpace(line) == "":
+			i++
+		case mdFenceRe.MatchString(line):
+			i = r.fence(lines, i)
+		case mdHeadingRe.MatchString(line):
+			m := mdHeadingRe.FindStringSubmatch(line)
+			fmt.Fprintf(&r.sb, "<h%d>%s</h%d>", len(m[1]), mdInline(m[2]), len(m[1]))
+			i++
+		case isMdRule(line):
+			r.sb.WriteString("<hr>")
+			i++
+		case isMdQuote(line):
+			i = r.quote(lines, i)
+		case isMdTableStart(lines, i):
+			i = r.table(lines, i)
+		case mdListRe.MatchString(line):
+			i = r.list(lines, i)
+		default:
+			i = r.paragraph(lines, i)
+		}
+	}
+}
+
+// isMdBlockStart сообщает, что строка начинает новый блок и прерывает абзац
+func isMdBlockStart(lines []string, i int) bool {
+	line := lines[i]
+	return strings.TrimSpace(line) == "" || mdFenceRe.MatchString(line) || mdHeadingRe.MatchString(line) ||
+		isMdRule(line) || isMdQuote(line) || isMdTableStart(lines, i) || mdListRe.MatchString(line)
+}
+
+// isMdRule — горизонтальная линия: три и более "-", "*" или "_"
+func isMdRule(line string) bool {
+	s := strings.ReplaceAll(strings.TrimSpace(line), " ", "")
+	if len(s) < 3 || strings.Trim(s, s[:1]) != "" {
+		return false
+	}
+	return s[0] == '-' || s[0] == '*' || s[0] == '_'
+}
+
+func isMdQuote(line string) bool {
+	return strings.HasPrefix(strings.TrimLeft(line, " "), ">")
+}
+
+// isMdTableStart — строка с "|", за которой идёт разделитель заголовка таблицы
+func isMdTableStart(lines []string, i int) bool {
+	return i+1 < len(lines) && strings.Contains(lines[i], "|") &&
+		strings.Contains(lines[i+1], "-") && mdTableSepRe.MatchString(lines[i+1])
+}
+
+// fence выводит блок кода; незакрытый блок (оборванный ответ) идёт до конца текста
+func (r *mdRenderer) fence(lines []string, i int) int {
+	m := mdFenceRe.FindStringSubmatch(lines[i])
+	indent, marker, lang := len(m[1]), m[2], m[3]
+
+	var body []string
+	j := i + 1
+	for ; j < len(lines); j++ {
+		t := strings.TrimSpace(lines[j])
+		if strings.HasPrefix(t, marker) && strings.Trim(t, marker[:1]) == "" {
+			j++
+			break
+		}
+		body = append(body, trimIndent(lines[j], indent))
+	}
+	r.sb.WriteString(r.code(lang, strings.Trim(strings.Join(body, "\n"), "\n")))
+	return j
+}
+
+// trimIndent убирает до n пробелов в начале строки
+func trimIndent(line string, n int) string {
+	k := 0
+	for k < n && k < len(line) && line[k] == ' ' {
+		k++
+	}
+	return line[k:]
+}
+
+// leadingSpaces возвращает ширину отступа строки (табуляция — 4 пробела)
+func leadingSpaces(line string) int {
+	n := 0
+	for _, c := range line {
+		switch c {
+		case ' ':
+			n++
+		case '\t':
+			n += 4
+		default:
+			return n
+		}
+	}
+	return n
+}
+
+// quote выводит цитату; её содержимое разбирается как Markdown
+func (r *mdRenderer) quote(lines []string, i int) int {
+	var inner []string
+	for ; i < len(lines) && isMdQuote(lines[i]); i++ {
+		s := strings.TrimPrefix(strings.TrimLeft(lines[i], " "), ">")
+		inner = append(inner, strings.TrimPrefix(s, " "))
+	}
+	sub := &mdRenderer{code: r.code}
+	sub.blocks(inner)
+	fmt.Fprintf(&r.sb, "<blockquote style=\"color: #AAAAAA;\">%s</blockquote>", sub.sb.String())
+	return i
+}
+
+// list выводит список с одинаковым отступом маркеров; вложенные списки
+// и продолжения пунктов разбираются рекурсивно
+func (r *mdRenderer) list(lines []string, i int) int {
+	first := mdListRe.FindStringSubmatch(lines[i])
+	indent := leadingSpaces(first[1])
+	ordered := first[2][0] >= '0' && first[2][0] <= '9'
+
+	var items [][]string
+	contentIndent := 0
+	for i < len(lines) {
+		line := lines[i]
+		if strings.TrimSpace(line) == "" {
+			// Пустая строка не прерывает список, если за ней продолжение или следующий пункт
+			next := i + 1
+			for next < len(lines) && strings.TrimSpace(lines[next]) == "" {
+				next++
+			}
+			if next >= len(lines) || leadingSpaces(lines[next]) < contentIndent && !isMdListItem(lines[next], indent, ordered) {
+				break
+			}
+			items[len(items)-1] = append(items[len(items)-1], "")
+			i++
+			continue
+		}
+
+		m := mdListRe.FindStringSubmatch(line)
+		switch {
+		case m != nil && leadingSpaces(m[1]) == indent:
+			if isOrderedMarker(m[2]) != ordered {
+				return r.endList(items, ordered, first[2], i)
+			}
+			items = append(items, []string{m[4]})
+			contentIndent = indent + len(m[2]) + max(len(m[3]), 1)
+		case leadingSpaces(line) >= contentIndent || leadingSpaces(line) > indent:
+			items[len(items)-1] = append(items[len(items)-1], trimIndent(line, contentIndent))
+		case m == nil && !isMdBlockStart(lines, i):
+			// "Ленивое" продолжение абзаца пункта без отступа
+			items[len(items)-1] = append(items[len(items)-1], strings.TrimSpace(line))
+		default:
+			return r.endList(items, ordered, first[2], i)
+		}
+		i++
+	}
+	return r.endList(items, ordered, first[2], i)
+}
+
+func isOrderedMarker(marker string) bool {
+	return marker[0] >= '0' && marker[0] <= '9'
+}
+
+// isMdListItem — пункт списка того же вида с отступом indent
+func isMdListItem(line string, indent int, ordered bool) bool {
+	m := mdListRe.FindStringSubmatch(line)
+	return m != nil && leadingSpaces(m[1]) == indent && isOrderedMarker(m[2]) == ordered
+}
+
+// endList выводит собранные пункты и возвращает индекс следующей строки
+func (r *mdRenderer) endList(items [][]string, ordered bool, marker string, next int) int {
+	tag := "ul"
+	attrs := ""
+	if ordered {
+		tag = "ol"
+		if n, err := strconv.Atoi(strings.TrimRight(marker, ".)")); err == nil && n != 1 {
+			attrs = fmt.Sprintf(" start=\"%d\"", n)
+		}
+	}
+	fmt.Fprintf(&r.sb, "<%s%s>", tag, attrs)
+	for _, item := range items {
+		sub := &mdRenderer{code: r.code, tight: true}
+		sub.blocks(item)
+		fmt.Fprintf(&r.sb, "<li>%s</li>", sub.sb.String())
+	}
+	fmt.Fprintf(&r.sb, "</%s>", tag)
+	return next
+}
+
+// table выводит таблицу GitHub Flavored Markdown с выравниванием столбцов
+func (r *mdRenderer) table(lines []string, i int) int {
+	header := splitMdRow(lines[i])
+	var align []string
+	for _, cell := range splitMdRow(lines[i+1]) {
+		switch left, right := strings.HasPrefix(cell, ":"), strings.HasSuffix(cell, ":"); {
+		case left && right:
+			align = append(align, "center")
+		case right:
+			align = append(align, "right")
+		default:
+			align = append(align, "left")
+		}
+	}
+	cell := func(tag string, col int, text string) {
+		a := "left"
+		if col < len(align) {
+			a = align[col]
+		}
+		fmt.Fprintf(&r.sb, "<%s align=\"%s\">%s</%s>", tag, a, mdInline(text), tag)
+	}
+
+	r.sb.WriteString("<table border=\"1\" cellspacing=\"0\" cellpadding=\"4\" style=\"border-color: #555555; margin: 4px 0;\"><tr>")
+	for col, text := range header {
+		cell("th", col, text)
+	}
+	r.sb.WriteString("</tr>")
+	for i += 2; i < len(lines) && strings.TrimSpace(lines[i]) != "" && strings.Contains(lines[i], "|"); i++ {
+		r.sb.WriteString("<tr>")
+		for col, text := range splitMdRow(lines[i]) {
+			cell("td", col, text)
+		}
+		r.sb.WriteString("</tr>")
+	}
+	r.sb.WriteString("</table>")
+	return i
+}
+
+// splitMdRow делит строку таблицы на ячейки; "|" внутри `кода` и "\|" не разделяют
+func splitMdRow(line string) []string {
+	line = strings.TrimSpace(line)
+	line = strings.TrimPrefix(line, "|")
+	if strings.HasSuffix(line, "|") && !strings.HasSuffix(line, "\\|") {
+		line = line[:len(line)-1]
+	}
+
+	var cells []string
+	var cur strings.Builder
+	inCode := false
+	for k := 0; k < len(line); k++ {
+		c := line[k]
+		switch {
+		case c == '\\' && k+1 < len(line) && line[k+1] == '|':
+			cur.WriteByte('|')
+			k++
+		case c == '`':
+			inCode = !inCode
+			cur.WriteByte(c)
+		case c == '|' && !inCode:
+			cells = append(cells, strings.TrimSpace(cur.String()))
+			cur.Reset()
+		default:
+			cur.WriteByte(c)
+		}
+	}
+	return append(cells, strings.TrimSpace(cur.String()))
+}
+
+// paragraph выводит абзац; переводы строк внутри него сохраняются
+func (r *mdRenderer) paragraph(lines []string, i int) int {
+	start := i
+	for i++; i < len(lines) && !isMdBlockStart(lines, i); i++ {
+	}
+	text := strings.ReplaceAll(mdInline(strings.Join(lines[start:i], "\n")), "\n", "<br>")
+	if r.tight {
+		r.sb.WriteString(text)
+	} else {
+		fmt.Fprintf(&r.sb, "<p>%s</p>", text)
+	}
+	return i
+}
+
+// mdInline переводит inline-разметку: `код`, **жирный**, *курсив*, ~~зачёркнутый~~,
+// ссылки и картинки (картинки показываются ссылками). Остальной текст экранируется.
+func mdInline(text string) string {
+	// NUL служит меткой подстановок, поэтому из ответа он убирается
+	text = strings.ReplaceAll(text, "\x00", "\uFFFD")
+	var held []string
+	hold := func(s string) string {
+		held = append(held, s)
+		return fmt.Sprintf("\x00%d\x00", len(held)-1)
+	}
+
+	// Код не размечается, поэтому вынимается первым
+	var sb strings.Builder
+	for {
+		start := strings.IndexByte(text, '`')
+		if start < 0 {
+			sb.WriteString(text)
+			break
+		}
+		n := 1
+		for start+n < len(text) && text[start+n] == '`' {
+			n++
+		}
+		ticks := text[start : start+n]
+		end := strings.Index(text[start+n:], ticks)
+		if end < 0 {
+			sb.WriteString(text[:start+n])
+			text = text[start+n:]
+			continue
+		}
+		code := strings.TrimSpace(text[start+n : start+n+end])
+		sb.WriteString(text[:start])
+		sb.WriteString(hold(fmt.Sprintf("<code style=\"%s\">%s</code>", mdInlineCodeStyle, html.EscapeString(code))))
+		text = text[start+n+end+n:]
+	}
+	text = sb.String()
+
+	text = mdEscapeRe.ReplaceAllStringFunc(text, func(s string) string { return hold(html.EscapeString(s[1:])) })
+	text = html.EscapeString(text)
+
+	// Переходить можно только по внешним ссылкам: схемы вроде copycode: служат кнопкам чата
+	link := func(label, url string) string {
+		if !strings.HasPrefix(url, "http://") && !strings.HasPrefix(url, "https://") && !strings.HasPrefix(url, "mailto:") {
+			return label
+		}
+		return hold(fmt.Sprintf("<a href=\"%s\">%s</a>", url, mdEmphasis(label)))
+	}
+	text = mdImageRe.ReplaceAllStringFunc(text, func(s string) string {
+		m := mdImageRe.FindStringSubmatch(s)
+		label := m[1]
+		if label == "" {
+			label = "image"
+		}
+		return link("🖼 "+label, m[2])
+	})
+	text = mdLinkRe.ReplaceAllStringFunc(text, func(s string) string {
+		m := mdLinkRe.FindStringSubmatch(s)
+		return link(m[1], m[2])
+	})
+
+	text = mdEmphasis(text)
+	// Вложенные подстановки (ссылка с кодом в подписи) раскрываются за несколько проходов;
+	// подстановка содержит только более ранние метки, так что проходов не больше len(held)
+	for pass := 0; pass <= len(held) && mdHolderRe.MatchString(text); pass++ {
+		text = mdHolderRe.ReplaceAllStringFunc(text, func(s string) string {
+			n, err := strconv.Atoi(strings.Trim(s, "\x00"))
+			if err != nil || n >= len(held) {
+				return ""
+			}
+			return held[n]
+		})
+	}
+	return text
+}
+
+// mdEmphasis размечает жирный, курсив и зачёркнутый текст в уже экранированной строке
+func mdEmphasis(text string) string {
+	text = mdBoldRe.ReplaceAllString(text, "<b>$1</b>")
+	text = mdBoldUnderRe.ReplaceAllString(text, "$1<b>$2</b>$3")
+	text = mdItalicRe.ReplaceAllString(text, "$1<i>$2</i>")
+	text = mdItalicUnder.ReplaceAllString(text, "$1<i>$2</i>$3")
+	return mdStrikeRe.ReplaceAllString(text, "<s>$1</s>")
+}
+
+// chromaStyle подбирает стиль chroma по имени цветовой схемы редактора
+// ("One Dark" → onedark, "GitHub Dark" → github-dark); по умолчанию monokai
+func chromaStyle(scheme string) *chroma.Style {
+	name := strings.ToLower(scheme)
+	for _, candidate := range []string{strings.ReplaceAll(name, " ", "-"), strings.ReplaceAll(name, " ", "")} {
+		if style, ok := styles.Registry[candidate]; ok {
+			return style
+		}
+	}
+	return styles.Get("monokai")
+}
+
+// highlightCode возвращает блок <pre> с кодом, раскрашенным chroma.
+// Язык берётся из метки блока, без неё определяется по содержимому.
+func highlightCode(lang, code string, style *chroma.Style) string {
+	lexer := lexers.Get(lang)
+	if lexer == nil {
+		lexer = lexers.Analyse(code)
+	}
+	if lexer == nil {
+		lexer = lexers.Fallback
+	}
+
+	base := style.Get(chroma.Background)
+	bg, fg := "#2E2E2E", "#DCDCDC"
+	if base.Background.IsSet() {
+		bg = base.Background.String()
+	}
+	if base.Colour.IsSet() {
+		fg = base.Colour.String()
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "<pre style=\"background-color: %s; color: %s; padding: 10px; white-space: pre-wrap; margin-top: 0;\">", bg, fg)
+	it, err := chroma.Coalesce(lexer).Tokenise(nil, code)
+	if err != nil {
+		sb.WriteString(html.EscapeString(code))
+		sb.WriteString("</pre>")
+		return sb.String()
+	}
+	for tok := it(); tok != chroma.EOF; tok = it() {
+		text := html.EscapeString(tok.Value)
+		if css := tokenCSS(style.Get(tok.Type), fg); css != "" {
+			fmt.Fprintf(&sb, "<span style=\"%s\">%s</span>", css, text)
+		} else {
+			sb.WriteString(text)
+		}
+	}
+	sb.WriteString("</pre>")
+	return sb.String()
+}
+
+// tokenCSS — стиль токена; цвет, совпадающий с основным, не выводится
+func tokenCSS(entry chroma.StyleEntry, fg string) string {
+	var css []string
+	if entry.Colour.IsSet() && entry.Colour.String() != fg {
+		css = append(css, "color: "+entry.Colour.String())
+	}
+	if entry.Bold == chroma.Yes {
+		css = append(css, "font-weight: bold")
+	}
+	if entry.Italic == chroma.Yes {
+		css = append(css, "font-style: italic")
+	}
+	if entry.Underline == chroma.Yes {
+		css = append(css, "text-decoration: underline")
+	}
+	return strings.Join(css, "; ")
+}
@@ -0,0 +1,95 @@
+package ui
+
+import (
+	"html"
+	"strings"
+	"testing"
+)
+
+func TestMdInlineIgnoresNulMarkers(t *testing.T) {
+	for _, text := range []string{
+		"`\x000\x00`",
+		"\x007\x00 and `x`",
+		"[`\x001\x00`](https://example.com) \x000\x00",
+	} {
+		out := mdInline(text)
+		if strings.Contains(out, "\x00") {
+			t.Errorf("mdInline(%q) = %q: placeholder left in the output", text, out)
+		}
+	}
+	if out := mdInline("[`code`](https://example.com)"); out != `<a href="https://example.com"><code style="`+mdInlineCodeStyle+`">code</code></a>` {
+		t.Errorf("nested placeholders: %q", out)
+	}
+}
+
+// testCodeBlock заменяет подсветку блоков кода в тестах
+func testCodeBlock(lang, code string) string {
+	return "<pre lang=\"" + lang + "\">" + html.EscapeString(code) + "</pre>"
+}
+
+func TestRenderMarkdown(t *testing.T) {
+	code := func(s string) string { return `<code style="` + mdInlineCodeStyle + `">` + s + `</code>` }
+	tests := []struct {
+		name, src, want string
+	}{
+		{"headings", "# Title\n### Sub *x* ###\n####### seven", "<h1>Title</h1><h3>Sub <i>x</i></h3><p>####### seven</p>"},
+		{"heading escapes html", "## <b>x</b> & y", "<h2>&lt;b&gt;x&lt;/b&gt; &amp; y</h2>"},
+		{"unordered list", "- one\n- **two**\n", "<ul><li>one</li><li><b>two</b></li></ul>"},
+		{"ordered list start", "3. c\n4. d", `<ol start="3"><li>c</li><li>d</li></ol>`},
+		{"nested list", "- a\n  - b\n  - c\n- d", "<ul><li>a<ul><li>b</li><li>c</li></ul></li><li>d</li></ul>"},
+		{"list kinds do not mix", "- a\n1. b", "<ul><li>a</li></ul><ol><li>b</li></ol>"},
+		{"lazy continuation", "- a\nmore\n\ntext", "<ul><li>a<br>more</li></ul><p>text</p>"},
+		{
+			"table",
+			"| Name | Size |\n|:-----|-----:|\n| `a|b` | 1 |\n| x \\| y | <2> |\n\nafter",
+			`<table border="1" cellspacing="0" cellpadding="4" style="border-color: #555555; margin: 4px 0;">` +
+				`<tr><th align="left">Name</th><th align="right">Size</th></tr>` +
+				`<tr><td align="left">` + code("a|b") + `</td><td align="right">1</td></tr>` +
+				`<tr><td align="left">x | y</td><td align="right">&lt;2&gt;</td></tr></table><p>after</p>`,
+		},
+		{"fenced code", "text\n```go\nfmt.Println(\"<hi>\")\n```\nend", `<p>text</p><pre lang="go">fmt.Println(&#34;&lt;hi&gt;&#34;)</pre><p>end</p>`},
+		{"tilde fence keeps markdown", "~~~\n# not a heading\n- not a list\n~~~", "<pre lang=\"\"># not a heading\n- not a list</pre>"},
+		{"unclosed fence", "```python\nprint(1)\n", `<pre lang="python">print(1)</pre>`},
+		{"indented fence in list", "- step\n\n  ```sh\n  go test\n  ```", `<ul><li>step<pre lang="sh">go test</pre></li></ul>`},
+		{"quote", "> **note**\n> - item", `<blockquote style="color: #AAAAAA;"><p><b>note</b></p><ul><li>item</li></ul></blockquote>`},
+		{"rule", "a\n\n---\n\nb", "<p>a</p><hr><p>b</p>"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := renderMarkdown(tt.src, testCodeBlock); got != tt.want {
+				t.Fatalf("got:\n%s\nwant:\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMdInline(t *testing.T) {
+	code := func(s string) string { return `<code style="` + mdInlineCodeStyle + `">` + s + `</code>` }
+	tests := []struct {
+		name, text, want string
+	}{
+		{"code is not formatted", "use `**x** [a](https://a.b)` here", "use " + code("**x** [a](https://a.b)") + " here"},
+		{"code keeps redaction placeholders", "`key = \"[REDACTED:api key]\"`", code("key = &#34;[REDACTED:api key]&#34;")},
+		{"code with placeholder markers", "`\x001\x00` **\x000\x00**", code("�1�") + " <b>�0�</b>"},
+		{"double backticks", "``a ` b``", code("a ` b")},
+		{"unclosed backtick", "a `b *c*", "a `b <i>c</i>"},
+		{"html escaped", "<script>alert(1)</script> & co", "&lt;script&gt;alert(1)&lt;/script&gt; &amp; co"},
+		{"backslash escapes", `\*not italic\* \[x\]`, "*not italic* [x]"},
+		{"emphasis", "**b** *i* __u__ _e_ ~~s~~ snake_case_name", "<b>b</b> <i>i</i> <b>u</b> <i>e</i> <s>s</s> snake_case_name"},
+		{"link text escaped", "[<b>click</b> & go](https://example.com)", `<a href="https://example.com">&lt;b&gt;click&lt;/b&gt; &amp; go</a>`},
+		{"link url escaped", `[q](https://example.com/?a=1&b="x"<y>)`, `<a href="https://example.com/?a=1&amp;b=&#34;x&#34;&lt;y&gt;">q</a>`},
+		{"link title dropped", `[t](https://example.com "Title")`, `<a href="https://example.com">t</a>`},
+		{"attribute injection", `[x](https://e.com/"onmouseover="alert(1))`, `<a href="https://e.com/&#34;onmouseover=&#34;alert(1">x</a>)`},
+		{"javascript link", "[run](javascript:alert(1))", "run)"},
+		{"internal scheme", "[copy](copycode:0)", "copy"},
+		{"image as link", "![<logo>](https://e.com/l.png)", `<a href="https://e.com/l.png">🖼 &lt;logo&gt;</a>`},
+		{"mailto", "[mail](mailto:a@b.c)", `<a href="mailto:a@b.c">mail</a>`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := mdInline(tt.text); got != tt.want {
+				t.Fatalf("mdInline(%q):\ngot:  %s\nwant: %s", tt.text, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,198 @@
+package ui
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/therecipe/qt/widgets"
+
+	"go-gnome-editor/internal/logic"
+)
+
+// settingsMenuActions хранит пункты меню, отражающие сохраняемые настройки,
+// чтобы синхронизировать их состояние после загрузки настроек проекта.
+type settingsMenuActions struct {
+	lineNumbers  *widgets.QAction
+	lineComplete *widgets.QAction
+	autoComplete *widgets.QAction
+	fim          *widgets.QAction
+	declContext  *widgets.QAction
+	codeIndex    *widgets.QAction
+	redact       *widgets.QAction
+	requestLog   *widgets.QAction
+	schemes      map[string]*widgets.QAction
+	cursors      map[string]*widgets.QAction
+}
+
+// applyConfig переносит настройки в состояние окна и редакторов.
+// Может вызываться как до построения UI, так и после (при открытии проекта).
+func (e *EditorWindow) applyConfig(cfg *logic.Config) {
+	e.LLMProvider = cfg.Provider
+	e.LLMModel = cfg.Model
+	e.LLMKey, e.llmKeyProvider = cfg.APIKey, cfg.Provider
+	if err := logic.SetProviderProfiles(cfg.Profiles); err != nil {
+		// UI может быть ещё не построен — дублируем в stderr
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		e.Window.StatusBar().ShowMessage(err.Error(), 5000)
+	}
+	logic.SetRetryPolicy(cfg.RetryPolicy())
+	logic.SetFallbackProviders(cfg.LLMFallbacks)
+	logic.SetModelContextLimits(cfg.ModelContextLimits)
+	logic.SetSystemPrompt(cfg.SystemPrompt)
+	logic.SetVisionModels(cfg.VisionModels)
+	logic.SetRequestLog(cfg.RequestLogFile())
+	if err := logic.SetResponseCache(cfg.ResponseCacheSettings()); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	}
+	if err := logic.SetRedaction(cfg.Redaction()); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		e.Window.StatusBar().ShowMessage(err.Error(), 5000)
+	}
+
+	e.setAITasks(cfg.Tasks)
+
+	e.AIHistoryContextSize = cfg.AIHistoryContextSize
+	e.AIUseOpenTabsAsContext = cfg.AIUseOpenTabsAsContext
+	e.UseCodeIndex = cfg.AICodeIndex
+	e.RunArgs = cfg.RunArgs
+
+	tm := e.TabManager
+	tm.applyColorScheme(cfg.ColorScheme)
+	tm.applyCursorStyle(cfg.CursorStyle)
+	tm.SetLineNumbersVisible(cfg.ShowLineNumbers)
+	tm.SetLineCompleteEnabled(cfg.AILineCompleteEnabled)
+	tm.SetAutoCompleteEnabled(cfg.AIAutoCompleteEnabled)
+	tm.FIMEnabled = cfg.AIFIMEnabled
+	tm.DeclContextEnabled = cfg.AIDeclContext
+
+	e.applyCLIOverrides()
+}
+
+// applyCLIOverrides применяет флаги командной строки поверх настроек из файлов
+func (e *EditorWindow) applyCLIOverrides() {
+	if e.CLIOverrides.Provider != "" {
+		e.LLMProvider = e.CLIOverrides.Provider
+	}
+	if e.CLIOverrides.Model != "" {
+		e.LLMModel = e.CLIOverrides.Model
+	}
+	if e.CLIOverrides.APIKey != "" {
+		e.LLMKey, e.llmKeyProvider = e.CLIOverrides.APIKey, e.LLMProvider
+	}
+}
+
+// setAITasks задаёт настройки видов запросов; флаги командной строки имеют приоритет
+func (e *EditorWindow) setAITasks(tasks map[logic.AITask]logic.TaskModel) {
+	e.aiTasks = make(map[logic.AITask]logic.TaskModel, len(tasks))
+	for task, m := range tasks {
+		e.aiTasks[task] = m
+	}
+	for task, over := range e.CLIOverrides.Tasks {
+		e.aiTasks[task] = e.aiTasks[task].Merge(over)
+	}
+}
+
+// llmTarget возвращает провайдер, модель, ключ и таймаут для вида запроса task
+func (e *EditorWindow) llmTarget(task logic.AITask) logic.LLMTarget {
+	chat := logic.LLMTarget{Provider: e.LLMProvider, Model: e.LLMModel, APIKey: e.explicitKey()}
+	target := logic.ResolveTask(task, chat, e.aiTasks[task])
+	if target.Model == "" {
+		target.Model = e.Config.LastModel(target.Provider)
+	}
+	target.Vars = e.promptVars(task)
+	return target
+}
+
+// explicitKey возвращает явно заданный ключ, если он относится к текущему провайдеру.
+// Пустая строка — ключ ищется в окружении и хранилищах (logic.ResolveAPIKey).
+func (e *EditorWindow) explicitKey() string {
+	if e.llmKeyProvider != e.LLMProvider {
+		return ""
+	}
+	return e.LLMKey
+}
+
+// loadProjectConfig применяет переопределения из <root>/.golite/config.json;
+// без файла проекта возвращаются глобальные настройки (сбрасываются переопределения
+// предыдущего проекта)
+func (e *EditorWindow) loadProjectConfig(root string) {
+	e.loadProjectPrompt(root)
+
+	merged, ignored, err := e.Config.WithProjectOverrides(root)
+	switch {
+	case err != nil:
+		e.Window.StatusBar().ShowMessage(fmt.Sprintf("Project settings ignored: %v", err), 5000)
+	case len(ignored) > 0:
+		e.Window.StatusBar().ShowMessage(fmt.Sprintf("Project settings not allowed in %s ignored: %s",
+			logic.ProjectConfigPath(root), strings.Join(ignored, ", ")), 8000)
+	}
+
+	e.applyConfig(merged)
+	e.syncSettingsMenus()
+	e.syncModelPicker()
+	e.UpdateAIContextDisplay()
+}
+
+// saveConfig записывает глобальные настройки; ошибка показывается в статусной строке
+func (e *EditorWindow) saveConfig() {
+	if e.Config == nil {
+		return
+	}
+	if err := e.Config.Save(); err != nil {
+		e.Window.StatusBar().ShowMessage(fmt.Sprintf("Failed to save settings: %v", err), 5000)
+	}
+}
+
+// saveRunArgs сохраняет аргументы запуска: для открытого проекта — в его настройки,
+// иначе — в глобальные.
+func (e *EditorWindow) saveRunArgs() {
+	if e.ProjectManager.IsActive {
+		if err := logic.SaveProjectSetting(e.ProjectManager.RootPath, "run_args", e.RunArgs); err != nil {
+			e.Window.StatusBar().ShowMessage(fmt.Sprintf("Failed to save project settings: %v", err), 5000)
+		}
+		return
+	}
+	e.Config.RunArgs = e.RunArgs
+	e.saveConfig()
+}
+
+// syncSettingsMenus приводит отметки пунктов меню в соответствие с текущим состоянием
+func (e *EditorWindow) syncSettingsMenus() {
+	acts := e.settingsActions
+	tm := e.TabManager
+
+	if acts.lineNumbers != nil {
+		acts.lineNumbers.SetChecked(tm.IsLineNumbersVisible())
+	}
+	if acts.lineComplete != nil {
+		acts.lineComplete.SetChecked(tm.IsLineCompleteEnabled())
+	}
+	if acts.autoComplete != nil {
+		acts.autoComplete.SetChecked(tm.IsAutoCompleteEnabled())
+	}
+	if acts.fim != nil {
+		acts.fim.SetChecked(tm.FIMEnabled)
+	}
+	if acts.declContext != nil {
+		acts.declContext.SetChecked(tm.DeclContextEnabled)
+	}
+	if acts.codeIndex != nil {
+		acts.codeIndex.SetChecked(e.UseCodeIndex)
+	}
+	if acts.redact != nil {
+		acts.redact.SetChecked(e.Config.RedactSecrets)
+	}
+	if acts.requestLog != nil {
+		acts.requestLog.SetChecked(e.Config.RequestLog)
+	}
+	if act, ok := acts.schemes[tm.GetCurrentSchemeName()]; ok {
+		act.SetChecked(true)
+	}
+	if act, ok := acts.cursors[tm.GetCurrentCursorStyleName()]; ok {
+		act.SetChecked(true)
+	}
+	if e.actUseTabsContext != nil {
+		e.actUseTabsContext.SetChecked(e.AIUseOpenTabsAsContext)
+	}
+}
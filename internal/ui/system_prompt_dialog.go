@@ -0,0 +1,151 @@
+package ui
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/therecipe/qt/core"
+	"github.com/therecipe/qt/widgets"
+
+	"go-gnome-editor/internal/logic"
+)
+
+// promptVarsHelp — подсказка о переменных шаблона системного промпта
+const promptVarsHelp = "Variables: {{language}} {{file}} {{module}} {{project}} {{task}}"
+
+// promptVars собирает значения переменных системного промпта для текущего файла и проекта
+func (e *EditorWindow) promptVars(task logic.AITask) logic.PromptVars {
+	vars := logic.PromptVars{Task: task}
+	root := ""
+	if e.ProjectManager.IsActive {
+		root = e.ProjectManager.RootPath
+		vars.Project = filepath.Base(root)
+		vars.Module = logic.ModulePath(root)
+	}
+
+	ed := e.TabManager.CurrentEditor()
+	if ed == nil {
+		return vars
+	}
+	vars.Language = e.TabManager.detectLanguageFromPath(ed.FilePath)
+	vars.File = ed.FilePath
+	if root != "" && ed.FilePath != "" {
+		if rel, err := filepath.Rel(root, ed.FilePath); err == nil && !strings.HasPrefix(rel, "..") {
+			vars.File = rel
+		}
+	}
+	return vars
+}
+
+// loadProjectPrompt читает системный промпт проекта (.golite/prompt.md)
+func (e *EditorWindow) loadProjectPrompt(root string) {
+	found, err := logic.LoadProjectPrompt(root)
+	if err != nil {
+		e.Window.StatusBar().ShowMessage(err.Error(), 5000)
+		return
+	}
+	if found {
+		e.Window.StatusBar().ShowMessage("Using project system prompt "+logic.ProjectPromptPath(root), 3000)
+	}
+}
+
+// describePromptSource — где задан действующий системный промпт провайдера
+func (e *EditorWindow) describePromptSource(provider string) string {
+	_, source := logic.SystemPromptTemplate(provider)
+	switch source {
+	case logic.PromptSourceProject:
+		return fmt.Sprintf("project file %s (overrides the prompt below)", logic.ProjectPromptPath(e.ProjectManager.RootPath))
+	case logic.PromptSourceProfile:
+		return fmt.Sprintf("system_prompt of profile %q (overrides the prompt below)", provider)
+	case logic.PromptSourceSettings:
+		return "the prompt below"
+	}
+	return "built-in default"
+}
+
+// showSystemPromptDialog показывает редактор шаблона системного промпта из настроек
+func (e *EditorWindow) showSystemPromptDialog() {
+	dlg := widgets.NewQDialog(e.Window, core.Qt__Dialog)
+	dlg.SetWindowTitle("System Prompt")
+
+	layout := widgets.NewQVBoxLayout()
+	active := widgets.NewQLabel2(fmt.Sprintf("Active for %s: %s", e.LLMProvider, e.describePromptSource(e.LLMProvider)), nil, 0)
+	active.SetWordWrap(true)
+	layout.AddWidget(active, 0, 0)
+
+	hint := widgets.NewQLabel2(promptVarsHelp, nil, 0)
+	hint.SetStyleSheet("color: #888;")
+	hint.SetTextInteractionFlags(core.Qt__TextSelectableByMouse)
+	layout.AddWidget(hint, 0, 0)
+
+	text := widgets.NewQPlainTextEdit(nil)
+	text.SetStyleSheet("font-family: Monospace;")
+	if e.Config.SystemPrompt != "" {
+		text.SetPlainText(e.Config.SystemPrompt)
+	} else {
+		text.SetPlainText(logic.DefaultSystemPrompt)
+	}
+	layout.AddWidget(text, 1, 0)
+
+	tools := widgets.NewQHBoxLayout()
+	btnDefault := widgets.NewQPushButton2("Reset to Default", nil)
+	btnDefault.ConnectClicked(func(bool) { text.SetPlainText(logic.DefaultSystemPrompt) })
+	tools.AddWidget(btnDefault, 0, 0)
+
+	btnProject := widgets.NewQPushButton2("Edit Project Prompt", nil)
+	btnProject.SetToolTip("Open .golite/prompt.md of the current project (created from the text above if missing)")
+	btnProject.SetEnabled(e.ProjectManager.IsActive)
+	btnProject.ConnectClicked(func(bool) {
+		if e.openProjectPrompt(text.ToPlainText()) {
+			dlg.Reject()
+		}
+	})
+	tools.AddWidget(btnProject, 0, 0)
+	tools.AddStretch(1)
+	layout.AddLayout(tools, 0)
+
+	buttons := widgets.NewQDialogButtonBox3(widgets.QDialogButtonBox__Ok|widgets.QDialogButtonBox__Cancel, nil)
+	buttons.ConnectAccepted(dlg.Accept)
+	buttons.ConnectRejected(dlg.Reject)
+	layout.AddWidget(buttons, 0, 0)
+	dlg.SetLayout(layout)
+	dlg.Resize2(720, 480)
+
+	if dlg.Exec() != int(widgets.QDialog__Accepted) {
+		return
+	}
+
+	// Встроенный промпт не сохраняется: он может измениться в новой версии
+	tmpl := strings.TrimSpace(text.ToPlainText())
+	if tmpl == strings.TrimSpace(logic.DefaultSystemPrompt) {
+		tmpl = ""
+	}
+	e.Config.SystemPrompt = tmpl
+	logic.SetSystemPrompt(tmpl)
+	e.saveConfig()
+	e.Window.StatusBar().ShowMessage("System prompt saved", 3000)
+}
+
+// openProjectPrompt открывает .golite/prompt.md проекта, создавая его из initial.
+// Промпт перечитывается при сохранении файла (onFileSaved).
+func (e *EditorWindow) openProjectPrompt(initial string) bool {
+	if !e.ProjectManager.IsActive {
+		return false
+	}
+	path := logic.ProjectPromptPath(e.ProjectManager.RootPath)
+	if !fileExists(path) {
+		err := os.MkdirAll(filepath.Dir(path), 0755)
+		if err == nil {
+			err = os.WriteFile(path, []byte(strings.TrimSpace(initial)+"\n"), 0644)
+		}
+		if err != nil {
+			e.Window.StatusBar().ShowMessage(fmt.Sprintf("Failed to create %s: %v", path, err), 5000)
+			return false
+		}
+		e.loadProjectPrompt(e.ProjectManager.RootPath)
+	}
+	e.TabManager.OpenFile(path)
+	return true
+}
@@ -1,6 +1,7 @@
 package ui
 
 import (
+	"context"
 	"fmt"
 	"path/filepath"
 	"strings"
@@ -26,6 +27,7 @@ type CodeEditorTab struct {
 	SuggestionEndPos       int    // Позиция конца предложения (для удаления при отклонении)
 	HasSuggestion          bool   // Флаг наличия активного предложения
 	IsWaitingLLM           bool   // Флаг ожидания ответа от LLM
+	llmCancel              context.CancelFunc // Отмена ожидаемого запроса к LLM (Escape)
 	IsLineSuggestion       bool
 	BracketHighlightActive bool
 	BracketPos1            int
@@ -42,6 +44,8 @@ type TabManager struct {
 	CurrentScheme       *ColorScheme
 	AutoCompleteEnabled bool
 	LineCompleteEnabled bool
+	FIMEnabled          bool // дополнение через fill-in-the-middle, если модель поддерживает
+	DeclContextEnabled  bool // объявления Go из модуля в запросах AI (см. goDeclRequest)
 	CurrentCursorStyle  *CursorStyle
 }
 
@@ -422,6 +426,9 @@ func (tm *TabManager) addTab(path, content string) {
 			return
 		}
 
+		// Размер контекста AI зависит от содержимого вкладок
+		tm.Parent.scheduleAIContextUpdate()
+
 		if !editor.IsModified {
 			editor.IsModified = true
 			// Обновляем заголовок вкладки, добавляя звёздочку
@@ -572,6 +579,7 @@ func (tm *TabManager) saveEditor(ed *CodeEditorTab, path string) bool {
 	tm.Tabs.SetTabToolTip(idx, path)
 
 	tm.Parent.Window.StatusBar().ShowMessage("Saved: "+filepath.Base(path), 2000)
+	tm.Parent.onFileSaved(path)
 	return true
 }
 
@@ -625,7 +633,10 @@ func (tm *TabManager) CloseTab(index int) bool {
 		}
 	}
 
-	// 3. Remove from UI and internal slice
+	// 3. Прерываем незавершённый запрос к LLM для этой вкладки
+	tm.CancelLLMRequest(ed)
+
+	// 4. Remove from UI and internal slice
 	tm.Tabs.RemoveTab(index)
 	if ed.Widget != nil {
 		ed.Widget.DeleteLater()
@@ -713,7 +724,12 @@ func (tm *TabManager) updateLineNumbers(editor *CodeEditorTab) {
 
 // NEW: ToggleLineNumbers переключает отображение номеров строк
 func (tm *TabManager) ToggleLineNumbers() {
-	tm.ShowLineNumbers = !tm.ShowLineNumbers
+	tm.SetLineNumbersVisible(!tm.ShowLineNumbers)
+}
+
+// SetLineNumbersVisible включает/выключает номера строк во всех редакторах
+func (tm *TabManager) SetLineNumbersVisible(visible bool) {
+	tm.ShowLineNumbers = visible
 
 	// Обновляем все открытые редакторы
 	for _, editor := range tm.Editors {
@@ -1137,9 +1153,19 @@ func (tm *TabManager) applySchemeToEditor(editor *CodeEditorTab) {
 
 // SetColorScheme устанавливает цветовую схему для всех редакторов
 func (tm *TabManager) SetColorScheme(schemeName string) {
+	if !tm.applyColorScheme(schemeName) {
+		return
+	}
+
+	tm.Parent.Window.StatusBar().ShowMessage(
+		fmt.Sprintf("Color scheme changed to: %s", schemeName), 2000)
+}
+
+// applyColorScheme применяет схему без сообщений в статусной строке (загрузка настроек)
+func (tm *TabManager) applyColorScheme(schemeName string) bool {
 	scheme, ok := ColorSchemes[schemeName]
 	if !ok {
-		return
+		return false
 	}
 
 	tm.CurrentScheme = scheme
@@ -1153,9 +1179,7 @@ func (tm *TabManager) SetColorScheme(schemeName string) {
 			editor.Highlighter.SetScheme(scheme)
 		}
 	}
-
-	tm.Parent.Window.StatusBar().ShowMessage(
-		fmt.Sprintf("Color scheme changed to: %s", schemeName), 2000)
+	return true
 }
 
 // GetCurrentSchemeName возвращает имя текущей схемы
@@ -1315,24 +1339,35 @@ IMPORTANT INSTRUCTIONS:
 Complete the code:`, fileInfo, contextBuilder.String())
 
 	// Показываем индикатор загрузки
-	ed.IsWaitingLLM = true
+	target := tm.Parent.llmTarget(logic.TaskMultiLine)
+	fim := tm.fimRequest(ed, target, false)
+	decls := tm.goDeclRequest(ed, false, completionDeclBytes)
+	ctx := tm.beginLLMRequest(ed, target.Timeout)
 	ed.SuggestionStartPos = cursor.Position()
-	tm.Parent.Window.StatusBar().ShowMessage("⏳ Waiting for AI suggestion...", 0)
+	tm.Parent.Window.StatusBar().ShowMessage("⏳ Waiting for AI suggestion... (Esc to cancel)", 0)
 
 	// Запускаем запрос к LLM в отдельной горутине
 	go func() {
-		resp, err := logic.SendMessageToLLM(prompt, tm.Parent.LLMProvider, tm.Parent.LLMModel, tm.Parent.LLMKey)
+		prompt := addGoDecls(decls, prompt, fim)
+		res, usedFIM, err := tm.completeCode(ctx, target, fim, prompt)
 
 		tm.Parent.RunOnUIThread(func() {
-			ed.IsWaitingLLM = false
+			if !tm.finishLLMRequest(ed, ctx) {
+				return
+			}
 
 			if err != nil {
 				tm.Parent.Window.StatusBar().ShowMessage(fmt.Sprintf("AI Error: %v", err), 3000)
 				return
 			}
 
+			tm.reportFallback(res)
+
 			// Очищаем ответ от возможных markdown-обёрток
-			suggestion := tm.cleanLLMResponse(resp)
+			suggestion := tm.cleanLLMResponse(res.Text)
+			if usedFIM {
+				suggestion = cleanFIMResponse(res.Text, false)
+			}
 
 			if suggestion == "" {
 				tm.Parent.Window.StatusBar().ShowMessage("AI returned empty suggestion", 2000)
@@ -1456,24 +1491,24 @@ STRICT RULES:
 Complete this line:`, fileInfo, contextBuilder.String(), textBeforeCursor, textAfterCursor)
 
 	// Показываем индикатор загрузки
-	ed.IsWaitingLLM = true
+	// Для inline completion по умолчанию используется более короткий таймаут
+	target := tm.Parent.llmTarget(logic.TaskLineComplete)
+	fim := tm.fimRequest(ed, target, true)
+	decls := tm.goDeclRequest(ed, false, completionDeclBytes)
+	ctx := tm.beginLLMRequest(ed, target.Timeout)
 	ed.IsLineSuggestion = true // Помечаем как однострочное
 	ed.SuggestionStartPos = cursor.Position()
-	tm.Parent.Window.StatusBar().ShowMessage("⏳ Completing line...", 0)
+	tm.Parent.Window.StatusBar().ShowMessage("⏳ Completing line... (Esc to cancel)", 0)
 
 	// Запускаем запрос к LLM в отдельной горутине
 	go func() {
-		// Используем более короткий таймаут для inline completion
-		resp, err := logic.SendMessageToLLMWithTimeout(
-			prompt,
-			tm.Parent.LLMProvider,
-			tm.Parent.LLMModel,
-			tm.Parent.LLMKey,
-			30*time.Second, // Короткий таймаут
-		)
+		prompt := addGoDecls(decls, prompt, fim)
+		res, usedFIM, err := tm.completeCode(ctx, target, fim, prompt)
 
 		tm.Parent.RunOnUIThread(func() {
-			ed.IsWaitingLLM = false
+			if !tm.finishLLMRequest(ed, ctx) {
+				return
+			}
 
 			if err != nil {
 				ed.IsLineSuggestion = false
@@ -1481,8 +1516,13 @@ Complete this line:`, fileInfo, contextBuilder.String(), textBeforeCursor, textA
 				return
 			}
 
+			tm.reportFallback(res)
+
 			// Очищаем ответ
-			suggestion := tm.cleanLineResponse(resp)
+			suggestion := tm.cleanLineResponse(res.Text)
+			if usedFIM {
+				suggestion = cleanFIMResponse(res.Text, true)
+			}
 
 			if suggestion == "" {
 				ed.IsLineSuggestion = false
@@ -1761,29 +1801,31 @@ STRICT RULES:
 Generate the implementation:`, language, fileInfo, contextBuilder.String(), comment, afterContext.String(), language)
 
 	// Показываем индикатор загрузки
-	ed.IsWaitingLLM = true
+	target := tm.Parent.llmTarget(logic.TaskCommentToCode)
+	decls := tm.goDeclRequest(ed, false, completionDeclBytes)
+	ctx := tm.beginLLMRequest(ed, target.Timeout)
 	ed.SuggestionStartPos = cursor.Position()
-	tm.Parent.Window.StatusBar().ShowMessage(fmt.Sprintf("🤖 Generating %s code for: %s", language, comment), 0)
+	tm.Parent.Window.StatusBar().ShowMessage(fmt.Sprintf("🤖 Generating %s code for: %s (Esc to cancel)", language, comment), 0)
 
 	// Запускаем запрос к LLM в отдельной горутине
 	go func() {
-		resp, err := logic.SendMessageToLLM(
-			prompt,
-			tm.Parent.LLMProvider,
-			tm.Parent.LLMModel,
-			tm.Parent.LLMKey,
-		)
+		prompt := addGoDecls(decls, prompt, nil)
+		res, err := tm.chatLLM(ctx, target, prompt)
 
 		tm.Parent.RunOnUIThread(func() {
-			ed.IsWaitingLLM = false
+			if !tm.finishLLMRequest(ed, ctx) {
+				return
+			}
 
 			if err != nil {
 				tm.Parent.Window.StatusBar().ShowMessage(fmt.Sprintf("AI Error: %v", err), 3000)
 				return
 			}
 
+			tm.reportFallback(res)
+
 			// Очищаем ответ
-			suggestion := tm.cleanLLMResponse(resp)
+			suggestion := tm.cleanLLMResponse(res.Text)
 
 			if suggestion == "" {
 				tm.Parent.Window.StatusBar().ShowMessage("AI returned empty code", 2000)
@@ -1796,6 +1838,156 @@ Generate the implementation:`, language, fileInfo, contextBuilder.String(), comm
 	}()
 }
 
+// beginLLMRequest помечает редактор как ожидающий ответа LLM и возвращает контекст запроса.
+// timeout = 0 означает таймаут по умолчанию из logic.
+func (tm *TabManager) beginLLMRequest(ed *CodeEditorTab, timeout time.Duration) context.Context {
+	var ctx context.Context
+	var cancel context.CancelFunc
+	if timeout > 0 {
+		ctx, cancel = context.WithTimeout(context.Background(), timeout)
+	} else {
+		ctx, cancel = context.WithCancel(context.Background())
+	}
+
+	ed.IsWaitingLLM = true
+	ed.llmCancel = cancel
+	return ctx
+}
+
+// finishLLMRequest снимает флаг ожидания после ответа LLM.
+// Возвращает false, если запрос был отменён пользователем — тогда ответ нужно отбросить.
+func (tm *TabManager) finishLLMRequest(ed *CodeEditorTab, ctx context.Context) bool {
+	if ctx.Err() == context.Canceled {
+		// Состояние редактора уже сброшено в CancelLLMRequest
+		return false
+	}
+
+	ed.IsWaitingLLM = false
+	if ed.llmCancel != nil {
+		ed.llmCancel()
+		ed.llmCancel = nil
+	}
+	return true
+}
+
+// CancelLLMRequest прерывает ожидаемый запрос inline-дополнения для редактора
+func (tm *TabManager) CancelLLMRequest(ed *CodeEditorTab) {
+	if ed == nil || !ed.IsWaitingLLM {
+		return
+	}
+
+	if ed.llmCancel != nil {
+		ed.llmCancel()
+		ed.llmCancel = nil
+	}
+	ed.IsWaitingLLM = false
+	ed.IsLineSuggestion = false
+
+	tm.Parent.Window.StatusBar().ShowMessage("AI request cancelled", 2000)
+}
+
+// chatLLM отправляет одиночный запрос провайдеру target (с цепочкой запасных)
+func (tm *TabManager) chatLLM(ctx context.Context, target logic.LLMTarget, prompt string) (*logic.ChatResult, error) {
+	return logic.Chat(ctx, logic.ChatRequest{
+		Provider: target.Provider,
+		Model:    target.Model,
+		APIKey:   target.APIKey,
+		History:  []logic.Message{{Role: "user", Content: prompt}},
+		Task:     target.Task,
+		Vars:     target.Vars,
+		Cache:    true,
+	})
+}
+
+// fimRequest готовит запрос fill-in-the-middle для позиции курсора,
+// если FIM включён и модель target его поддерживает (иначе nil)
+func (tm *TabManager) fimRequest(ed *CodeEditorTab, target logic.LLMTarget, singleLine bool) *logic.FIMRequest {
+	if !tm.FIMEnabled || !logic.FIMSupported(target.Provider, target.Model) {
+		return nil
+	}
+
+	// Код до курсора важнее: модели дополняют его продолжение
+	prefix, suffix := fimContext(ed, 150, 50)
+	req := &logic.FIMRequest{Prefix: prefix, Suffix: suffix, Task: target.Task, Cache: true}
+	if singleLine {
+		req.MaxTokens = 64
+		req.Stop = []string{"\n"}
+	}
+	return req
+}
+
+// fimContext возвращает текст документа до и после курсора
+// (не более maxBefore строк до текущей и maxAfter строк после неё)
+func fimContext(ed *CodeEditorTab, maxBefore, maxAfter int) (prefix, suffix string) {
+	cursor := ed.TextEdit.TextCursor()
+	doc := ed.TextEdit.Document()
+	block := cursor.Block()
+	lineNum := block.BlockNumber()
+
+	// Позиция в блоке считается в символах, а не байтах
+	line := []rune(block.Text())
+	pos := cursor.PositionInBlock()
+	if pos > len(line) {
+		pos = len(line)
+	}
+
+	var before, after strings.Builder
+	start := lineNum - maxBefore
+	if start < 0 {
+		start = 0
+	}
+	for i := start; i < lineNum; i++ {
+		before.WriteString(doc.FindBlockByNumber(i).Text())
+		before.WriteString("\n")
+	}
+	before.WriteString(string(line[:pos]))
+
+	after.WriteString(string(line[pos:]))
+	end := lineNum + maxAfter
+	if last := doc.BlockCount() - 1; end > last {
+		end = last
+	}
+	for i := lineNum + 1; i <= end; i++ {
+		after.WriteString("\n")
+		after.WriteString(doc.FindBlockByNumber(i).Text())
+	}
+	return before.String(), after.String()
+}
+
+// completeCode выполняет запрос дополнения: через FIM, если fim задан,
+// иначе (или если FIM-запрос не удался) — через чат с промптом chatPrompt.
+// usedFIM сообщает, каким способом получен ответ.
+func (tm *TabManager) completeCode(ctx context.Context, target logic.LLMTarget, fim *logic.FIMRequest, chatPrompt string) (res *logic.ChatResult, usedFIM bool, err error) {
+	if fim != nil {
+		res, err := logic.CompleteFIM(ctx, target.Provider, target.Model, target.APIKey, *fim)
+		if err == nil || ctx.Err() != nil {
+			return res, true, err
+		}
+		// Например, сервер не поддерживает /completions — обычный чат-запрос всё равно сработает
+	}
+	res, err = tm.chatLLM(ctx, target, chatPrompt)
+	return res, false, err
+}
+
+// cleanFIMResponse обрабатывает ответ FIM: это сырой код для вставки,
+// поэтому начальные пробелы сохраняются
+func cleanFIMResponse(response string, singleLine bool) string {
+	if singleLine {
+		if idx := strings.Index(response, "\n"); idx != -1 {
+			response = response[:idx]
+		}
+	}
+	return strings.TrimRight(response, " \t\r\n")
+}
+
+// reportFallback сообщает в статусной строке, если ответил запасной провайдер
+// или в запросе были замаскированы секреты
+func (tm *TabManager) reportFallback(res *logic.ChatResult) {
+	if res.UsedFallback() || len(res.Redactions) > 0 {
+		tm.Parent.reportChatResult(res)
+	}
+}
+
 // cleanLLMResponse очищает ответ LLM от markdown и лишних символов
 func (tm *TabManager) cleanLLMResponse(response string) string {
 	response = strings.TrimSpace(response)
@@ -2048,9 +2240,19 @@ func (tm *TabManager) PromptSaveAll() bool {
 
 // SetCursorStyle устанавливает стиль курсора для всех редакторов
 func (tm *TabManager) SetCursorStyle(styleName string) {
+	if !tm.applyCursorStyle(styleName) {
+		return
+	}
+
+	tm.Parent.Window.StatusBar().ShowMessage(
+		fmt.Sprintf("Cursor style changed to: %s", styleName), 2000)
+}
+
+// applyCursorStyle применяет стиль курсора без сообщений в статусной строке
+func (tm *TabManager) applyCursorStyle(styleName string) bool {
 	style, ok := CursorStyles[styleName]
 	if !ok {
-		return
+		return false
 	}
 
 	tm.CurrentCursorStyle = style
@@ -2061,9 +2263,7 @@ func (tm *TabManager) SetCursorStyle(styleName string) {
 			editor.TextEdit.SetCursorWidth(style.Width)
 		}
 	}
-
-	tm.Parent.Window.StatusBar().ShowMessage(
-		fmt.Sprintf("Cursor style changed to: %s", styleName), 2000)
+	return true
 }
 
 // GetCurrentCursorStyleName возвращает имя текущего стиля курсора
@@ -2303,18 +2503,22 @@ func (tm *TabManager) ClearCurrentBracketHighlight() {
 	}
 }
 
-// GetAllOpenTabsContext собирает содержимое всех открытых вкладок (кроме текущей)
-func (tm *TabManager) GetAllOpenTabsContext(currentEditor *CodeEditorTab) (string, []string) {
+// TabContext — содержимое одной из открытых вкладок для контекста AI
+type TabContext struct {
+	Name    string
+	Content string
+}
+
+// OpenTabsContext возвращает содержимое всех открытых вкладок (кроме текущей)
+// с учётом лимитов на вкладку и на все вкладки вместе
+func (tm *TabManager) OpenTabsContext(currentEditor *CodeEditorTab) []TabContext {
 	const (
 		maxPerTabChars    = 120000  // лимит на 1 вкладку
 		maxTotalTabsChars = 3000000 // общий лимит на все вкладки
 	)
 
-	var contextBuilder strings.Builder
-	var fileNames []string
-
+	var tabs []TabContext
 	totalAdded := 0
-	hasHeader := false
 
 	for _, ed := range tm.Editors {
 		// Пропускаем текущий редактор, так как он уже добавляется отдельно
@@ -2344,11 +2548,6 @@ func (tm *TabManager) GetAllOpenTabsContext(currentEditor *CodeEditorTab) (strin
 		// Проверяем общий лимит
 		remaining := maxTotalTabsChars - totalAdded
 		if remaining <= 0 {
-			if !hasHeader {
-				// даже если ничего не добавили, заголовок не нужен
-			} else {
-				contextBuilder.WriteString("\n... [other tabs context truncated: total limit reached]\n")
-			}
 			break
 		}
 
@@ -2357,20 +2556,12 @@ func (tm *TabManager) GetAllOpenTabsContext(currentEditor *CodeEditorTab) (strin
 			content = content[:remaining] + "\n... [truncated]"
 		}
 
-		if !hasHeader {
-			contextBuilder.WriteString("\n--- Context from other open tabs ---\n")
-			hasHeader = true
-		}
-
-		contextBuilder.WriteString(fmt.Sprintf("\nFile: %s\nContent:\n%s\n", fileName, content))
-		fileNames = append(fileNames, fileName)
-
+		tabs = append(tabs, TabContext{Name: fileName, Content: content})
 		totalAdded += len(content)
 	}
+	return tabs
+}
 
-	if hasHeader {
-		contextBuilder.WriteString("\n--- End of other open tabs context ---\n")
-	}
-
-	return contextBuilder.String(), fileNames
+func formatTabContext(tab TabContext) string {
+	return fmt.Sprintf("\nFile: %s\nContent:\n%s\n", tab.Name, tab.Content)
 }
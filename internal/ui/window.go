@@ -1,11 +1,11 @@
 package ui
 
 import (
+	"context"
 	"os"
 	"fmt"
     "path/filepath"
 	"strings"
-	"time"
 
 	"github.com/therecipe/qt/core"
 	"github.com/therecipe/qt/widgets"
@@ -33,33 +33,75 @@ type EditorWindow struct {
 	OutputDock  *widgets.QDockWidget
 	OutputText  *widgets.QPlainTextEdit
 	AIDock      *widgets.QDockWidget
-	AIChat      *widgets.QTextBrowser
 	AIInput     *widgets.QPlainTextEdit
+	inspector   *llmInspector
+	review      *reviewPanel
 
 	// Controls
 	BtnStop     *widgets.QPushButton
+	BtnAIStop   *widgets.QPushButton
+	btnAISend   *widgets.QPushButton
 
     // AI Panel Controls (NEW)
 	AIClipboardCheckbox *widgets.QCheckBox
 	AIContextLabel      *widgets.QLabel
 
-    // Хранение кодовых блоков из AI ответов
-    CurrentCodeBlocks   []CodeBlockData
+	// Картинки (data URL), приложенные к следующему сообщению чата
+	aiImages     []string
+	aiImageNames []string
+	aiImageBar   *aiImageBar
+
+	// Вкладки AI чата; каждая хранит свой диалог и блоки кода
+	aiChats       *widgets.QTabWidget
+	aiChatTabs    []*aiChatTab
+	aiCleared     *clearedAIChat // последний очищенный диалог (для отмены)
+
+	// Режим агента: модель вызывает инструменты, действия подтверждаются в чате
+	aiAgentCheckbox *widgets.QCheckBox
+	agentApprovals  map[int]*agentApproval
+	agentApprovalID int
+
+	// Семантический индекс кода проекта (загружается при первом обращении)
+	UseCodeIndex  bool
+	codeIndex     *logic.CodeIndex
+	indexCancel   context.CancelFunc // построение индекса (nil — не идёт)
+	lastIndexHits []logic.CodeHit    // фрагменты, найденные для последнего вопроса
 
     // Runtime Configuration
 	RunArgs string
+	lastRunDir string // каталог последнего запуска go (пути в ошибках считаются от него)
 
 	// Logic State
-	LLMProvider string
-	LLMModel    string
-	LLMKey      string
+	LLMProvider    string
+	LLMModel       string
+	LLMKey         string // явный ключ (--key или api_key в config.json), только для llmKeyProvider
+	llmKeyProvider string
+
+	// Настройки отдельных видов запросов (дополнение строки, генерация по комментарию, ...)
+	aiTasks map[logic.AITask]logic.TaskModel
 
     // AI Chat History for context
-    AIResponseHistory    []AIHistoryEntry
     AIHistoryContextSize int   
 	AIUseOpenTabsAsContext bool
 
     actUseTabsContext *widgets.QAction
+
+	// Persistent settings (global config file) and CLI flags that take precedence over it
+	Config          *logic.Config
+	CLIOverrides    logic.ConfigOverrides
+	settingsActions settingsMenuActions
+
+	// Отложенное обновление панели контекста AI (счётчик токенов)
+	aiContextTimer *core.QTimer
+	aiContextLines []string         // строки панели под счётчиком
+	aiMeterHTML    string           // последний посчитанный счётчик токенов
+	aiMeterSeq     int              // номер последнего запущенного подсчёта
+	aiContextFiles contextFileCache // файлы контекста проекта (по времени изменения)
+
+	// Выбор модели в панели AI
+	AIModelCombo     *widgets.QComboBox
+	modelsLoadedFor  string // провайдер, для которого загружен список моделей
+	modelListRequest int
 }
 
 // CodeBlockData хранит информацию о блоке кода в AI чате
@@ -69,20 +111,22 @@ type CodeBlockData struct {
 	Index    int
 }
 
-func NewEditorWindow(provider, model, key string) *EditorWindow {
+// NewEditorWindow создаёт главное окно с сохранёнными настройками cfg;
+// overrides (флаги командной строки) имеют приоритет над ними.
+func NewEditorWindow(cfg *logic.Config, overrides logic.ConfigOverrides) *EditorWindow {
+	if cfg == nil {
+		cfg = logic.DefaultConfig()
+	}
 	ew := &EditorWindow{
 		Window:         widgets.NewQMainWindow(nil, 0),
 		FileManager:    logic.NewFileManager(),
 		ProjectManager: logic.NewProjectManager(),
 		ProcessRunner:  logic.NewProcessRunner(),
-		LLMProvider:    provider,
-		LLMModel:       model,
-		LLMKey:         key,
-        AIResponseHistory:    make([]AIHistoryEntry, 0),
-		AIHistoryContextSize: 3,
-		AIUseOpenTabsAsContext: false,
+		Config:         cfg,
+		CLIOverrides:   overrides,
 	}
 	ew.TabManager = NewTabManager(ew)
+	ew.applyConfig(cfg)
 	return ew
 }
 
@@ -104,6 +148,17 @@ func (e *EditorWindow) SetupUI() {
 		if e.ProcessRunner != nil {
 			e.ProcessRunner.StopAll()
 		}
+		// Сохраняем кэш ответов (если он хранится на диске)
+		if err := logic.FlushResponseCache(); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		}
+		// Прерываем незавершённые запросы к LLM
+		for _, chat := range e.aiChatTabs {
+			chat.cancelRequest()
+		}
+		for _, ed := range e.TabManager.Editors {
+			e.TabManager.CancelLLMRequest(ed)
+		}
 		
 		event.Accept()
 	})
@@ -115,6 +170,8 @@ func (e *EditorWindow) SetupUI() {
 	e.setupProjectDock()
 	e.setupOutputDock()
 	e.setupAIDock()
+	e.setupInspectorDock()
+	e.setupReviewDock()
 
 	// 3. Menus
 	e.createMenus()
@@ -151,8 +208,13 @@ func (e *EditorWindow) setupOutputDock() {
 	e.BtnStop.SetStyleSheet("color: red; font-weight: bold;")
 	e.BtnStop.SetEnabled(false)
 
+	btnFix := widgets.NewQPushButton2("Fix with AI", nil)
+	btnFix.SetToolTip("Send the errors of the last run (file.go:line:col) with the surrounding code to the AI\nand review the suggested fix as a diff")
+	btnFix.ConnectClicked(func(bool) { e.fixBuildErrors() })
+
 	toolbar.AddWidget(btnClear, 0, 0)
 	toolbar.AddWidget(e.BtnStop, 0, 0)
+	toolbar.AddWidget(btnFix, 0, 0)
 	toolbar.AddStretch(1)
 
 	layout.AddLayout(toolbar, 0)
@@ -186,6 +248,9 @@ func (e *EditorWindow) setupAIDock() {
 	layout.SetContentsMargins(5, 5, 5, 5)
 	layout.SetSpacing(5)
 
+	// Выбор модели
+	layout.AddLayout(e.createModelPicker(), 0)
+
 	// Секция отображения контекста ===
 	contextGroup := widgets.NewQGroupBox2("Context Files", nil)
 	contextLayout := widgets.NewQVBoxLayout()
@@ -196,30 +261,14 @@ func (e *EditorWindow) setupAIDock() {
 	e.AIContextLabel.SetStyleSheet("color: #888; font-size: 11px;")
 	e.AIContextLabel.SetMaximumHeight(80)
 	contextLayout.AddWidget(e.AIContextLabel, 0, 0)
+	contextLayout.AddWidget(e.createImageBar(), 0, 0)
 	
 	contextGroup.SetLayout(contextLayout)
-	contextGroup.SetMaximumHeight(120)
+	contextGroup.SetMaximumHeight(120 + aiThumbnailSize + 10)
 	layout.AddWidget(contextGroup, 0, 0)
 
     // Chat History
-	e.AIChat = widgets.NewQTextBrowser(nil)
-	e.AIChat.SetOpenExternalLinks(false)
-	e.AIChat.SetReadOnly(true)
-	e.AIChat.SetTextInteractionFlags(
-		core.Qt__TextBrowserInteraction | core.Qt__LinksAccessibleByMouse,
-	) // разрешаем только клик по ссылкам
-	
-    // Важно: обрабатываем клик строго в UI потоке и запрещаем "навигацию" QTextBrowser.
-    e.AIChat.ConnectAnchorClicked(func(link *core.QUrl) {
-    	if link == nil {
-    		return
-    	}
-    	urlStr := link.ToString(core.QUrl__None)
-    	e.handleCodeBlockClick(urlStr)
-    	e.AIChat.SetSource(core.NewQUrl())
-    })
-    
-	layout.AddWidget(e.AIChat, 1, 0)
+	layout.AddWidget(e.createAIChatTabs(), 1, 0)
 	optionsLayout := widgets.NewQHBoxLayout()
 	optionsLayout.SetContentsMargins(0, 0, 0, 0)
 	
@@ -228,12 +277,24 @@ func (e *EditorWindow) setupAIDock() {
 	e.AIClipboardCheckbox.SetChecked(false)
 
 	optionsLayout.AddWidget(e.AIClipboardCheckbox, 0, 0)
+
+	e.aiAgentCheckbox = widgets.NewQCheckBox2("Agent", nil)
+	e.aiAgentCheckbox.SetToolTip("Agent mode: the model reads and searches project files, proposes edits\nand runs go build / go test. Every edit and command needs your approval.\nRequires a model with tool calling (AI > Models per Task... > Agent mode).")
+	e.aiAgentCheckbox.ConnectToggled(func(bool) { e.UpdateAIContextDisplay() })
+	optionsLayout.AddWidget(e.aiAgentCheckbox, 0, 0)
+
+	btnAttachImage := widgets.NewQPushButton2("📎", nil)
+	btnAttachImage.SetToolTip("Attach image (PNG/JPEG) for vision models.\nYou can also paste or drop images into the input field.")
+	btnAttachImage.SetMaximumWidth(30)
+	btnAttachImage.ConnectClicked(func(bool) { e.promptAttachImages() })
+	optionsLayout.AddWidget(btnAttachImage, 0, 0)
+	optionsLayout.AddWidget(e.createPromptPicker(), 0, 0)
 	optionsLayout.AddStretch(1) // Добавляем разделитель перед кнопками
 
 	// Кнопка очистки контекста
 	btnClearContext := widgets.NewQPushButton2("Clear Context", nil)
 	// Обновляем подсказку, чтобы она включала историю чата
-	btnClearContext.SetToolTip("Clear all optional context:\n- Project files\n- Other open tabs\n- Clipboard\n- Chat history")
+	btnClearContext.SetToolTip("Clear all optional context:\n- Project files\n- Other open tabs\n- Clipboard\n- Attached images\n- Chat history")
 	btnClearContext.ConnectClicked(func(bool) {
 		// 1. Очищаем файлы проекта из контекста
 		e.ProjectManager.ClearContextFiles()
@@ -243,6 +304,8 @@ func (e *EditorWindow) setupAIDock() {
 		if e.actUseTabsContext != nil {
 			e.actUseTabsContext.SetChecked(false)
 		}
+		e.Config.AIUseOpenTabsAsContext = false
+		e.saveConfig()
 
 		// 3. Снимаем галочку с буфера обмена
 		if e.AIClipboardCheckbox != nil {
@@ -251,6 +314,7 @@ func (e *EditorWindow) setupAIDock() {
 		
 		// 4. (ИСПРАВЛЕНИЕ) Очищаем историю диалога
 		e.ClearAIHistory()
+		e.takeAIImages()
 
 		// 5. Обновляем UI и показываем сообщение
 		e.UpdateAIContextDisplay()
@@ -272,13 +336,28 @@ func (e *EditorWindow) setupAIDock() {
 
 	// Input Area
 	e.AIInput = widgets.NewQPlainTextEdit(nil)
-	e.AIInput.SetPlaceholderText("Ask AI about your code ...")
+	e.AIInput.SetPlaceholderText("Ask AI about your code ... (/explain, /test, /review, ...)")
 	e.AIInput.SetMaximumHeight(100)
+	e.AIInput.ConnectTextChanged(e.scheduleAIContextUpdate)
+	e.setupAIInputImages()
 	layout.AddWidget(e.AIInput, 0, 0)
 
-	// Send Button
+	// Send / Stop Buttons
+	sendLayout := widgets.NewQHBoxLayout()
+	sendLayout.SetContentsMargins(0, 0, 0, 0)
+
 	btnSend := widgets.NewQPushButton2("Send", nil)
-	layout.AddWidget(btnSend, 0, 0)
+	e.btnAISend = btnSend
+	sendLayout.AddWidget(btnSend, 1, 0)
+
+	e.BtnAIStop = widgets.NewQPushButton2("Stop", nil)
+	e.BtnAIStop.SetToolTip("Cancel the running AI request")
+	e.BtnAIStop.SetStyleSheet("color: red; font-weight: bold;")
+	e.BtnAIStop.SetEnabled(false)
+	e.BtnAIStop.ConnectClicked(func(bool) { e.CancelAIRequest() })
+	sendLayout.AddWidget(e.BtnAIStop, 0, 0)
+
+	layout.AddLayout(sendLayout, 0)
 
 	wrapper.SetLayout(layout)
 	e.AIDock.SetWidget(wrapper)
@@ -289,9 +368,14 @@ func (e *EditorWindow) setupAIDock() {
 	// Connect Send
 	sendFunc := func() {
 		text := e.AIInput.ToPlainText()
-		if text == "" { return }
+		if text == "" && len(e.aiImages) == 0 { return }
+		// Пока выполняется запрос, текст остаётся в поле ввода
+		if !e.btnAISend.IsEnabled() { return }
+		// Слэш-команда раскрывается в шаблон из библиотеки промптов
+		prompt, ok := e.expandSlashCommand(text)
+		if !ok { return }
 		e.AIInput.Clear()
-		e.HandleAskLLM(text)
+		e.askLLM(text, prompt)
 	}
 	btnSend.ConnectClicked(func(bool) { sendFunc() })
 	
@@ -299,6 +383,7 @@ func (e *EditorWindow) setupAIDock() {
 	e.AIDock.ConnectVisibilityChanged(func(visible bool) {
 		if visible {
 			e.UpdateAIContextDisplay()
+			e.ensureModelList()
 		}
 	})
 }
@@ -313,6 +398,7 @@ func (e *EditorWindow) OpenPath(path string) {
 	if stat.IsDir() {
 		// Логика открытия проекта
 		e.ProjectManager.SetRootPath(path)
+		e.loadProjectConfig(path)
 		e.ProjectTree.Refresh()
 		e.ProjectTree.DockWidget.Show()
 		
@@ -350,12 +436,17 @@ func (e *EditorWindow) setupGlobalShortcuts() {
 				e.TabManager.ClearBracketHighlight(ed)
 				return
 			}
-			// Приоритет 2: Отклоняем предложение AI
+			// Приоритет 2: Отменяем ожидающий запрос inline-дополнения
+			if ed.IsWaitingLLM {
+				e.TabManager.CancelLLMRequest(ed)
+				return
+			}
+			// Приоритет 3: Отклоняем предложение AI
 			if ed.HasSuggestion {
 				e.TabManager.RejectSuggestion(ed)
 				return
 			}
-			// Приоритет 3: Закрываем панель поиска
+			// Приоритет 4: Закрываем панель поиска
 			if ed.SearchWidget != nil && ed.SearchWidget.IsVisible() {
 				ed.SearchWidget.Hide()
 			}
@@ -417,6 +508,10 @@ func (e *EditorWindow) UpdateAIContextDisplay() {
 
 	var contextParts []string
 
+	// 0. Размер запроса относительно окна контекста модели считается вне UI-потока
+	req := e.newAIPromptRequest(e.AIInput.ToPlainText(), len(e.aiImages), goDecls{}, nil)
+	e.updateTokenMeter(req)
+
 	// 1. Текущий открытый файл
 	if ed := e.TabManager.CurrentEditor(); ed != nil && ed.FilePath != "" {
 		contextParts = append(contextParts, fmt.Sprintf("📄 <b>Current:</b> %s", filepath.Base(ed.FilePath)))
@@ -440,8 +535,7 @@ func (e *EditorWindow) UpdateAIContextDisplay() {
 
 	// 2.5 Контекст из других открытых вкладок
 	if e.AIUseOpenTabsAsContext {
-		ed := e.TabManager.CurrentEditor()
-		_, tabNames := e.TabManager.GetAllOpenTabsContext(ed)
+		tabNames := req.sources.labels(ctxTab)
 		if len(tabNames) > 0 {
 			contextParts = append(contextParts, fmt.Sprintf("🧩 <b>Open tabs context:</b> %d tab(s)", len(tabNames)))
 			// Показать первые 3 вкладки
@@ -460,6 +554,11 @@ func (e *EditorWindow) UpdateAIContextDisplay() {
 	}
 
 
+	// 2.7 Семантический индекс кода и найденные по последнему вопросу фрагменты
+	if e.UseCodeIndex {
+		contextParts = append(contextParts, e.codeIndexStatusHTML()...)
+	}
+
 	// 3. Статус буфера обмена
 	if e.AIClipboardCheckbox != nil && e.AIClipboardCheckbox.IsChecked() {
 		clipboard := gui.QGuiApplication_Clipboard()
@@ -483,7 +582,7 @@ func (e *EditorWindow) UpdateAIContextDisplay() {
 
 	// 4. История диалога с AI
 	if e.AIHistoryContextSize > 0 {
-		historyCount := len(e.AIResponseHistory)
+		historyCount := len(e.aiHistory())
 		usedCount := e.AIHistoryContextSize
 		if usedCount > historyCount {
 			usedCount = historyCount
@@ -495,6 +594,17 @@ func (e *EditorWindow) UpdateAIContextDisplay() {
 		contextParts = append(contextParts, "💬 <b>Chat history:</b> disabled")
 	}
 
+	e.aiContextLines = contextParts
+	e.showAIContextLabel()
+}
+
+// showAIContextLabel выводит на панель счётчик токенов и строки контекста
+func (e *EditorWindow) showAIContextLabel() {
+	contextParts := e.aiContextLines
+	if e.aiMeterHTML != "" {
+		contextParts = append([]string{e.aiMeterHTML}, contextParts...)
+	}
+
 	// Формируем итоговый текст
 	if len(contextParts) == 0 {
 		e.AIContextLabel.SetText("No context files selected")
@@ -505,56 +615,83 @@ func (e *EditorWindow) UpdateAIContextDisplay() {
 	}
 }
 
-// AddToAIHistory добавляет запись в историю диалога с AI
-func (e *EditorWindow) AddToAIHistory(userPrompt, aiResponse string) {
-	entry := AIHistoryEntry{
-		UserPrompt: userPrompt,
-		AIResponse: aiResponse,
-		Timestamp:  time.Now().Format("15:04:05"),
+// aiHistoryForContext возвращает последние N записей истории, используемые как контекст
+func (e *EditorWindow) aiHistoryForContext() []AIHistoryEntry {
+	history := e.aiHistory()
+	if e.AIHistoryContextSize <= 0 || len(history) == 0 {
+		return nil
 	}
 	
-	e.AIResponseHistory = append(e.AIResponseHistory, entry)
-	
-	// Ограничиваем размер истории (храним максимум 50 записей)
-	const maxHistorySize = 50
-	if len(e.AIResponseHistory) > maxHistorySize {
-		e.AIResponseHistory = e.AIResponseHistory[len(e.AIResponseHistory)-maxHistorySize:]
+	// Определяем, сколько записей взять
+	count := e.AIHistoryContextSize
+	if count > len(history) {
+		count = len(history)
 	}
+	
+	// Берём последние N записей
+	return history[len(history)-count:]
 }
 
 // GetAIHistoryContext возвращает последние N ответов для использования как контекст
 func (e *EditorWindow) GetAIHistoryContext() string {
-	if e.AIHistoryContextSize <= 0 || len(e.AIResponseHistory) == 0 {
+	relevantHistory := e.aiHistoryForContext()
+	if len(relevantHistory) == 0 {
 		return ""
 	}
 	
-	// Определяем, сколько записей взять
-	count := e.AIHistoryContextSize
-	if count > len(e.AIResponseHistory) {
-		count = len(e.AIResponseHistory)
-	}
-	
-	// Берём последние N записей
-	startIdx := len(e.AIResponseHistory) - count
-	relevantHistory := e.AIResponseHistory[startIdx:]
-	
 	var sb strings.Builder
-	sb.WriteString("\n--- Previous conversation context ---\n")
+	sb.WriteString(aiHistoryHeader)
 	
 	for i, entry := range relevantHistory {
-		sb.WriteString(fmt.Sprintf("\n[%d] User asked:\n%s\n", i+1, truncateForContext(entry.UserPrompt, 500)))
-		sb.WriteString(fmt.Sprintf("\n[%d] AI responded:\n%s\n", i+1, truncateForContext(entry.AIResponse, 1500)))
+		sb.WriteString(formatAIHistoryEntry(i+1, entry))
 	}
 	
-	sb.WriteString("--- End of previous conversation ---\n")
+	sb.WriteString(aiHistoryFooter)
 	
 	return sb.String()
 }
 
-// ClearAIHistory очищает историю диалога
-func (e *EditorWindow) ClearAIHistory() {
-	e.AIResponseHistory = make([]AIHistoryEntry, 0)
-	e.Window.StatusBar().ShowMessage("AI conversation history cleared", 2000)
+const (
+	aiHistoryHeader = "\n--- Previous conversation context ---\n"
+	aiHistoryFooter = "--- End of previous conversation ---\n"
+)
+
+// formatAIHistoryEntry форматирует одну запись истории (n — номер записи в контексте)
+func formatAIHistoryEntry(n int, entry AIHistoryEntry) string {
+	return fmt.Sprintf("\n[%d] User asked:\n%s\n", n, truncateForContext(entry.UserPrompt, 500)) +
+		fmt.Sprintf("\n[%d] AI responded:\n%s\n", n, truncateForContext(entry.AIResponse, 1500))
+}
+
+// CancelAIRequest прерывает запрос активной вкладки AI чата (кнопка Stop). Запрос
+// считается выполняющимся, пока его горутина не уберёт свой вывод из чата, поэтому
+// новый можно отправить только после этого.
+func (e *EditorWindow) CancelAIRequest() {
+	chat := e.currentAIChat()
+	if chat == nil || chat.cancel == nil {
+		return
+	}
+	chat.cancelRequest()
+	if e.BtnAIStop != nil {
+		e.BtnAIStop.SetEnabled(false)
+	}
+	e.Window.StatusBar().ShowMessage("AI request cancelled", 2000)
+}
+
+// setAIRequest запоминает отмену выполняющегося запроса вкладки (nil — запроса нет)
+func (e *EditorWindow) setAIRequest(chat *aiChatTab, cancel context.CancelFunc) {
+	chat.cancel = cancel
+	chat.pending = false
+	e.updateAIButtons()
+}
+
+// updateAIButtons включает Send и Stop по состоянию активной вкладки чата
+func (e *EditorWindow) updateAIButtons() {
+	if e.btnAISend == nil || e.BtnAIStop == nil {
+		return
+	}
+	chat := e.currentAIChat()
+	e.btnAISend.SetEnabled(chat != nil && !chat.busy())
+	e.BtnAIStop.SetEnabled(chat != nil && chat.cancel != nil)
 }
 
 // truncateForContext обрезает текст до указанной длины для использования в контексте